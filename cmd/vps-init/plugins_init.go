@@ -13,7 +13,9 @@ import (
 	"github.com/wasilwamark/vps-init/internal/services/redis"
 	"github.com/wasilwamark/vps-init/internal/services/restic"
 	"github.com/wasilwamark/vps-init/internal/services/runtimes"
+	"github.com/wasilwamark/vps-init/internal/services/sshhardening"
 	"github.com/wasilwamark/vps-init/internal/services/system"
+	"github.com/wasilwamark/vps-init/internal/services/users"
 	"github.com/wasilwamark/vps-init/internal/services/wireguard"
 	"github.com/wasilwamark/vps-init/internal/services/wordpress"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
@@ -38,4 +40,6 @@ func initializeBuiltinPlugins() {
 	plugin.RegisterBuiltin("github.com/wasilwamark/vps-init/services/restic", &restic.Plugin{})
 	plugin.RegisterBuiltin("github.com/wasilwamark/vps-init/services/runtimes", &runtimes.Plugin{})
 	plugin.RegisterBuiltin("github.com/wasilwamark/vps-init/services/wordpress", &wordpress.Plugin{})
+	plugin.RegisterBuiltin("github.com/wasilwamark/vps-init/services/users", &users.Plugin{})
+	plugin.RegisterBuiltin("github.com/wasilwamark/vps-init/services/ssh-hardening", &sshhardening.Plugin{})
 }
@@ -1,9 +1,15 @@
 package cli
 
 import (
+	"bufio"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/wasilwamark/vps-init/internal/config"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/version"
 )
 
 func InitPluginSystem() error {
@@ -20,12 +26,71 @@ func InitPluginSystem() error {
 		// fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 	}
 
+	// Load external plugins from ~/.vps-init/plugins. A broken plugin must not
+	// make the rest of the CLI unusable, so failures are only reported.
+	external := plugin.NewExternalLoader(filepath.Join(config.Dir(), "plugins"), version.Version)
+	trust, err := plugin.LoadTrustStore(filepath.Join(config.Dir(), "trust.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+		trust = &plugin.TrustStore{Policy: plugin.PolicyRefuse}
+	}
+	external.SetTrustStore(trust)
+	external.Confirm = confirmUntrusted
+	external.ApprovePermissions = approvePermissions
+	if err := plugin.SetWasmCacheDir(filepath.Join(config.Dir(), "cache", "wasm")); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+	}
+	plugins, err := external.LoadPlugins()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+	}
+	for _, p := range plugins {
+		if err := plugin.RegisterExternal(p); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping external plugin %s: %v\n", p.GetMetadata().InstallPath, err)
+		}
+	}
+
+	// Hooks from ~/.vps-init/config.json run around every plugin command
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if len(settings.Hooks) > 0 {
+		plugin.Use(&hooksMiddleware{hooks: settings.Hooks})
+	}
+
+	// Refuse plugins that are incompatible with this version or whose
+	// dependencies cannot be satisfied
+	if err := plugin.ResolvePlugins(version.Version); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+	}
+	plugin.SubscribePlugins()
+
 	return nil
 }
 
+// confirmUntrusted asks before running a plugin that is not signed by a trusted key
+func confirmUntrusted(name string) bool {
+	fmt.Fprintf(os.Stderr, "⚠️  Plugin '%s' is not signed by a trusted key. Run it anyway? [y/N]: ", name)
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// approvePermissions asks before giving a WASM plugin local access that was
+// not approved when it was installed
+func approvePermissions(name string, perms plugin.Permissions) bool {
+	fmt.Fprintf(os.Stderr, "⚠️  Plugin '%s' requests local access: %s. Allow it for this run? [y/N]: ", name, perms)
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func Execute() error {
 	// Load commands from plugins
-	registry := plugin.GetBuiltinRegistry()
+	registry := plugin.GetRegistry()
 	for _, cmd := range registry.GetRootCommands() {
 		rootCmd.AddCommand(cmd)
 	}
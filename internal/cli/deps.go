@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// ensureDependencies checks that the services a plugin depends on are present
+// on the target. Missing services are installed first when install is set;
+// otherwise the error lists the commands that install them.
+func ensureDependencies(ctx context.Context, conn plugin.Connection, flags map[string]interface{}, target string, pl plugin.Plugin, install bool) error {
+	missing := plugin.MissingServices(conn, pl)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if !install {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s needs services that are not installed on %s. Install them with:\n", pl.Name(), target)
+		for _, dep := range missing {
+			fmt.Fprintf(&b, "   vps-init %s %s %s\n", target, dep.Name(), dep.(plugin.ServiceProvider).InstallCommand())
+		}
+		b.WriteString("or re-run the command with --install-deps")
+		return fmt.Errorf("%s", b.String())
+	}
+
+	for _, dep := range missing {
+		cmdName := dep.(plugin.ServiceProvider).InstallCommand()
+		var handler plugin.CommandHandler
+		for _, cmd := range dep.GetCommands() {
+			if cmd.Name == cmdName {
+				handler = cmd.Handler
+				break
+			}
+		}
+		if handler == nil {
+			return fmt.Errorf("plugin %s has no '%s' command", dep.Name(), cmdName)
+		}
+
+		fmt.Printf("📦 %s needs %s, installing it first...\n", pl.Name(), dep.Name())
+		inv := plugin.Invocation{Plugin: dep, Command: cmdName, Target: target}
+		handler = plugin.Chain(inv, plugin.WithLifecycle(dep, pluginConfig(target), handler))
+		if err := runWithHistory(ctx, conn, flags, dep.Name(), cmdName, nil, handler); err != nil {
+			return fmt.Errorf("failed to install dependency %s: %w", dep.Name(), err)
+		}
+		recordCommand(ctx, conn, flags, dep.Name(), cmdName, nil)
+	}
+	return nil
+}
+
+// removeArg removes every occurrence of flag from args, reporting whether it was present
+func removeArg(args []string, flag string) ([]string, bool) {
+	kept := make([]string, 0, len(args))
+	found := false
+	for _, arg := range args {
+		if arg == flag {
+			found = true
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	return kept, found
+}
@@ -0,0 +1,270 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// execOptions are the flags accepted by "vps-init <targets> exec"
+type execOptions struct {
+	command  string
+	sudo     bool
+	timeout  time.Duration
+	group    bool
+	json     bool
+	parallel int
+}
+
+// parseExecArgs splits "[flags] -- <command>" into options and the remote command
+func parseExecArgs(args []string) (execOptions, error) {
+	opts := execOptions{timeout: 60 * time.Second, parallel: 10}
+
+	var command []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			command = append(command, args[i+1:]...)
+			break
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		next := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("flag %s requires a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "--sudo":
+			opts.sudo = true
+		case "--group":
+			opts.group = true
+		case "--json":
+			opts.json = true
+		case "--timeout":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if opts.timeout, err = time.ParseDuration(v); err != nil {
+				return opts, fmt.Errorf("invalid --timeout '%s': %v", v, err)
+			}
+		case "--parallel":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if opts.parallel, err = strconv.Atoi(v); err != nil || opts.parallel < 1 {
+				return opts, fmt.Errorf("invalid --parallel '%s'", v)
+			}
+		default:
+			if strings.HasPrefix(arg, "--") {
+				return opts, fmt.Errorf("unknown flag %s (put the remote command after --)", arg)
+			}
+			command = append(command, arg)
+		}
+	}
+
+	// A single argument is a shell script, as in exec -- 'df -h | tail -1';
+	// several are a command whose arguments keep their quoting
+	if len(command) == 1 {
+		opts.command = command[0]
+	} else {
+		quoted := make([]string, len(command))
+		for i, arg := range command {
+			quoted[i] = shellQuote(arg)
+		}
+		opts.command = strings.Join(quoted, " ")
+	}
+	if opts.command == "" {
+		return opts, fmt.Errorf("usage: vps-init <target>[,<target>...] exec [--sudo] [--timeout 60s] [--group] [--json] -- <command>")
+	}
+	return opts, nil
+}
+
+// executeExec runs one command on every comma-separated target concurrently
+func executeExec(rawTargets string, args []string) {
+	opts, err := parseExecArgs(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	var targets []string
+	for _, t := range strings.Split(rawTargets, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			targets = append(targets, t)
+		}
+	}
+
+	results := make([]plugin.Result, len(targets))
+	sem := make(chan struct{}, opts.parallel)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = execOnTarget(target, opts)
+		}(i, target)
+	}
+	wg.Wait()
+
+	switch {
+	case opts.json:
+		printExecJSON(targets, results)
+	case opts.group:
+		printExecGrouped(targets, results)
+	default:
+		printExecPerHost(targets, results)
+	}
+
+	for _, r := range results {
+		if !r.Success {
+			os.Exit(1)
+		}
+	}
+}
+
+// contextRunner is implemented by connections that can kill a running
+// command when its context is done
+type contextRunner interface {
+	RunCommandContext(ctx context.Context, cmd string, sudo bool) plugin.Result
+	RunSudoContext(ctx context.Context, cmd, password string) plugin.Result
+}
+
+// execOnTarget connects to a target and runs the command, killing it after
+// the timeout. The remote side runs it under timeout(1) too, as sshd does
+// not stop a command when the client goes away.
+func execOnTarget(target string, opts execOptions) plugin.Result {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	timedOut := func() plugin.Result {
+		msg := fmt.Sprintf("timed out after %s", opts.timeout)
+		return plugin.Result{
+			Success:   false,
+			Error:     msg,
+			Stderr:    msg,
+			ExitCode:  -1,
+			Duration:  time.Since(start).String(),
+			Timestamp: start.Format(time.RFC3339),
+		}
+	}
+
+	conn, flags, err := connectTarget(target)
+	if err != nil {
+		return plugin.Result{Success: false, Error: err.Error(), Stderr: err.Error(), ExitCode: -1}
+	}
+	defer conn.Close()
+	if ctx.Err() != nil {
+		return timedOut()
+	}
+
+	var result plugin.Result
+	runWithHistory(ctx, conn, flags, "", "exec", []string{opts.command},
+		func(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+			deadline, _ := ctx.Deadline()
+			seconds := int(time.Until(deadline).Seconds()) + 1
+			cmd := fmt.Sprintf("timeout %d sh -c %s", seconds, shellQuote(opts.command))
+			sudoPass, _ := flags["sudo-password"].(string)
+
+			runner, ok := conn.(contextRunner)
+			switch {
+			case ok && opts.sudo:
+				result = runner.RunSudoContext(ctx, cmd, sudoPass)
+			case ok:
+				result = runner.RunCommandContext(ctx, cmd, false)
+			case opts.sudo:
+				result = conn.RunSudo(cmd, sudoPass)
+			default:
+				result = conn.RunCommand(cmd, false)
+			}
+			if ctx.Err() != nil {
+				result = timedOut()
+			}
+			return result.GetError()
+		})
+	return result
+}
+
+func printExecPerHost(targets []string, results []plugin.Result) {
+	for i, target := range targets {
+		printExecResult(target, results[i])
+	}
+}
+
+// printExecGrouped collapses hosts that returned identical output and exit code
+func printExecGrouped(targets []string, results []plugin.Result) {
+	type group struct {
+		hosts  []string
+		result plugin.Result
+	}
+
+	var groups []*group
+	byKey := make(map[string]*group)
+	for i, target := range targets {
+		r := results[i]
+		key := fmt.Sprintf("%d\x00%s\x00%s", r.ExitCode, r.Stdout, r.Stderr)
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{result: r}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.hosts = append(g.hosts, target)
+	}
+
+	// Largest groups first, so outliers end up at the bottom
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].hosts) > len(groups[j].hosts)
+	})
+	for _, g := range groups {
+		label := strings.Join(g.hosts, ", ")
+		if len(g.hosts) > 1 {
+			label = fmt.Sprintf("%s (%d hosts)", label, len(g.hosts))
+		}
+		printExecResult(label, g.result)
+	}
+}
+
+func printExecResult(label string, r plugin.Result) {
+	status := "✅"
+	if !r.Success {
+		status = "❌"
+	}
+	fmt.Printf("%s %s [exit %d]\n", status, label, r.ExitCode)
+	if out := strings.TrimRight(r.Stdout, "\n"); out != "" {
+		fmt.Println(out)
+	}
+	if errOut := strings.TrimRight(r.Stderr, "\n"); errOut != "" {
+		fmt.Println(errOut)
+	}
+	fmt.Println()
+}
+
+// printExecJSON emits one plugin.Result per host, keyed by target
+func printExecJSON(targets []string, results []plugin.Result) {
+	byTarget := make(map[string]plugin.Result, len(targets))
+	for i, target := range targets {
+		byTarget[target] = results[i]
+	}
+	data, _ := json.MarshalIndent(byTarget, "", "  ")
+	fmt.Println(string(data))
+}
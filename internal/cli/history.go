@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wasilwamark/vps-init/internal/history"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the local history of commands run against servers",
+	Example: `  vps-init history
+  vps-init history --host 1.2.3.4 --since 2d
+  vps-init history show 20250101-120000-a1b2c3`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host, _ := cmd.Flags().GetString("host")
+		sinceFlag, _ := cmd.Flags().GetString("since")
+
+		since, err := history.ParseSince(sinceFlag)
+		if err != nil {
+			return err
+		}
+
+		entries, err := history.List(history.Filter{Host: host, Since: since})
+		if err != nil {
+			return fmt.Errorf("failed to read history: %w", err)
+		}
+		if len(entries) == 0 {
+			fmt.Println("No history found.")
+			return nil
+		}
+
+		fmt.Printf("%-23s  %-19s  %-24s  %-30s  %s\n", "ID", "STARTED", "TARGET", "COMMAND", "STATUS")
+		for _, e := range entries {
+			status := "✅"
+			if e.Status != "success" {
+				status = "❌"
+			}
+			fmt.Printf("%-23s  %-19s  %-24s  %-30s  %s %s\n",
+				e.ID,
+				e.StartedAt.Local().Format("2006-01-02 15:04:05"),
+				e.Target,
+				commandLine(e),
+				status,
+				e.Duration().Round(time.Millisecond),
+			)
+		}
+		return nil
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show every remote command and file write of a history entry",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		e, err := history.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("ID:       %s\n", e.ID)
+		fmt.Printf("Target:   %s\n", e.Target)
+		fmt.Printf("Command:  %s\n", strings.TrimSpace(commandLine(e)+" "+strings.Join(e.Args, " ")))
+		fmt.Printf("Operator: %s\n", e.Operator)
+		fmt.Printf("Started:  %s\n", e.StartedAt.Local().Format(time.RFC3339))
+		fmt.Printf("Ended:    %s (%s)\n", e.EndedAt.Local().Format(time.RFC3339), e.Duration().Round(time.Millisecond))
+		fmt.Printf("Status:   %s\n", e.Status)
+		if e.Error != "" {
+			fmt.Printf("Error:    %s\n", e.Error)
+		}
+
+		fmt.Printf("\nOperations (%d):\n", len(e.Operations))
+		for i, op := range e.Operations {
+			status := "✅"
+			if !op.Result.Success {
+				status = "❌"
+			}
+
+			switch {
+			case op.Path != "" && op.Bytes > 0:
+				fmt.Printf("%3d. %s [%s] %s (%d bytes, sha256 %s)\n", i+1, status, op.Kind, op.Path, op.Bytes, op.SHA256[:12])
+			case op.Path != "":
+				fmt.Printf("%3d. %s [%s] %s %s\n", i+1, status, op.Kind, op.Path, op.Command)
+			default:
+				fmt.Printf("%3d. %s [%s] %s\n", i+1, status, op.Kind, op.Command)
+			}
+
+			if !op.Result.Success {
+				if msg := strings.TrimSpace(op.Result.Stderr + " " + op.Result.Error); msg != "" {
+					fmt.Printf("       exit %d: %s\n", op.Result.ExitCode, msg)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	historyCmd.Flags().String("host", "", "Only show entries for this host or user@host")
+	historyCmd.Flags().String("since", "", "Only show entries newer than this (e.g. 30m, 12h, 2d, 1w)")
+	historyCmd.AddCommand(historyShowCmd)
+	rootCmd.AddCommand(historyCmd)
+
+	registerTargetCommand("changes", targetCommand{
+		Description: "Show the change log vps-init keeps on the server",
+		Handler:     changesHandler,
+	})
+}
+
+// runWithHistory runs a handler with a recording connection and appends the
+// invocation to the local history, whether or not it succeeded
+func runWithHistory(ctx context.Context, conn plugin.Connection, flags map[string]interface{}, pluginName, cmdName string, args []string, handler plugin.CommandHandler) error {
+	entry := history.NewEntry(targetKey(conn), pluginName, cmdName, args)
+	sudoPass, _ := flags["sudo-password"].(string)
+
+	err := handler(ctx, history.Wrap(conn, entry, sudoPass), args, flags)
+
+	entry.Finish(err)
+	if herr := history.Append(entry); herr != nil {
+		fmt.Printf("⚠️  Failed to write history: %v\n", herr)
+	}
+
+	// Leave an audit trail on the server itself, even for partially failed changes
+	if entry.Mutating() {
+		if herr := history.AppendRemote(conn, sudoPass, entry); herr != nil {
+			fmt.Printf("⚠️  Failed to write remote change log: %v\n", herr)
+		}
+	}
+	return err
+}
+
+// changesHandler pretty-prints the change log kept on the server
+func changesHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	sudoPass, _ := flags["sudo-password"].(string)
+	changes, err := history.ReadRemote(conn, sudoPass)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Printf("No changes recorded in %s yet.\n", history.ChangeLogPath)
+		return nil
+	}
+
+	for _, c := range changes {
+		status := "✅"
+		if c.Status != "success" {
+			status = "❌"
+		}
+		command := strings.TrimSpace(strings.Join(append([]string{c.Plugin, c.Command}, c.Args...), " "))
+		fmt.Printf("%s %s  %s\n", status, c.Time.Local().Format("2006-01-02 15:04:05"), command)
+		fmt.Printf("   by %s (vps-init %s, id %s)\n", c.Operator, c.Version, c.ID)
+		if c.Error != "" {
+			fmt.Printf("   error: %s\n", c.Error)
+		}
+		for _, f := range c.Files {
+			fmt.Printf("   📄 %s\n", f)
+		}
+		for _, s := range c.Services {
+			fmt.Printf("   ⚙️  %s\n", s)
+		}
+	}
+	return nil
+}
+
+// commandLine renders "plugin command" or just the target command name
+func commandLine(e *history.Entry) string {
+	return strings.TrimSpace(e.Plugin + " " + e.Command)
+}
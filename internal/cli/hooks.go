@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/config"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// hooksMiddleware runs the hooks from ~/.vps-init/config.json around plugin
+// commands. A failing "before" hook stops the command; a failing "after"
+// hook is only reported.
+type hooksMiddleware struct {
+	hooks []config.Hook
+}
+
+func (m *hooksMiddleware) Wrap(inv plugin.Invocation, next plugin.CommandHandler) plugin.CommandHandler {
+	return func(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+		resolved := conn.User() + "@" + conn.Host()
+		var before, after []config.Hook
+		for _, h := range m.hooks {
+			if !h.Matches(inv.Plugin.Name(), inv.Command, inv.Target, resolved) {
+				continue
+			}
+			if h.When == "before" {
+				before = append(before, h)
+			} else {
+				after = append(after, h)
+			}
+		}
+
+		env := map[string]string{
+			"VPS_INIT_PLUGIN":  inv.Plugin.Name(),
+			"VPS_INIT_COMMAND": inv.Command,
+			"VPS_INIT_ARGS":    strings.Join(inv.Args, " "),
+			"VPS_INIT_TARGET":  inv.Target,
+			"VPS_INIT_HOST":    conn.Host(),
+		}
+
+		for _, h := range before {
+			fmt.Printf("🪝 Running before hook: %s\n", h.Label())
+			if err := runHook(conn, flags, h, env); err != nil {
+				return fmt.Errorf("before hook '%s' stopped the command: %w", h.Label(), err)
+			}
+		}
+
+		err := next(ctx, conn, args, flags)
+
+		env["VPS_INIT_STATUS"] = "success"
+		if err != nil {
+			env["VPS_INIT_STATUS"] = "failed"
+			env["VPS_INIT_ERROR"] = err.Error()
+		}
+		for _, h := range after {
+			fmt.Printf("🪝 Running after hook: %s\n", h.Label())
+			if hookErr := runHook(conn, flags, h, env); hookErr != nil {
+				fmt.Printf("⚠️  After hook '%s' failed: %v\n", h.Label(), hookErr)
+			}
+		}
+		return err
+	}
+}
+
+// runHook runs a hook locally or on the target, with details of the command
+// in VPS_INIT_* environment variables
+func runHook(conn plugin.Connection, flags map[string]interface{}, h config.Hook, env map[string]string) error {
+	if h.Local != "" {
+		cmd := exec.Command("sh", "-c", h.Local)
+		cmd.Stdout, cmd.Stderr, cmd.Stdin = os.Stdout, os.Stderr, os.Stdin
+		cmd.Env = os.Environ()
+		for k, v := range env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		return cmd.Run()
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var exports strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&exports, "export %s=%s; ", k, shellQuote(env[k]))
+	}
+	remote := "sh -c " + shellQuote(exports.String()+h.Remote)
+
+	sudoPass, _ := flags["sudo-password"].(string)
+	var result plugin.Result
+	if h.Sudo && !(sudoPass == "" && conn.User() == "root") {
+		result = conn.RunSudo(remote, sudoPass)
+	} else {
+		result = conn.RunCommand(remote, false)
+	}
+	if result.Stdout != "" {
+		fmt.Print(result.Stdout)
+	}
+	if !result.Success {
+		return fmt.Errorf("exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// shellQuote single-quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
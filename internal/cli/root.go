@@ -10,6 +10,7 @@ import (
 	"github.com/wasilwamark/vps-init/internal/config"
 	ssh "github.com/wasilwamark/vps-init/internal/ssh"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/version"
 )
 
 var rootCmd = &cobra.Command{
@@ -22,6 +23,12 @@ Examples:
   vps-init mark@1.2.3.4 nginx install
   vps-init mark@1.2.3.4 nginx install-ssl api.tiza.africa
   vps-init myserver docker install
+  vps-init myserver plan state.yml
+  vps-init myserver apply state.yml
+  vps-init myserver drift --fix
+  vps-init myserver changes
+  vps-init web1,web2 exec --group -- df -h /
+  vps-init history --host 1.2.3.4 --since 2d
   vps-init --add-alias myserver mark@1.2.3.4
 
 Use "vps-init help" for more information.`,
@@ -109,13 +116,29 @@ func executeDirectCommand() {
 		os.Exit(1)
 	}
 
-	target := os.Args[1]
+	pluginName := os.Args[2]
 
-	// Resolve alias if present
-	cfg := config.New()
-	target = cfg.ResolveTarget(target)
+	// exec fans out over comma-separated targets, so it connects on its own
+	if pluginName == "exec" {
+		executeExec(os.Args[1], os.Args[3:])
+		return
+	}
 
-	pluginName := os.Args[2]
+	// Target-level commands (plan, apply, ...) are not bound to a plugin
+	if tc, ok := targetCommands[pluginName]; ok {
+		conn, flags, err := connectTarget(os.Args[1])
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		defer conn.Close()
+
+		if err := runWithHistory(context.Background(), conn, flags, "", pluginName, os.Args[3:], tc.Handler); err != nil {
+			fmt.Printf("❌ Command failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Default to "help" or equivalent if no command provided?
 	// The current signature expects at least 4 args provided in valid check
@@ -128,10 +151,14 @@ func executeDirectCommand() {
 	}
 
 	// Get registry
-	registry := plugin.GetBuiltinRegistry()
+	registry := plugin.GetRegistry()
 
 	// Find plugin
 	pl, exists := registry.Get(pluginName)
+	if err := plugin.Refused(pluginName); err != nil {
+		fmt.Printf("❌ Plugin %s is disabled: %v\n", pluginName, err)
+		os.Exit(1)
+	}
 	if !exists {
 		// Try to see if it's an alias command or something else?
 		// Actually, aliases are handled by config.
@@ -159,43 +186,70 @@ func executeDirectCommand() {
 		os.Exit(1)
 	}
 
-	// Establish SSH connection
-	// We need to parse target (user@host)
-	parts := strings.Split(target, "@")
-	if len(parts) != 2 {
-		fmt.Printf("❌ Invalid target format '%s'. Expected 'user@host' or a valid alias.\n", target)
-		fmt.Println("Tip: Use 'vps-init alias list' to see available aliases.")
+	conn, flags, err := connectTarget(os.Args[1])
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
 		os.Exit(1)
 	}
-	user := parts[0]
-	host := parts[1]
+	defer conn.Close()
 
 	ctx := context.Background()
-	config := ssh.Config{
-		Host: host,
-		User: user,
-		Port: 22,
+	args, installDeps := removeArg(args, "--install-deps")
+	if err := ensureDependencies(ctx, conn, flags, os.Args[1], pl, installDeps); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
 	}
-	conn, err := ssh.Connect(config)
+
+	inv := plugin.Invocation{Plugin: pl, Command: cmdName, Target: os.Args[1], Args: args}
+	handler := plugin.Chain(inv, plugin.WithLifecycle(pl, pluginConfig(os.Args[1]), commandToRun.Handler))
+	if err := runWithHistory(ctx, conn, flags, pluginName, cmdName, args, handler); err != nil {
+		fmt.Printf("❌ Command failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	recordCommand(ctx, conn, flags, pluginName, cmdName, args)
+}
+
+// pluginConfig is the configuration plugins are initialized with before a command
+func pluginConfig(target string) map[string]interface{} {
+	return map[string]interface{}{
+		"target":       target,
+		"host_version": version.Version,
+		"config_dir":   config.Dir(),
+	}
+}
+
+// connectTarget resolves an alias or user@host string, establishes the SSH
+// connection and returns the base flags (sudo password) for handlers.
+func connectTarget(rawTarget string) (plugin.Connection, map[string]interface{}, error) {
+	// Resolve alias if present
+	cfg := config.New()
+	target := cfg.ResolveTarget(rawTarget)
+
+	// Establish SSH connection
+	// We need to parse target (user@host[:port])
+	parsed, err := config.ParseConnection(target)
 	if err != nil {
-		fmt.Printf("❌ Failed to establish SSH connection: %v\n", err)
-		return
+		return nil, nil, fmt.Errorf("invalid target format '%s'. Expected 'user@host[:port]' or a valid alias.\nTip: Use 'vps-init alias list' to see available aliases", target)
 	}
-	defer conn.Close()
 
-	if !conn.Connect() {
-		fmt.Printf("❌ Failed to establish SSH connection manually (Connect returned false)\n")
-		os.Exit(1)
+	config := ssh.Config{
+		Host: parsed.Host,
+		User: parsed.User,
+		Port: parsed.Port,
+	}
+	conn, err := ssh.Connect(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to establish SSH connection: %v", err)
 	}
 
-	// Execute handler
 	// Parse args for flags
 	flags := make(map[string]interface{})
 
 	// Read sudo password from environment for security (Per Alias)
 	// We check if the original target was an alias
-	if _, isAlias := cfg.GetAlias(os.Args[1]); isAlias {
-		aliasName := strings.ToUpper(os.Args[1])
+	if _, isAlias := cfg.GetAlias(rawTarget); isAlias {
+		aliasName := strings.ToUpper(rawTarget)
 		// Normalize alias name for env var (e.g. replace - with _)
 		aliasName = strings.ReplaceAll(aliasName, "-", "_")
 		envVar := fmt.Sprintf("SSH_SUDO_PWD_%s", aliasName)
@@ -204,14 +258,11 @@ func executeDirectCommand() {
 			flags["sudo-password"] = envPass
 		} else {
 			// Check local secrets store
-			if secret, exists := cfg.GetSecret(os.Args[1]); exists {
+			if secret, exists := cfg.GetSecret(rawTarget); exists {
 				flags["sudo-password"] = secret
 			}
 		}
 	}
 
-	if err := commandToRun.Handler(ctx, conn, args, flags); err != nil {
-		fmt.Printf("❌ Command failed: %v\n", err)
-		os.Exit(1)
-	}
+	return conn, flags, nil
 }
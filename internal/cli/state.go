@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/state"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+func init() {
+	registerTargetCommand("plan", targetCommand{
+		Description: "Show the changes needed to converge the server to a state file",
+		Handler:     planHandler,
+	})
+	registerTargetCommand("apply", targetCommand{
+		Description: "Converge the server to a state file",
+		Handler:     applyHandler,
+	})
+	registerTargetCommand("drift", targetCommand{
+		Description: "Compare the server against what vps-init last configured",
+		Handler:     driftHandler,
+	})
+}
+
+// trackedCommand describes which resource type a mutating plugin command changes
+type trackedCommand struct {
+	resourceType string
+	commands     []string
+}
+
+// trackedPlugins maps plugins whose changes are recorded to the resources they manage
+var trackedPlugins = map[string]trackedCommand{
+	"firewall":  {resourceType: "firewall", commands: []string{"allow", "deny", "delete", "reset"}},
+	"nginx":     {resourceType: "nginx_site", commands: []string{"add-site", "remove-site", "install-ssl"}},
+	"wireguard": {resourceType: "wireguard_peer", commands: []string{"setup", "add-peer", "remove-peer"}},
+}
+
+// targetKey identifies a target in local records
+func targetKey(conn plugin.Connection) string {
+	return fmt.Sprintf("%s@%s", conn.User(), conn.Host())
+}
+
+// loadPlan loads the state file from args and computes the plan against the target
+func loadPlan(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) (*state.Env, []state.Diff, error) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "--") {
+		return nil, nil, fmt.Errorf("usage: <plan|apply> <state.yml> [--yes]")
+	}
+
+	spec, err := state.LoadSpec(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env, err := state.NewEnv(conn, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Printf("🔍 Reading current state of %s@%s...\n", conn.User(), conn.Host())
+	diffs, err := state.Plan(ctx, env, spec.Resources())
+	if err != nil {
+		return nil, nil, err
+	}
+	return env, diffs, nil
+}
+
+func planHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	_, diffs, err := loadPlan(ctx, conn, args, flags)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n📋 Plan:")
+	state.PrintPlan(diffs)
+	return nil
+}
+
+func applyHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	env, diffs, err := loadPlan(ctx, conn, args, flags)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n📋 Plan:")
+	if state.PrintPlan(diffs) == 0 {
+		return nil
+	}
+
+	if !hasArg(args, "--yes") {
+		fmt.Print("\nApply these changes? [y/N]: ")
+		reader := bufio.NewReader(os.Stdin)
+		answer, _ := reader.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("❌ Apply cancelled")
+			return nil
+		}
+	}
+
+	if err := state.Apply(ctx, env, diffs); err != nil {
+		return err
+	}
+
+	// Record what was configured so that drift can be detected later
+	rec, err := state.LoadRecord(targetKey(conn))
+	if err == nil {
+		for _, d := range diffs {
+			if err = rec.Upsert(d.Resource); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = rec.Save()
+		}
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Failed to record applied state: %v\n", err)
+	}
+
+	fmt.Println("✅ Server converged to desired state")
+	return nil
+}
+
+func driftHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	rec, err := state.LoadRecord(targetKey(conn))
+	if err != nil {
+		return err
+	}
+	if len(rec.Resources) == 0 && len(rec.Tracked) == 0 {
+		fmt.Println("ℹ️  Nothing recorded for this server yet. Configure it with 'apply' or plugin commands first.")
+		return nil
+	}
+
+	env, err := state.NewEnv(conn, flags)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🔍 Comparing %s against the state recorded on %s...\n", targetKey(conn), rec.UpdatedAt)
+	drifts, err := state.DetectDrift(ctx, env, rec)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	state.PrintDrift(drifts)
+	if len(drifts) == 0 || !hasArg(args, "--fix") {
+		return nil
+	}
+
+	prune := hasArg(args, "--prune")
+	if prune && !hasArg(args, "--yes") {
+		fmt.Print("\nDelete everything added outside vps-init? [y/N]: ")
+		reader := bufio.NewReader(os.Stdin)
+		answer, _ := reader.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("❌ Drift fix cancelled")
+			return nil
+		}
+	}
+
+	fmt.Println("\n🔧 Restoring recorded state...")
+	if err := state.FixDrift(ctx, env, drifts, prune); err != nil {
+		return err
+	}
+	fmt.Println("✅ Drift fixed")
+	return nil
+}
+
+// recordCommand updates the local state record after a successful plugin
+// command that changes tracked resources
+func recordCommand(ctx context.Context, conn plugin.Connection, flags map[string]interface{}, pluginName, cmdName string, args []string) {
+	tc, tracked := trackedPlugins[pluginName]
+	tracked = tracked && hasArg(tc.commands, cmdName)
+	isPackageCmd := pluginName == "system" && (cmdName == "install" || cmdName == "uninstall")
+	if !tracked && !isPackageCmd {
+		return
+	}
+
+	rec, err := state.LoadRecord(targetKey(conn))
+	if err != nil {
+		fmt.Printf("⚠️  Failed to load state record: %v\n", err)
+		return
+	}
+
+	env, err := state.NewEnv(conn, flags)
+	if err != nil {
+		fmt.Printf("⚠️  Failed to update state record: %v\n", err)
+		return
+	}
+	if tracked {
+		err = rec.Track(ctx, env, tc.resourceType)
+	} else {
+		for _, name := range args {
+			if strings.HasPrefix(name, "-") {
+				continue
+			}
+			pkg := &state.PackageResource{Name: name}
+			if cmdName == "uninstall" {
+				pkg.Ensure = "absent"
+			}
+			if err = rec.Upsert(pkg); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = rec.Save()
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Failed to record %s changes: %v\n", pluginName, err)
+	}
+}
+
+// hasArg reports whether a bare flag such as --yes was passed
+func hasArg(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// targetCommand is a command that runs directly against a target instead of
+// through a plugin, e.g. "vps-init myserver plan state.yml"
+type targetCommand struct {
+	Description string
+	Handler     plugin.CommandHandler
+}
+
+// targetCommands holds the target-level commands keyed by name
+var targetCommands = map[string]targetCommand{}
+
+// registerTargetCommand registers a target-level command
+func registerTargetCommand(name string, cmd targetCommand) {
+	targetCommands[name] = cmd
+}
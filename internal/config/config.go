@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -14,9 +15,14 @@ type Config struct {
 	secrets   map[string]string
 }
 
-func New() *Config {
+// Dir returns the local VPS-Init configuration directory (~/.vps-init)
+func Dir() string {
 	homeDir, _ := os.UserHomeDir()
-	configDir := filepath.Join(homeDir, ".vps-init")
+	return filepath.Join(homeDir, ".vps-init")
+}
+
+func New() *Config {
+	configDir := Dir()
 
 	cfg := &Config{
 		configDir: configDir,
@@ -150,6 +156,7 @@ type Connection struct {
 	Port int
 }
 
+// ParseConnection parses user@host[:port]; the port defaults to 22
 func ParseConnection(connStr string) (*Connection, error) {
 	parts := strings.Split(connStr, "@")
 	if len(parts) != 2 {
@@ -158,10 +165,26 @@ func ParseConnection(connStr string) (*Connection, error) {
 
 	user := parts[0]
 	host := parts[1]
+	port := 22
+	if h, p, ok := strings.Cut(host, ":"); ok {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid port '%s'", p)
+		}
+		host, port = h, n
+	}
 
 	return &Connection{
 		User: user,
 		Host: host,
-		Port: 22,
+		Port: port,
 	}, nil
 }
+
+// String formats the connection as user@host, adding the port if it isn't 22
+func (c *Connection) String() string {
+	if c.Port != 0 && c.Port != 22 {
+		return fmt.Sprintf("%s@%s:%d", c.User, c.Host, c.Port)
+	}
+	return c.User + "@" + c.Host
+}
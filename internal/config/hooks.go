@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Hook runs a local script or a remote command before or after plugin commands
+type Hook struct {
+	Name    string   `json:"name,omitempty"`
+	When    string   `json:"when"`              // "before" or "after"
+	Plugin  string   `json:"plugin"`            // plugin name, or "*" for every plugin
+	Command string   `json:"command,omitempty"` // command name; empty or "*" for every command
+	Targets []string `json:"targets,omitempty"` // aliases or user@host; empty for every target
+	Local   string   `json:"local,omitempty"`   // shell command run on this machine
+	Remote  string   `json:"remote,omitempty"`  // shell command run on the target
+	Sudo    bool     `json:"sudo,omitempty"`    // run the remote command with sudo
+}
+
+// Settings is the optional ~/.vps-init/config.json file
+type Settings struct {
+	Hooks []Hook `json:"hooks,omitempty"`
+}
+
+// LoadSettings reads ~/.vps-init/config.json, returning empty settings if it does not exist
+func LoadSettings() (*Settings, error) {
+	path := filepath.Join(Dir(), "config.json")
+
+	var s Settings
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for i, h := range s.Hooks {
+		if h.When != "before" && h.When != "after" {
+			return nil, fmt.Errorf("%s: hook %d: when must be 'before' or 'after'", path, i+1)
+		}
+		if h.Plugin == "" {
+			return nil, fmt.Errorf("%s: hook %d: plugin is required (use \"*\" for every plugin)", path, i+1)
+		}
+		if (h.Local == "") == (h.Remote == "") {
+			return nil, fmt.Errorf("%s: hook %d: set exactly one of local or remote", path, i+1)
+		}
+	}
+	return &s, nil
+}
+
+// Matches reports whether the hook applies to a command on a target. The
+// target matches either as given on the command line or as resolved user@host.
+func (h Hook) Matches(pluginName, command, target, resolved string) bool {
+	if h.Plugin != "*" && h.Plugin != pluginName {
+		return false
+	}
+	if h.Command != "" && h.Command != "*" && h.Command != command {
+		return false
+	}
+	if len(h.Targets) == 0 {
+		return true
+	}
+	for _, t := range h.Targets {
+		if t == target || t == resolved {
+			return true
+		}
+	}
+	return false
+}
+
+// Label names the hook in output
+func (h Hook) Label() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	if h.Local != "" {
+		return h.Local
+	}
+	return h.Remote
+}
@@ -106,9 +106,7 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64", "darwin/amd64", "darwin/arm64"},
+		MinVPSInitVersion: "0.1.0",
 		Tags:              []string{"core", "management", "alias"},
 	}
 }
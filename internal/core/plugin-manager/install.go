@@ -0,0 +1,354 @@
+package pluginmanager
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wasilwamark/vps-init/internal/config"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/version"
+)
+
+// pluginsDir is where external plugins are installed, one directory per plugin
+func pluginsDir() string {
+	return filepath.Join(config.Dir(), "plugins")
+}
+
+func isGitURL(src string) bool {
+	for _, prefix := range []string{"https://", "http://", "git@", "ssh://", "git://"} {
+		if strings.HasPrefix(src, prefix) {
+			return true
+		}
+	}
+	return strings.HasSuffix(src, ".git")
+}
+
+// installPlugin fetches a plugin from a local directory or git URL, builds it if
+// the manifest says how, and installs it with a checksummed manifest. An
+// existing installation is only replaced when replace is set.
+func installPlugin(src string, replace bool) (*plugin.Manifest, error) {
+	var srcDir string
+	var buildInfo plugin.BuildInfo
+
+	if isGitURL(src) {
+		tmp, err := os.MkdirTemp("", "vps-init-plugin-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+
+		fmt.Printf("📥 Cloning %s...\n", src)
+		clone := exec.Command("git", "clone", "--depth", "1", src, tmp)
+		clone.Stdout, clone.Stderr = os.Stderr, os.Stderr
+		if err := clone.Run(); err != nil {
+			return nil, fmt.Errorf("failed to clone %s: %w", src, err)
+		}
+		srcDir = tmp
+	} else {
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return nil, err
+		}
+		src, srcDir = abs, abs
+	}
+	buildInfo.GitCommit = gitOutput(srcDir, "rev-parse", "HEAD")
+	buildInfo.GitTag = gitOutput(srcDir, "describe", "--tags", "--exact-match")
+
+	m, err := plugin.LoadManifest(filepath.Join(srcDir, plugin.ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	if _, builtin := plugin.GetBuiltinRegistry().Get(m.Name); builtin {
+		return nil, fmt.Errorf("plugin '%s' conflicts with a built-in plugin", m.Name)
+	}
+
+	dest := filepath.Join(pluginsDir(), m.Name)
+	existing, err := plugin.LoadManifest(filepath.Join(dest, plugin.ManifestFile))
+	if err == nil && !replace {
+		return nil, fmt.Errorf("plugin '%s' is already installed; use 'vps-init plugin update %s'", m.Name, m.Name)
+	}
+
+	// Decide whether to trust the plugin before its build command or
+	// executable runs. The signature covers the published checksum, which the
+	// built executable must match below.
+	trust, err := loadTrustStore()
+	if err != nil {
+		return nil, err
+	}
+	if m.Signature != "" && m.Checksum == "" {
+		return nil, fmt.Errorf("plugin '%s' is signed but its manifest has no checksum; sign it with 'vps-init plugin sign'", m.Name)
+	}
+	level, err := trust.Verify(m)
+	if err != nil {
+		return nil, err
+	}
+	if level == plugin.TrustUntrusted {
+		switch trust.Policy {
+		case plugin.PolicyRefuse:
+			return nil, fmt.Errorf("plugin '%s' is not signed by a trusted key and the trust policy is 'refuse'", m.Name)
+		case plugin.PolicyConfirm:
+			if !confirmInstall(m) {
+				return nil, fmt.Errorf("installation of untrusted plugin '%s' cancelled", m.Name)
+			}
+		}
+	}
+
+	if m.Build != "" {
+		fmt.Printf("🔨 Building %s: %s\n", m.Name, m.Build)
+		build := exec.Command("sh", "-c", m.Build)
+		build.Dir = srcDir
+		build.Stdout, build.Stderr = os.Stderr, os.Stderr
+		if err := build.Run(); err != nil {
+			return nil, fmt.Errorf("build failed: %w", err)
+		}
+		buildInfo.BuildTime = time.Now().Format(time.RFC3339)
+		buildInfo.BuildFlags = []string{m.Build}
+		if strings.HasPrefix(m.Build, "go ") {
+			if out, err := exec.Command("go", "env", "GOVERSION").Output(); err == nil {
+				buildInfo.GoVersion = strings.TrimSpace(string(out))
+			}
+		}
+	}
+
+	// Stage next to the destination so the final swap is a rename
+	if err := os.MkdirAll(pluginsDir(), 0755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(pluginsDir(), "."+m.Name+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	exe := filepath.Join(staging, filepath.Base(m.Executable))
+	if err := copyExecutable(filepath.Join(srcDir, m.Executable), exe); err != nil {
+		return nil, err
+	}
+
+	// A signed manifest carries the published checksum; the executable we
+	// installed must be exactly the one that was signed
+	checksum, err := plugin.FileChecksum(exe)
+	if err != nil {
+		return nil, err
+	}
+	if m.Checksum != "" && m.Checksum != checksum {
+		return nil, fmt.Errorf("executable checksum %s does not match the published checksum %s", checksum, m.Checksum)
+	}
+	m.Checksum = checksum
+	m.InstallPath = dest
+	m.InstalledAt = time.Now().Format(time.RFC3339)
+	if existing != nil {
+		m.InstalledAt = existing.InstalledAt
+		m.LastUpdated = time.Now().Format(time.RFC3339)
+	}
+	m.Source = src
+	m.BuildInfo = buildInfo
+	m.Granted = grantPermissions(m, existing)
+
+	// Make sure the plugin actually speaks the protocol before replacing anything
+	var loaded *plugin.ExternalPlugin
+	if m.IsWasm() {
+		loaded, err = plugin.LoadWasmPlugin(exe, version.Version)
+	} else {
+		loaded, err = plugin.LoadExternalPlugin(exe, version.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if loaded.Name() != m.Name {
+		return nil, fmt.Errorf("plugin reports name '%s', which does not match its manifest name '%s'", loaded.Name(), m.Name)
+	}
+	// Recorded so the plugin can be listed later without starting it
+	m.Commands = nil
+	for _, cmd := range loaded.GetCommands() {
+		m.Commands = append(m.Commands, plugin.CommandSpec{Name: cmd.Name, Description: cmd.Description, Aliases: cmd.Aliases})
+	}
+	if err := m.Save(filepath.Join(staging, plugin.ManifestFile)); err != nil {
+		return nil, err
+	}
+
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staging, dest); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// confirmInstall asks before building and starting a plugin that is not
+// signed by a trusted key
+var confirmInstall = func(m *plugin.Manifest) bool {
+	fmt.Printf("⚠️  Plugin '%s' is not signed by a trusted key.\n", m.Name)
+	if m.Build != "" {
+		fmt.Printf("   Installing runs its build command: %s\n", m.Build)
+	}
+	fmt.Printf("   Installing starts %s to check it speaks the plugin protocol.\n", m.Executable)
+	fmt.Print("Continue? [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// grantPermissions asks the user to approve the local access a WASM plugin
+// requests. An update keeps an earlier approval that still covers the request;
+// whatever the source manifest claims was granted is ignored.
+func grantPermissions(m, existing *plugin.Manifest) *plugin.Permissions {
+	if !m.IsWasm() || m.Permissions.Empty() {
+		return nil
+	}
+	if existing != nil && existing.Granted != nil && existing.Granted.Covers(m.Permissions) {
+		return existing.Granted
+	}
+
+	fmt.Printf("🔐 Plugin '%s' requests local access: %s\n", m.Name, m.Permissions)
+	fmt.Print("Allow it? [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		fmt.Println("⚠️  Not approved; you will be asked again each time the plugin runs")
+		return nil
+	}
+	granted := m.Permissions
+	return &granted
+}
+
+// copyExecutable copies a built plugin into the install directory
+func copyExecutable(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("plugin executable not found: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// gitOutput runs a git command in dir, returning "" if it fails (e.g. not a repository)
+func gitOutput(dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (p *Plugin) runInstall(cmd *cobra.Command, args []string) error {
+	m, err := installPlugin(args[0], false)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Installed plugin %s %s (%s)\n", m.Name, m.Version, m.Checksum)
+	if level := trustLevel(m); level == plugin.TrustUntrusted {
+		fmt.Println("⚠️  The plugin is not signed by a trusted key; see 'vps-init plugin trust --help'")
+	}
+	return nil
+}
+
+func (p *Plugin) runUninstall(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if !plugin.ValidPluginName(name) {
+		return fmt.Errorf("invalid plugin name '%s' (use lowercase letters, digits and dashes)", name)
+	}
+	dir := filepath.Join(pluginsDir(), name)
+	if _, err := os.Stat(filepath.Join(dir, plugin.ManifestFile)); err != nil {
+		return fmt.Errorf("plugin '%s' is not installed", name)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove plugin: %w", err)
+	}
+	fmt.Printf("✅ Uninstalled plugin %s\n", name)
+	return nil
+}
+
+func (p *Plugin) runUpdate(cmd *cobra.Command, args []string) error {
+	manifests, err := plugin.NewExternalLoader(pluginsDir(), version.Version).InstalledManifests()
+	if err != nil && len(manifests) == 0 {
+		return err
+	}
+
+	updated := 0
+	for _, m := range manifests {
+		if len(args) > 0 && !contains(args, m.Name) {
+			continue
+		}
+		fmt.Printf("🔄 Updating %s from %s...\n", m.Name, m.Source)
+		newManifest, err := installPlugin(m.Source, true)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", m.Name, err)
+		}
+		fmt.Printf("✅ %s %s -> %s\n", m.Name, m.Version, newManifest.Version)
+		updated++
+	}
+
+	if updated == 0 {
+		if len(args) > 0 {
+			return fmt.Errorf("plugin '%s' is not installed", strings.Join(args, ", "))
+		}
+		fmt.Println("No installed plugins to update.")
+	}
+	return nil
+}
+
+// runListInstalled lists external plugins installed with "plugin install"
+func (p *Plugin) runListInstalled() error {
+	manifests, err := plugin.NewExternalLoader(pluginsDir(), version.Version).InstalledManifests()
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	if len(manifests) == 0 {
+		fmt.Println("No plugins installed. Use 'vps-init plugin install <path|git-url>' to install one.")
+		return nil
+	}
+
+	fmt.Println("Installed Plugins:")
+	for _, m := range manifests {
+		status := "✅ " + trustLevel(m)
+		if err := m.Verify(); err != nil {
+			status = "❌ checksum mismatch"
+		}
+		fmt.Printf("  %s (%s) %s\n", m.Name, m.Version, status)
+		fmt.Printf("    source:    %s\n", m.Source)
+		fmt.Printf("    installed: %s\n", m.InstalledAt)
+		if m.LastUpdated != "" {
+			fmt.Printf("    updated:   %s\n", m.LastUpdated)
+		}
+		if m.BuildInfo.GitCommit != "" {
+			fmt.Printf("    commit:    %s\n", m.BuildInfo.GitCommit)
+		}
+		if m.IsWasm() {
+			access := "none"
+			if m.Granted != nil {
+				access = m.Granted.String()
+			}
+			fmt.Printf("    runtime:   wasm (local access: %s)\n", access)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
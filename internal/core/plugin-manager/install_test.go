@@ -0,0 +1,143 @@
+package pluginmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wasilwamark/vps-init/internal/config"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// writeSource creates a plugin source tree whose build step leaves a marker
+// file, returning the tree and the marker path
+func writeSource(t *testing.T, m *plugin.Manifest) (string, string) {
+	t.Helper()
+	src := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "built")
+	m.Name = "evil"
+	m.Version = "1.0.0"
+	m.Executable = "evil"
+	m.Build = "touch " + marker + " && printf '#!/bin/sh\\ntouch " + marker + "\\n' > evil && chmod +x evil"
+	if err := m.Save(filepath.Join(src, plugin.ManifestFile)); err != nil {
+		t.Fatal(err)
+	}
+	return src, marker
+}
+
+func setPolicy(t *testing.T, policy string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	trust, err := loadTrustStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trust.SetPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+	if err := trust.Save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stubConfirm answers the install confirmation for the rest of the test
+func stubConfirm(t *testing.T, answer func(*plugin.Manifest) bool) {
+	t.Helper()
+	orig := confirmInstall
+	confirmInstall = answer
+	t.Cleanup(func() { confirmInstall = orig })
+}
+
+func TestInstallDoesNotBuildUntrustedPlugins(t *testing.T) {
+	_, unknownKey, err := plugin.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := plugin.ParsePrivateKey(unknownKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		policy   string
+		manifest func(m *plugin.Manifest)
+	}{
+		{
+			name:   "placeholder signature",
+			policy: plugin.PolicyRefuse,
+			manifest: func(m *plugin.Manifest) {
+				m.Checksum = "sha256:0000"
+				m.Signature = "ed25519:placeholder:AAAA"
+			},
+		},
+		{
+			name:   "signature by an untrusted key",
+			policy: plugin.PolicyRefuse,
+			manifest: func(m *plugin.Manifest) {
+				m.Name, m.Version, m.Checksum = "evil", "1.0.0", "sha256:0000"
+				plugin.SignManifest(m, priv)
+			},
+		},
+		{
+			name:     "unsigned",
+			policy:   plugin.PolicyRefuse,
+			manifest: func(m *plugin.Manifest) {},
+		},
+		{
+			name:   "signature without a checksum",
+			policy: plugin.PolicyAllow,
+			manifest: func(m *plugin.Manifest) {
+				m.Name, m.Version = "evil", "1.0.0"
+				plugin.SignManifest(m, priv)
+			},
+		},
+		{
+			name:     "declined under confirm",
+			policy:   plugin.PolicyConfirm,
+			manifest: func(m *plugin.Manifest) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setPolicy(t, tt.policy)
+			stubConfirm(t, func(*plugin.Manifest) bool { return false })
+
+			m := &plugin.Manifest{}
+			tt.manifest(m)
+			src, marker := writeSource(t, m)
+
+			if _, err := installPlugin(src, false); err == nil {
+				t.Fatal("installPlugin() succeeded")
+			}
+			if _, err := os.Stat(marker); err == nil {
+				t.Error("the build command ran")
+			}
+			if _, err := os.Stat(filepath.Join(config.Dir(), "plugins", "evil")); err == nil {
+				t.Error("the plugin was installed")
+			}
+		})
+	}
+}
+
+func TestInstallShowsBuildBeforeConfirming(t *testing.T) {
+	setPolicy(t, plugin.PolicyConfirm)
+	var asked *plugin.Manifest
+	stubConfirm(t, func(m *plugin.Manifest) bool {
+		asked = m
+		return false
+	})
+
+	src, marker := writeSource(t, &plugin.Manifest{})
+	_, err := installPlugin(src, false)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("installPlugin() error = %v, want cancelled", err)
+	}
+	if asked == nil || !strings.Contains(asked.Build, "touch") {
+		t.Errorf("confirmation did not get the build command")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the build command ran")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/version"
 )
 
 type Plugin struct {
@@ -36,7 +37,7 @@ func (p *Plugin) Author() string {
 
 func (p *Plugin) Initialize(config map[string]interface{}) error {
 	p.config = config
-	p.registry = plugin.GetBuiltinRegistry()
+	p.registry = plugin.GetRegistry()
 	return nil
 }
 
@@ -71,21 +72,30 @@ func (p *Plugin) GetCommands() []plugin.Command {
 func (p *Plugin) GetRootCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "plugin",
-		Short: "Built-in plugin management commands",
-		Long: `Manage built-in VPS-Init plugins.
+		Short: "Plugin management commands",
+		Long: `Manage built-in and external VPS-Init plugins.
 
 Examples:
   vps-init plugin list
+  vps-init plugin list --installed
   vps-init plugin info nginx
-  vps-init plugin validate`,
+  vps-init plugin validate
+  vps-init plugin new my-plugin --external
+  vps-init plugin install ./my-plugin
+  vps-init plugin install https://github.com/acme/vps-init-deploy.git
+  vps-init plugin update deploy
+  vps-init plugin uninstall deploy
+  vps-init plugin trust list
+  vps-init plugin sign ./my-plugin --key ~/.vps-init/signing.key`,
 	}
 
 	// list command
 	listCmd := &cobra.Command{
 		Use:   "list",
-		Short: "List all built-in plugins",
+		Short: "List all plugins",
 		RunE:  p.runList,
 	}
+	listCmd.Flags().Bool("installed", false, "Only list external plugins installed with 'plugin install'")
 	cmd.AddCommand(listCmd)
 
 	// info command
@@ -116,6 +126,56 @@ Examples:
 	validateCmd.Flags().Bool("strict", false, "Enable strict validation")
 	cmd.AddCommand(validateCmd)
 
+	installCmd := &cobra.Command{
+		Use:   "install <path|git-url>",
+		Short: "Install an external plugin from a local directory or git repository",
+		Long: `Install an external plugin. The source must contain a vps-init-plugin.yaml
+manifest; its build command, if any, is run before the executable is copied
+to ~/.vps-init/plugins/<name>/ and checksummed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: p.runInstall,
+	}
+	cmd.AddCommand(installCmd)
+
+	uninstallCmd := &cobra.Command{
+		Use:   "uninstall <name>",
+		Short: "Remove an installed external plugin",
+		Args:  cobra.ExactArgs(1),
+		RunE:  p.runUninstall,
+	}
+	cmd.AddCommand(uninstallCmd)
+
+	updateCmd := &cobra.Command{
+		Use:   "update [name...]",
+		Short: "Reinstall external plugins from their recorded source",
+		RunE:  p.runUpdate,
+	}
+	cmd.AddCommand(updateCmd)
+
+	cmd.AddCommand(p.newCommand())
+
+	cmd.AddCommand(p.trustCommand())
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "keygen <private-key-file>",
+		Short: "Generate an ed25519 key pair for signing plugins",
+		Args:  cobra.ExactArgs(1),
+		RunE:  p.runKeygen,
+	})
+
+	signCmd := &cobra.Command{
+		Use:   "sign <plugin-dir>",
+		Short: "Sign a built plugin's manifest with a private key",
+		Long: `Record the checksum of the built executable in the plugin's manifest and
+sign it. Users who trust the matching public key can then install the plugin
+under the "refuse" policy. Build the executable reproducibly (or ship it
+prebuilt) so the installed checksum matches the signed one.`,
+		Args: cobra.ExactArgs(1),
+		RunE: p.runSign,
+	}
+	signCmd.Flags().String("key", "", "Private key file created with 'vps-init plugin keygen'")
+	cmd.AddCommand(signCmd)
+
 	return cmd
 }
 
@@ -139,9 +199,7 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64", "darwin/amd64", "darwin/arm64"},
+		MinVPSInitVersion: "0.1.0",
 		Tags:              []string{"core", "management", "builtin"},
 	}
 }
@@ -174,7 +232,14 @@ func (p *Plugin) handleList(ctx context.Context, conn plugin.Connection, args []
 
 	fmt.Println("Available Plugins:")
 	for _, pl := range plugins {
-		fmt.Printf("  %s (%s) - %s\n", pl.Name(), pl.Version(), pl.Description())
+		source := ""
+		if plugin.IsExternal(pl.Name()) {
+			source = " [external]"
+			if ext, ok := pl.(*plugin.ExternalPlugin); ok && ext.Runtime() == plugin.RuntimeWasm {
+				source = " [external, wasm]"
+			}
+		}
+		fmt.Printf("  %s (%s)%s - %s\n", pl.Name(), pl.Version(), source, pl.Description())
 	}
 
 	return nil
@@ -226,8 +291,11 @@ func (p *Plugin) handleInfo(ctx context.Context, conn plugin.Connection, args []
 
 // Cobra command runners
 func (p *Plugin) runList(cmd *cobra.Command, args []string) error {
+	if installed, _ := cmd.Flags().GetBool("installed"); installed {
+		return p.runListInstalled()
+	}
 	if p.registry == nil {
-		p.registry = plugin.GetBuiltinRegistry()
+		p.registry = plugin.GetRegistry()
 	}
 	plugins := p.registry.GetAll()
 
@@ -238,7 +306,14 @@ func (p *Plugin) runList(cmd *cobra.Command, args []string) error {
 
 	fmt.Println("Available Plugins:")
 	for _, pl := range plugins {
-		fmt.Printf("  %s (%s) - %s\n", pl.Name(), pl.Version(), pl.Description())
+		source := ""
+		if plugin.IsExternal(pl.Name()) {
+			source = " [external]"
+			if ext, ok := pl.(*plugin.ExternalPlugin); ok && ext.Runtime() == plugin.RuntimeWasm {
+				source = " [external, wasm]"
+			}
+		}
+		fmt.Printf("  %s (%s)%s - %s\n", pl.Name(), pl.Version(), source, pl.Description())
 	}
 
 	return nil
@@ -247,7 +322,7 @@ func (p *Plugin) runList(cmd *cobra.Command, args []string) error {
 
 func (p *Plugin) runInfo(cmd *cobra.Command, args []string) error {
 	if p.registry == nil {
-		p.registry = plugin.GetBuiltinRegistry()
+		p.registry = plugin.GetRegistry()
 	}
 	pluginName := args[0]
 
@@ -290,7 +365,7 @@ func (p *Plugin) runInfo(cmd *cobra.Command, args []string) error {
 
 func (p *Plugin) handleValidate(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	if p.registry == nil {
-		p.registry = plugin.GetBuiltinRegistry()
+		p.registry = plugin.GetRegistry()
 	}
 
 	strict := false
@@ -309,7 +384,7 @@ func (p *Plugin) handleValidate(ctx context.Context, conn plugin.Connection, arg
 		return nil
 	}
 
-	validator := plugin.NewValidator("1.0.0") // Get from build info
+	validator := plugin.NewValidator(version.Version)
 
 	validationErrors := 0
 	for _, pl := range plugins {
@@ -0,0 +1,582 @@
+package pluginmanager
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/version"
+)
+
+const modulePath = "github.com/wasilwamark/vps-init"
+
+// scaffoldNamePattern is the plugin name pattern the validator accepts,
+// further limited to names that start with a letter so they make a Go
+// package name
+var scaffoldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// scaffold holds the values substituted into the plugin templates
+type scaffold struct {
+	Name        string // plugin name, e.g. "my-tool"
+	Package     string // Go package name, e.g. "mytool"
+	Title       string // human readable name, e.g. "My Tool"
+	Description string
+	Author      string
+	HostVersion string
+	Wasm        bool // external plugin built as a WASM module for the sandbox
+}
+
+// scaffoldFile is a file to generate, relative to the output directory
+type scaffoldFile struct {
+	path string
+	tmpl string
+}
+
+func (p *Plugin) newCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "new <name>",
+		Short: "Generate a skeleton for a new plugin",
+		Long: `Generate a plugin skeleton with install and status commands, a test using
+a fake connection and a documentation page. The generated plugin passes
+'vps-init plugin validate' as is.
+
+By default a built-in plugin is generated under internal/services/<name>;
+run this from the root of the vps-init repository. With --external a
+standalone plugin is generated in its own directory instead; --wasm makes
+it a WebAssembly plugin that runs in the sandbox.
+
+Examples:
+  vps-init plugin new backup-agent
+  vps-init plugin new my-tool --external --author "Jane Doe"
+  vps-init plugin new my-tool --external --dir ~/src/vps-init-my-tool
+  vps-init plugin new my-tool --wasm`,
+		Args: cobra.ExactArgs(1),
+		RunE: p.runNew,
+	}
+	cmd.Flags().Bool("external", false, "Generate an external plugin instead of a built-in one")
+	cmd.Flags().Bool("wasm", false, "Generate an external plugin built as a sandboxed WASM module (implies --external)")
+	cmd.Flags().String("dir", "", "Output directory for an external plugin (default ./<name>)")
+	cmd.Flags().String("author", "", "Plugin author")
+	cmd.Flags().String("description", "", "One line plugin description")
+	return cmd
+}
+
+func (p *Plugin) runNew(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	external, _ := cmd.Flags().GetBool("external")
+	wasm, _ := cmd.Flags().GetBool("wasm")
+	dir, _ := cmd.Flags().GetString("dir")
+	author, _ := cmd.Flags().GetString("author")
+	description, _ := cmd.Flags().GetString("description")
+
+	if !scaffoldNamePattern.MatchString(name) || len(name) > 50 {
+		return fmt.Errorf("invalid plugin name '%s': use lowercase letters, digits and dashes, starting with a letter", name)
+	}
+	pkg := strings.ReplaceAll(name, "-", "")
+	if token.IsKeyword(pkg) {
+		return fmt.Errorf("invalid plugin name '%s': '%s' is a Go keyword", name, pkg)
+	}
+	if _, exists := plugin.GetBuiltinRegistry().Get(name); exists {
+		return fmt.Errorf("a built-in plugin named '%s' already exists", name)
+	}
+
+	s := scaffold{
+		Name:        name,
+		Package:     pkg,
+		Title:       titleCase(name),
+		Description: description,
+		Author:      author,
+		HostVersion: version.Version,
+		Wasm:        wasm,
+	}
+	if s.Description == "" {
+		s.Description = s.Title + " management"
+	}
+
+	if external || wasm {
+		if s.Author == "" {
+			s.Author = defaultAuthor()
+		}
+		if dir == "" {
+			dir = name
+		}
+		return generateExternal(s, dir)
+	}
+
+	if dir != "" {
+		return fmt.Errorf("--dir only applies to external plugins; built-in plugins go under internal/services")
+	}
+	if s.Author == "" {
+		s.Author = "VPS-Init Team"
+	}
+	return generateBuiltin(s)
+}
+
+func generateBuiltin(s scaffold) error {
+	if !isRepoRoot() {
+		return fmt.Errorf("built-in plugins must be generated from the root of the vps-init repository (or use --external)")
+	}
+
+	dir := filepath.Join("internal", "services", s.Package)
+	files := []scaffoldFile{
+		{filepath.Join(dir, "plugin.go"), builtinPluginTemplate},
+		{filepath.Join(dir, "plugin_test.go"), builtinTestTemplate},
+		{filepath.Join("docs", "plugins", s.Name+".md"), docsTemplate},
+	}
+	if err := writeScaffold(".", files, s); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Built-in plugin '%s' generated\n", s.Name)
+	fmt.Println("Next steps:")
+	fmt.Println("  1. Register it in cmd/vps-init/plugins_init.go:")
+	fmt.Printf("       \"%s/internal/services/%s\"\n", modulePath, s.Package)
+	fmt.Printf("       plugin.RegisterBuiltin(\"%s/services/%s\", &%s.Plugin{})\n", modulePath, s.Package, s.Package)
+	fmt.Printf("  2. go test ./%s/...\n", filepath.ToSlash(dir))
+	fmt.Println("  3. vps-init plugin validate")
+	return nil
+}
+
+func generateExternal(s scaffold, dir string) error {
+	files := []scaffoldFile{
+		{"main.go", externalPluginTemplate},
+		{"main_test.go", externalTestTemplate},
+		{plugin.ManifestFile, manifestTemplate},
+		{"README.md", docsTemplate},
+	}
+	if err := writeScaffold(dir, files, s); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ External plugin '%s' generated in %s\n", s.Name, dir)
+	fmt.Println("Next steps:")
+	fmt.Printf("  cd %s\n", dir)
+	fmt.Printf("  go mod init <module-path> && go mod tidy\n")
+	fmt.Println("  go test ./...")
+	if s.Wasm {
+		fmt.Println("  vps-init plugin install .   # builds with GOOS=wasip1 GOARCH=wasm")
+		return nil
+	}
+	fmt.Println("  vps-init plugin install .")
+	return nil
+}
+
+// writeScaffold renders the files into dir, refusing to overwrite any existing file
+func writeScaffold(dir string, files []scaffoldFile, s scaffold) error {
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		}
+	}
+
+	funcs := template.FuncMap{"fence": func() string { return "```" }}
+	for _, f := range files {
+		tmpl, err := template.New(f.path).Funcs(funcs).Parse(f.tmpl)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(out, s); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to render %s: %w", path, err)
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("  created %s\n", path)
+	}
+	return nil
+}
+
+// isRepoRoot reports whether the working directory is the vps-init module root
+func isRepoRoot() bool {
+	f, err := os.Open("go.mod")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); strings.HasPrefix(line, "module ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "module ")) == modulePath
+		}
+	}
+	return false
+}
+
+func titleCase(name string) string {
+	words := strings.Split(name, "-")
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func defaultAuthor() string {
+	if u, err := user.Current(); err == nil {
+		if u.Name != "" {
+			return u.Name
+		}
+		if u.Username != "" {
+			return u.Username
+		}
+	}
+	return "Unknown"
+}
+
+const builtinPluginTemplate = `package {{.Package}}
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+type Plugin struct{}
+
+func (p *Plugin) Name() string {
+	return "{{.Name}}"
+}
+
+func (p *Plugin) Description() string {
+	return {{printf "%q" .Description}}
+}
+
+func (p *Plugin) Version() string {
+	return "0.1.0"
+}
+
+func (p *Plugin) Author() string {
+	return {{printf "%q" .Author}}
+}
+
+func (p *Plugin) Initialize(config map[string]interface{}) error {
+	return nil
+}
+
+func (p *Plugin) Validate() error {
+	return nil
+}
+
+func (p *Plugin) Dependencies() []plugin.Dependency {
+	return []plugin.Dependency{}
+}
+
+func (p *Plugin) Compatibility() plugin.Compatibility {
+	return plugin.Compatibility{
+		MinVPSInitVersion: "{{.HostVersion}}",
+		GoVersion:         "1.19",
+		Platforms:         []string{"linux/amd64", "linux/arm64", "darwin/amd64", "darwin/arm64"},
+		Tags:              []string{"{{.Name}}"},
+	}
+}
+
+func (p *Plugin) GetMetadata() plugin.PluginMetadata {
+	return plugin.PluginMetadata{
+		Name:        p.Name(),
+		Description: p.Description(),
+		Version:     p.Version(),
+		Author:      p.Author(),
+		License:     "MIT",
+		Repository:  "github.com/wasilwamark/vps-init",
+		Tags:        []string{"{{.Name}}"},
+		TrustLevel:  "official",
+		BuildInfo: plugin.BuildInfo{
+			GoVersion: "1.21",
+		},
+	}
+}
+
+func (p *Plugin) GetCommands() []plugin.Command {
+	return []plugin.Command{
+		{
+			Name:        "install",
+			Description: "Install {{.Title}}",
+			Handler:     p.installHandler,
+		},
+		{
+			Name:        "status",
+			Description: "Check {{.Title}} service status",
+			Handler:     p.statusHandler,
+		},
+	}
+}
+
+func (p *Plugin) GetRootCommand() *cobra.Command {
+	return nil
+}
+
+func (p *Plugin) Start(ctx context.Context) error {
+	return nil
+}
+
+func (p *Plugin) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+
+	fmt.Println("📦 Installing {{.Title}}...")
+	if err := c.Install("{{.Name}}"); err != nil {
+		return err
+	}
+
+	c.Success("{{.Title}} installed successfully!")
+	c.Publish(plugin.ServiceInstalled{Service: "{{.Name}}"})
+	return nil
+}
+
+func (p *Plugin) statusHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+	if !c.ServiceActive("{{.Name}}") {
+		fmt.Println("❌ {{.Title}} is not running")
+		return nil
+	}
+	c.Success("{{.Title}} is running")
+	return nil
+}
+`
+
+const builtinTestTemplate = `package {{.Package}}
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/plugintest"
+	"github.com/wasilwamark/vps-init/pkg/version"
+)
+
+func TestValidatePlugin(t *testing.T) {
+	if errs := plugin.NewValidator(version.Version).ValidatePlugin(&Plugin{}); len(errs) > 0 {
+		t.Fatal(errs.Error())
+	}
+}
+
+func TestInstall(t *testing.T) {
+	conn := plugintest.NewConnection()
+	p := &Plugin{}
+
+	if err := p.installHandler(context.Background(), conn, nil, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if !conn.Ran("apt-get install -y {{.Name}}") {
+		t.Errorf("{{.Name}} was not installed; ran %q", conn.Commands())
+	}
+}
+
+func TestInstallFailure(t *testing.T) {
+	conn := plugintest.NewConnection()
+	conn.Fail("apt-get install", "E: Unable to locate package {{.Name}}")
+	p := &Plugin{}
+
+	if err := p.installHandler(context.Background(), conn, nil, map[string]interface{}{}); err == nil {
+		t.Fatal("expected an error when the package cannot be installed")
+	}
+}
+`
+
+const externalPluginTemplate = `package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+type Plugin struct{}
+
+func (p *Plugin) Name() string        { return "{{.Name}}" }
+func (p *Plugin) Description() string { return {{printf "%q" .Description}} }
+func (p *Plugin) Version() string     { return "0.1.0" }
+func (p *Plugin) Author() string      { return {{printf "%q" .Author}} }
+
+func (p *Plugin) Initialize(config map[string]interface{}) error { return nil }
+func (p *Plugin) Validate() error                                { return nil }
+func (p *Plugin) Start(ctx context.Context) error                { return nil }
+func (p *Plugin) Stop(ctx context.Context) error                 { return nil }
+func (p *Plugin) GetRootCommand() *cobra.Command                 { return nil }
+func (p *Plugin) Dependencies() []plugin.Dependency              { return nil }
+
+func (p *Plugin) Compatibility() plugin.Compatibility {
+	return plugin.Compatibility{
+		MinVPSInitVersion: "{{.HostVersion}}",
+		Platforms:         []string{"linux/amd64", "linux/arm64", "darwin/amd64", "darwin/arm64"},
+	}
+}
+
+func (p *Plugin) GetMetadata() plugin.PluginMetadata {
+	return plugin.PluginMetadata{
+		Name:        p.Name(),
+		Description: p.Description(),
+		Version:     p.Version(),
+		Author:      p.Author(),
+		License:     "MIT",
+		Tags:        []string{"{{.Name}}"},
+	}
+}
+
+func (p *Plugin) GetCommands() []plugin.Command {
+	return []plugin.Command{
+		{
+			Name:        "install",
+			Description: "Install {{.Title}}",
+			Handler:     p.install,
+		},
+		{
+			Name:        "status",
+			Description: "Check {{.Title}} service status",
+			Handler:     p.status,
+		},
+	}
+}
+
+func (p *Plugin) install(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+
+	fmt.Println("📦 Installing {{.Title}}...")
+	if err := c.Install("{{.Name}}"); err != nil {
+		return err
+	}
+	c.Success("{{.Title}} installed successfully!")
+	return nil
+}
+
+func (p *Plugin) status(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+	if !c.ServiceActive("{{.Name}}") {
+		fmt.Println("❌ {{.Title}} is not running")
+		return nil
+	}
+	c.Success("{{.Title}} is running")
+	return nil
+}
+
+func main() {
+	if err := plugin.Serve(&Plugin{}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`
+
+const externalTestTemplate = `package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/plugintest"
+	"github.com/wasilwamark/vps-init/pkg/version"
+)
+
+func TestValidatePlugin(t *testing.T) {
+	if errs := plugin.NewValidator(version.Version).ValidatePlugin(&Plugin{}); len(errs) > 0 {
+		t.Fatal(errs.Error())
+	}
+}
+
+func TestInstall(t *testing.T) {
+	conn := plugintest.NewConnection()
+	p := &Plugin{}
+
+	if err := p.install(context.Background(), conn, nil, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if !conn.Ran("apt-get install -y {{.Name}}") {
+		t.Errorf("{{.Name}} was not installed; ran %q", conn.Commands())
+	}
+}
+
+func TestStatusNotRunning(t *testing.T) {
+	conn := plugintest.NewConnection()
+	conn.Fail("systemctl is-active", "inactive")
+	p := &Plugin{}
+
+	if err := p.status(context.Background(), conn, nil, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if !conn.Ran("systemctl is-active --quiet {{.Name}}") {
+		t.Errorf("status was not checked; ran %q", conn.Commands())
+	}
+}
+`
+
+const manifestTemplate = `name: {{.Name}}
+version: 0.1.0
+description: {{printf "%q" .Description}}
+author: {{printf "%q" .Author}}
+license: MIT
+{{- if .Wasm}}
+runtime: wasm
+executable: {{.Name}}.wasm
+build: GOOS=wasip1 GOARCH=wasm go build -o {{.Name}}.wasm .
+# Local access the sandbox should allow; the user approves it at install time
+# permissions:
+#   filesystem: ["~/.config/{{.Name}}"]
+#   network: true
+{{- else}}
+executable: bin/{{.Name}}
+build: go build -o bin/{{.Name}} .
+{{- end}}
+`
+
+const docsTemplate = `# {{.Title}} Plugin
+
+{{.Description}}.
+
+## Usage
+
+{{fence}}bash
+vps-init <target> {{.Name}} <command>
+{{fence}}
+
+## Commands
+
+### install
+
+Install {{.Title}} with the server's package manager.
+
+{{fence}}bash
+vps-init myserver {{.Name}} install
+{{fence}}
+
+### status
+
+Check whether the {{.Title}} service is running.
+
+{{fence}}bash
+vps-init myserver {{.Name}} status
+{{fence}}
+`
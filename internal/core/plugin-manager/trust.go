@@ -0,0 +1,202 @@
+package pluginmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wasilwamark/vps-init/internal/config"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// loadTrustStore reads the publisher keys and policy from ~/.vps-init/trust.json
+func loadTrustStore() (*plugin.TrustStore, error) {
+	return plugin.LoadTrustStore(filepath.Join(config.Dir(), "trust.json"))
+}
+
+// trustLevel verifies an installed manifest's signature for display
+func trustLevel(m *plugin.Manifest) string {
+	trust, err := loadTrustStore()
+	if err != nil {
+		return plugin.TrustUntrusted
+	}
+	level, err := trust.Verify(m)
+	if err != nil {
+		return "invalid signature"
+	}
+	return level
+}
+
+func (p *Plugin) trustCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trust",
+		Short: "Manage trusted plugin publisher keys",
+		Long: `Manage the local trust store (~/.vps-init/trust.json).
+
+External plugins signed with a trusted ed25519 key get that key's trust level.
+Unsigned plugins, and plugins signed by unknown keys, are untrusted; the policy
+decides whether they are refused, need confirmation before each run, or are
+allowed. Verification never uses the network.
+
+Examples:
+  vps-init plugin trust add acme ed25519:3q2+7w... --level community
+  vps-init plugin trust list
+  vps-init plugin trust remove acme
+  vps-init plugin trust policy refuse`,
+	}
+
+	addCmd := &cobra.Command{
+		Use:   "add <name> <ed25519:public-key>",
+		Short: "Trust a publisher key",
+		Args:  cobra.ExactArgs(2),
+		RunE:  p.runTrustAdd,
+	}
+	addCmd.Flags().String("level", plugin.TrustVerified, "Trust level for plugins signed with this key (official, verified, community)")
+	cmd.AddCommand(addCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove <name|key-id>",
+		Short: "Stop trusting a publisher key",
+		Args:  cobra.ExactArgs(1),
+		RunE:  p.runTrustRemove,
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List trusted keys and the policy for untrusted plugins",
+		RunE:  p.runTrustList,
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "policy <refuse|confirm|allow>",
+		Short: "Set what happens with untrusted plugins",
+		Args:  cobra.ExactArgs(1),
+		RunE:  p.runTrustPolicy,
+	})
+
+	return cmd
+}
+
+func (p *Plugin) runTrustAdd(cmd *cobra.Command, args []string) error {
+	level, _ := cmd.Flags().GetString("level")
+
+	trust, err := loadTrustStore()
+	if err != nil {
+		return err
+	}
+	if err := trust.Add(args[0], args[1], level); err != nil {
+		return err
+	}
+	if err := trust.Save(); err != nil {
+		return fmt.Errorf("failed to save trust store: %w", err)
+	}
+
+	pub, _ := plugin.ParsePublicKey(args[1])
+	fmt.Printf("✅ Trusted key %s (%s) at level %s\n", args[0], plugin.KeyID(pub), level)
+	return nil
+}
+
+func (p *Plugin) runTrustRemove(cmd *cobra.Command, args []string) error {
+	trust, err := loadTrustStore()
+	if err != nil {
+		return err
+	}
+	if !trust.Remove(args[0]) {
+		return fmt.Errorf("no trusted key named '%s'", args[0])
+	}
+	if err := trust.Save(); err != nil {
+		return fmt.Errorf("failed to save trust store: %w", err)
+	}
+	fmt.Printf("✅ Removed trusted key %s\n", args[0])
+	return nil
+}
+
+func (p *Plugin) runTrustList(cmd *cobra.Command, args []string) error {
+	trust, err := loadTrustStore()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Policy for untrusted plugins: %s\n", trust.Policy)
+	if len(trust.Keys) == 0 {
+		fmt.Println("No trusted keys. Use 'vps-init plugin trust add <name> <ed25519:key>' to add one.")
+		return nil
+	}
+
+	fmt.Println("Trusted keys:")
+	for _, k := range trust.Keys {
+		fmt.Printf("  %s [%s] %s\n", k.Name, k.ID(), k.Level)
+		fmt.Printf("    key:   %s\n", k.Key)
+		fmt.Printf("    added: %s\n", k.AddedAt)
+	}
+	return nil
+}
+
+func (p *Plugin) runTrustPolicy(cmd *cobra.Command, args []string) error {
+	trust, err := loadTrustStore()
+	if err != nil {
+		return err
+	}
+	if err := trust.SetPolicy(args[0]); err != nil {
+		return err
+	}
+	if err := trust.Save(); err != nil {
+		return fmt.Errorf("failed to save trust store: %w", err)
+	}
+	fmt.Printf("✅ Untrusted plugins policy set to %s\n", args[0])
+	return nil
+}
+
+func (p *Plugin) runKeygen(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	pub, priv, err := plugin.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(priv+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	fmt.Printf("✅ Private key written to %s (keep it secret)\n", path)
+	fmt.Printf("Public key: %s\n", pub)
+	fmt.Printf("Users trust it with: vps-init plugin trust add <name> %s\n", pub)
+	return nil
+}
+
+func (p *Plugin) runSign(cmd *cobra.Command, args []string) error {
+	keyPath, _ := cmd.Flags().GetString("key")
+	if keyPath == "" {
+		return fmt.Errorf("--key is required")
+	}
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+	priv, err := plugin.ParsePrivateKey(string(keyData))
+	if err != nil {
+		return err
+	}
+
+	manifestPath := filepath.Join(args[0], plugin.ManifestFile)
+	m, err := plugin.LoadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	m.Checksum, err = plugin.FileChecksum(filepath.Join(args[0], m.Executable))
+	if err != nil {
+		return fmt.Errorf("plugin executable not found, build it first: %w", err)
+	}
+	plugin.SignManifest(m, priv)
+	if err := m.Save(manifestPath); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Signed %s %s (%s)\n", m.Name, m.Version, m.Checksum)
+	return nil
+}
@@ -1,7 +1,9 @@
 package distro
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -13,6 +15,7 @@ const (
 	PackageManagerYUM    PackageManager = "yum"
 	PackageManagerPacman PackageManager = "pacman"
 	PackageManagerAPK    PackageManager = "apk"
+	PackageManagerZypper PackageManager = "zypper"
 )
 
 type ServiceManager string
@@ -30,8 +33,13 @@ const (
 	DistroFamilyRedHat DistroFamily = "redhat"
 	DistroFamilyArch   DistroFamily = "arch"
 	DistroFamilyAlpine DistroFamily = "alpine"
+	DistroFamilySUSE   DistroFamily = "suse"
 )
 
+// ErrUnsupported is returned for distributions vps-init does not know how to
+// manage
+var ErrUnsupported = errors.New("unsupported distro")
+
 type DistroInfo struct {
 	ID         string
 	IDLike     string
@@ -89,6 +97,9 @@ func DetectOSRelease(content string) (*OSRelease, error) {
 	return release, nil
 }
 
+// GetDistroInfo classifies a distribution by its ID, then by ID_LIKE.
+// Distributions that match no family are returned without a package manager;
+// Check reports them as unsupported.
 func GetDistroInfo(osRelease *OSRelease) *DistroInfo {
 	info := &DistroInfo{
 		ID:         osRelease.ID,
@@ -99,24 +110,23 @@ func GetDistroInfo(osRelease *OSRelease) *DistroInfo {
 		IDLikeList: parseIDLike(osRelease.IDLike),
 	}
 
-	idLikes := info.IDLikeList
-	idLikes = append(idLikes, osRelease.ID)
+	ids := append([]string{osRelease.ID}, info.IDLikeList...)
 
-	for _, id := range idLikes {
+	for _, id := range ids {
 		switch {
 		case id == "ubuntu" || id == "debian":
 			info.Family = DistroFamilyDebian
 			info.PackageMgr = PackageManagerAPT
 			info.ServiceMgr = ServiceManagerSystemd
 			return info
-		case id == "rhel" || id == "centos" || id == "fedora":
+		case id == "rhel" || id == "centos" || id == "fedora" || id == "rocky" || id == "almalinux" || id == "ol" || id == "amzn":
 			info.Family = DistroFamilyRedHat
-			if id == "fedora" || (info.VersionID != "" && strings.HasPrefix(info.VersionID, "8") || strings.HasPrefix(info.VersionID, "9")) {
-				info.PackageMgr = PackageManagerDNF
-			} else {
-				info.PackageMgr = PackageManagerYUM
-			}
+			info.PackageMgr = redHatPackageManager(osRelease.ID, info.IDLikeList, info.MajorVersion())
 			info.ServiceMgr = ServiceManagerSystemd
+			if major := info.MajorVersion(); major > 0 && major < 7 && osRelease.ID != "fedora" && osRelease.ID != "amzn" {
+				// RHEL and CentOS 6 predate systemd
+				info.ServiceMgr = ServiceManagerInitD
+			}
 			return info
 		case id == "arch" || id == "archarm":
 			info.Family = DistroFamilyArch
@@ -128,15 +138,70 @@ func GetDistroInfo(osRelease *OSRelease) *DistroInfo {
 			info.PackageMgr = PackageManagerAPK
 			info.ServiceMgr = ServiceManagerOpenRC
 			return info
+		case id == "suse" || id == "sles" || strings.HasPrefix(id, "opensuse"):
+			info.Family = DistroFamilySUSE
+			info.PackageMgr = PackageManagerZypper
+			info.ServiceMgr = ServiceManagerSystemd
+			return info
 		}
 	}
 
-	info.Family = DistroFamilyDebian
-	info.PackageMgr = PackageManagerAPT
-	info.ServiceMgr = ServiceManagerSystemd
 	return info
 }
 
+// redHatPackageManager picks dnf or yum. Fedora and Amazon Linux 2023 use
+// dnf, Amazon Linux 2 uses yum, and RHEL and its rebuilds switched to dnf
+// with version 8.
+func redHatPackageManager(id string, idLike []string, major int) PackageManager {
+	switch {
+	case id == "fedora":
+		return PackageManagerDNF
+	case id == "amzn":
+		if major >= 2022 {
+			return PackageManagerDNF
+		}
+		return PackageManagerYUM
+	case contains(idLike, "fedora") && !contains(idLike, "rhel") && !contains(idLike, "centos"):
+		// Fedora derivatives
+		return PackageManagerDNF
+	case major >= 8:
+		return PackageManagerDNF
+	default:
+		return PackageManagerYUM
+	}
+}
+
+// MajorVersion returns the major part of VERSION_ID, or 0 if it is not a number
+func (d *DistroInfo) MajorVersion() int {
+	major, _, _ := strings.Cut(d.VersionID, ".")
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Supported reports whether vps-init knows how to manage the distribution
+func (d *DistroInfo) Supported() bool {
+	return d.PackageMgr != ""
+}
+
+// Check returns an ErrUnsupported error for distributions vps-init does not
+// know how to manage
+func (d *DistroInfo) Check() error {
+	if d.Supported() {
+		return nil
+	}
+	name := d.Name
+	if name == "" {
+		name = d.ID
+	}
+	if d.IDLike != "" {
+		return fmt.Errorf("%w: %s (ID=%s, ID_LIKE=%s)", ErrUnsupported, name, d.ID, d.IDLike)
+	}
+	return fmt.Errorf("%w: %s (ID=%s)", ErrUnsupported, name, d.ID)
+}
+
 func parseIDLike(idLike string) []string {
 	if idLike == "" {
 		return []string{}
@@ -180,6 +245,22 @@ func (d *DistroInfo) IsAlpine() bool {
 	return d.ID == "alpine"
 }
 
+func (d *DistroInfo) IsRocky() bool {
+	return d.ID == "rocky"
+}
+
+func (d *DistroInfo) IsAlma() bool {
+	return d.ID == "almalinux"
+}
+
+func (d *DistroInfo) IsAmazonLinux() bool {
+	return d.ID == "amzn"
+}
+
+func (d *DistroInfo) IsSUSE() bool {
+	return d.Family == DistroFamilySUSE
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
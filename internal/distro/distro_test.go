@@ -0,0 +1,170 @@
+package distro
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetDistroInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		osRelease  string
+		family     DistroFamily
+		packageMgr PackageManager
+		serviceMgr ServiceManager
+	}{
+		{
+			name: "ubuntu 22.04",
+			osRelease: `PRETTY_NAME="Ubuntu 22.04.4 LTS"
+NAME="Ubuntu"
+VERSION_ID="22.04"
+VERSION="22.04.4 LTS (Jammy Jellyfish)"
+VERSION_CODENAME=jammy
+ID=ubuntu
+ID_LIKE=debian
+HOME_URL="https://www.ubuntu.com/"
+`,
+			family: DistroFamilyDebian, packageMgr: PackageManagerAPT, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "debian 12",
+			osRelease: `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"
+NAME="Debian GNU/Linux"
+VERSION_ID="12"
+VERSION="12 (bookworm)"
+VERSION_CODENAME=bookworm
+ID=debian
+`,
+			family: DistroFamilyDebian, packageMgr: PackageManagerAPT, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "linux mint by ID_LIKE",
+			osRelease: `NAME="Linux Mint"
+VERSION="21.3 (Virginia)"
+ID=linuxmint
+ID_LIKE="ubuntu debian"
+VERSION_ID="21.3"
+`,
+			family: DistroFamilyDebian, packageMgr: PackageManagerAPT, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "rocky 9",
+			osRelease: `NAME="Rocky Linux"
+VERSION="9.4 (Blue Onyx)"
+ID="rocky"
+ID_LIKE="rhel centos fedora"
+VERSION_ID="9.4"
+PLATFORM_ID="platform:el9"
+`,
+			family: DistroFamilyRedHat, packageMgr: PackageManagerDNF, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "centos 7",
+			osRelease: `NAME="CentOS Linux"
+VERSION="7 (Core)"
+ID="centos"
+ID_LIKE="rhel fedora"
+VERSION_ID="7"
+`,
+			family: DistroFamilyRedHat, packageMgr: PackageManagerYUM, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "centos 6",
+			osRelease: `NAME="CentOS Linux"
+ID="centos"
+ID_LIKE="rhel fedora"
+VERSION_ID="6"
+`,
+			family: DistroFamilyRedHat, packageMgr: PackageManagerYUM, serviceMgr: ServiceManagerInitD,
+		},
+		{
+			name: "fedora 40",
+			osRelease: `NAME="Fedora Linux"
+VERSION="40 (Server Edition)"
+ID=fedora
+VERSION_ID=40
+`,
+			family: DistroFamilyRedHat, packageMgr: PackageManagerDNF, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "amazon linux 2",
+			osRelease: `NAME="Amazon Linux"
+VERSION="2"
+ID="amzn"
+ID_LIKE="centos rhel fedora"
+VERSION_ID="2"
+`,
+			family: DistroFamilyRedHat, packageMgr: PackageManagerYUM, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "amazon linux 2023",
+			osRelease: `NAME="Amazon Linux"
+VERSION="2023"
+ID="amzn"
+ID_LIKE="fedora"
+VERSION_ID="2023"
+`,
+			family: DistroFamilyRedHat, packageMgr: PackageManagerDNF, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "arch",
+			osRelease: `NAME="Arch Linux"
+PRETTY_NAME="Arch Linux"
+ID=arch
+BUILD_ID=rolling
+`,
+			family: DistroFamilyArch, packageMgr: PackageManagerPacman, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "alpine 3.19",
+			osRelease: `NAME="Alpine Linux"
+ID=alpine
+VERSION_ID=3.19.1
+PRETTY_NAME="Alpine Linux v3.19"
+`,
+			family: DistroFamilyAlpine, packageMgr: PackageManagerAPK, serviceMgr: ServiceManagerOpenRC,
+		},
+		{
+			name: "opensuse leap 15.5",
+			osRelease: `NAME="openSUSE Leap"
+VERSION="15.5"
+ID="opensuse-leap"
+ID_LIKE="suse opensuse"
+VERSION_ID="15.5"
+`,
+			family: DistroFamilySUSE, packageMgr: PackageManagerZypper, serviceMgr: ServiceManagerSystemd,
+		},
+		{
+			name: "void is unsupported",
+			osRelease: `NAME="Void"
+ID="void"
+PRETTY_NAME="Void Linux"
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release, err := DetectOSRelease(tt.osRelease)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := GetDistroInfo(release)
+			if info.Family != tt.family || info.PackageMgr != tt.packageMgr || info.ServiceMgr != tt.serviceMgr {
+				t.Errorf("GetDistroInfo() = %s/%s/%s, want %s/%s/%s",
+					info.Family, info.PackageMgr, info.ServiceMgr, tt.family, tt.packageMgr, tt.serviceMgr)
+			}
+			if supported := tt.packageMgr != ""; info.Supported() != supported {
+				t.Errorf("Supported() = %v, want %v", info.Supported(), supported)
+			}
+			if err := info.Check(); (err != nil) == info.Supported() || (err != nil && !errors.Is(err, ErrUnsupported)) {
+				t.Errorf("Check() = %v", err)
+			}
+		})
+	}
+}
+
+func TestDetectOSReleaseWithoutID(t *testing.T) {
+	if _, err := DetectOSRelease("NAME=\"Something\"\n"); err == nil {
+		t.Error("DetectOSRelease() accepted a file without ID")
+	}
+}
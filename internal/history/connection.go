@@ -0,0 +1,257 @@
+package history
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// Connection wraps a plugin.Connection and records every remote command and
+// file change on the entry. Read-only helpers (FileExists, ListDirectory, ...)
+// pass through unrecorded.
+type Connection struct {
+	plugin.Connection
+	entry   *Entry
+	secrets []string
+	mu      sync.Mutex
+}
+
+// Wrap returns a connection that records operations on entry. Any occurrence of
+// the given secrets in commands or output is redacted before it is stored.
+func Wrap(conn plugin.Connection, entry *Entry, secrets ...string) *Connection {
+	var nonEmpty []string
+	for _, s := range secrets {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return &Connection{Connection: conn, entry: entry, secrets: nonEmpty}
+}
+
+func (c *Connection) redact(s string) string {
+	for _, secret := range c.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+func (c *Connection) record(op Operation) {
+	op.Command = c.redact(op.Command)
+	op.Result.Output = c.redact(op.Result.Output)
+	op.Result.Stdout = c.redact(op.Result.Stdout)
+	op.Result.Stderr = c.redact(op.Result.Stderr)
+	op.Result.Error = c.redact(op.Result.Error)
+
+	c.mu.Lock()
+	c.entry.Operations = append(c.entry.Operations, op)
+	c.mu.Unlock()
+}
+
+// errorResult converts the error of a file operation into a Result
+func errorResult(err error, start time.Time) plugin.Result {
+	result := plugin.Result{
+		Success:   err == nil,
+		Duration:  time.Since(start).String(),
+		Timestamp: start.Format(time.RFC3339),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.ExitCode = 1
+	}
+	return result
+}
+
+func (c *Connection) recordErr(kind, command, path string, err error, start time.Time) {
+	c.record(Operation{Kind: kind, Command: command, Path: path, Result: errorResult(err, start)})
+}
+
+// commandKind is "sudo" for commands that run as root, including every
+// command of a root login, which needs no sudo, and "command" otherwise
+func (c *Connection) commandKind(sudo bool) string {
+	if sudo || c.Connection.User() == "root" {
+		return "sudo"
+	}
+	return "command"
+}
+
+func (c *Connection) RunCommand(cmd string, sudo bool) plugin.Result {
+	result := c.Connection.RunCommand(cmd, sudo)
+	kind := c.commandKind(sudo)
+	c.record(Operation{Kind: kind, Command: cmd, Result: result})
+	return result
+}
+
+func (c *Connection) RunCommandWithOutput(cmd string, sudo bool) (string, error) {
+	start := time.Now()
+	output, err := c.Connection.RunCommandWithOutput(cmd, sudo)
+	kind := c.commandKind(sudo)
+	result := errorResult(err, start)
+	result.Stdout, result.Output = output, output
+	c.record(Operation{Kind: kind, Command: cmd, Result: result})
+	return output, err
+}
+
+func (c *Connection) RunSudo(cmd, password string) plugin.Result {
+	result := c.Connection.RunSudo(cmd, password)
+	c.record(Operation{Kind: "sudo", Command: cmd, Result: result})
+	return result
+}
+
+// contextRunner is implemented by connections that can kill a running
+// command when its context is done
+type contextRunner interface {
+	RunCommandContext(ctx context.Context, cmd string, sudo bool) plugin.Result
+	RunSudoContext(ctx context.Context, cmd, password string) plugin.Result
+}
+
+// RunCommandContext runs cmd through the wrapped connection's context variant
+// when it has one, so a timeout still kills the command once it is recorded
+func (c *Connection) RunCommandContext(ctx context.Context, cmd string, sudo bool) plugin.Result {
+	var result plugin.Result
+	if runner, ok := c.Connection.(contextRunner); ok {
+		result = runner.RunCommandContext(ctx, cmd, sudo)
+	} else {
+		result = c.Connection.RunCommand(cmd, sudo)
+	}
+	kind := c.commandKind(sudo)
+	c.record(Operation{Kind: kind, Command: cmd, Result: result})
+	return result
+}
+
+// RunSudoContext is RunSudo with the context passed on like RunCommandContext
+func (c *Connection) RunSudoContext(ctx context.Context, cmd, password string) plugin.Result {
+	var result plugin.Result
+	if runner, ok := c.Connection.(contextRunner); ok {
+		result = runner.RunSudoContext(ctx, cmd, password)
+	} else {
+		result = c.Connection.RunSudo(cmd, password)
+	}
+	c.record(Operation{Kind: "sudo", Command: cmd, Result: result})
+	return result
+}
+
+func (c *Connection) RunInteractive(cmd string) error {
+	start := time.Now()
+	err := c.Connection.RunInteractive(cmd)
+	c.recordErr("interactive", cmd, "", err, start)
+	return err
+}
+
+func (c *Connection) WriteFile(content, path string) error {
+	start := time.Now()
+	err := c.Connection.WriteFile(content, path)
+	sum := sha256.Sum256([]byte(content))
+	c.record(Operation{
+		Kind:   "write",
+		Path:   path,
+		Bytes:  len(content),
+		SHA256: hex.EncodeToString(sum[:]),
+		Result: errorResult(err, start),
+	})
+	return err
+}
+
+func (c *Connection) AppendFile(content, path string) error {
+	start := time.Now()
+	err := c.Connection.AppendFile(content, path)
+	sum := sha256.Sum256([]byte(content))
+	c.record(Operation{
+		Kind:   "append",
+		Path:   path,
+		Bytes:  len(content),
+		SHA256: hex.EncodeToString(sum[:]),
+		Result: errorResult(err, start),
+	})
+	return err
+}
+
+func (c *Connection) UploadFile(localPath, remotePath string) error {
+	start := time.Now()
+	err := c.Connection.UploadFile(localPath, remotePath)
+	c.recordErr("upload", "from "+localPath, remotePath, err, start)
+	return err
+}
+
+func (c *Connection) WriteFileFromLocal(localPath, remotePath string) error {
+	start := time.Now()
+	err := c.Connection.WriteFileFromLocal(localPath, remotePath)
+	c.recordErr("upload", "from "+localPath, remotePath, err, start)
+	return err
+}
+
+func (c *Connection) CopyFile(src, dst string) error {
+	start := time.Now()
+	err := c.Connection.CopyFile(src, dst)
+	c.recordErr("copy", "from "+src, dst, err, start)
+	return err
+}
+
+func (c *Connection) MoveFile(src, dst string) error {
+	start := time.Now()
+	err := c.Connection.MoveFile(src, dst)
+	c.recordErr("move", "from "+src, dst, err, start)
+	return err
+}
+
+func (c *Connection) DeleteFile(path string) error {
+	start := time.Now()
+	err := c.Connection.DeleteFile(path)
+	c.recordErr("delete", "", path, err, start)
+	return err
+}
+
+func (c *Connection) CreateDirectory(path string) error {
+	start := time.Now()
+	err := c.Connection.CreateDirectory(path)
+	c.recordErr("mkdir", "", path, err, start)
+	return err
+}
+
+func (c *Connection) RemoveDirectory(path string, recursive bool) error {
+	start := time.Now()
+	err := c.Connection.RemoveDirectory(path, recursive)
+	c.recordErr("rmdir", "", path, err, start)
+	return err
+}
+
+func (c *Connection) ChangePermissions(path, permissions string) error {
+	start := time.Now()
+	err := c.Connection.ChangePermissions(path, permissions)
+	c.recordErr("chmod", permissions, path, err, start)
+	return err
+}
+
+func (c *Connection) ChangeOwner(path, user, group string) error {
+	start := time.Now()
+	err := c.Connection.ChangeOwner(path, user, group)
+	c.recordErr("chown", fmt.Sprintf("%s:%s", user, group), path, err, start)
+	return err
+}
+
+func (c *Connection) Systemctl(action, service string) bool {
+	start := time.Now()
+	ok := c.Connection.Systemctl(action, service)
+	var err error
+	if !ok {
+		err = fmt.Errorf("systemctl %s %s failed", action, service)
+	}
+	c.recordErr("sudo", fmt.Sprintf("systemctl %s %s", action, service), "", err, start)
+	return ok
+}
+
+func (c *Connection) InstallPackage(packageName string) bool {
+	start := time.Now()
+	ok := c.Connection.InstallPackage(packageName)
+	var err error
+	if !ok {
+		err = fmt.Errorf("failed to install %s", packageName)
+	}
+	c.recordErr("sudo", "install "+packageName, "", err, start)
+	return ok
+}
@@ -0,0 +1,91 @@
+package history
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// fakeConn records which of its run methods were called. Methods the tests
+// do not use panic through the nil embedded interface.
+type fakeConn struct {
+	plugin.Connection
+	calls []string
+}
+
+func (f *fakeConn) User() string { return "deploy" }
+
+func (f *fakeConn) RunCommand(cmd string, sudo bool) plugin.Result {
+	f.calls = append(f.calls, "RunCommand")
+	return plugin.Result{Success: true}
+}
+
+func (f *fakeConn) RunSudo(cmd, password string) plugin.Result {
+	f.calls = append(f.calls, "RunSudo")
+	return plugin.Result{Success: true}
+}
+
+// fakeContextConn is a fakeConn that can also cancel commands
+type fakeContextConn struct {
+	fakeConn
+}
+
+func (f *fakeContextConn) RunCommandContext(ctx context.Context, cmd string, sudo bool) plugin.Result {
+	f.calls = append(f.calls, "RunCommandContext")
+	return plugin.Result{Success: true}
+}
+
+func (f *fakeContextConn) RunSudoContext(ctx context.Context, cmd, password string) plugin.Result {
+	f.calls = append(f.calls, "RunSudoContext")
+	return plugin.Result{Success: true}
+}
+
+func TestConnectionForwardsContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		context bool
+		sudo    bool
+		call    string
+		kind    string
+	}{
+		{name: "command", context: true, call: "RunCommandContext", kind: "command"},
+		{name: "sudo", context: true, sudo: true, call: "RunSudoContext", kind: "sudo"},
+		{name: "command without context support", call: "RunCommand", kind: "command"},
+		{name: "sudo without context support", sudo: true, call: "RunSudo", kind: "sudo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				conn  plugin.Connection
+				calls func() []string
+			)
+			if tt.context {
+				fake := &fakeContextConn{}
+				conn, calls = fake, func() []string { return fake.calls }
+			} else {
+				fake := &fakeConn{}
+				conn, calls = fake, func() []string { return fake.calls }
+			}
+
+			entry := &Entry{}
+			wrapped := Wrap(conn, entry, "hunter2")
+			if tt.sudo {
+				wrapped.RunSudoContext(context.Background(), "echo hunter2", "hunter2")
+			} else {
+				wrapped.RunCommandContext(context.Background(), "echo hunter2", false)
+			}
+
+			if got := calls(); len(got) != 1 || got[0] != tt.call {
+				t.Errorf("calls = %v, want [%s]", got, tt.call)
+			}
+			if len(entry.Operations) != 1 {
+				t.Fatalf("recorded %d operations, want 1", len(entry.Operations))
+			}
+			op := entry.Operations[0]
+			if op.Kind != tt.kind || op.Command != "echo "+redacted {
+				t.Errorf("recorded %s %q, want %s %q", op.Kind, op.Command, tt.kind, "echo "+redacted)
+			}
+		})
+	}
+}
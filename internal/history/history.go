@@ -0,0 +1,277 @@
+package history
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wasilwamark/vps-init/internal/config"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+const redacted = "****"
+
+// Entry is one vps-init invocation against a target
+type Entry struct {
+	ID         string      `json:"id"`
+	Target     string      `json:"target"`
+	Plugin     string      `json:"plugin,omitempty"`
+	Command    string      `json:"command"`
+	Args       []string    `json:"args,omitempty"`
+	Operator   string      `json:"operator"`
+	StartedAt  time.Time   `json:"started_at"`
+	EndedAt    time.Time   `json:"ended_at"`
+	Status     string      `json:"status"` // success or failed
+	Error      string      `json:"error,omitempty"`
+	Operations []Operation `json:"operations,omitempty"`
+}
+
+// Operation is a single remote command or file write performed during an invocation
+type Operation struct {
+	Kind    string        `json:"kind"` // command, sudo, interactive, write, append, upload, ...
+	Command string        `json:"command,omitempty"`
+	Path    string        `json:"path,omitempty"`
+	Bytes   int           `json:"bytes,omitempty"`
+	SHA256  string        `json:"sha256,omitempty"`
+	Result  plugin.Result `json:"result"`
+}
+
+// Duration returns how long the invocation took
+func (e *Entry) Duration() time.Duration {
+	return e.EndedAt.Sub(e.StartedAt)
+}
+
+// NewEntry starts a history entry for an invocation. Secret values in args are redacted.
+func NewEntry(target, pluginName, command string, args []string) *Entry {
+	operator := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		operator = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		operator = fmt.Sprintf("%s@%s", operator, host)
+	}
+
+	return &Entry{
+		ID:        newID(),
+		Target:    target,
+		Plugin:    pluginName,
+		Command:   command,
+		Args:      RedactArgs(args),
+		Operator:  operator,
+		StartedAt: time.Now(),
+	}
+}
+
+// Finish marks the entry as completed with the handler error, if any
+func (e *Entry) Finish(err error) {
+	e.EndedAt = time.Now()
+	e.Status = "success"
+	if err != nil {
+		e.Status = "failed"
+		e.Error = err.Error()
+	}
+}
+
+// Dir returns the history directory (~/.vps-init/history)
+func Dir() string {
+	return filepath.Join(config.Dir(), "history")
+}
+
+// Append writes the entry to the journal of the month it started in.
+// Journals are append-only JSON lines files named YYYY-MM.jsonl.
+func Append(e *Entry) error {
+	if err := os.MkdirAll(Dir(), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(Dir(), e.StartedAt.Format("2006-01")+".jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Filter selects history entries
+type Filter struct {
+	Host  string    // matches the target host or user@host
+	Since time.Time // zero means no lower bound
+}
+
+// List returns entries matching the filter, oldest first
+func List(filter Filter) ([]*Entry, error) {
+	files, err := filepath.Glob(filepath.Join(Dir(), "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var entries []*Entry
+	for _, file := range files {
+		// Skip journals that end before the requested window
+		if !filter.Since.IsZero() {
+			month, err := time.Parse("2006-01", strings.TrimSuffix(filepath.Base(file), ".jsonl"))
+			if err == nil && month.AddDate(0, 1, 0).Before(filter.Since) {
+				continue
+			}
+		}
+
+		fileEntries, err := readJournal(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range fileEntries {
+			if filter.matches(e) {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedAt.Before(entries[j].StartedAt)
+	})
+	return entries, nil
+}
+
+// Get returns the entry with the given ID (or unique ID prefix)
+func Get(id string) (*Entry, error) {
+	entries, err := List(Filter{})
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Entry
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+		if strings.HasPrefix(e.ID, id) {
+			if found != nil {
+				return nil, fmt.Errorf("history id '%s' is ambiguous", id)
+			}
+			found = e
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("history entry '%s' not found", id)
+	}
+	return found, nil
+}
+
+func (f Filter) matches(e *Entry) bool {
+	if !f.Since.IsZero() && e.StartedAt.Before(f.Since) {
+		return false
+	}
+	if f.Host != "" {
+		host := e.Target
+		if i := strings.Index(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+		if f.Host != e.Target && f.Host != host {
+			return false
+		}
+	}
+	return true
+}
+
+func readJournal(path string) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			// A truncated last line must not hide the rest of the history
+			continue
+		}
+		entries = append(entries, &e)
+	}
+	return entries, scanner.Err()
+}
+
+// ParseSince parses a relative window such as 30m, 12h, 2d or 1w
+func ParseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	unit := s[len(s)-1]
+	var n int
+	if _, err := fmt.Sscanf(s[:len(s)-1], "%d", &n); err == nil {
+		switch unit {
+		case 'd':
+			return time.Now().AddDate(0, 0, -n), nil
+		case 'w':
+			return time.Now().AddDate(0, 0, -7*n), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since value '%s' (use e.g. 30m, 12h, 2d, 1w)", s)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// secretArg matches flag names whose values must never be written to history
+var secretArg = regexp.MustCompile(`(?i)(pass|secret|token|key)`)
+
+// RedactArgs replaces the values of secret-looking flags, in both
+// "--flag value" and "--flag=value" form
+func RedactArgs(args []string) []string {
+	out := make([]string, len(args))
+	redactNext := false
+	for i, arg := range args {
+		switch {
+		case redactNext:
+			out[i] = redacted
+			redactNext = false
+		case strings.HasPrefix(arg, "-") && strings.Contains(arg, "="):
+			name, _, _ := strings.Cut(arg, "=")
+			if secretArg.MatchString(name) {
+				out[i] = name + "=" + redacted
+			} else {
+				out[i] = arg
+			}
+		case strings.HasPrefix(arg, "-") && secretArg.MatchString(arg):
+			out[i] = arg
+			redactNext = true
+		default:
+			out[i] = arg
+		}
+	}
+	return out
+}
+
+func newID() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,272 @@
+package history
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/version"
+)
+
+// ChangeLogPath is the audit log kept on every managed server
+const ChangeLogPath = "/var/log/vps-init/changes.log"
+
+// Change is one line of the remote change log
+type Change struct {
+	Time     time.Time `json:"time"`
+	ID       string    `json:"id"`
+	Operator string    `json:"operator"`
+	Version  string    `json:"version"`
+	Plugin   string    `json:"plugin,omitempty"`
+	Command  string    `json:"command"`
+	Args     []string  `json:"args,omitempty"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Files    []string  `json:"files,omitempty"`
+	Services []string  `json:"services,omitempty"`
+}
+
+// fileOps are operation kinds that change the remote filesystem
+var fileOps = map[string]bool{
+	"write": true, "append": true, "upload": true, "copy": true, "move": true,
+	"delete": true, "mkdir": true, "rmdir": true, "chmod": true, "chown": true,
+}
+
+// serviceActions are systemctl/service/rc-service actions that change a service
+var serviceActions = map[string]bool{
+	"start": true, "stop": true, "restart": true, "reload": true,
+	"enable": true, "disable": true, "mask": true, "unmask": true,
+}
+
+// wrapper matches commands that run the rest of the line, such as the
+// "timeout N sh -c" exec wraps ad-hoc commands in
+var wrapper = regexp.MustCompile(`^(?:(?:sudo|nohup)\s+|timeout\s+\d+[smhd]?\s+|(?:ba)?sh\s+-c\s+)+`)
+
+// assignment matches a shell variable assignment word
+var assignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// Touched returns the remote files and services changed during the invocation.
+// Staging files under /tmp and devices such as /dev/null are left out.
+func (e *Entry) Touched() (files, services []string) {
+	fileSet := make(map[string]bool)
+	serviceSet := make(map[string]bool)
+
+	for _, op := range e.Operations {
+		if fileOps[op.Kind] && op.Path != "" {
+			fileSet[op.Path] = true
+		}
+		if op.Kind != "sudo" {
+			continue
+		}
+		for _, segment := range commandSegments(op.Command) {
+			for _, f := range segmentFiles(segment) {
+				fileSet[f] = true
+			}
+			for _, s := range segmentServices(segment) {
+				serviceSet[s] = true
+			}
+		}
+	}
+
+	for f := range fileSet {
+		if !strings.HasPrefix(f, "/tmp/") && !strings.HasPrefix(f, "/dev/") {
+			files = append(files, f)
+		}
+	}
+	for s := range serviceSet {
+		services = append(services, s)
+	}
+	sort.Strings(files)
+	sort.Strings(services)
+	return files, services
+}
+
+// Mutating reports whether the invocation may have changed anything on the
+// server. Every ad-hoc exec and every command run as root counts, as their
+// effect cannot be told from the command text.
+func (e *Entry) Mutating() bool {
+	if e.Plugin == "" && e.Command == "exec" {
+		return true
+	}
+	files, _ := e.Touched()
+	if len(files) > 0 {
+		return true
+	}
+	for _, op := range e.Operations {
+		if op.Kind == "sudo" {
+			return true
+		}
+	}
+	return false
+}
+
+// Change builds the remote change log line for the entry
+func (e *Entry) Change() Change {
+	files, services := e.Touched()
+	return Change{
+		Time:     e.StartedAt.UTC(),
+		ID:       e.ID,
+		Operator: e.Operator,
+		Version:  version.Version,
+		Plugin:   e.Plugin,
+		Command:  e.Command,
+		Args:     e.Args,
+		Status:   e.Status,
+		Error:    e.Error,
+		Files:    files,
+		Services: services,
+	}
+}
+
+// AppendRemote appends the entry to the change log on the server
+func AppendRemote(conn plugin.Connection, sudoPass string, e *Entry) error {
+	data, err := json.Marshal(e.Change())
+	if err != nil {
+		return err
+	}
+
+	// The line travels base64 encoded, so neither the shell nor printf
+	// rewrites the escapes and % signs JSON can contain
+	encoded := base64.StdEncoding.EncodeToString(append(data, '\n'))
+	cmd := fmt.Sprintf("sh -c 'mkdir -p /var/log/vps-init && echo %s | base64 -d >> %s && chmod 640 %s'", encoded, ChangeLogPath, ChangeLogPath)
+	if result := runPrivileged(conn, sudoPass, cmd); !result.Success {
+		return fmt.Errorf("failed to append to %s: %s", ChangeLogPath, result.Stderr)
+	}
+	return nil
+}
+
+// ReadRemote reads the change log from the server, oldest first
+func ReadRemote(conn plugin.Connection, sudoPass string) ([]Change, error) {
+	if !conn.FileExists(ChangeLogPath) {
+		return nil, nil
+	}
+
+	result := runPrivileged(conn, sudoPass, "cat "+ChangeLogPath)
+	if !result.Success {
+		return nil, fmt.Errorf("failed to read %s: %s", ChangeLogPath, result.Stderr)
+	}
+
+	var changes []Change
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var c Change
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// runPrivileged runs cmd with sudo, or directly when connected as root without a sudo password
+func runPrivileged(conn plugin.Connection, sudoPass, cmd string) plugin.Result {
+	if sudoPass == "" && conn.User() == "root" {
+		return conn.RunCommand(cmd, plugin.WithHideOutput())
+	}
+	return conn.RunSudo(cmd, sudoPass)
+}
+
+// commandSegments splits a shell command on && ; || and | into simple commands
+func commandSegments(cmd string) []string {
+	cmd = strings.NewReplacer("&&", "\n", "||", "\n", ";", "\n", "|", "\n").Replace(cmd)
+	var segments []string
+	for _, s := range strings.Split(cmd, "\n") {
+		s = strings.TrimSpace(s)
+		s = wrapper.ReplaceAllString(s, "")
+		s = strings.Trim(s, `'"`)
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// commandFields splits a simple command into words, dropping leading
+// variable assignments such as DEBIAN_FRONTEND=noninteractive
+func commandFields(segment string) []string {
+	fields := strings.Fields(segment)
+	for len(fields) > 0 && assignment.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+	return fields
+}
+
+// segmentFiles returns the destination paths of common file-changing commands
+func segmentFiles(segment string) []string {
+	fields := commandFields(segment)
+	if len(fields) < 2 {
+		return nil
+	}
+
+	var paths, redirected []string
+	for i, f := range fields[1:] {
+		f = strings.Trim(f, `'"`)
+		switch {
+		case (f == ">" || f == ">>") && i+2 < len(fields):
+			redirected = append(redirected, strings.Trim(fields[i+2], `'"`))
+		case strings.HasPrefix(f, ">/") || strings.HasPrefix(f, ">>/"):
+			redirected = append(redirected, strings.TrimLeft(f, ">"))
+		case strings.HasPrefix(f, "/"):
+			paths = append(paths, f)
+		}
+	}
+	if len(redirected) > 0 {
+		// Redirections to shell variables such as "$tmp" name no known file
+		var absolute []string
+		for _, r := range redirected {
+			if strings.HasPrefix(r, "/") {
+				absolute = append(absolute, r)
+			}
+		}
+		return absolute
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	switch fields[0] {
+	case "mv", "cp", "ln", "install":
+		// Only the destination changes
+		return paths[len(paths)-1:]
+	case "rm", "mkdir", "rmdir", "chmod", "chown", "touch", "tee", "truncate":
+		return paths
+	case "sed":
+		if strings.HasPrefix(fields[1], "-i") {
+			return paths[len(paths)-1:]
+		}
+	}
+	return nil
+}
+
+// segmentServices returns services changed by systemctl, service, rc-service,
+// rc-update, update-rc.d or chkconfig commands
+func segmentServices(segment string) []string {
+	fields := commandFields(segment)
+	switch {
+	case len(fields) >= 3 && fields[0] == "systemctl" && serviceActions[fields[1]]:
+		var services []string
+		for _, f := range fields[2:] {
+			if !strings.HasPrefix(f, "-") {
+				services = append(services, strings.TrimSuffix(f, ".service"))
+			}
+		}
+		return services
+	case len(fields) >= 3 && (fields[0] == "service" || fields[0] == "rc-service") && serviceActions[fields[2]]:
+		return []string{fields[1]}
+	case len(fields) >= 3 && fields[0] == "rc-update" && (fields[1] == "add" || fields[1] == "del"):
+		return []string{fields[2]}
+	case len(fields) >= 3 && fields[0] == "update-rc.d" && (fields[2] == "enable" || fields[2] == "disable" || fields[2] == "defaults" || fields[2] == "remove"):
+		return []string{fields[1]}
+	case len(fields) >= 3 && fields[0] == "chkconfig" && (fields[2] == "on" || fields[2] == "off"):
+		return []string{fields[1]}
+	}
+	return nil
+}
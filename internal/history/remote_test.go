@@ -0,0 +1,112 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTouchedAndMutating(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		ops      []Operation
+		files    []string
+		services []string
+		mutating bool
+	}{
+		{
+			name: "nginx install",
+			ops: []Operation{
+				{Kind: "command", Command: "dpkg-query -W -f='${Status}\\t${Version}\\n' nginx 2>/dev/null"},
+				{Kind: "sudo", Command: "DEBIAN_FRONTEND=noninteractive apt-get install -y nginx"},
+				{Kind: "sudo", Command: "systemctl enable --now nginx"},
+			},
+			services: []string{"nginx"},
+			mutating: true,
+		},
+		{
+			name: "package install alone",
+			ops: []Operation{
+				{Kind: "sudo", Command: "DEBIAN_FRONTEND=noninteractive apt-get install -y fail2ban"},
+			},
+			mutating: true,
+		},
+		{
+			name: "atomic file write",
+			ops: []Operation{
+				{Kind: "sudo", Command: `sh -c 'set -e; tmp=$(mktemp); trap '"'"'rm -f "$tmp"'"'"' EXIT; echo cG9ydCA2Mzc5Cg== | base64 -d > "$tmp"; mkdir -p '"'"'/etc/redis'"'"'; install -m 0644 "$tmp" '"'"'/etc/redis/local.conf.vps-init.new'"'"'; mv -f '"'"'/etc/redis/local.conf.vps-init.new'"'"' '"'"'/etc/redis/local.conf'"'"''`},
+				{Kind: "sudo", Command: "systemctl restart redis-server.service"},
+			},
+			files:    []string{"/etc/redis", "/etc/redis/local.conf", "/etc/redis/local.conf.vps-init.new"},
+			services: []string{"redis-server"},
+			mutating: true,
+		},
+		{
+			name: "connection writes, staging files left out",
+			ops: []Operation{
+				{Kind: "write", Path: "/tmp/vps-init-1718000000.tmp"},
+				{Kind: "write", Path: "/etc/wireguard/wg0.conf"},
+				{Kind: "sudo", Command: "chmod 600 /etc/wireguard/wg0.conf && wg-quick up wg0 >/dev/null"},
+			},
+			files:    []string{"/etc/wireguard/wg0.conf"},
+			mutating: true,
+		},
+		{
+			name: "openrc service",
+			ops: []Operation{
+				{Kind: "sudo", Command: "rc-update add swap boot"},
+				{Kind: "sudo", Command: "rc-service sshd reload"},
+			},
+			services: []string{"sshd", "swap"},
+			mutating: true,
+		},
+		{
+			name: "commands run as root are changes",
+			ops: []Operation{
+				{Kind: "command", Command: "cat /etc/os-release"},
+				{Kind: "sudo", Command: "ufw status"},
+			},
+			mutating: true,
+		},
+		{
+			name:    "exec wrapped in timeout",
+			command: "exec",
+			ops: []Operation{
+				{Kind: "command", Command: `timeout 31 sh -c 'crontab -r'`},
+			},
+			mutating: true,
+		},
+		{
+			name:    "exec wrapped in timeout as root",
+			command: "exec",
+			ops: []Operation{
+				{Kind: "sudo", Command: `timeout 31 sh -c 'systemctl restart nginx && echo ok > /etc/motd'`},
+			},
+			files:    []string{"/etc/motd"},
+			services: []string{"nginx"},
+			mutating: true,
+		},
+		{
+			name: "unprivileged commands are not changes",
+			ops: []Operation{
+				{Kind: "command", Command: "mkdir -p /home/deploy/app && touch /home/deploy/app/.keep"},
+			},
+			mutating: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Entry{Command: tt.command, Operations: tt.ops}
+			files, services := e.Touched()
+			if !reflect.DeepEqual(files, tt.files) {
+				t.Errorf("Touched() files = %v, want %v", files, tt.files)
+			}
+			if !reflect.DeepEqual(services, tt.services) {
+				t.Errorf("Touched() services = %v, want %v", services, tt.services)
+			}
+			if got := e.Mutating(); got != tt.mutating {
+				t.Errorf("Mutating() = %v, want %v", got, tt.mutating)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package pkgmgr
+
+import (
+	"fmt"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+)
+
+// RepoEPEL is Extra Packages for Enterprise Linux, which RHEL and its
+// rebuilds need for packages such as certbot and fail2ban
+const RepoEPEL = "epel"
+
+// nameRule gives the packages for a logical name on one family, optionally
+// only for some distribution IDs or major versions
+type nameRule struct {
+	family distro.DistroFamily
+	// ids limits the rule to these IDs; excludeIDs skips them
+	ids        []string
+	excludeIDs []string
+	// minVersion and maxVersion bound the major version; 0 means no bound
+	minVersion int
+	maxVersion int
+	packages   []string
+	repos      []string
+}
+
+func (r nameRule) matches(info *distro.DistroInfo) bool {
+	if r.family != info.Family {
+		return false
+	}
+	if len(r.ids) > 0 && !contains(r.ids, info.ID) {
+		return false
+	}
+	if contains(r.excludeIDs, info.ID) {
+		return false
+	}
+	major := info.MajorVersion()
+	if r.minVersion > 0 && major < r.minVersion {
+		return false
+	}
+	if r.maxVersion > 0 && major > r.maxVersion {
+		return false
+	}
+	return true
+}
+
+// enterpriseIDs are the RHEL-compatible distributions EPEL is built for
+var enterpriseIDs = []string{"rhel", "centos", "rocky", "almalinux", "ol"}
+
+// packageNames maps logical package names to distribution packages. The
+// first matching rule wins; families without a rule install the logical name
+// as is.
+var packageNames = map[string][]nameRule{
+	"certbot-nginx": {
+		{family: distro.DistroFamilyDebian, packages: []string{"certbot", "python3-certbot-nginx"}},
+		{family: distro.DistroFamilyRedHat, ids: enterpriseIDs, packages: []string{"certbot", "python3-certbot-nginx"}, repos: []string{RepoEPEL}},
+		{family: distro.DistroFamilyRedHat, packages: []string{"certbot", "python3-certbot-nginx"}},
+		{family: distro.DistroFamilySUSE, packages: []string{"python3-certbot", "python3-certbot-nginx"}},
+		{family: distro.DistroFamilyArch, packages: []string{"certbot", "certbot-nginx"}},
+		{family: distro.DistroFamilyAlpine, packages: []string{"certbot", "certbot-nginx"}},
+	},
+	"mariadb-server": {
+		{family: distro.DistroFamilySUSE, packages: []string{"mariadb"}},
+		{family: distro.DistroFamilyArch, packages: []string{"mariadb"}},
+		{family: distro.DistroFamilyAlpine, packages: []string{"mariadb", "mariadb-client"}},
+	},
+	"wireguard": {
+		{family: distro.DistroFamilyDebian, packages: []string{"wireguard", "wireguard-tools"}},
+		{family: distro.DistroFamilyRedHat, packages: []string{"wireguard-tools"}},
+		{family: distro.DistroFamilySUSE, packages: []string{"wireguard-tools"}},
+		{family: distro.DistroFamilyArch, packages: []string{"wireguard-tools"}},
+		{family: distro.DistroFamilyAlpine, packages: []string{"wireguard-tools"}},
+	},
+	"qrencode": {
+		{family: distro.DistroFamilyRedHat, ids: enterpriseIDs, packages: []string{"qrencode"}, repos: []string{RepoEPEL}},
+		{family: distro.DistroFamilyAlpine, packages: []string{"libqrencode-tools"}},
+	},
+	"redis": {
+		{family: distro.DistroFamilyDebian, packages: []string{"redis-server", "redis-tools"}},
+		{family: distro.DistroFamilyRedHat, ids: []string{"amzn"}, minVersion: 2022, packages: []string{"redis6"}},
+		{family: distro.DistroFamilyRedHat, ids: []string{"amzn"}, repos: []string{RepoEPEL}, packages: []string{"redis"}},
+	},
+	"fail2ban": {
+		{family: distro.DistroFamilyRedHat, excludeIDs: []string{"fedora"}, packages: []string{"fail2ban"}, repos: []string{RepoEPEL}},
+	},
+}
+
+// Resolution is what to install for a set of logical package names
+type Resolution struct {
+	Packages []string
+	// Repos are prerequisite repositories, such as RepoEPEL, to enable first
+	Repos []string
+}
+
+// Resolve maps logical package names to the distribution's packages and the
+// prerequisite repositories they need. Names not in the table pass through
+// unchanged, so distribution package names work too.
+func Resolve(info *distro.DistroInfo, names ...string) Resolution {
+	var res Resolution
+	for _, name := range names {
+		packages := []string{name}
+		for _, rule := range packageNames[name] {
+			if rule.matches(info) {
+				packages = rule.packages
+				for _, repo := range rule.repos {
+					if !contains(res.Repos, repo) {
+						res.Repos = append(res.Repos, repo)
+					}
+				}
+				break
+			}
+		}
+		for _, pkg := range packages {
+			if !contains(res.Packages, pkg) {
+				res.Packages = append(res.Packages, pkg)
+			}
+		}
+	}
+	return res
+}
+
+// PrerequisiteRepo is a distribution repository that packages depend on
+type PrerequisiteRepo struct {
+	Name string
+	// Check exits 0 when the repository is already enabled
+	Check string
+	// Enable are the commands that enable it, run as root in order
+	Enable []string
+}
+
+// Prerequisite returns how to enable a prerequisite repository on the
+// distribution
+func Prerequisite(info *distro.DistroInfo, name string) (PrerequisiteRepo, error) {
+	if name != RepoEPEL {
+		return PrerequisiteRepo{}, fmt.Errorf("unknown prerequisite repository '%s'", name)
+	}
+
+	major := info.MajorVersion()
+	repo := PrerequisiteRepo{Name: name, Check: "test -f /etc/yum.repos.d/epel.repo"}
+	install := fmt.Sprintf("%s install -y", info.PackageMgr)
+	switch {
+	case info.Family != distro.DistroFamilyRedHat:
+		return repo, fmt.Errorf("EPEL is only available on Red Hat family distributions")
+	case info.ID == "amzn" && major >= 2022:
+		return repo, fmt.Errorf("EPEL is not available on Amazon Linux %s", info.VersionID)
+	case info.ID == "amzn":
+		repo.Enable = []string{"amazon-linux-extras install -y epel"}
+	case info.ID == "ol":
+		repo.Check = fmt.Sprintf("test -f /etc/yum.repos.d/oracle-epel-ol%d.repo", major)
+		repo.Enable = []string{fmt.Sprintf("%s oracle-epel-release-el%d", install, major)}
+	case info.ID == "rhel":
+		repo.Enable = []string{fmt.Sprintf("%s https://dl.fedoraproject.org/pub/epel/epel-release-latest-%d.noarch.rpm", install, major)}
+	default:
+		repo.Enable = []string{install + " epel-release"}
+	}
+	return repo, nil
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -15,6 +15,8 @@ type PackageManager interface {
 	DistUpgrade() (string, error)
 	Autoremove() (string, error)
 	Search(query string) (string, error)
+	Queries
+	Repositories
 }
 
 type APT struct{}
@@ -71,7 +73,8 @@ func NewDNF() *DNF {
 }
 
 func (d *DNF) Update(packages ...string) (string, error) {
-	cmd := "dnf check-update"
+	// check-update exits 100 when updates are available, makecache does not
+	cmd := "dnf makecache"
 	if len(packages) > 0 {
 		cmd = fmt.Sprintf("dnf install -y %s", strings.Join(packages, " "))
 	}
@@ -118,7 +121,8 @@ func NewYUM() *YUM {
 }
 
 func (y *YUM) Update(packages ...string) (string, error) {
-	cmd := "yum check-update"
+	// check-update exits 100 when updates are available, makecache does not
+	cmd := "yum makecache"
 	if len(packages) > 0 {
 		cmd = fmt.Sprintf("yum install -y %s", strings.Join(packages, " "))
 	}
@@ -252,6 +256,79 @@ func (a *APK) Search(query string) (string, error) {
 	return fmt.Sprintf("apk search %s", query), nil
 }
 
+type Zypper struct{}
+
+func NewZypper() *Zypper {
+	return &Zypper{}
+}
+
+func (z *Zypper) Update(packages ...string) (string, error) {
+	cmd := "zypper --non-interactive refresh"
+	if len(packages) > 0 {
+		cmd = fmt.Sprintf("zypper --non-interactive install %s", strings.Join(packages, " "))
+	}
+	return cmd, nil
+}
+
+func (z *Zypper) Install(packages ...string) (string, error) {
+	if len(packages) == 0 {
+		return "", fmt.Errorf("no packages specified for installation")
+	}
+	return fmt.Sprintf("zypper --non-interactive install %s", strings.Join(packages, " ")), nil
+}
+
+func (z *Zypper) Remove(packages ...string) (string, error) {
+	if len(packages) == 0 {
+		return "", fmt.Errorf("no packages specified for removal")
+	}
+	return fmt.Sprintf("zypper --non-interactive remove %s", strings.Join(packages, " ")), nil
+}
+
+func (z *Zypper) Upgrade(packages ...string) (string, error) {
+	if len(packages) > 0 {
+		return fmt.Sprintf("zypper --non-interactive update %s", strings.Join(packages, " ")), nil
+	}
+	return "zypper --non-interactive update", nil
+}
+
+func (z *Zypper) DistUpgrade() (string, error) {
+	return "zypper --non-interactive dist-upgrade", nil
+}
+
+func (z *Zypper) Autoremove() (string, error) {
+	return "", fmt.Errorf("zypper has no autoremove; review unneeded packages with 'zypper packages --unneeded'")
+}
+
+func (z *Zypper) Search(query string) (string, error) {
+	return fmt.Sprintf("zypper search %s", query), nil
+}
+
+// Unsupported stands in for the package manager of a distribution vps-init
+// does not recognise. Every method fails with distro.ErrUnsupported rather
+// than guessing.
+type Unsupported struct {
+	distro *distro.DistroInfo
+}
+
+func NewUnsupported(distroInfo *distro.DistroInfo) *Unsupported {
+	return &Unsupported{distro: distroInfo}
+}
+
+func (u *Unsupported) err() error {
+	if err := u.distro.Check(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: no package manager for %s", distro.ErrUnsupported, u.distro.PackageMgr)
+}
+
+func (u *Unsupported) Update(packages ...string) (string, error)  { return "", u.err() }
+func (u *Unsupported) Install(packages ...string) (string, error) { return "", u.err() }
+func (u *Unsupported) Remove(packages ...string) (string, error)  { return "", u.err() }
+func (u *Unsupported) Upgrade(packages ...string) (string, error) { return "", u.err() }
+func (u *Unsupported) DistUpgrade() (string, error)               { return "", u.err() }
+func (u *Unsupported) Autoremove() (string, error)                { return "", u.err() }
+func (u *Unsupported) Search(query string) (string, error)        { return "", u.err() }
+
 func GetPackageManager(distroInfo *distro.DistroInfo) PackageManager {
 	switch distroInfo.PackageMgr {
 	case distro.PackageManagerAPT:
@@ -264,7 +341,9 @@ func GetPackageManager(distroInfo *distro.DistroInfo) PackageManager {
 		return NewPacman()
 	case distro.PackageManagerAPK:
 		return NewAPK()
+	case distro.PackageManagerZypper:
+		return NewZypper()
 	default:
-		return NewAPT()
+		return NewUnsupported(distroInfo)
 	}
 }
@@ -0,0 +1,668 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Runner runs a read-only query on the target and returns its stdout. It
+// returns an error when the command exits non-zero.
+type Runner func(cmd string) (string, error)
+
+// Upgrade is a package with a newer version available
+type Upgrade struct {
+	Name      string `json:"name"`
+	Current   string `json:"current,omitempty"`
+	Available string `json:"available"`
+}
+
+// Queries read package state from the target. A package that is not
+// installed or not known has an empty version rather than an error.
+type Queries interface {
+	IsInstalled(run Runner, pkg string) (bool, error)
+	InstalledVersion(run Runner, pkg string) (string, error)
+	CandidateVersion(run Runner, pkg string) (string, error)
+	ListUpgradable(run Runner) ([]Upgrade, error)
+	Held(run Runner) ([]string, error)
+	Hold(packages ...string) (string, error)
+	Unhold(packages ...string) (string, error)
+	// Files lists the paths each installed package owns, in one round trip
+	Files(run Runner, packages ...string) (map[string][]string, error)
+}
+
+func isInstalled(q Queries, run Runner, pkg string) (bool, error) {
+	version, err := q.InstalledVersion(run, pkg)
+	return version != "", err
+}
+
+// APT
+
+func (a *APT) IsInstalled(run Runner, pkg string) (bool, error) {
+	return isInstalled(a, run, pkg)
+}
+
+func (a *APT) InstalledVersion(run Runner, pkg string) (string, error) {
+	out, err := run(fmt.Sprintf("dpkg-query -W -f='${Status}\\t${Version}\\n' %s 2>/dev/null", pkg))
+	if err != nil {
+		return "", nil
+	}
+	return parseDpkgQuery(out), nil
+}
+
+func (a *APT) CandidateVersion(run Runner, pkg string) (string, error) {
+	out, err := run(fmt.Sprintf("apt-cache policy %s", pkg))
+	if err != nil {
+		return "", err
+	}
+	return parseAptPolicy(out), nil
+}
+
+func (a *APT) ListUpgradable(run Runner) ([]Upgrade, error) {
+	out, err := run("apt list --upgradable 2>/dev/null")
+	if err != nil {
+		return nil, err
+	}
+	return parseAptUpgradable(out), nil
+}
+
+func (a *APT) Held(run Runner) ([]string, error) {
+	out, err := run("apt-mark showhold")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+func (a *APT) Hold(packages ...string) (string, error) {
+	return packagesCommand("apt-mark hold", packages)
+}
+
+func (a *APT) Unhold(packages ...string) (string, error) {
+	return packagesCommand("apt-mark unhold", packages)
+}
+
+func (a *APT) Files(run Runner, packages ...string) (map[string][]string, error) {
+	return packageFiles(run, "dpkg -L", packages)
+}
+
+// DNF and YUM
+
+func (d *DNF) IsInstalled(run Runner, pkg string) (bool, error) {
+	return isInstalled(d, run, pkg)
+}
+
+func (d *DNF) InstalledVersion(run Runner, pkg string) (string, error) {
+	return rpmInstalledVersion(run, pkg)
+}
+
+func (d *DNF) CandidateVersion(run Runner, pkg string) (string, error) {
+	return rpmCandidateVersion(run, "dnf", pkg)
+}
+
+func (d *DNF) ListUpgradable(run Runner) ([]Upgrade, error) {
+	return rpmListUpgradable(run, "dnf")
+}
+
+func (d *DNF) Held(run Runner) ([]string, error) {
+	return rpmHeld(run, "dnf")
+}
+
+func (d *DNF) Hold(packages ...string) (string, error) {
+	return packagesCommand("dnf versionlock add", packages)
+}
+
+func (d *DNF) Unhold(packages ...string) (string, error) {
+	return packagesCommand("dnf versionlock delete", packages)
+}
+
+func (d *DNF) Files(run Runner, packages ...string) (map[string][]string, error) {
+	return packageFiles(run, "rpm -ql", packages)
+}
+
+func (y *YUM) IsInstalled(run Runner, pkg string) (bool, error) {
+	return isInstalled(y, run, pkg)
+}
+
+func (y *YUM) InstalledVersion(run Runner, pkg string) (string, error) {
+	return rpmInstalledVersion(run, pkg)
+}
+
+func (y *YUM) CandidateVersion(run Runner, pkg string) (string, error) {
+	return rpmCandidateVersion(run, "yum", pkg)
+}
+
+func (y *YUM) ListUpgradable(run Runner) ([]Upgrade, error) {
+	return rpmListUpgradable(run, "yum")
+}
+
+func (y *YUM) Held(run Runner) ([]string, error) {
+	return rpmHeld(run, "yum")
+}
+
+func (y *YUM) Hold(packages ...string) (string, error) {
+	return packagesCommand("yum versionlock add", packages)
+}
+
+func (y *YUM) Unhold(packages ...string) (string, error) {
+	return packagesCommand("yum versionlock delete", packages)
+}
+
+func (y *YUM) Files(run Runner, packages ...string) (map[string][]string, error) {
+	return packageFiles(run, "rpm -ql", packages)
+}
+
+func rpmInstalledVersion(run Runner, pkg string) (string, error) {
+	out, err := run(fmt.Sprintf("rpm -q --qf '%%{VERSION}-%%{RELEASE}\\n' %s", pkg))
+	if err != nil {
+		return "", nil
+	}
+	return firstLine(out), nil
+}
+
+func rpmCandidateVersion(run Runner, tool, pkg string) (string, error) {
+	out, err := run(fmt.Sprintf("%s -q list %s 2>/dev/null", tool, pkg))
+	if err != nil {
+		return "", nil
+	}
+	return parseYumList(out, pkg), nil
+}
+
+func rpmListUpgradable(run Runner, tool string) ([]Upgrade, error) {
+	out, err := run(fmt.Sprintf("%s -q list updates 2>/dev/null", tool))
+	if err != nil {
+		// Nothing to list exits 1 on some versions
+		if strings.TrimSpace(out) == "" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	upgrades := parseYumUpdates(out)
+	if len(upgrades) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, len(upgrades))
+	for i, u := range upgrades {
+		names[i] = u.Name
+	}
+	installed, _ := run(fmt.Sprintf("rpm -q --qf '%%{NAME} %%{VERSION}-%%{RELEASE}\\n' %s", strings.Join(names, " ")))
+	current := parseNameVersionLines(installed)
+	for i := range upgrades {
+		upgrades[i].Current = current[upgrades[i].Name]
+	}
+	return upgrades, nil
+}
+
+func rpmHeld(run Runner, tool string) ([]string, error) {
+	out, err := run(fmt.Sprintf("%s -q versionlock list", tool))
+	if err != nil {
+		return nil, err
+	}
+	return parseVersionlock(out), nil
+}
+
+// Pacman
+
+func (p *Pacman) IsInstalled(run Runner, pkg string) (bool, error) {
+	return isInstalled(p, run, pkg)
+}
+
+func (p *Pacman) InstalledVersion(run Runner, pkg string) (string, error) {
+	out, err := run(fmt.Sprintf("pacman -Q %s 2>/dev/null", pkg))
+	if err != nil {
+		return "", nil
+	}
+	return parseNameVersionLines(out)[pkg], nil
+}
+
+func (p *Pacman) CandidateVersion(run Runner, pkg string) (string, error) {
+	out, err := run(fmt.Sprintf("pacman -Si %s 2>/dev/null", pkg))
+	if err != nil {
+		return "", nil
+	}
+	return parseField(out, "Version"), nil
+}
+
+func (p *Pacman) ListUpgradable(run Runner) ([]Upgrade, error) {
+	out, err := run("pacman -Qu")
+	if err != nil {
+		// pacman -Qu exits 1 when nothing is upgradable
+		if strings.TrimSpace(out) == "" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parsePacmanUpgradable(out), nil
+}
+
+func (p *Pacman) Held(run Runner) ([]string, error) {
+	out, err := run("pacman-conf IgnorePkg")
+	if err != nil {
+		return nil, nil
+	}
+	return strings.Fields(out), nil
+}
+
+func (p *Pacman) Hold(packages ...string) (string, error) {
+	return "", fmt.Errorf("pacman cannot hold packages from the command line; add them to IgnorePkg in /etc/pacman.conf")
+}
+
+func (p *Pacman) Unhold(packages ...string) (string, error) {
+	return "", fmt.Errorf("pacman cannot unhold packages from the command line; remove them from IgnorePkg in /etc/pacman.conf")
+}
+
+func (p *Pacman) Files(run Runner, packages ...string) (map[string][]string, error) {
+	return packageFiles(run, "pacman -Qlq", packages)
+}
+
+// APK
+
+func (a *APK) IsInstalled(run Runner, pkg string) (bool, error) {
+	return isInstalled(a, run, pkg)
+}
+
+func (a *APK) InstalledVersion(run Runner, pkg string) (string, error) {
+	out, err := run(fmt.Sprintf("apk list --installed %s 2>/dev/null", pkg))
+	if err != nil {
+		return "", nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if name, version := splitApkPackage(firstField(line)); name == pkg {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+func (a *APK) CandidateVersion(run Runner, pkg string) (string, error) {
+	out, err := run(fmt.Sprintf("apk list %s 2>/dev/null", pkg))
+	if err != nil {
+		return "", nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if name, version := splitApkPackage(firstField(line)); name == pkg {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+func (a *APK) ListUpgradable(run Runner) ([]Upgrade, error) {
+	out, err := run("apk list --upgradable 2>/dev/null")
+	if err != nil {
+		return nil, err
+	}
+	return parseApkUpgradable(out), nil
+}
+
+// Held lists packages pinned to a version in /etc/apk/world
+func (a *APK) Held(run Runner) ([]string, error) {
+	out, err := run("cat /etc/apk/world")
+	if err != nil {
+		return nil, err
+	}
+	var held []string
+	for _, entry := range strings.Fields(out) {
+		if i := strings.IndexAny(entry, "=~<>"); i > 0 {
+			held = append(held, entry[:i])
+		}
+	}
+	return held, nil
+}
+
+func (a *APK) Hold(packages ...string) (string, error) {
+	return "", fmt.Errorf("apk cannot hold packages; pin a version with apk add <package>=<version>")
+}
+
+// Unhold drops version pins by adding the packages back unpinned
+func (a *APK) Unhold(packages ...string) (string, error) {
+	return packagesCommand("apk add", packages)
+}
+
+// Files lists apk's paths, which are relative to /, as absolute paths
+func (a *APK) Files(run Runner, packages ...string) (map[string][]string, error) {
+	files, err := packageFiles(run, "apk info -qL", packages)
+	for pkg, paths := range files {
+		for i, path := range paths {
+			if !strings.HasPrefix(path, "/") {
+				paths[i] = "/" + path
+			}
+		}
+		files[pkg] = paths
+	}
+	return files, err
+}
+
+// Zypper
+
+func (z *Zypper) IsInstalled(run Runner, pkg string) (bool, error) {
+	return isInstalled(z, run, pkg)
+}
+
+func (z *Zypper) InstalledVersion(run Runner, pkg string) (string, error) {
+	return rpmInstalledVersion(run, pkg)
+}
+
+func (z *Zypper) CandidateVersion(run Runner, pkg string) (string, error) {
+	out, err := run(fmt.Sprintf("zypper --non-interactive --quiet info %s", pkg))
+	if err != nil {
+		return "", nil
+	}
+	return parseField(out, "Version"), nil
+}
+
+func (z *Zypper) ListUpgradable(run Runner) ([]Upgrade, error) {
+	out, err := run("zypper --non-interactive --quiet list-updates")
+	if err != nil {
+		return nil, err
+	}
+	var upgrades []Upgrade
+	for _, row := range parseZypperTable(out) {
+		// S | Repository | Name | Current Version | Available Version | Arch
+		if len(row) >= 5 {
+			upgrades = append(upgrades, Upgrade{Name: row[2], Current: row[3], Available: row[4]})
+		}
+	}
+	return upgrades, nil
+}
+
+func (z *Zypper) Held(run Runner) ([]string, error) {
+	out, err := run("zypper --non-interactive --quiet locks")
+	if err != nil {
+		return nil, err
+	}
+	var held []string
+	for _, row := range parseZypperTable(out) {
+		// # | Name | Type | Repository
+		if len(row) >= 2 {
+			held = append(held, row[1])
+		}
+	}
+	return held, nil
+}
+
+func (z *Zypper) Hold(packages ...string) (string, error) {
+	return packagesCommand("zypper --non-interactive addlock", packages)
+}
+
+func (z *Zypper) Unhold(packages ...string) (string, error) {
+	return packagesCommand("zypper --non-interactive removelock", packages)
+}
+
+func (z *Zypper) Files(run Runner, packages ...string) (map[string][]string, error) {
+	return packageFiles(run, "rpm -ql", packages)
+}
+
+// Unsupported
+
+func (u *Unsupported) IsInstalled(run Runner, pkg string) (bool, error) {
+	return false, u.err()
+}
+
+func (u *Unsupported) InstalledVersion(run Runner, pkg string) (string, error) {
+	return "", u.err()
+}
+
+func (u *Unsupported) CandidateVersion(run Runner, pkg string) (string, error) {
+	return "", u.err()
+}
+
+func (u *Unsupported) ListUpgradable(run Runner) ([]Upgrade, error) {
+	return nil, u.err()
+}
+
+func (u *Unsupported) Held(run Runner) ([]string, error) {
+	return nil, u.err()
+}
+
+func (u *Unsupported) Hold(packages ...string) (string, error) {
+	return "", u.err()
+}
+
+func (u *Unsupported) Unhold(packages ...string) (string, error) {
+	return "", u.err()
+}
+
+func (u *Unsupported) Files(run Runner, packages ...string) (map[string][]string, error) {
+	return nil, u.err()
+}
+
+// packageFiles runs listCmd for every package in one shell loop, prefixing
+// each path with its package
+func packageFiles(run Runner, listCmd string, packages []string) (map[string][]string, error) {
+	if len(packages) == 0 {
+		return map[string][]string{}, nil
+	}
+	out, err := run(fmt.Sprintf(`for p in %s; do %s "$p" 2>/dev/null | awk -v p="$p" '{print p " " $0}'; done`, strings.Join(packages, " "), listCmd))
+	if err != nil {
+		return nil, err
+	}
+	return parsePackageFiles(out), nil
+}
+
+// Parsers
+
+// parsePackageFiles reads "package path" lines. Lines that are not paths,
+// such as rpm's "(contains no files)", are skipped.
+func parsePackageFiles(out string) map[string][]string {
+	files := map[string][]string{}
+	for _, line := range strings.Split(out, "\n") {
+		pkg, path, ok := strings.Cut(strings.TrimRight(line, "\r"), " ")
+		if !ok || path == "" || strings.HasPrefix(path, "(") {
+			continue
+		}
+		files[pkg] = append(files[pkg], path)
+	}
+	return files
+}
+
+// parseZypperTable reads the rows of a zypper table, skipping the header
+// and separator lines
+func parseZypperTable(out string) [][]string {
+	var rows [][]string
+	header := true
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.Contains(line, "|") {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "--") || strings.Contains(line, "-+-") {
+			continue
+		}
+		if header {
+			header = false
+			continue
+		}
+		var row []string
+		for _, cell := range strings.Split(line, "|") {
+			row = append(row, strings.TrimSpace(cell))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// parseDpkgQuery reads "<status>\t<version>" and returns the version of an
+// installed package
+func parseDpkgQuery(out string) string {
+	status, version, ok := strings.Cut(firstLine(out), "\t")
+	if !ok || !strings.HasSuffix(status, " installed") {
+		return ""
+	}
+	return version
+}
+
+// parseAptPolicy reads the Candidate line of apt-cache policy
+func parseAptPolicy(out string) string {
+	candidate := parseField(out, "Candidate")
+	if candidate == "(none)" {
+		return ""
+	}
+	return candidate
+}
+
+// aptUpgradableLine matches "nginx/jammy-updates 1.18.0-6ubuntu14.4 amd64 [upgradable from: 1.18.0-6ubuntu14.3]"
+var aptUpgradableLine = regexp.MustCompile(`^([^/\s]+)/\S+\s+(\S+)\s+\S+\s+\[upgradable from: ([^\]]+)\]`)
+
+func parseAptUpgradable(out string) []Upgrade {
+	var upgrades []Upgrade
+	for _, line := range strings.Split(out, "\n") {
+		if m := aptUpgradableLine.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			upgrades = append(upgrades, Upgrade{Name: m[1], Current: m[3], Available: m[2]})
+		}
+	}
+	return upgrades
+}
+
+// parseYumList reads "<name>.<arch> <version> <repo>" lines under the
+// Installed and Available headings and returns the newest version offered,
+// or the installed one when nothing newer is available
+func parseYumList(out, pkg string) string {
+	var installed, available string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || stripArch(fields[0]) != pkg {
+			continue
+		}
+		version := stripEpoch(fields[1])
+		if len(fields) >= 3 && strings.HasPrefix(fields[2], "@") {
+			installed = version
+		} else {
+			available = version
+		}
+	}
+	if available != "" {
+		return available
+	}
+	return installed
+}
+
+// parseYumUpdates reads "<name>.<arch> <version> <repo>" lines of yum/dnf
+// list updates
+func parseYumUpdates(out string) []Upgrade {
+	var upgrades []Upgrade
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !strings.Contains(fields[0], ".") {
+			continue
+		}
+		upgrades = append(upgrades, Upgrade{Name: stripArch(fields[0]), Available: stripEpoch(fields[1])})
+	}
+	return upgrades
+}
+
+// parseVersionlock reads versionlock entries such as "nginx-1:1.20.1-14.el9.*"
+// and returns the package names
+func parseVersionlock(out string) []string {
+	var held []string
+	for _, line := range strings.Split(out, "\n") {
+		entry := strings.TrimSpace(line)
+		if entry == "" || strings.HasPrefix(entry, "#") || strings.Contains(entry, " ") {
+			continue
+		}
+		entry = strings.TrimPrefix(entry, "!")
+		// Drop "-[epoch:]version-release.arch"
+		parts := strings.Split(entry, "-")
+		if len(parts) < 3 {
+			continue
+		}
+		held = append(held, strings.Join(parts[:len(parts)-2], "-"))
+	}
+	return held
+}
+
+// parsePacmanUpgradable reads "nginx 1.24.0-1 -> 1.26.0-1" lines
+func parsePacmanUpgradable(out string) []Upgrade {
+	var upgrades []Upgrade
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[2] == "->" {
+			upgrades = append(upgrades, Upgrade{Name: fields[0], Current: fields[1], Available: fields[3]})
+		}
+	}
+	return upgrades
+}
+
+// apkUpgradableLine matches "nginx-1.24.0-r7 x86_64 {nginx} (BSD-2-Clause) [upgradable from: nginx-1.24.0-r6]"
+var apkUpgradableLine = regexp.MustCompile(`^(\S+)\s.*\[upgradable from: (\S+)\]`)
+
+func parseApkUpgradable(out string) []Upgrade {
+	var upgrades []Upgrade
+	for _, line := range strings.Split(out, "\n") {
+		m := apkUpgradableLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		name, available := splitApkPackage(m[1])
+		_, current := splitApkPackage(m[2])
+		upgrades = append(upgrades, Upgrade{Name: name, Current: current, Available: available})
+	}
+	return upgrades
+}
+
+// splitApkPackage splits "nginx-1.24.0-r7" into name and version; the
+// version is the last two dash-separated parts
+func splitApkPackage(s string) (name, version string) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 {
+		return s, ""
+	}
+	return strings.Join(parts[:len(parts)-2], "-"), strings.Join(parts[len(parts)-2:], "-")
+}
+
+// parseNameVersionLines reads "<name> <version>" lines into a map
+func parseNameVersionLines(out string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			versions[fields[0]] = fields[1]
+		}
+	}
+	return versions
+}
+
+// parseField returns the value of a "Key : value" line
+func parseField(out, key string) string {
+	for _, line := range strings.Split(out, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func packagesCommand(cmd string, packages []string) (string, error) {
+	if len(packages) == 0 {
+		return "", fmt.Errorf("no packages specified")
+	}
+	return fmt.Sprintf("%s %s", cmd, strings.Join(packages, " ")), nil
+}
+
+func stripArch(s string) string {
+	if i := strings.LastIndex(s, "."); i > 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func stripEpoch(s string) string {
+	if _, version, ok := strings.Cut(s, ":"); ok {
+		return version
+	}
+	return s
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(line)
+}
+
+func firstField(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
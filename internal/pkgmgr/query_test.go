@@ -0,0 +1,335 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRunner answers each command with the output of the first key it
+// contains, and fails commands it has no output for
+func fakeRunner(outputs map[string]string) Runner {
+	return func(cmd string) (string, error) {
+		for match, out := range outputs {
+			if strings.Contains(cmd, match) {
+				return out, nil
+			}
+		}
+		return "", fmt.Errorf("exit status 1")
+	}
+}
+
+func TestParseDpkgQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{"installed", "install ok installed\t1.18.0-6ubuntu14.4\n", "1.18.0-6ubuntu14.4"},
+		{"held", "hold ok installed\t1:9.18.28-0ubuntu0.22.04.1\n", "1:9.18.28-0ubuntu0.22.04.1"},
+		{"removed with config files", "deinstall ok config-files\t1.18.0-6ubuntu14.4\n", ""},
+		{"not installed", "unknown ok not-installed\t\n", ""},
+		{"no output", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDpkgQuery(tt.out); got != tt.want {
+				t.Errorf("parseDpkgQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAptPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{
+			name: "update available",
+			out: `nginx:
+  Installed: 1.18.0-6ubuntu14.4
+  Candidate: 1.18.0-6ubuntu14.5
+  Version table:
+     1.18.0-6ubuntu14.5 500
+        500 http://archive.ubuntu.com/ubuntu jammy-updates/main amd64 Packages
+ *** 1.18.0-6ubuntu14.4 100
+        100 /var/lib/dpkg/status
+`,
+			want: "1.18.0-6ubuntu14.5",
+		},
+		{
+			name: "unknown package",
+			out: `no-such-package:
+  Installed: (none)
+  Candidate: (none)
+  Version table:
+`,
+			want: "",
+		},
+		{"no output", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseAptPolicy(tt.out); got != tt.want {
+				t.Errorf("parseAptPolicy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAptUpgradable(t *testing.T) {
+	out := `Listing... Done
+nginx/jammy-updates 1.18.0-6ubuntu14.5 amd64 [upgradable from: 1.18.0-6ubuntu14.4]
+openssl/jammy-updates,jammy-security 3.0.2-0ubuntu1.15 amd64 [upgradable from: 3.0.2-0ubuntu1.14]
+tzdata/jammy-updates 2024a-0ubuntu0.22.04.1 all [upgradable from: 2023c-0ubuntu0.22.04.2]
+`
+	want := []Upgrade{
+		{Name: "nginx", Current: "1.18.0-6ubuntu14.4", Available: "1.18.0-6ubuntu14.5"},
+		{Name: "openssl", Current: "3.0.2-0ubuntu1.14", Available: "3.0.2-0ubuntu1.15"},
+		{Name: "tzdata", Current: "2023c-0ubuntu0.22.04.2", Available: "2024a-0ubuntu0.22.04.1"},
+	}
+	if got := parseAptUpgradable(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAptUpgradable() = %+v, want %+v", got, want)
+	}
+	if got := parseAptUpgradable("Listing... Done\n"); got != nil {
+		t.Errorf("parseAptUpgradable() with nothing upgradable = %+v, want nil", got)
+	}
+}
+
+func TestParseYumList(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{
+			name: "update available",
+			out: `Installed Packages
+nginx.x86_64                    1:1.20.1-14.el9_2.1                    @appstream
+Available Packages
+nginx.x86_64                    1:1.20.1-16.el9_4.1                    appstream
+`,
+			want: "1.20.1-16.el9_4.1",
+		},
+		{
+			name: "installed is newest",
+			out: `Installed Packages
+nginx.x86_64                    1:1.20.1-16.el9_4.1                    @appstream
+`,
+			want: "1.20.1-16.el9_4.1",
+		},
+		{
+			name: "other packages with the same prefix",
+			out: `Available Packages
+nginx-all-modules.noarch        1:1.20.1-16.el9_4.1                    appstream
+nginx-core.x86_64               1:1.20.1-16.el9_4.1                    appstream
+`,
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseYumList(tt.out, "nginx"); got != tt.want {
+				t.Errorf("parseYumList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRpmListUpgradable(t *testing.T) {
+	run := fakeRunner(map[string]string{
+		"list updates": `Available Upgrades
+kernel.x86_64                 5.14.0-427.16.1.el9_4           baseos
+openssl.x86_64                1:3.0.7-27.el9                  baseos
+python3-libs.x86_64           3.9.18-3.el9_4.1                baseos
+`,
+		"rpm -q": `kernel 5.14.0-362.24.1.el9_3
+openssl 3.0.7-24.el9
+python3-libs 3.9.18-1.el9_3.1
+`,
+	})
+	want := []Upgrade{
+		{Name: "kernel", Current: "5.14.0-362.24.1.el9_3", Available: "5.14.0-427.16.1.el9_4"},
+		{Name: "openssl", Current: "3.0.7-24.el9", Available: "3.0.7-27.el9"},
+		{Name: "python3-libs", Current: "3.9.18-1.el9_3.1", Available: "3.9.18-3.el9_4.1"},
+	}
+	got, err := (&DNF{}).ListUpgradable(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListUpgradable() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRpmInstalledVersion(t *testing.T) {
+	run := fakeRunner(map[string]string{"rpm -q --qf '%{VERSION}-%{RELEASE}\\n' nginx": "1.20.1-14.el9_2.1\n"})
+	tests := []struct {
+		pkg  string
+		want string
+	}{
+		{"nginx", "1.20.1-14.el9_2.1"},
+		{"httpd", ""}, // rpm -q exits 1: "package httpd is not installed"
+	}
+	for _, tt := range tests {
+		t.Run(tt.pkg, func(t *testing.T) {
+			got, err := (&DNF{}).InstalledVersion(run, tt.pkg)
+			if err != nil || got != tt.want {
+				t.Errorf("InstalledVersion(%s) = %q, %v, want %q", tt.pkg, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVersionlock(t *testing.T) {
+	out := `Last metadata expiration check: 0:12:03 ago on Tue 14 May 2024 10:00:00 AM UTC.
+nginx-1:1.20.1-14.el9_2.1.*
+python3-libs-0:3.9.18-3.el9.*
+!kernel-0:5.14.0-427.16.1.el9_4.*
+`
+	want := []string{"nginx", "python3-libs", "kernel"}
+	if got := parseVersionlock(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseVersionlock() = %v, want %v", got, want)
+	}
+}
+
+func TestPacman(t *testing.T) {
+	run := fakeRunner(map[string]string{
+		"pacman -Q nginx": "nginx 1.26.1-1\n",
+		"pacman -Si nginx": `Repository      : extra
+Name            : nginx
+Version         : 1.26.1-2
+Description     : Lightweight HTTP server and IMAP/POP3 proxy server
+Architecture    : x86_64
+URL             : https://nginx.org
+`,
+		"pacman -Qu": `linux 6.9.3.arch1-1 -> 6.9.5.arch1-1
+nginx 1.26.1-1 -> 1.26.1-2 [ignored]
+`,
+	})
+	p := &Pacman{}
+
+	if got, _ := p.InstalledVersion(run, "nginx"); got != "1.26.1-1" {
+		t.Errorf("InstalledVersion() = %q, want 1.26.1-1", got)
+	}
+	if got, _ := p.InstalledVersion(run, "caddy"); got != "" {
+		t.Errorf("InstalledVersion() of a missing package = %q, want empty", got)
+	}
+	if got, _ := p.CandidateVersion(run, "nginx"); got != "1.26.1-2" {
+		t.Errorf("CandidateVersion() = %q, want 1.26.1-2", got)
+	}
+	want := []Upgrade{
+		{Name: "linux", Current: "6.9.3.arch1-1", Available: "6.9.5.arch1-1"},
+		{Name: "nginx", Current: "1.26.1-1", Available: "1.26.1-2"},
+	}
+	if got, err := p.ListUpgradable(run); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ListUpgradable() = %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestAPK(t *testing.T) {
+	run := fakeRunner(map[string]string{
+		"apk list --installed nginx": `nginx-1.24.0-r15 x86_64 {nginx} (BSD-2-Clause) [installed]
+nginx-mod-http-geoip-1.24.0-r15 x86_64 {nginx} (BSD-2-Clause) [installed]
+`,
+		"apk list nginx": "nginx-1.24.0-r16 x86_64 {nginx} (BSD-2-Clause)\n",
+		"apk list --upgradable": `musl-1.2.4_git20230717-r5 x86_64 {musl} (MIT) [upgradable from: musl-1.2.4_git20230717-r4]
+nginx-1.24.0-r16 x86_64 {nginx} (BSD-2-Clause) [upgradable from: nginx-1.24.0-r15]
+`,
+		"cat /etc/apk/world": "alpine-base\ncurl~8.5\nnginx=1.24.0-r15\nopenssh\n",
+		"apk info -qL":       "nginx etc/nginx/nginx.conf\nnginx usr/sbin/nginx\n",
+	})
+	a := &APK{}
+
+	if got, _ := a.InstalledVersion(run, "nginx"); got != "1.24.0-r15" {
+		t.Errorf("InstalledVersion() = %q, want 1.24.0-r15", got)
+	}
+	if got, _ := a.CandidateVersion(run, "nginx"); got != "1.24.0-r16" {
+		t.Errorf("CandidateVersion() = %q, want 1.24.0-r16", got)
+	}
+	wantUpgrades := []Upgrade{
+		{Name: "musl", Current: "1.2.4_git20230717-r4", Available: "1.2.4_git20230717-r5"},
+		{Name: "nginx", Current: "1.24.0-r15", Available: "1.24.0-r16"},
+	}
+	if got, err := a.ListUpgradable(run); err != nil || !reflect.DeepEqual(got, wantUpgrades) {
+		t.Errorf("ListUpgradable() = %+v, %v, want %+v", got, err, wantUpgrades)
+	}
+	if got, err := a.Held(run); err != nil || !reflect.DeepEqual(got, []string{"curl", "nginx"}) {
+		t.Errorf("Held() = %v, %v, want [curl nginx]", got, err)
+	}
+	wantFiles := map[string][]string{"nginx": {"/etc/nginx/nginx.conf", "/usr/sbin/nginx"}}
+	if got, err := a.Files(run, "nginx"); err != nil || !reflect.DeepEqual(got, wantFiles) {
+		t.Errorf("Files() = %v, %v, want %v", got, err, wantFiles)
+	}
+}
+
+func TestSplitApkPackage(t *testing.T) {
+	tests := []struct {
+		in, name, version string
+	}{
+		{"nginx-1.24.0-r15", "nginx", "1.24.0-r15"},
+		{"nginx-mod-http-geoip-1.24.0-r15", "nginx-mod-http-geoip", "1.24.0-r15"},
+		{"musl-1.2.4_git20230717-r5", "musl", "1.2.4_git20230717-r5"},
+		{"nginx", "nginx", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			name, version := splitApkPackage(tt.in)
+			if name != tt.name || version != tt.version {
+				t.Errorf("splitApkPackage() = %q, %q, want %q, %q", name, version, tt.name, tt.version)
+			}
+		})
+	}
+}
+
+func TestZypper(t *testing.T) {
+	run := fakeRunner(map[string]string{
+		"list-updates": `S | Repository             | Name        | Current Version    | Available Version  | Arch
+--+------------------------+-------------+--------------------+--------------------+-------
+v | Main Update Repository | libopenssl3 | 3.1.4-150600.5.7.1 | 3.1.4-150600.5.10.1 | x86_64
+v | Main Update Repository | vim         | 9.1.0330-150500.20.9.1 | 9.1.0330-150500.20.12.1 | x86_64
+`,
+		"locks": `
+# | Name  | Type    | Repository
+--+-------+---------+-----------
+1 | nginx | package | (any)
+`,
+		"info nginx": `Information for package nginx:
+------------------------------
+Repository     : Main Repository (OSS)
+Name           : nginx
+Version        : 1.21.5-150600.8.4
+Arch           : x86_64
+Installed      : No
+`,
+	})
+	z := &Zypper{}
+
+	wantUpgrades := []Upgrade{
+		{Name: "libopenssl3", Current: "3.1.4-150600.5.7.1", Available: "3.1.4-150600.5.10.1"},
+		{Name: "vim", Current: "9.1.0330-150500.20.9.1", Available: "9.1.0330-150500.20.12.1"},
+	}
+	if got, err := z.ListUpgradable(run); err != nil || !reflect.DeepEqual(got, wantUpgrades) {
+		t.Errorf("ListUpgradable() = %+v, %v, want %+v", got, err, wantUpgrades)
+	}
+	if got, err := z.Held(run); err != nil || !reflect.DeepEqual(got, []string{"nginx"}) {
+		t.Errorf("Held() = %v, %v, want [nginx]", got, err)
+	}
+	if got, _ := z.CandidateVersion(run, "nginx"); got != "1.21.5-150600.8.4" {
+		t.Errorf("CandidateVersion() = %q, want 1.21.5-150600.8.4", got)
+	}
+}
+
+func TestParsePackageFiles(t *testing.T) {
+	out := `nginx /etc/nginx/nginx.conf
+nginx /usr/sbin/nginx
+filesystem (contains no files)
+`
+	want := map[string][]string{"nginx": {"/etc/nginx/nginx.conf", "/usr/sbin/nginx"}}
+	if got := parsePackageFiles(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePackageFiles() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,416 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Repository is a third-party package repository signed with a pinned key
+type Repository struct {
+	// Name identifies the repository and names its files on the target
+	Name string `json:"name"`
+	// URL is the repository base URL. dnf/yum variables such as $releasever
+	// and $basearch are kept for dnf to expand.
+	URL string `json:"url"`
+	// Suite is the apt distribution; the target's release codename when empty
+	Suite string `json:"suite,omitempty"`
+	// Components are the apt components; "main" when empty
+	Components []string `json:"components,omitempty"`
+	// KeyURL is where the signing key is downloaded from
+	KeyURL string `json:"key_url,omitempty"`
+	// Fingerprint is the expected key fingerprint: the OpenPGP fingerprint
+	// for apt and dnf/yum, the sha256 of the key file for apk
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// File is where the repository is defined on the target, when listed
+	File string `json:"file,omitempty"`
+}
+
+// Repositories manages third-party repositories. AddRepository and
+// RemoveRepository return shell scripts to run as root with sh -c.
+type Repositories interface {
+	AddRepository(repo Repository) (string, error)
+	RemoveRepository(name string) (string, error)
+	ListRepositories(run Runner) ([]Repository, error)
+	// RepositoryTools maps the commands AddRepository needs to the
+	// packages that provide them
+	RepositoryTools() map[string]string
+}
+
+var repoNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+func validateRepository(repo Repository, fingerprintPattern *regexp.Regexp, keyRequired bool) (Repository, error) {
+	if !repoNamePattern.MatchString(repo.Name) {
+		return repo, fmt.Errorf("invalid repository name '%s': use lowercase letters, digits, '.', '_' and '-'", repo.Name)
+	}
+	if !strings.HasPrefix(repo.URL, "https://") && !strings.HasPrefix(repo.URL, "http://") {
+		return repo, fmt.Errorf("invalid repository URL '%s'", repo.URL)
+	}
+	if repo.KeyURL == "" {
+		if keyRequired {
+			return repo, fmt.Errorf("repository '%s' needs a signing key URL", repo.Name)
+		}
+		return repo, nil
+	}
+	if !strings.HasPrefix(repo.KeyURL, "https://") {
+		return repo, fmt.Errorf("signing key for '%s' must be downloaded over https", repo.Name)
+	}
+	repo.Fingerprint = strings.ToUpper(strings.ReplaceAll(repo.Fingerprint, " ", ""))
+	if !fingerprintPattern.MatchString(repo.Fingerprint) {
+		return repo, fmt.Errorf("repository '%s' needs the key fingerprint to verify the downloaded key", repo.Name)
+	}
+	return repo, nil
+}
+
+var (
+	pgpFingerprint    = regexp.MustCompile(`^[0-9A-F]{40}$`)
+	sha256Fingerprint = regexp.MustCompile(`^[0-9A-F]{64}$`)
+)
+
+// fetchPGPKey downloads a key to $key and fails unless one of its primary
+// keys has the expected fingerprint
+func fetchPGPKey(repo Repository) string {
+	return strings.Join([]string{
+		"set -e",
+		`key=$(mktemp); export GNUPGHOME=$(mktemp -d)`,
+		`trap 'rm -rf "$key" "$GNUPGHOME"' EXIT`,
+		"curl -fsSL " + quote(repo.KeyURL) + ` -o "$key"`,
+		`fprs=$(gpg --batch --show-keys --with-colons "$key" | awk -F: '/^pub:/{p=1} /^fpr:/ && p {print $10; p=0}')`,
+		fmt.Sprintf(`echo "$fprs" | grep -qix %s || { echo "signing key fingerprint mismatch for %s: expected %s, got $fprs" >&2; exit 1; }`,
+			repo.Fingerprint, repo.Name, repo.Fingerprint),
+	}, "\n")
+}
+
+// APT
+
+func (a *APT) AddRepository(repo Repository) (string, error) {
+	repo, err := validateRepository(repo, pgpFingerprint, true)
+	if err != nil {
+		return "", err
+	}
+	suite := quote(repo.Suite)
+	if repo.Suite == "" {
+		// Derivatives such as Mint name the Ubuntu release they follow
+		suite = `"$(. /etc/os-release && echo "${UBUNTU_CODENAME:-$VERSION_CODENAME}")"`
+	}
+	components := repo.Components
+	if len(components) == 0 {
+		components = []string{"main"}
+	}
+	keyring := "/etc/apt/keyrings/" + repo.Name + ".gpg"
+
+	return strings.Join([]string{
+		fetchPGPKey(repo),
+		"install -d -m 0755 /etc/apt/keyrings",
+		fmt.Sprintf(`if grep -q "BEGIN PGP" "$key"; then gpg --batch --yes --dearmor -o %s "$key"; else cp "$key" %s; fi`, keyring, keyring),
+		"chmod 0644 " + keyring,
+		fmt.Sprintf(`echo "deb [arch=$(dpkg --print-architecture) signed-by=%s] "%s" "%s %s > /etc/apt/sources.list.d/%s.list`,
+			keyring, quote(repo.URL), suite, quote(strings.Join(components, " ")), repo.Name),
+	}, "\n"), nil
+}
+
+func (a *APT) RemoveRepository(name string) (string, error) {
+	if !repoNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid repository name '%s'", name)
+	}
+	return fmt.Sprintf("rm -f /etc/apt/sources.list.d/%s.list /etc/apt/keyrings/%s.gpg", name, name), nil
+}
+
+func (a *APT) ListRepositories(run Runner) ([]Repository, error) {
+	out, err := run("grep -sH -E '^(deb |URIs:)' /etc/apt/sources.list.d/*.list /etc/apt/sources.list.d/*.sources")
+	if err != nil && strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+	return parseAptSources(out), nil
+}
+
+func (a *APT) RepositoryTools() map[string]string {
+	return map[string]string{"curl": "curl", "gpg": "gnupg"}
+}
+
+// DNF and YUM
+
+func (d *DNF) AddRepository(repo Repository) (string, error) {
+	return rpmAddRepository(repo)
+}
+
+func (d *DNF) RemoveRepository(name string) (string, error) {
+	return rpmRemoveRepository(name)
+}
+
+func (d *DNF) ListRepositories(run Runner) ([]Repository, error) {
+	return rpmListRepositories(run)
+}
+
+func (d *DNF) RepositoryTools() map[string]string {
+	return map[string]string{"curl": "curl", "gpg": "gnupg2"}
+}
+
+func (y *YUM) AddRepository(repo Repository) (string, error) {
+	return rpmAddRepository(repo)
+}
+
+func (y *YUM) RemoveRepository(name string) (string, error) {
+	return rpmRemoveRepository(name)
+}
+
+func (y *YUM) ListRepositories(run Runner) ([]Repository, error) {
+	return rpmListRepositories(run)
+}
+
+func (y *YUM) RepositoryTools() map[string]string {
+	return map[string]string{"curl": "curl", "gpg": "gnupg2"}
+}
+
+func rpmAddRepository(repo Repository) (string, error) {
+	repo, err := validateRepository(repo, pgpFingerprint, true)
+	if err != nil {
+		return "", err
+	}
+	keyFile := "/etc/pki/rpm-gpg/RPM-GPG-KEY-" + repo.Name
+
+	return strings.Join([]string{
+		fetchPGPKey(repo),
+		"install -d -m 0755 /etc/pki/rpm-gpg",
+		fmt.Sprintf(`install -m 0644 "$key" %s`, keyFile),
+		"rpm --import " + keyFile,
+		fmt.Sprintf("printf '%%s\\n' %s %s %s enabled=1 gpgcheck=1 %s > /etc/yum.repos.d/%s.repo",
+			quote("["+repo.Name+"]"), quote("name="+repo.Name), quote("baseurl="+repo.URL), quote("gpgkey=file://"+keyFile), repo.Name),
+	}, "\n"), nil
+}
+
+func rpmRemoveRepository(name string) (string, error) {
+	if !repoNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid repository name '%s'", name)
+	}
+	return fmt.Sprintf("rm -f /etc/yum.repos.d/%s.repo /etc/pki/rpm-gpg/RPM-GPG-KEY-%s", name, name), nil
+}
+
+func rpmListRepositories(run Runner) ([]Repository, error) {
+	out, err := run("grep -sH -E '^(\\[|baseurl|mirrorlist|metalink)' /etc/yum.repos.d/*.repo")
+	if err != nil && strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+	return parseRepoFiles(out), nil
+}
+
+// Pacman
+
+func (p *Pacman) AddRepository(repo Repository) (string, error) {
+	return "", fmt.Errorf("adding repositories is not supported with pacman; edit /etc/pacman.conf")
+}
+
+func (p *Pacman) RemoveRepository(name string) (string, error) {
+	return "", fmt.Errorf("removing repositories is not supported with pacman; edit /etc/pacman.conf")
+}
+
+func (p *Pacman) ListRepositories(run Runner) ([]Repository, error) {
+	out, err := run("pacman-conf --repo-list")
+	if err != nil {
+		return nil, nil
+	}
+	var repos []Repository
+	for _, name := range strings.Fields(out) {
+		repos = append(repos, Repository{Name: name, File: "/etc/pacman.conf"})
+	}
+	return repos, nil
+}
+
+func (p *Pacman) RepositoryTools() map[string]string {
+	return map[string]string{}
+}
+
+// APK
+
+// AddRepository adds a tagged repository: its packages are only installed
+// when asked for as <package>@<name>, so they can't replace system packages
+func (a *APK) AddRepository(repo Repository) (string, error) {
+	repo, err := validateRepository(repo, sha256Fingerprint, false)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{"set -e"}
+	if repo.KeyURL != "" {
+		keyFile := "/etc/apk/keys/" + repo.Name + ".rsa.pub"
+		lines = append(lines,
+			`key=$(mktemp)`,
+			`trap 'rm -f "$key"' EXIT`,
+			"curl -fsSL "+quote(repo.KeyURL)+` -o "$key"`,
+			`sum=$(sha256sum "$key" | cut -d' ' -f1)`,
+			fmt.Sprintf(`echo "$sum" | grep -qix %s || { echo "signing key checksum mismatch for %s: expected %s, got $sum" >&2; exit 1; }`,
+				repo.Fingerprint, repo.Name, repo.Fingerprint),
+			fmt.Sprintf(`install -m 0644 "$key" %s`, keyFile),
+		)
+	}
+	lines = append(lines,
+		fmt.Sprintf("sed -i '/^@%s /d' /etc/apk/repositories", regexp.QuoteMeta(repo.Name)),
+		fmt.Sprintf("echo %s >> /etc/apk/repositories", quote("@"+repo.Name+" "+repo.URL)),
+	)
+	return strings.Join(lines, "\n"), nil
+}
+
+func (a *APK) RemoveRepository(name string) (string, error) {
+	if !repoNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid repository name '%s'", name)
+	}
+	return fmt.Sprintf("sed -i '/^@%s /d' /etc/apk/repositories && rm -f /etc/apk/keys/%s.rsa.pub", regexp.QuoteMeta(name), name), nil
+}
+
+func (a *APK) ListRepositories(run Runner) ([]Repository, error) {
+	out, err := run("cat /etc/apk/repositories")
+	if err != nil {
+		return nil, err
+	}
+	return parseApkRepositories(out), nil
+}
+
+func (a *APK) RepositoryTools() map[string]string {
+	return map[string]string{"curl": "curl"}
+}
+
+// Zypper
+
+func (z *Zypper) AddRepository(repo Repository) (string, error) {
+	repo, err := validateRepository(repo, pgpFingerprint, true)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		fetchPGPKey(repo),
+		`rpm --import "$key"`,
+		fmt.Sprintf("zypper --non-interactive removerepo %s >/dev/null 2>&1 || true", repo.Name),
+		fmt.Sprintf("zypper --non-interactive addrepo --refresh --gpgcheck %s %s", quote(repo.URL), repo.Name),
+	}, "\n"), nil
+}
+
+func (z *Zypper) RemoveRepository(name string) (string, error) {
+	if !repoNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid repository name '%s'", name)
+	}
+	return "zypper --non-interactive removerepo " + name, nil
+}
+
+func (z *Zypper) ListRepositories(run Runner) ([]Repository, error) {
+	out, err := run("zypper --non-interactive --quiet repos --uri")
+	if err != nil {
+		return nil, err
+	}
+	var repos []Repository
+	for _, row := range parseZypperTable(out) {
+		// # | Alias | Name | Enabled | GPG Check | Refresh | URI
+		if len(row) >= 3 {
+			repos = append(repos, Repository{Name: row[1], URL: row[len(row)-1]})
+		}
+	}
+	return repos, nil
+}
+
+func (z *Zypper) RepositoryTools() map[string]string {
+	return map[string]string{"curl": "curl", "gpg": "gpg2"}
+}
+
+// Unsupported
+
+func (u *Unsupported) AddRepository(repo Repository) (string, error) {
+	return "", u.err()
+}
+
+func (u *Unsupported) RemoveRepository(name string) (string, error) {
+	return "", u.err()
+}
+
+func (u *Unsupported) ListRepositories(run Runner) ([]Repository, error) {
+	return nil, u.err()
+}
+
+func (u *Unsupported) RepositoryTools() map[string]string {
+	return map[string]string{}
+}
+
+// Parsers
+
+// parseAptSources reads grep -H output of one-line "deb" entries and deb822
+// "URIs:" fields, naming each repository after its file
+func parseAptSources(out string) []Repository {
+	var repos []Repository
+	for _, line := range strings.Split(out, "\n") {
+		file, entry, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimSuffix(path.Base(file), ".list"), ".sources")
+		fields := strings.Fields(entry)
+		switch {
+		case len(fields) >= 2 && fields[0] == "URIs:":
+			for _, uri := range fields[1:] {
+				repos = append(repos, Repository{Name: name, URL: uri, File: file})
+			}
+		case len(fields) >= 3 && fields[0] == "deb":
+			fields = fields[1:]
+			if strings.HasPrefix(fields[0], "[") {
+				for len(fields) > 0 && !strings.HasSuffix(fields[0], "]") {
+					fields = fields[1:]
+				}
+				if len(fields) > 0 {
+					fields = fields[1:]
+				}
+			}
+			if len(fields) < 2 {
+				continue
+			}
+			repos = append(repos, Repository{Name: name, URL: fields[0], Suite: fields[1], Components: fields[2:], File: file})
+		}
+	}
+	return repos
+}
+
+// parseRepoFiles reads grep -H output of .repo section headers and URL keys
+func parseRepoFiles(out string) []Repository {
+	var repos []Repository
+	for _, line := range strings.Split(out, "\n") {
+		file, entry, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		entry = strings.TrimSpace(entry)
+		if strings.HasPrefix(entry, "[") && strings.HasSuffix(entry, "]") {
+			repos = append(repos, Repository{Name: strings.Trim(entry, "[]"), File: file})
+			continue
+		}
+		if _, value, ok := strings.Cut(entry, "="); ok && len(repos) > 0 && repos[len(repos)-1].URL == "" {
+			repos[len(repos)-1].URL = strings.TrimSpace(value)
+		}
+	}
+	return repos
+}
+
+// parseApkRepositories reads /etc/apk/repositories. Tagged entries are named
+// by their tag, the rest by their URL's last two path elements.
+func parseApkRepositories(out string) []Repository {
+	var repos []Repository
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		repo := Repository{URL: fields[len(fields)-1], File: "/etc/apk/repositories"}
+		if strings.HasPrefix(fields[0], "@") && len(fields) > 1 {
+			repo.Name = strings.TrimPrefix(fields[0], "@")
+		} else {
+			parts := strings.Split(strings.TrimSuffix(repo.URL, "/"), "/")
+			if len(parts) >= 2 {
+				parts = parts[len(parts)-2:]
+			}
+			repo.Name = strings.Join(parts, "/")
+		}
+		repos = append(repos, repo)
+	}
+	return repos
+}
+
+// quote single-quotes s for a POSIX shell
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
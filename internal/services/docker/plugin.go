@@ -7,7 +7,10 @@ import (
 
 	"github.com/spf13/cobra"
 	
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -25,7 +28,7 @@ func (p *Plugin) Author() string {
 }
 
 func (p *Plugin) Version() string {
-	return "0.0.1"
+	return "0.1.0"
 }
 
 func (p *Plugin) Initialize(config map[string]interface{}) error {
@@ -57,12 +60,19 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 	}
 }
 
+// ServiceInstalled reports whether the docker engine is installed on the target
+func (p *Plugin) ServiceInstalled(conn plugin.Connection) bool {
+	return conn.RunCommand("command -v docker", false).Success
+}
+
+func (p *Plugin) InstallCommand() string {
+	return "install"
+}
+
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
-		Tags:              []string{"TODO", "add", "relevant", "tags"},
+		MinVPSInitVersion: "0.1.0",
+		Tags:              []string{"containers", "docker", "compose"},
 	}
 }
 
@@ -74,7 +84,7 @@ func (p *Plugin) GetMetadata() plugin.PluginMetadata {
 		Author:      p.Author(),
 		License:     "MIT",
 		Repository:  "github.com/wasilwamark/vps-init-plugins/" + p.Name(),
-		Tags:        []string{"TODO", "add", "tags"},
+		Tags:        []string{"containers", "docker", "compose"},
 		Validated:   true,
 		TrustLevel:  "official",
 		BuildInfo: plugin.BuildInfo{
@@ -143,28 +153,82 @@ func (p *Plugin) GetCommands() []plugin.Command {
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🐳 Installing Docker...")
 
-	// using convenience script
-	cmd := "curl -fsSL https://get.docker.com | sh"
-	pass := getSudoPass(flags)
-
-	result := conn.RunSudo(cmd, pass); if !result.Success {
-		return fmt.Errorf("failed to install docker: %s", result.Stderr)
+	c := sdk.New(ctx, conn, flags)
+	packages := []string{"docker-ce", "docker-ce-cli", "containerd.io", "docker-buildx-plugin", "docker-compose-plugin"}
+	if repo, ok := dockerRepository(c.Distro()); ok {
+		if err := c.AddRepository(repo); err != nil {
+			return err
+		}
+	} else if c.Distro().Family == distro.DistroFamilyAlpine {
+		packages = []string{"docker", "docker-cli-compose"}
+	} else if c.Distro().IsAmazonLinux() {
+		packages = []string{"docker"}
+	} else {
+		packages = []string{"docker", "docker-compose"}
+	}
+	if err := c.Install(packages...); err != nil {
+		return fmt.Errorf("failed to install docker: %w", err)
+	}
+	if err := c.EnableService("docker"); err != nil {
+		return err
 	}
 
 	// Add user to docker group
 	fmt.Println("👤 Adding user to docker group...")
-	result = conn.RunSudo("usermod -aG docker $USER", pass); if !result.Success {
-		fmt.Printf("⚠️  Failed to add user to docker group: %s\n", result.Stderr)
+	if err := c.Exec("usermod -aG docker " + conn.User()); err != nil {
+		c.Warn("Failed to add user to docker group: %v", err)
 	} else {
-		fmt.Println("✅ User added to docker group (requires re-login to take effect)")
+		c.Success("User added to docker group (requires re-login to take effect)")
 	}
 
-	fmt.Println("✅ Docker installed successfully!")
+	c.Success("Docker installed successfully!")
+	c.Publish(plugin.ServiceInstalled{Service: "docker"})
 	return nil
 }
 
+// dockerRepository returns Docker's upstream repository for the target, or
+// false where the distribution packages Docker itself
+func dockerRepository(info *distro.DistroInfo) (pkgmgr.Repository, bool) {
+	switch {
+	case info.IsAmazonLinux():
+		// Amazon Linux ships Docker; Docker's CentOS repo doesn't fit it
+		return pkgmgr.Repository{}, false
+	case info.IsUbuntu():
+		return pkgmgr.Repository{
+			Name:        "docker",
+			URL:         "https://download.docker.com/linux/ubuntu",
+			Components:  []string{"stable"},
+			KeyURL:      "https://download.docker.com/linux/ubuntu/gpg",
+			Fingerprint: "9DC858229FC7DD38854AE2D88D81803C0EBFCD88",
+		}, true
+	case info.Family == distro.DistroFamilyDebian:
+		return pkgmgr.Repository{
+			Name:        "docker",
+			URL:         "https://download.docker.com/linux/debian",
+			Components:  []string{"stable"},
+			KeyURL:      "https://download.docker.com/linux/debian/gpg",
+			Fingerprint: "9DC858229FC7DD38854AE2D88D81803C0EBFCD88",
+		}, true
+	case info.IsFedora():
+		return pkgmgr.Repository{
+			Name:        "docker",
+			URL:         "https://download.docker.com/linux/fedora/$releasever/$basearch/stable",
+			KeyURL:      "https://download.docker.com/linux/fedora/gpg",
+			Fingerprint: "060A61C51B558A7F742B77AAC52FEB6B621E9F35",
+		}, true
+	case info.Family == distro.DistroFamilyRedHat:
+		return pkgmgr.Repository{
+			Name:        "docker",
+			URL:         "https://download.docker.com/linux/centos/$releasever/$basearch/stable",
+			KeyURL:      "https://download.docker.com/linux/centos/gpg",
+			Fingerprint: "060A61C51B558A7F742B77AAC52FEB6B621E9F35",
+		}, true
+	}
+	return pkgmgr.Repository{}, false
+}
+
 func (p *Plugin) statusHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	return conn.RunInteractive("systemctl status docker")
+	return sdk.New(ctx, conn, flags).ServiceStatus("docker")
 }
 
 func (p *Plugin) composeHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
@@ -201,11 +265,3 @@ func (p *Plugin) simpleComposeHandler(subcmd string) plugin.CommandHandler {
 		return conn.RunInteractive(cmd)
 	}
 }
-
-// Helper
-func getSudoPass(flags map[string]interface{}) string {
-	if v, ok := flags["sudo-password"]; ok {
-		return v.(string)
-	}
-	return ""
-}
@@ -6,9 +6,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -59,10 +58,8 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
-		Tags:              []string{"TODO", "add", "relevant", "tags"},
+		MinVPSInitVersion: "0.1.0",
+		Tags:              []string{"security", "intrusion-prevention", "fail2ban"},
 	}
 }
 
@@ -74,7 +71,7 @@ func (p *Plugin) GetMetadata() plugin.PluginMetadata {
 		Author:      p.Author(),
 		License:     "MIT",
 		Repository:  "github.com/wasilwamark/vps-init-plugins/" + p.Name(),
-		Tags:        []string{"TODO", "add", "tags"},
+		Tags:        []string{"security", "intrusion-prevention", "fail2ban"},
 		Validated:   true,
 		TrustLevel:  "official",
 		BuildInfo: plugin.BuildInfo{
@@ -110,39 +107,18 @@ func (p *Plugin) GetCommands() []plugin.Command {
 
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🛡️  Installing Fail2Ban...")
-	pass := getSudoPass(flags)
-	pkgMgr := getPackageManager(conn)
-
-	// Update & Install
-	updateCmd, _ := pkgMgr.Update()
-	result := conn.RunSudo(updateCmd, pass)
-	if !result.Success {
-		return fmt.Errorf("failed to update package lists: %s", result.Stderr)
-	}
-	installCmd, err := pkgMgr.Install("fail2ban")
-	if err != nil {
+	c := sdk.New(ctx, conn, flags)
+
+	if err := c.Install("fail2ban"); err != nil {
 		return err
 	}
-	result = conn.RunSudo(installCmd, pass)
-	if !result.Success {
-		return fmt.Errorf("failed to install fail2ban: %s", result.Stderr)
-	}
-	result = conn.RunSudo("apt-get install -y fail2ban", pass)
-	if !result.Success {
-		return fmt.Errorf("failed to install fail2ban: %s", result.Stderr)
-	}
 
 	// Ensure service is running
-	result = conn.RunSudo("systemctl enable fail2ban", pass)
-	if !result.Success {
-		return fmt.Errorf("failed to enable fail2ban: %s", result.Stderr)
-	}
-	result = conn.RunSudo("systemctl start fail2ban", pass)
-	if !result.Success {
-		return fmt.Errorf("failed to start fail2ban: %s", result.Stderr)
+	if err := c.EnableService("fail2ban"); err != nil {
+		return err
 	}
 
-	fmt.Println("✅ Fail2Ban installed and running.")
+	c.Success("Fail2Ban installed and running.")
 	return nil
 }
 
@@ -180,16 +156,3 @@ func (p *Plugin) unbanHandler(ctx context.Context, conn plugin.Connection, args
 
 	return conn.RunInteractive(cmd)
 }
-
-// Helper
-func getSudoPass(flags map[string]interface{}) string {
-	if v, ok := flags["sudo-password"]; ok {
-		return v.(string)
-	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-	return pkgmgr.GetPackageManager(distroInfo)
-}
@@ -0,0 +1,192 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+// SubscribeEvents opens the firewall for sites and services other plugins set up
+func (p *Plugin) SubscribeEvents() {
+	plugin.Subscribe(p.Name(), p.onSiteAdded)
+	plugin.Subscribe(p.Name(), p.onPortOpened)
+	plugin.Subscribe(p.Name(), p.onPortClosed)
+}
+
+// onSiteAdded makes sure HTTP and HTTPS are reachable for a new site
+func (p *Plugin) onSiteAdded(ctx context.Context, conn plugin.Connection, flags map[string]interface{}, event plugin.SiteAdded) error {
+	c := sdk.New(ctx, conn, flags)
+	for _, port := range []int{80, 443} {
+		if err := openPort(c, port, "tcp"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onPortOpened allows the port a service listens on
+func (p *Plugin) onPortOpened(ctx context.Context, conn plugin.Connection, flags map[string]interface{}, event plugin.PortOpened) error {
+	return openPort(sdk.New(ctx, conn, flags), event.Port, event.Protocol)
+}
+
+// onPortClosed removes the rule for a port nothing listens on any more
+func (p *Plugin) onPortClosed(ctx context.Context, conn plugin.Connection, flags map[string]interface{}, event plugin.PortClosed) error {
+	return closePort(sdk.New(ctx, conn, flags), event.Port, event.Protocol)
+}
+
+// openPort allows a port in whichever firewall is active. Servers without an
+// active firewall are left alone.
+func openPort(c *sdk.Context, port int, protocol string) error {
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	spec := fmt.Sprintf("%d/%s", port, protocol)
+
+	if result := c.Sudo("ufw status"); result.Success && strings.Contains(result.Stdout, "Status: active") {
+		if ufwAllows(result.Stdout, port, protocol) {
+			return nil
+		}
+		if result := c.Sudo("ufw allow " + spec); !result.Success {
+			return fmt.Errorf("failed to allow %s: %s", spec, result.Stderr)
+		}
+		fmt.Printf("🔥 Firewall: allowed %s\n", spec)
+		return nil
+	}
+
+	if result := c.Sudo("firewall-cmd --state"); result.Success && strings.TrimSpace(result.Stdout) == "running" {
+		if c.Sudo("firewall-cmd --query-port=" + spec).Success {
+			return nil
+		}
+		cmd := fmt.Sprintf("firewall-cmd --permanent --add-port=%s && firewall-cmd --reload", spec)
+		if result := c.Shell(cmd); !result.Success {
+			return fmt.Errorf("failed to allow %s: %s", spec, result.Stderr)
+		}
+		fmt.Printf("🔥 Firewall: allowed %s\n", spec)
+		return nil
+	}
+
+	fmt.Printf("ℹ️  No active firewall, %s not changed\n", spec)
+	return nil
+}
+
+// closePort removes the rule allowing a port from whichever firewall is
+// active. SSH on 22/tcp is often allowed by service name, so that rule goes
+// too.
+func closePort(c *sdk.Context, port int, protocol string) error {
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	spec := fmt.Sprintf("%d/%s", port, protocol)
+	isSSH := spec == "22/tcp"
+
+	if result := c.Sudo("ufw status"); result.Success && strings.Contains(result.Stdout, "Status: active") {
+		rules := []string{spec}
+		if isSSH {
+			rules = append(rules, "ssh", "OpenSSH")
+		}
+		for _, rule := range rules {
+			c.Sudo("ufw delete allow " + rule)
+		}
+		if result := c.Sudo("ufw status"); ufwAllows(result.Stdout, port, protocol) {
+			return fmt.Errorf("failed to remove %s: %s", spec, result.Stderr)
+		}
+		fmt.Printf("🔥 Firewall: removed %s\n", spec)
+		return nil
+	}
+
+	if result := c.Sudo("firewall-cmd --state"); result.Success && strings.TrimSpace(result.Stdout) == "running" {
+		var removes []string
+		if c.Sudo("firewall-cmd --query-port=" + spec).Success {
+			removes = append(removes, "--remove-port="+spec)
+		}
+		if isSSH && c.Sudo("firewall-cmd --query-service=ssh").Success {
+			removes = append(removes, "--remove-service=ssh")
+		}
+		if len(removes) == 0 {
+			return nil
+		}
+		cmd := fmt.Sprintf("firewall-cmd --permanent %s && firewall-cmd --reload", strings.Join(removes, " "))
+		if result := c.Shell(cmd); !result.Success {
+			return fmt.Errorf("failed to remove %s: %s", spec, result.Stderr)
+		}
+		fmt.Printf("🔥 Firewall: removed %s\n", spec)
+	}
+	return nil
+}
+
+// ufwRule is a rule line of "ufw status"
+type ufwRule struct {
+	to     string // port spec or application profile, e.g. 80/tcp, 80,443/tcp, 6000:6007/udp or OpenSSH
+	action string // ALLOW, DENY, REJECT or LIMIT
+	in     bool   // false for outgoing and routed rules
+}
+
+// parseUFWStatus reads the rules listed by "ufw status", IPv4 and IPv6 alike
+func parseUFWStatus(out string) []ufwRule {
+	var rules []ufwRule
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if i == 0 || (field != "ALLOW" && field != "DENY" && field != "REJECT" && field != "LIMIT") {
+				continue
+			}
+			to := fields[:i]
+			if to[len(to)-1] == "(v6)" {
+				to = to[:len(to)-1]
+			}
+			// "22/tcp on eth0" is bound to an interface; the port is what matters
+			for j, token := range to {
+				if token == "on" && j > 0 {
+					to = to[:j]
+					break
+				}
+			}
+			in := i+1 >= len(fields) || (fields[i+1] != "OUT" && fields[i+1] != "FWD")
+			rules = append(rules, ufwRule{to: strings.Join(to, " "), action: field, in: in})
+			break
+		}
+	}
+	return rules
+}
+
+// allows reports whether the rule lets incoming traffic reach port/protocol
+func (r ufwRule) allows(port int, protocol string) bool {
+	if !r.in || (r.action != "ALLOW" && r.action != "LIMIT") {
+		return false
+	}
+	// A destination address comes before the port, as in "10.0.0.1 22/tcp"
+	spec := r.to
+	if i := strings.LastIndex(spec, " "); i >= 0 {
+		spec = spec[i+1:]
+	}
+	ports, proto, hasProto := strings.Cut(spec, "/")
+	if hasProto && proto != protocol {
+		return false
+	}
+	for _, part := range strings.Split(ports, ",") {
+		low, high, isRange := strings.Cut(part, ":")
+		if !isRange {
+			high = low
+		}
+		from, err1 := strconv.Atoi(low)
+		to, err2 := strconv.Atoi(high)
+		if err1 == nil && err2 == nil && from <= port && port <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// ufwAllows reports whether "ufw status" output has a rule allowing port/protocol in
+func ufwAllows(status string, port int, protocol string) bool {
+	for _, rule := range parseUFWStatus(status) {
+		if rule.allows(port, protocol) {
+			return true
+		}
+	}
+	return false
+}
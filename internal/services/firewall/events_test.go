@@ -0,0 +1,64 @@
+package firewall
+
+import "testing"
+
+// ufwStatus is "ufw status" output from Ubuntu 22.04 with IPv6 enabled
+const ufwStatus = `Status: active
+
+To                         Action      From
+--                         ------      ----
+2222/tcp                   ALLOW       Anywhere
+OpenSSH                    ALLOW       Anywhere
+8080/tcp                   ALLOW       Anywhere
+3306/tcp                   DENY        Anywhere
+443                        ALLOW       Anywhere
+60000:61000/udp            ALLOW       Anywhere
+25,587/tcp                 ALLOW       Anywhere
+5432/tcp on eth1           ALLOW       10.0.0.0/8
+10.0.0.5 6379/tcp          ALLOW       Anywhere
+9000/tcp                   LIMIT       Anywhere
+53/udp                     ALLOW OUT   Anywhere
+2222/tcp (v6)              ALLOW       Anywhere (v6)
+OpenSSH (v6)               ALLOW       Anywhere (v6)
+8080/tcp (v6)              ALLOW       Anywhere (v6)
+3306/tcp (v6)              DENY        Anywhere (v6)
+`
+
+func TestUFWAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     int
+		protocol string
+		want     bool
+	}{
+		{"exact rule", 2222, "tcp", true},
+		{"prefix of an allowed port", 222, "tcp", false},
+		{"suffix of an allowed port", 80, "tcp", false},
+		{"denied port", 3306, "tcp", false},
+		{"other protocol", 2222, "udp", false},
+		{"rule without protocol, tcp", 443, "tcp", true},
+		{"rule without protocol, udp", 443, "udp", true},
+		{"inside a range", 60001, "udp", true},
+		{"range end", 61000, "udp", true},
+		{"outside a range", 61001, "udp", false},
+		{"multiport rule", 587, "tcp", true},
+		{"interface rule", 5432, "tcp", true},
+		{"destination address rule", 6379, "tcp", true},
+		{"limited port", 9000, "tcp", true},
+		{"outgoing rule", 53, "udp", false},
+		{"application profile only", 22, "tcp", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ufwAllows(ufwStatus, tt.port, tt.protocol); got != tt.want {
+				t.Errorf("ufwAllows(%d/%s) = %v, want %v", tt.port, tt.protocol, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUFWAllowsInactive(t *testing.T) {
+	if ufwAllows("Status: inactive\n", 22, "tcp") {
+		t.Error("inactive firewall reported a rule")
+	}
+}
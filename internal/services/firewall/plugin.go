@@ -8,8 +8,8 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -52,9 +52,7 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
+		MinVPSInitVersion: "0.1.0",
 		Tags:              []string{"security", "networking", "firewall", "ufw"},
 	}
 }
@@ -218,10 +216,9 @@ func (p *Plugin) Stop(ctx context.Context) error {
 
 // Command handlers
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
+	c := sdk.New(ctx, conn, flags)
+	distroInfo := c.Distro()
 
-	fmt.Printf("ℹ️  Detected Distribution: %s %s\n", distroInfo.Name, distroInfo.Version)
 	fmt.Printf("🔥 Installing firewall (detected: %s)...\n", distroInfo.Family)
 
 	// Check if UFW is already installed
@@ -230,74 +227,49 @@ func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, arg
 			fmt.Println("✅ UFW is already installed")
 			return nil
 		}
-	} else if distroInfo.Family == distro.DistroFamilyRedHat {
+	} else if distroInfo.Family == distro.DistroFamilyRedHat || distroInfo.Family == distro.DistroFamilySUSE {
 		if result := conn.RunCommand("firewall-cmd --version", plugin.WithHideOutput()); result.Success {
 			fmt.Println("✅ Firewalld is already installed")
 			return nil
 		}
 	}
 
-	// Update package list
-	fmt.Println("Updating package list...")
-	pkgMgr := getPackageManager(conn)
-	updateCmd, _ := pkgMgr.Update()
-	fmt.Printf("⚡ Executing: %s\n", updateCmd)
-	if result := conn.RunSudo(updateCmd, sudoPass); !result.Success {
-		return fmt.Errorf("failed to update package list: %w", result.GetError())
-	}
-
 	// Install firewall
-	fmt.Println("Installing firewall...")
 	var pkgName string
 	if distroInfo.Family == distro.DistroFamilyDebian {
 		pkgName = "ufw"
-	} else if distroInfo.Family == distro.DistroFamilyRedHat {
+	} else if distroInfo.Family == distro.DistroFamilyRedHat || distroInfo.Family == distro.DistroFamilySUSE {
 		pkgName = "firewalld"
 	} else {
 		pkgName = "ufw"
 	}
-	installCmd, err := pkgMgr.Install(pkgName)
-	if err != nil {
+	if err := c.Install(pkgName); err != nil {
 		return err
 	}
-	fmt.Printf("⚡ Executing: %s\n", installCmd)
-	if result := conn.RunSudo(installCmd, sudoPass); !result.Success {
-		return fmt.Errorf("failed to install firewall: %w", result.GetError())
-	}
 
 	// Set default policy
 	defaultPolicy := "deny"
-	if dp, ok := flags["default-policy"].(string); ok && dp != "" {
+	if dp := c.String("default-policy"); dp != "" {
 		defaultPolicy = dp
 	}
 
 	fmt.Printf("Setting default policy to %s...\n", defaultPolicy)
-	if result := conn.RunSudo(fmt.Sprintf("ufw default %s", defaultPolicy), sudoPass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("ufw default %s", defaultPolicy)); !result.Success {
 		return fmt.Errorf("failed to set default policy: %w", result.GetError())
 	}
 
 	// Enable logging if requested
-	enableLogging := true
-	if el, ok := flags["enable-logging"].(bool); ok {
-		enableLogging = el
-	}
-
-	if enableLogging {
+	if c.Bool("enable-logging", true) {
 		fmt.Println("Enabling firewall logging...")
-		if result := conn.RunSudo("ufw logging on", sudoPass); !result.Success {
+		if result := c.Sudo("ufw logging on"); !result.Success {
 			return fmt.Errorf("failed to enable logging: %w", result.GetError())
 		}
 	}
 
 	// Allow SSH by default to prevent lockout
-	allowSSH := true
-	if as, ok := flags["allow-ssh"].(bool); ok {
-		allowSSH = as
-	}
-
-	if allowSSH {
+	if c.Bool("allow-ssh", true) {
 		fmt.Println("Allowing SSH connections...")
-		if result := conn.RunSudo("ufw allow ssh", sudoPass); !result.Success {
+		if result := c.Sudo("ufw allow " + sshRule(conn)); !result.Success {
 			return fmt.Errorf("failed to allow SSH: %w", result.GetError())
 		}
 	}
@@ -310,7 +282,7 @@ func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, arg
 }
 
 func (p *Plugin) allowHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	if len(args) < 1 {
 		return fmt.Errorf("port or service is required")
@@ -328,19 +300,10 @@ func (p *Plugin) allowHandler(ctx context.Context, conn plugin.Connection, args
 		from = args[2]
 	}
 
-	var cmd string
-	if protocol != "" && from != "" {
-		cmd = fmt.Sprintf("ufw allow from %s to any port %s proto %s", from, port, protocol)
-	} else if protocol != "" {
-		cmd = fmt.Sprintf("ufw allow %s/%s", port, protocol)
-	} else if from != "" {
-		cmd = fmt.Sprintf("ufw allow from %s to any port %s", from, port)
-	} else {
-		cmd = fmt.Sprintf("ufw allow %s", port)
-	}
+	cmd := "ufw " + RuleSpec("allow", port, protocol, from)
 
 	fmt.Printf("Allowing traffic: %s\n", cmd)
-	if result := conn.RunSudo(cmd, sudoPass); !result.Success {
+	if result := c.Sudo(cmd); !result.Success {
 		return fmt.Errorf("failed to allow traffic: %w", result.GetError())
 	}
 
@@ -349,7 +312,7 @@ func (p *Plugin) allowHandler(ctx context.Context, conn plugin.Connection, args
 }
 
 func (p *Plugin) denyHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	if len(args) < 1 {
 		return fmt.Errorf("port or service is required")
@@ -367,19 +330,10 @@ func (p *Plugin) denyHandler(ctx context.Context, conn plugin.Connection, args [
 		from = args[2]
 	}
 
-	var cmd string
-	if protocol != "" && from != "" {
-		cmd = fmt.Sprintf("ufw deny from %s to any port %s proto %s", from, port, protocol)
-	} else if protocol != "" {
-		cmd = fmt.Sprintf("ufw deny %s/%s", port, protocol)
-	} else if from != "" {
-		cmd = fmt.Sprintf("ufw deny from %s to any port %s", from, port)
-	} else {
-		cmd = fmt.Sprintf("ufw deny %s", port)
-	}
+	cmd := "ufw " + RuleSpec("deny", port, protocol, from)
 
 	fmt.Printf("Denying traffic: %s\n", cmd)
-	if result := conn.RunSudo(cmd, sudoPass); !result.Success {
+	if result := c.Sudo(cmd); !result.Success {
 		return fmt.Errorf("failed to deny traffic: %w", result.GetError())
 	}
 
@@ -415,22 +369,31 @@ func (p *Plugin) statusHandler(ctx context.Context, conn plugin.Connection, args
 	return nil
 }
 
+// sshRule is the ufw rule for the port vps-init connects on, which is not 22
+// once ssh-hardening has moved sshd
+func sshRule(conn plugin.Connection) string {
+	if conn.Port() == 22 {
+		return "ssh"
+	}
+	return fmt.Sprintf("%d/tcp", conn.Port())
+}
+
 func (p *Plugin) enableHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("🔥 Enabling firewall...")
 	fmt.Println("⚠️  WARNING: This will activate the firewall!")
 
 	// Check SSH rule before enabling to prevent lockout
-	if result := conn.RunCommand("ufw status | grep '22/tcp'", plugin.WithHideOutput()); !result.Success {
+	if !ufwAllows(c.Sudo("ufw status").Stdout, conn.Port(), "tcp") {
 		fmt.Println("❌ SSH rule not found! Adding SSH rule to prevent lockout...")
-		if result := conn.RunSudo("ufw allow ssh", sudoPass); !result.Success {
+		if result := c.Sudo("ufw allow " + sshRule(conn)); !result.Success {
 			return fmt.Errorf("failed to add SSH rule: %w", result.GetError())
 		}
 		fmt.Println("✅ SSH rule added")
 	}
 
-	if result := conn.RunSudo("ufw --force enable", sudoPass); !result.Success {
+	if result := c.Sudo("ufw --force enable"); !result.Success {
 		return fmt.Errorf("failed to enable firewall: %w", result.GetError())
 	}
 
@@ -439,11 +402,11 @@ func (p *Plugin) enableHandler(ctx context.Context, conn plugin.Connection, args
 }
 
 func (p *Plugin) disableHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("🔥 Disabling firewall...")
 
-	if result := conn.RunSudo("ufw disable", sudoPass); !result.Success {
+	if result := c.Sudo("ufw disable"); !result.Success {
 		return fmt.Errorf("failed to disable firewall: %w", result.GetError())
 	}
 
@@ -452,12 +415,12 @@ func (p *Plugin) disableHandler(ctx context.Context, conn plugin.Connection, arg
 }
 
 func (p *Plugin) resetHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("🔥 Resetting firewall to default settings...")
 	fmt.Println("⚠️  WARNING: This will remove all firewall rules!")
 
-	if result := conn.RunSudo("ufw --force reset", sudoPass); !result.Success {
+	if result := c.Sudo("ufw --force reset"); !result.Success {
 		return fmt.Errorf("failed to reset firewall: %w", result.GetError())
 	}
 
@@ -467,7 +430,7 @@ func (p *Plugin) resetHandler(ctx context.Context, conn plugin.Connection, args
 }
 
 func (p *Plugin) deleteHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	if len(args) < 1 {
 		return fmt.Errorf("rule number is required")
@@ -476,7 +439,7 @@ func (p *Plugin) deleteHandler(ctx context.Context, conn plugin.Connection, args
 	ruleNum := args[0]
 
 	fmt.Printf("🔥 Deleting firewall rule %s...\n", ruleNum)
-	if result := conn.RunSudo(fmt.Sprintf("ufw delete %s", ruleNum), sudoPass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("ufw delete %s", ruleNum)); !result.Success {
 		return fmt.Errorf("failed to delete rule: %w", result.GetError())
 	}
 
@@ -485,7 +448,7 @@ func (p *Plugin) deleteHandler(ctx context.Context, conn plugin.Connection, args
 }
 
 func (p *Plugin) loggingHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	if len(args) < 1 {
 		return fmt.Errorf("logging action is required (on/off/low/medium/high/full)")
@@ -507,7 +470,7 @@ func (p *Plugin) loggingHandler(ctx context.Context, conn plugin.Connection, arg
 	}
 
 	fmt.Printf("🔥 Setting firewall logging to %s...\n", action)
-	if result := conn.RunSudo(fmt.Sprintf("ufw logging %s", action), sudoPass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("ufw logging %s", action)); !result.Success {
 		return fmt.Errorf("failed to set logging: %w", result.GetError())
 	}
 
@@ -515,24 +478,23 @@ func (p *Plugin) loggingHandler(ctx context.Context, conn plugin.Connection, arg
 	return nil
 }
 
-// Helper function to get sudo password from flags
-func getSudoPass(flags map[string]interface{}) string {
-	if pass, ok := flags["sudo_password"].(string); ok {
-		return pass
+// RuleSpec builds the ufw rule arguments for an allow/deny rule, e.g. "allow 443/tcp"
+func RuleSpec(action, port, protocol, from string) string {
+	if protocol != "" && from != "" {
+		return fmt.Sprintf("%s from %s to any port %s proto %s", action, from, port, protocol)
+	} else if protocol != "" {
+		return fmt.Sprintf("%s %s/%s", action, port, protocol)
+	} else if from != "" {
+		return fmt.Sprintf("%s from %s to any port %s", action, from, port)
 	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-	return pkgmgr.GetPackageManager(distroInfo)
+	return fmt.Sprintf("%s %s", action, port)
 }
 
 func getFirewallCmd(conn plugin.Connection) string {
 	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
 	if distroInfo.Family == distro.DistroFamilyDebian {
 		return "ufw"
-	} else if distroInfo.Family == distro.DistroFamilyRedHat {
+	} else if distroInfo.Family == distro.DistroFamilyRedHat || distroInfo.Family == distro.DistroFamilySUSE {
 		return "firewall-cmd"
 	}
 	return "ufw"
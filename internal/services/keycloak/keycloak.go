@@ -7,13 +7,12 @@ import (
 	"strings"
 	"time"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("🔐 Installing Keycloak...")
 
@@ -49,7 +48,7 @@ func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, arg
 	adminPassword := generateRandomPassword(32)
 
 	fmt.Println("📁 Creating installation directory...")
-	if result := conn.RunSudo(fmt.Sprintf("mkdir -p %s", keycloakDir), sudoPass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("mkdir -p %s", keycloakDir)); !result.Success {
 		return fmt.Errorf("failed to create installation directory: %s", result.Stderr)
 	}
 
@@ -57,12 +56,12 @@ func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, arg
 	fmt.Println("📝 Creating Docker Compose configuration...")
 	dockerComposeContent := fmt.Sprintf(dockerComposeTemplate, dbPassword, dbPassword, adminPassword, domain)
 
-	if err := conn.WriteFile(dockerComposeContent, fmt.Sprintf("%s/docker-compose.yml", keycloakDir)); err != nil {
-		return fmt.Errorf("failed to create docker-compose.yml: %v", err)
+	if err := c.WriteFile(fmt.Sprintf("%s/docker-compose.yml", keycloakDir), dockerComposeContent, 0644); err != nil {
+		return err
 	}
 
 	// Set ownership
-	if result := conn.RunSudo(fmt.Sprintf("chown -R $USER:$USER %s", keycloakDir), sudoPass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("chown -R %s: %s", conn.User(), keycloakDir)); !result.Success {
 		return fmt.Errorf("failed to set ownership: %s", result.Stderr)
 	}
 
@@ -83,34 +82,25 @@ func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, arg
 	nginxConfig := fmt.Sprintf(nginxTemplate, domain)
 	nginxConfigPath := fmt.Sprintf("/etc/nginx/sites-available/%s", domain)
 
-	// Write nginx config to temp file first
-	tempNginxPath := fmt.Sprintf("/tmp/%s.conf", domain)
-	if err := conn.WriteFile(nginxConfig, tempNginxPath); err != nil {
-		return fmt.Errorf("failed to write nginx config: %v", err)
+	if err := c.WriteFile(nginxConfigPath, nginxConfig, 0644); err != nil {
+		return err
 	}
-
-	// Move to sites-available and enable
-	nginxCmds := []string{
-		fmt.Sprintf("mv %s %s", tempNginxPath, nginxConfigPath),
-		fmt.Sprintf("ln -sf %s /etc/nginx/sites-enabled/", nginxConfigPath),
-	}
-
-	for _, cmd := range nginxCmds {
-		if result := conn.RunSudo(cmd, sudoPass); !result.Success {
-			return fmt.Errorf("failed to configure nginx: %s", result.Stderr)
-		}
+	if err := c.Exec(fmt.Sprintf("ln -sf %s /etc/nginx/sites-enabled/", nginxConfigPath)); err != nil {
+		return fmt.Errorf("failed to configure nginx: %w", err)
 	}
 
 	// Test nginx config
-	if result := conn.RunSudo("nginx -t", sudoPass); !result.Success {
+	if result := c.Sudo("nginx -t"); !result.Success {
 		fmt.Println("⚠️  Nginx config test failed, removing configuration...")
-		conn.RunSudo(fmt.Sprintf("rm -f %s /etc/nginx/sites-enabled/%s", nginxConfigPath, domain), sudoPass)
+		c.Sudo(fmt.Sprintf("rm -f /etc/nginx/sites-enabled/%s", domain))
+		if err := c.RestoreBackup(nginxConfigPath); err != nil {
+			c.Warn("%v", err)
+		}
 		return fmt.Errorf("nginx configuration error: %s", result.Stderr)
 	}
 
-	// Reload nginx
-	if result := conn.RunSudo("systemctl reload nginx", sudoPass); !result.Success {
-		return fmt.Errorf("failed to reload nginx: %s", result.Stderr)
+	if err := c.Service("reload", "nginx"); err != nil {
+		return err
 	}
 
 	// Save credentials to file
@@ -128,13 +118,8 @@ Installation Date: %s
 `, domain, adminPassword, dbPassword, domain, domain, time.Now().Format("2006-01-02 15:04:05"))
 
 	credentialsFile := fmt.Sprintf("%s/credentials.txt", keycloakDir)
-	if err := conn.WriteFile(credentialsContent, credentialsFile); err != nil {
-		fmt.Printf("⚠️  Failed to save credentials file: %v\n", err)
-	}
-
-	// Set file permissions
-	if result := conn.RunSudo(fmt.Sprintf("chmod 600 %s", credentialsFile), sudoPass); !result.Success {
-		fmt.Printf("⚠️  Failed to set credentials file permissions\n")
+	if err := c.WriteFile(credentialsFile, credentialsContent, 0600); err != nil {
+		c.Warn("Failed to save credentials file: %v", err)
 	}
 
 	fmt.Println("✅ Keycloak installed successfully!")
@@ -152,7 +137,7 @@ Installation Date: %s
 }
 
 func (p *Plugin) uninstallHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("🗑️  Uninstalling Keycloak...")
 
@@ -182,15 +167,17 @@ func (p *Plugin) uninstallHandler(ctx context.Context, conn plugin.Connection, a
 	}
 
 	for _, cmd := range nginxCmds {
-		conn.RunSudo(cmd, sudoPass)
+		c.Sudo(cmd)
 	}
 
 	// Reload nginx
-	conn.RunSudo("systemctl reload nginx", sudoPass)
+	if err := c.Service("reload", "nginx"); err != nil {
+		c.Warn("%v", err)
+	}
 
 	// Remove installation directory
 	fmt.Println("📁 Removing installation directory...")
-	if result := conn.RunSudo(fmt.Sprintf("rm -rf %s", keycloakDir), sudoPass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("rm -rf %s", keycloakDir)); !result.Success {
 		fmt.Printf("⚠️  Failed to remove installation directory: %s\n", result.Stderr)
 	}
 
@@ -420,7 +407,7 @@ func (p *Plugin) clientHandler(ctx context.Context, conn plugin.Connection, args
 }
 
 func (p *Plugin) sslHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	domain := "keycloak.local"
 	if len(args) > 0 {
@@ -431,25 +418,15 @@ func (p *Plugin) sslHandler(ctx context.Context, conn plugin.Connection, args []
 
 	// Install certbot and nginx plugin
 	fmt.Println("📦 Installing Certbot...")
-	pkgMgr := getPackageManager(conn)
-	updateCmd, _ := pkgMgr.Update()
-	if result := conn.RunSudo(updateCmd, sudoPass); !result.Success {
-		fmt.Printf("⚠️  Failed to update packages: %s\n", result.Stderr)
-	}
-	installCmd, err := pkgMgr.Install("certbot", "python3-certbot-nginx")
-	if err != nil {
-		fmt.Printf("⚠️  Failed to install certbot: %v\n", err)
-	} else {
-		if result := conn.RunSudo(installCmd, sudoPass); !result.Success {
-			fmt.Printf("⚠️  Failed to install certbot: %s\n", result.Stderr)
-		}
+	if err := c.Install("certbot-nginx"); err != nil {
+		c.Warn("%v", err)
 	}
 
 	// Obtain SSL certificate
 	fmt.Printf("🔐 Obtaining SSL certificate for %s...\n", domain)
 	cmd := fmt.Sprintf("certbot --nginx -d %s --non-interactive --agree-tos --email admin@%s", domain, domain)
 
-	if result := conn.RunSudo(cmd, sudoPass); !result.Success {
+	if result := c.Sudo(cmd); !result.Success {
 		return fmt.Errorf("failed to obtain SSL certificate: %s", result.Stderr)
 	}
 
@@ -486,21 +463,20 @@ server {
 
 	nginxConfigPath := fmt.Sprintf("/etc/nginx/sites-available/%s", domain)
 
-	if err := conn.WriteFile(sslConfig, fmt.Sprintf("/tmp/%s-ssl.conf", domain)); err != nil {
-		return fmt.Errorf("failed to write SSL config: %v", err)
-	}
-
-	if result := conn.RunSudo(fmt.Sprintf("mv /tmp/%s-ssl.conf %s", domain, nginxConfigPath), sudoPass); !result.Success {
-		return fmt.Errorf("failed to update nginx config: %s", result.Stderr)
+	if err := c.WriteFile(nginxConfigPath, sslConfig, 0644); err != nil {
+		return err
 	}
 
-	// Test and reload nginx
-	if result := conn.RunSudo("nginx -t", sudoPass); !result.Success {
+	// Test and reload nginx, putting the previous config back if the test fails
+	if result := c.Sudo("nginx -t"); !result.Success {
+		if err := c.RestoreBackup(nginxConfigPath); err != nil {
+			c.Warn("%v", err)
+		}
 		return fmt.Errorf("nginx config test failed: %s", result.Stderr)
 	}
 
-	if result := conn.RunSudo("systemctl reload nginx", sudoPass); !result.Success {
-		return fmt.Errorf("failed to reload nginx: %s", result.Stderr)
+	if err := c.Service("reload", "nginx"); err != nil {
+		return err
 	}
 
 	// Update Keycloak hostname configuration
@@ -525,7 +501,7 @@ server {
 }
 
 func (p *Plugin) backupHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("💾 Creating Keycloak backup...")
 
@@ -534,7 +510,7 @@ func (p *Plugin) backupHandler(ctx context.Context, conn plugin.Connection, args
 
 	// Create backup directory
 	fmt.Printf("📁 Creating backup directory: %s\n", backupDir)
-	if result := conn.RunSudo(fmt.Sprintf("mkdir -p %s", backupDir), sudoPass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("mkdir -p %s", backupDir)); !result.Success {
 		return fmt.Errorf("failed to create backup directory: %s", result.Stderr)
 	}
 
@@ -551,12 +527,12 @@ func (p *Plugin) backupHandler(ctx context.Context, conn plugin.Connection, args
 
 	// Backup directory
 	cmd := fmt.Sprintf("tar -czf %s %s", backupFile, keycloakDir)
-	if result := conn.RunSudo(cmd, sudoPass); !result.Success {
+	if result := c.Sudo(cmd); !result.Success {
 		return fmt.Errorf("failed to create backup: %s", result.Stderr)
 	}
 
 	// Set permissions
-	if result := conn.RunSudo(fmt.Sprintf("chmod 600 %s", backupFile), sudoPass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("chmod 600 %s", backupFile)); !result.Success {
 		fmt.Printf("⚠️  Failed to set backup permissions\n")
 	}
 
@@ -572,7 +548,7 @@ func (p *Plugin) backupHandler(ctx context.Context, conn plugin.Connection, args
 }
 
 func (p *Plugin) restoreHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	if len(args) < 1 {
 		return fmt.Errorf("usage: restore <backup-file>")
@@ -595,14 +571,14 @@ func (p *Plugin) restoreHandler(ctx context.Context, conn plugin.Connection, arg
 
 	// Remove current installation
 	fmt.Println("🗑️  Removing current installation...")
-	if result := conn.RunSudo("rm -rf /opt/keycloak", sudoPass); !result.Success {
+	if result := c.Sudo("rm -rf /opt/keycloak"); !result.Success {
 		return fmt.Errorf("failed to remove current installation: %s", result.Stderr)
 	}
 
 	// Extract backup
 	fmt.Println("📂 Extracting backup...")
-	cmd := fmt.Sprintf("cd /opt && tar -xzf %s", backupFile)
-	if result := conn.RunSudo(cmd, sudoPass); !result.Success {
+	cmd := fmt.Sprintf("tar -xzf %s -C /opt", backupFile)
+	if result := c.Sudo(cmd); !result.Success {
 		return fmt.Errorf("failed to extract backup: %s", result.Stderr)
 	}
 
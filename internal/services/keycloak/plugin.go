@@ -7,8 +7,6 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
 )
 
@@ -42,12 +40,12 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 	return []plugin.Dependency{
 		{
 			Name:     "docker",
-			Version:  ">=1.0.0",
+			Version:  ">=0.1.0",
 			Optional: false,
 		},
 		{
 			Name:     "nginx",
-			Version:  ">=1.0.0",
+			Version:  ">=0.1.0",
 			Optional: false,
 		},
 		{
@@ -60,9 +58,7 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
+		MinVPSInitVersion: "0.1.0",
 		Tags:              []string{"identity", "authentication", "sso", "enterprise"},
 	}
 }
@@ -173,8 +169,6 @@ func (p *Plugin) Stop(ctx context.Context) error {
 
 func (p *Plugin) serviceActionHandler(action string) plugin.CommandHandler {
 	return func(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-		_ = getSudoPass(flags) // For consistency with other handlers
-
 		fmt.Printf("⚙️  %sing Keycloak services...\n", strings.Title(action))
 
 		keycloakDir := "/opt/keycloak"
@@ -189,18 +183,6 @@ func (p *Plugin) serviceActionHandler(action string) plugin.CommandHandler {
 	}
 }
 
-func getSudoPass(flags map[string]interface{}) string {
-	if pass, ok := flags["sudo_password"].(string); ok {
-		return pass
-	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-	return pkgmgr.GetPackageManager(distroInfo)
-}
-
 const dockerComposeTemplate = `version: '3.8'
 
 services:
@@ -6,9 +6,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -16,7 +15,7 @@ type Plugin struct{}
 func (p *Plugin) Name() string                                   { return "mysql" }
 func (p *Plugin) Description() string                            { return "Manage MySQL/MariaDB Database Server" }
 func (p *Plugin) Author() string                                 { return "VPS-Init" }
-func (p *Plugin) Version() string                                { return "0.0.1" }
+func (p *Plugin) Version() string                                { return "0.1.0" }
 func (p *Plugin) Initialize(config map[string]interface{}) error { return nil }
 
 // Enhanced plugin interface methods
@@ -29,11 +28,16 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 	return []plugin.Dependency{}
 }
 
+// ServiceInstalled reports whether MariaDB/MySQL server is installed on the target
+func (p *Plugin) ServiceInstalled(conn plugin.Connection) bool {
+	return conn.RunCommand("command -v mysqld || command -v mariadbd || test -x /usr/sbin/mysqld || test -x /usr/sbin/mariadbd", false).Success
+}
+
+func (p *Plugin) InstallCommand() string { return "install" }
+
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
+		MinVPSInitVersion: "0.1.0",
 		Tags:              []string{"database", "mysql", "mariadb"},
 	}
 }
@@ -42,7 +46,7 @@ func (p *Plugin) GetMetadata() plugin.PluginMetadata {
 	return plugin.PluginMetadata{
 		Name:        "mysql",
 		Description: "Manage MySQL/MariaDB Database Server",
-		Version:     "0.0.1",
+		Version:     "0.1.0",
 		Author:      "VPS-Init",
 		License:     "MIT",
 		Repository:  "github.com/wasilwamark/vps-init-plugins/mysql",
@@ -93,25 +97,10 @@ func (p *Plugin) GetCommands() []plugin.Command {
 
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🗄️  Installing MariaDB Server...")
-	pass := getSudoPass(flags)
-	pkgMgr := getPackageManager(conn)
-
-	updateCmd, _ := pkgMgr.Update()
-	logCommand(updateCmd)
-	result := conn.RunSudo(updateCmd, pass)
-	if !result.Success {
-		return fmt.Errorf("package update failed: %s", result.Stderr)
-	}
-
-	installCmd, err := pkgMgr.Install("mariadb-server")
-	if err != nil {
+	c := sdk.New(ctx, conn, flags)
+	if err := c.Install("mariadb-server"); err != nil {
 		return err
 	}
-	logCommand(installCmd)
-	result = conn.RunSudo(installCmd, pass)
-	if !result.Success {
-		return fmt.Errorf("installation failed: %s", result.Stderr)
-	}
 
 	// Secure Installation
 	// We'll do a basic automated security setup using SQL commands since 'mysql_secure_installation' is interactive.
@@ -125,21 +114,15 @@ DROP DATABASE IF EXISTS test;
 DELETE FROM mysql.db WHERE Db='test' OR Db='test_%';
 FLUSH PRIVILEGES;
 `
-	// Write to tmp file
-	if err := conn.WriteFile(secureSql, "/tmp/secure_mysql.sql"); err != nil {
-		return fmt.Errorf("failed to write secure sql file: %w", err)
-	}
-
-	// Execute as root
-	result = conn.RunSudo("mysql -u root < /tmp/secure_mysql.sql", pass)
-	if !result.Success {
+	// Execute as root, feeding the script on stdin
+	if result := c.Shell("mysql -u root <<'SQL'\n" + secureSql + "SQL\n"); !result.Success {
 		// Verify if it failed because it's already secured (maybe root has password now?)
 		// If it fails, log warning but continue
-		fmt.Printf("Warning: automated security script had issues: %s\n", result.Stderr)
+		c.Warn("automated security script had issues: %s", result.Stderr)
 	}
-	conn.RunSudo("rm /tmp/secure_mysql.sql", pass)
 
-	fmt.Println("✅ MariaDB installed and secured.")
+	c.Success("MariaDB installed and secured.")
+	c.Publish(plugin.ServiceInstalled{Service: "mysql"})
 	return nil
 }
 
@@ -148,12 +131,12 @@ func (p *Plugin) createDbHandler(ctx context.Context, conn plugin.Connection, ar
 		return fmt.Errorf("usage: create-db <dbname>")
 	}
 	dbName := args[0]
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Printf("Creating database %s...\n", dbName)
 	cmd := fmt.Sprintf("mysql -u root -e 'CREATE DATABASE IF NOT EXISTS %s CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;'", dbName)
 
-	result := conn.RunSudo(cmd, pass)
+	result := c.Sudo(cmd)
 	if !result.Success {
 		return fmt.Errorf("failed to create db: %s", result.Stderr)
 	}
@@ -168,13 +151,13 @@ func (p *Plugin) createUserHandler(ctx context.Context, conn plugin.Connection,
 	}
 	user := args[0]
 	dbPass := args[1]
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Printf("Creating user %s...\n", user)
 	// Create user allowing connection from localhost
 	cmd := fmt.Sprintf("mysql -u root -e \"CREATE USER IF NOT EXISTS '%s'@'localhost' IDENTIFIED BY '%s';\"", user, dbPass)
 
-	result := conn.RunSudo(cmd, pass)
+	result := c.Sudo(cmd)
 	if !result.Success {
 		return fmt.Errorf("failed to create user: %s", result.Stderr)
 	}
@@ -189,12 +172,12 @@ func (p *Plugin) grantHandler(ctx context.Context, conn plugin.Connection, args
 	}
 	user := args[0]
 	dbName := args[1]
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Printf("Granting privileges to %s on %s...\n", user, dbName)
 	cmd := fmt.Sprintf("mysql -u root -e \"GRANT ALL PRIVILEGES ON %s.* TO '%s'@'localhost'; FLUSH PRIVILEGES;\"", dbName, user)
 
-	result := conn.RunSudo(cmd, pass)
+	result := c.Sudo(cmd)
 	if !result.Success {
 		return fmt.Errorf("failed to grant privileges: %s", result.Stderr)
 	}
@@ -204,26 +187,5 @@ func (p *Plugin) grantHandler(ctx context.Context, conn plugin.Connection, args
 }
 
 func (p *Plugin) statusHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	return conn.RunInteractive("systemctl status mariadb")
-}
-
-func getSudoPass(flags map[string]interface{}) string {
-	if v, ok := flags["sudo-password"]; ok {
-		return v.(string)
-	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-
-	pkgMgr := pkgmgr.GetPackageManager(distroInfo)
-	fmt.Printf("ℹ️  Detected Distribution: %s %s\n", distroInfo.Name, distroInfo.Version)
-	fmt.Printf("📦 Using Package Manager: %s\n", distroInfo.PackageMgr)
-
-	return pkgMgr
-}
-
-func logCommand(cmd string) {
-	fmt.Printf("⚡ Executing: %s\n", cmd)
+	return sdk.New(ctx, conn, flags).ServiceStatus("mariadb")
 }
@@ -8,9 +8,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -28,7 +27,7 @@ func (p *Plugin) Author() string {
 }
 
 func (p *Plugin) Version() string {
-	return "0.0.1"
+	return "0.1.0"
 }
 
 func (p *Plugin) Initialize(config map[string]interface{}) error {
@@ -61,12 +60,19 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 	}
 }
 
+// ServiceInstalled reports whether nginx is installed on the target
+func (p *Plugin) ServiceInstalled(conn plugin.Connection) bool {
+	return conn.RunCommand("command -v nginx || test -x /usr/sbin/nginx", false).Success
+}
+
+func (p *Plugin) InstallCommand() string {
+	return "install"
+}
+
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
-		Tags:              []string{"TODO", "add", "relevant", "tags"},
+		MinVPSInitVersion: "0.1.0",
+		Tags:              []string{"web", "nginx", "proxy", "ssl"},
 	}
 }
 
@@ -78,7 +84,7 @@ func (p *Plugin) GetMetadata() plugin.PluginMetadata {
 		Author:      p.Author(),
 		License:     "MIT",
 		Repository:  "github.com/wasilwamark/vps-init-plugins/" + p.Name(),
-		Tags:        []string{"TODO", "add", "tags"},
+		Tags:        []string{"web", "nginx", "proxy", "ssl"},
 		Validated:   true,
 		TrustLevel:  "official",
 		BuildInfo: plugin.BuildInfo{
@@ -150,49 +156,36 @@ func (p *Plugin) GetCommands() []plugin.Command {
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🌐 Installing Nginx...")
 
-	sudoPass := getSudoPass(flags)
-	pkgMgr := getPackageManager(conn)
-
-	updateCmd, _ := pkgMgr.Update()
-	logCommand(updateCmd)
-	if result := conn.RunSudo(updateCmd, sudoPass); !result.Success {
-		return fmt.Errorf("failed to update package lists: %s", result.Stderr)
-	}
-
-	installCmd, err := pkgMgr.Install("nginx")
-	if err != nil {
+	c := sdk.New(ctx, conn, flags)
+	if err := c.Install("nginx"); err != nil {
 		return err
 	}
-	logCommand(installCmd)
-	if result := conn.RunSudo(installCmd, sudoPass); !result.Success {
-		return fmt.Errorf("failed to install nginx: %s", result.Stderr)
-	}
 
-	fmt.Println("✅ Nginx installed successfully!")
+	c.Success("Nginx installed successfully!")
+	c.Publish(plugin.ServiceInstalled{Service: "nginx"})
 	return nil
 }
 
 func (p *Plugin) statusHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	return conn.RunInteractive("systemctl status nginx")
+	return sdk.New(ctx, conn, flags).ServiceStatus("nginx")
 }
 
 func (p *Plugin) serviceActionHandler(action string) plugin.CommandHandler {
 	return func(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-		pass := getSudoPass(flags)
+		c := sdk.New(ctx, conn, flags)
 
 		// For reload, always test config first
 		if action == "reload" {
 			fmt.Println("🔍 Testing Nginx configuration...")
-			if result := conn.RunSudo("nginx -t", pass); !result.Success {
+			if result := c.Sudo("nginx -t"); !result.Success {
 				return fmt.Errorf("nginx config test failed:\n%s", result.Stderr)
 			}
 		}
 
-		fmt.Printf("⚙️  Running: systemctl %s nginx...\n", action)
-		if result := conn.RunSudo(fmt.Sprintf("systemctl %s nginx", action), pass); !result.Success {
-			return fmt.Errorf("failed to %s nginx: %s", action, result.Stderr)
+		if err := c.Service(action, "nginx"); err != nil {
+			return err
 		}
-		fmt.Printf("✅ Nginx %sed successfully\n", action)
+		c.Success("Nginx %sed successfully", action)
 		return nil
 	}
 }
@@ -311,20 +304,7 @@ func (p *Plugin) addSiteHandler(ctx context.Context, conn plugin.Connection, arg
 		configContent = string(content)
 	} else {
 		fmt.Printf("📝 Configuring site %s (proxying to localhost:%s)...\n", domain, proxyPort)
-		configContent = fmt.Sprintf(`server {
-    listen 80;
-    server_name %s;
-
-    location / {
-        proxy_pass http://localhost:%s;
-        proxy_http_version 1.1;
-        proxy_set_header Upgrade $http_upgrade;
-        proxy_set_header Connection 'upgrade';
-        proxy_set_header Host $host;
-        proxy_cache_bypass $http_upgrade;
-    }
-}
-`, domain, proxyPort)
+		configContent = ProxySiteConfig(domain, proxyPort)
 	}
 
 	// Check if Nginx is installed
@@ -332,47 +312,38 @@ func (p *Plugin) addSiteHandler(ctx context.Context, conn plugin.Connection, arg
 		return fmt.Errorf("Nginx configuration directory not found. Is Nginx installed? Try running: vps-init <target> nginx install")
 	}
 
-	// Create temp file securely? Or just echo to path.
-	// Since we need sudo to write to /etc/nginx, we write to /tmp first then move.
-	tmpPath := fmt.Sprintf("/tmp/nginx_%s.conf", domain)
-	if err := conn.WriteFile(configContent, tmpPath); err != nil {
-		return fmt.Errorf("failed to write temp config")
-	}
-
+	c := sdk.New(ctx, conn, flags)
 	confPath := fmt.Sprintf("/etc/nginx/sites-available/%s", domain)
+	enabledPath := fmt.Sprintf("/etc/nginx/sites-enabled/%s", domain)
+	wasEnabled := conn.FileExists(enabledPath)
 
-	// Move and Enable
-	cmds := []string{
-		fmt.Sprintf("mv %s %s", tmpPath, confPath),
-		fmt.Sprintf("ln -sf %s /etc/nginx/sites-enabled/", confPath),
+	if err := c.WriteFile(confPath, configContent, 0644); err != nil {
+		return err
 	}
-
-	pass := getSudoPass(flags)
-	for _, cmd := range cmds {
-		result := conn.RunSudo(cmd, pass)
-		if !result.Success {
-			return fmt.Errorf("failed step '%s': %s", cmd, result.Stderr)
-		}
+	if err := c.Exec(fmt.Sprintf("ln -sf %s /etc/nginx/sites-enabled/", confPath)); err != nil {
+		return fmt.Errorf("failed to enable site: %w", err)
 	}
 
 	// Verify Config with Rollback
 	fmt.Println("🔍 Testing Nginx configuration...")
-	result := conn.RunSudo("nginx -t", pass)
-	if !result.Success {
+	if result := c.Sudo("nginx -t"); !result.Success {
 		fmt.Printf("❌ Config test failed details:\n%s\n", result.Stderr)
 		fmt.Println("🔄 Rolling back changes...")
-		// Remove the symlink
-		conn.RunSudo(fmt.Sprintf("rm -f /etc/nginx/sites-enabled/%s", domain), pass)
+		if !wasEnabled {
+			c.Sudo("rm -f " + enabledPath)
+		}
+		if err := c.RestoreBackup(confPath); err != nil {
+			c.Warn("%v", err)
+		}
 		return fmt.Errorf("nginx config test failed. Changes rolled back")
 	}
 
-	// Reload
-	result = conn.RunSudo("systemctl reload nginx", pass)
-	if !result.Success {
-		return fmt.Errorf("failed to reload nginx: %s", result.Stderr)
+	if err := c.Service("reload", "nginx"); err != nil {
+		return err
 	}
 
-	fmt.Printf("✅ Site %s added and enabled!\n", domain)
+	c.Success("Site %s added and enabled!", domain)
+	c.Publish(plugin.SiteAdded{Domain: domain, SSL: ssl})
 
 	if ssl {
 		fmt.Println("🔒 Proceeding to SSL installation...")
@@ -393,18 +364,19 @@ func (p *Plugin) removeSiteHandler(ctx context.Context, conn plugin.Connection,
 		fmt.Sprintf("rm -f /etc/nginx/sites-enabled/%s", domain),
 		fmt.Sprintf("rm -f /etc/nginx/sites-available/%s", domain),
 		"nginx -t", // Test config to make sure we didn't break anything (though removing shouldn't)
-		"systemctl reload nginx",
 	}
 
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 	for _, cmd := range cmds {
-		result := conn.RunSudo(cmd, pass)
-		if !result.Success {
-			return fmt.Errorf("failed step '%s': %s", cmd, result.Stderr)
+		if err := c.Exec(cmd); err != nil {
+			return fmt.Errorf("failed step '%s': %w", cmd, err)
 		}
 	}
+	if err := c.Service("reload", "nginx"); err != nil {
+		return err
+	}
 
-	fmt.Printf("✅ Site %s removed successfully!\n", domain)
+	c.Success("Site %s removed successfully!", domain)
 	return nil
 }
 
@@ -450,24 +422,12 @@ func (p *Plugin) installSSLHandler(ctx context.Context, conn plugin.Connection,
 		domain = validSites[selection-1]
 	}
 
-	pass := getSudoPass(flags)
-
 	fmt.Println("🔒 Installing Certbot and SSL...")
 
-	pkgMgr := getPackageManager(conn)
-	updateCmd, _ := pkgMgr.Update()
-	if result := conn.RunSudo(updateCmd, pass); !result.Success {
-		// Don't error immediately, might be installed
-	}
-
-	installCmd, err := pkgMgr.Install("certbot", "python3-certbot-nginx")
-	if err != nil {
-		// Continue anyway, certbot might already be installed
-		fmt.Printf("Warning: %v\n", err)
-	} else {
-		if result := conn.RunSudo(installCmd, pass); !result.Success {
-			// Don't error immediately, might be installed
-		}
+	// Continue on failure, certbot might already be installed
+	c := sdk.New(ctx, conn, flags)
+	if err := c.Install("certbot-nginx"); err != nil {
+		c.Warn("%v", err)
 	}
 
 	fmt.Printf("🔐 Obtaining certificate for %s...\n", domain)
@@ -476,24 +436,20 @@ func (p *Plugin) installSSLHandler(ctx context.Context, conn plugin.Connection,
 	return conn.RunInteractive(cmd)
 }
 
-// Helper
-func getSudoPass(flags map[string]interface{}) string {
-	if v, ok := flags["sudo-password"]; ok {
-		return v.(string)
-	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-
-	pkgMgr := pkgmgr.GetPackageManager(distroInfo)
-	fmt.Printf("ℹ️  Detected Distribution: %s %s\n", distroInfo.Name, distroInfo.Version)
-	fmt.Printf("📦 Using Package Manager: %s\n", distroInfo.PackageMgr)
+// ProxySiteConfig returns the server block used for reverse proxy sites
+func ProxySiteConfig(domain, proxyPort string) string {
+	return fmt.Sprintf(`server {
+    listen 80;
+    server_name %s;
 
-	return pkgMgr
+    location / {
+        proxy_pass http://localhost:%s;
+        proxy_http_version 1.1;
+        proxy_set_header Upgrade $http_upgrade;
+        proxy_set_header Connection 'upgrade';
+        proxy_set_header Host $host;
+        proxy_cache_bypass $http_upgrade;
+    }
 }
-
-func logCommand(cmd string) {
-	fmt.Printf("⚡ Executing: %s\n", cmd)
+`, domain, proxyPort)
 }
@@ -8,8 +8,8 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -51,9 +51,7 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
+		MinVPSInitVersion: "0.1.0",
 		Tags:              []string{"database", "cache", "production-ready"},
 	}
 }
@@ -142,43 +140,48 @@ func (p *Plugin) Stop(ctx context.Context) error {
 	return nil
 }
 
+// serviceName is the name of the Redis service, which follows the package:
+// redis-server on Debian, redis6 on Amazon Linux 2023 and redis elsewhere
+func serviceName(info *distro.DistroInfo) string {
+	switch {
+	case info.Family == distro.DistroFamilyDebian:
+		return "redis-server"
+	case info.ID == "amzn" && info.MajorVersion() >= 2022:
+		return "redis6"
+	}
+	return "redis"
+}
+
+// serverBinary is the Redis server executable
+func serverBinary(info *distro.DistroInfo) string {
+	if info.ID == "amzn" && info.MajorVersion() >= 2022 {
+		return "redis6-server"
+	}
+	return "redis-server"
+}
+
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("Installing Redis server...")
 
 	// Check if Redis is already installed
-	checkCmd := "redis-server --version"
-	if result := conn.RunCommand(checkCmd, plugin.WithHideOutput()); result.Success {
+	if c.IsInstalled("redis") {
 		fmt.Println("Redis is already installed")
 		return nil
 	}
 
-	// Update package list
-	fmt.Println("Updating package list...")
-	pkgMgr := getPackageManager(conn)
-	updateCmd, _ := pkgMgr.Update()
-	if result := conn.RunSudo(updateCmd, sudoPass); !result.Success {
-		return fmt.Errorf("failed to update package list: %w", result.GetError())
-	}
-
-	// Install Redis
-	fmt.Println("Installing Redis server...")
-	installCmd, err := pkgMgr.Install("redis-server")
-	if err != nil {
+	if err := c.Install("redis"); err != nil {
 		return err
 	}
-	if result := conn.RunSudo(installCmd, sudoPass); !result.Success {
-		return fmt.Errorf("failed to install Redis: %w", result.GetError())
-	}
 
 	// Enable Redis service
-	fmt.Println("Enabling Redis service...")
-	if result := conn.RunSudo("systemctl enable redis-server", sudoPass); !result.Success {
-		return fmt.Errorf("failed to enable Redis service: %w", result.GetError())
+	if err := c.Service("enable", serviceName(c.Distro())); err != nil {
+		return fmt.Errorf("failed to enable Redis service: %w", err)
 	}
 
-	fmt.Println("✅ Redis server installed successfully!")
+	c.Success("Redis server installed successfully!")
+	c.Publish(plugin.ServiceInstalled{Service: "redis"})
 	fmt.Println("You can now:")
 	fmt.Println("  - Start Redis: vps-init redis start")
 	fmt.Println("  - Configure Redis: vps-init redis configure")
@@ -188,34 +191,26 @@ func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, arg
 }
 
 func (p *Plugin) uninstallHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("Uninstalling Redis server...")
 
-	// Stop Redis service
+	// Stop and disable Redis service
 	fmt.Println("Stopping Redis service...")
-	conn.RunSudo("systemctl stop redis-server", sudoPass)
-
-	// Disable Redis service
-	fmt.Println("Disabling Redis service...")
-	conn.RunSudo("systemctl disable redis-server", sudoPass)
+	if err := c.DisableService(serviceName(c.Distro())); err != nil {
+		c.Warn("%v", err)
+	}
 
 	// Remove Redis package
-	fmt.Println("Removing Redis server package...")
-	pkgMgr := getPackageManager(conn)
-	removeCmd, err := pkgMgr.Remove("redis-server", "redis-tools")
-	if err != nil {
+	if err := c.Remove("redis"); err != nil {
 		return err
 	}
-	if result := conn.RunSudo(removeCmd, sudoPass); !result.Success {
-		return fmt.Errorf("failed to remove Redis packages: %w", result.GetError())
-	}
 
 	// Remove Redis configuration and data directories
 	fmt.Println("Removing Redis configuration and data...")
-	conn.RunSudo("rm -rf /etc/redis", sudoPass)
-	conn.RunSudo("rm -rf /var/lib/redis", sudoPass)
-	conn.RunSudo("rm -rf /var/log/redis", sudoPass)
+	c.Sudo("rm -rf /etc/redis")
+	c.Sudo("rm -rf /var/lib/redis")
+	c.Sudo("rm -rf /var/log/redis")
 
 	fmt.Println("✅ Redis server uninstalled successfully!")
 
@@ -223,47 +218,45 @@ func (p *Plugin) uninstallHandler(ctx context.Context, conn plugin.Connection, a
 }
 
 func (p *Plugin) startHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
-	fmt.Println("Starting Redis service...")
-	if result := conn.RunSudo("systemctl start redis-server", sudoPass); !result.Success {
-		return fmt.Errorf("failed to start Redis service: %w", result.GetError())
+	if err := c.Service("start", serviceName(c.Distro())); err != nil {
+		return err
 	}
 
-	fmt.Println("✅ Redis service started successfully!")
+	c.Success("Redis service started successfully!")
 	return p.statusHandler(ctx, conn, args, flags)
 }
 
 func (p *Plugin) stopHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
-	fmt.Println("Stopping Redis service...")
-	if result := conn.RunSudo("systemctl stop redis-server", sudoPass); !result.Success {
-		return fmt.Errorf("failed to stop Redis service: %w", result.GetError())
+	if err := c.Service("stop", serviceName(c.Distro())); err != nil {
+		return err
 	}
 
-	fmt.Println("✅ Redis service stopped successfully!")
+	c.Success("Redis service stopped successfully!")
 	return nil
 }
 
 func (p *Plugin) restartHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
-	fmt.Println("Restarting Redis service...")
-	if result := conn.RunSudo("systemctl restart redis-server", sudoPass); !result.Success {
-		return fmt.Errorf("failed to restart Redis service: %w", result.GetError())
+	if err := c.Service("restart", serviceName(c.Distro())); err != nil {
+		return err
 	}
 
-	fmt.Println("✅ Redis service restarted successfully!")
+	c.Success("Redis service restarted successfully!")
 	return p.statusHandler(ctx, conn, args, flags)
 }
 
 func (p *Plugin) statusHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
 	fmt.Println("Checking Redis service status...")
 
-	// Check systemctl status
-	if result := conn.RunCommand("systemctl is-active redis-server", plugin.WithHideOutput()); result.Success {
-		fmt.Printf("🟢 Redis service status: %s\n", strings.TrimSpace(result.Stdout))
+	// Check service status
+	if c.ServiceActive(serviceName(c.Distro())) {
+		fmt.Println("🟢 Redis service status: active")
 	} else {
 		fmt.Printf("❌ Redis service is not active\n")
 	}
@@ -280,7 +273,8 @@ func (p *Plugin) statusHandler(ctx context.Context, conn plugin.Connection, args
 	}
 
 	// Show version if available
-	if result := conn.RunCommand("redis-server --version 2>/dev/null || echo 'Version not available'", plugin.WithHideOutput()); result.Success {
+	versionCmd := fmt.Sprintf("%s --version 2>/dev/null || echo 'Version not available'", serverBinary(c.Distro()))
+	if result := conn.RunCommand(versionCmd, plugin.WithHideOutput()); result.Success {
 		fmt.Printf("📦 Version: %s\n", strings.TrimSpace(result.Stdout))
 	}
 
@@ -325,11 +319,18 @@ if [[ -n $redis_password ]]; then
     sudo sed -i "s/# requirepass .*/requirepass $redis_password/" /etc/redis/redis.conf
 fi
 echo "✅ Configuration updated!"
-echo "Restarting Redis service..."
-sudo systemctl restart redis-server
-echo "✅ Redis service restarted!"
 `
-	return conn.RunInteractive(configureScript)
+	if err := conn.RunInteractive(configureScript); err != nil {
+		return err
+	}
+
+	c := sdk.New(ctx, conn, flags)
+	fmt.Println("Restarting Redis service...")
+	if err := c.Service("restart", serviceName(c.Distro())); err != nil {
+		return err
+	}
+	c.Success("Redis service restarted!")
+	return nil
 }
 
 func (p *Plugin) testHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
@@ -428,14 +429,14 @@ func (p *Plugin) infoHandler(ctx context.Context, conn plugin.Connection, args [
 }
 
 func (p *Plugin) backupHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	sudoPass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("Creating Redis backup...")
 
 	// Create backup directory
 	backupDir := "/var/backups/redis"
 	fmt.Printf("Creating backup directory: %s\n", backupDir)
-	if result := conn.RunSudo(fmt.Sprintf("mkdir -p %s", backupDir), sudoPass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("mkdir -p %s", backupDir)); !result.Success {
 		return fmt.Errorf("failed to create backup directory: %w", result.GetError())
 	}
 
@@ -468,14 +469,14 @@ func (p *Plugin) backupHandler(ctx context.Context, conn plugin.Connection, args
 
 	// Copy the RDB file to backup location
 	rdbPath := "/var/lib/redis/dump.rdb"
-	copyCmd := fmt.Sprintf("sudo cp %s %s", rdbPath, backupFile)
-	if result := conn.RunSudo(copyCmd, sudoPass); !result.Success {
+	copyCmd := fmt.Sprintf("cp %s %s", rdbPath, backupFile)
+	if result := c.Sudo(copyCmd); !result.Success {
 		return fmt.Errorf("failed to copy RDB file: %w", result.GetError())
 	}
 
 	// Set proper permissions
-	chmodCmd := fmt.Sprintf("sudo chmod 640 %s", backupFile)
-	if result := conn.RunSudo(chmodCmd, sudoPass); !result.Success {
+	chmodCmd := fmt.Sprintf("chmod 640 %s", backupFile)
+	if result := c.Sudo(chmodCmd); !result.Success {
 		return fmt.Errorf("failed to set backup file permissions: %w", result.GetError())
 	}
 
@@ -487,16 +488,3 @@ func (p *Plugin) backupHandler(ctx context.Context, conn plugin.Connection, args
 
 	return nil
 }
-
-// Helper function to get sudo password from flags
-func getSudoPass(flags map[string]interface{}) string {
-	if pass, ok := flags["sudo_password"].(string); ok {
-		return pass
-	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-	return pkgmgr.GetPackageManager(distroInfo)
-}
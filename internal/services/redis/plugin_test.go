@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+)
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    distro.DistroInfo
+		service string
+		binary  string
+	}{
+		{name: "ubuntu", info: distro.DistroInfo{ID: "ubuntu", Family: distro.DistroFamilyDebian, VersionID: "22.04"}, service: "redis-server", binary: "redis-server"},
+		{name: "rocky", info: distro.DistroInfo{ID: "rocky", Family: distro.DistroFamilyRedHat, VersionID: "9.4"}, service: "redis", binary: "redis-server"},
+		{name: "amazon linux 2", info: distro.DistroInfo{ID: "amzn", Family: distro.DistroFamilyRedHat, VersionID: "2"}, service: "redis", binary: "redis-server"},
+		{name: "amazon linux 2023", info: distro.DistroInfo{ID: "amzn", Family: distro.DistroFamilyRedHat, VersionID: "2023"}, service: "redis6", binary: "redis6-server"},
+		{name: "alpine", info: distro.DistroInfo{ID: "alpine", Family: distro.DistroFamilyAlpine, VersionID: "3.19.1"}, service: "redis", binary: "redis-server"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serviceName(&tt.info); got != tt.service {
+				t.Errorf("serviceName() = %s, want %s", got, tt.service)
+			}
+			if got := serverBinary(&tt.info); got != tt.binary {
+				t.Errorf("serverBinary() = %s, want %s", got, tt.binary)
+			}
+		})
+	}
+}
@@ -8,9 +8,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -33,9 +32,7 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
+		MinVPSInitVersion: "0.1.0",
 		Tags:              []string{"backup", "storage", "s3", "restic"},
 	}
 }
@@ -100,31 +97,11 @@ func (p *Plugin) GetCommands() []plugin.Command {
 
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("💾 Installing Restic...")
-	pass := getSudoPass(flags)
-	pkgMgr := getPackageManager(conn)
+	c := sdk.New(ctx, conn, flags)
 
-	// Update
-	updateCmd, _ := pkgMgr.Update()
-	result := conn.RunSudo(updateCmd, pass)
-	if !result.Success {
-		return fmt.Errorf("package update failed: %s", result.Stderr)
-	}
-
-	// Install
-	installCmd, err := pkgMgr.Install("restic")
-	if err != nil {
+	if err := c.Install("restic"); err != nil {
 		return err
 	}
-	result = conn.RunSudo(installCmd, pass)
-	if !result.Success {
-		return fmt.Errorf("installation failed: %s", result.Stderr)
-	}
-
-	// Install
-	result = conn.RunSudo("apt-get install -y restic", pass)
-	if !result.Success {
-		return fmt.Errorf("installation failed: %s", result.Stderr)
-	}
 
 	fmt.Println("✅ Restic installed.")
 	return nil
@@ -132,7 +109,7 @@ func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, arg
 
 func (p *Plugin) initHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("⚙️  Initializing Repository Configuration...")
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	// Interactive Input
 	var repo, id, key, password string
@@ -176,12 +153,9 @@ export AWS_SECRET_ACCESS_KEY="%s"
 export RESTIC_PASSWORD="%s"
 `, repo, id, key, password)
 
-	conn.RunSudo("mkdir -p /etc/vps-init", pass)
-	if err := conn.WriteFile(envContent, "/tmp/restic.env"); err != nil {
+	if err := c.WriteFile("/etc/vps-init/restic.env", envContent, 0600); err != nil {
 		return fmt.Errorf("failed to write restic env file: %w", err)
 	}
-	conn.RunSudo("mv /tmp/restic.env /etc/vps-init/restic.env", pass)
-	conn.RunSudo("chmod 600 /etc/vps-init/restic.env", pass)
 
 	fmt.Println("🔒 Credentials saved to /etc/vps-init/restic.env")
 
@@ -190,7 +164,7 @@ export RESTIC_PASSWORD="%s"
 	// We run directly as root? or standard user? standard user might not read /etc/vps-init/restic.env if 600 root
 	// Let's run as root for now since backups usually need root to read all files
 	fmt.Println("🚀 Initializing backend...")
-	result := conn.RunSudo(cmd, pass)
+	result := c.Sudo(cmd)
 	if !result.Success {
 		if strings.Contains(result.Stderr, "config file already exists") || strings.Contains(result.Stdout, "already initialized") {
 			fmt.Println("⚠️  Repository already initialized.")
@@ -207,11 +181,11 @@ export RESTIC_PASSWORD="%s"
 // Database Discovery Logic
 
 func (p *Plugin) backupDbHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	// 1. Discover Database Instances (Host Services & Docker Containers)
 	fmt.Println("🔍 Scanning for database instances...")
-	instances, err := discoverInstances(conn, pass)
+	instances, err := discoverInstances(c)
 	if err != nil {
 		fmt.Printf("Warning during scan: %v\n", err)
 	}
@@ -240,7 +214,7 @@ func (p *Plugin) backupDbHandler(ctx context.Context, conn plugin.Connection, ar
 
 	// 3. Configure Credentials (Interactive)
 	// We try to detect defaults to offer them, but ALWAYS ask.
-	detectedUser, detectedPass := detectCredentials(conn, targetInst, pass)
+	detectedUser, detectedPass := detectCredentials(c, targetInst)
 
 	var user, dbPass string
 	fmt.Printf("Database User [%s]: ", detectedUser)
@@ -268,7 +242,7 @@ func (p *Plugin) backupDbHandler(ctx context.Context, conn plugin.Connection, ar
 
 	// 4. List Databases in Instance
 	fmt.Println("🔍 Listing databases...")
-	dbs, err := listDatabases(conn, targetInst, user, dbPass, pass)
+	dbs, err := listDatabases(c, targetInst, user, dbPass)
 	if err != nil {
 		return fmt.Errorf("failed to list databases: %v", err)
 	}
@@ -299,10 +273,10 @@ func (p *Plugin) backupDbHandler(ctx context.Context, conn plugin.Connection, ar
 		Password:    dbPass,
 	}
 
-	return p.performBackup(conn, targetInfo, pass)
+	return p.performBackup(c, targetInfo)
 }
 
-func (p *Plugin) performBackup(conn plugin.Connection, targetDB DatabaseInfo, sudoPass string) error {
+func (p *Plugin) performBackup(c *sdk.Context, targetDB DatabaseInfo) error {
 	fmt.Printf("📦 Streaming backup of %s (%s)...\n", targetDB.Name, targetDB.Engine)
 
 	var dumpCmd string
@@ -355,7 +329,7 @@ func (p *Plugin) performBackup(conn plugin.Connection, targetDB DatabaseInfo, su
 	// Pipe to Restic
 	fullCmd := fmt.Sprintf("bash -c 'source /etc/vps-init/restic.env && %s | restic backup --stdin --stdin-filename %s.%s'", dumpCmd, targetDB.Name, ext)
 
-	result := conn.RunSudo(fullCmd, sudoPass)
+	result := c.Sudo(fullCmd)
 	if !result.Success {
 		return fmt.Errorf("backup failed: %s", result.Stderr)
 	}
@@ -384,23 +358,23 @@ type DatabaseInfo struct {
 
 // Discovery Logic
 
-func discoverInstances(conn plugin.Connection, sudoPass string) ([]DatabaseInstance, error) {
+func discoverInstances(c *sdk.Context) ([]DatabaseInstance, error) {
 	var inst []DatabaseInstance
 
 	// 1. Host Services
-	if conn.RunCommand("which mysql", plugin.WithHideOutput()).Success {
+	if c.Run("which mysql").Success {
 		inst = append(inst, DatabaseInstance{Engine: "mysql", Type: "host"})
 	}
-	if conn.RunCommand("which psql", plugin.WithHideOutput()).Success {
+	if c.Run("which psql").Success {
 		inst = append(inst, DatabaseInstance{Engine: "postgres", Type: "host"})
 	}
-	if conn.RunCommand("which mongosh", plugin.WithHideOutput()).Success || conn.RunCommand("which mongo", plugin.WithHideOutput()).Success {
+	if c.Run("which mongosh").Success || c.Run("which mongo").Success {
 		inst = append(inst, DatabaseInstance{Engine: "mongo", Type: "host"})
 	}
 
 	// 2. Docker Services
-	if conn.RunCommand("which docker", plugin.WithHideOutput()).Success {
-		result := conn.RunSudo("docker ps --format '{{.ID}}|{{.Names}}|{{.Image}}'", sudoPass)
+	if c.Run("which docker").Success {
+		result := c.Sudo("docker ps --format '{{.ID}}|{{.Names}}|{{.Image}}'")
 		if result.Success {
 			lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
 			for _, line := range lines {
@@ -426,7 +400,7 @@ func discoverInstances(conn plugin.Connection, sudoPass string) ([]DatabaseInsta
 	return inst, nil
 }
 
-func detectCredentials(conn plugin.Connection, inst DatabaseInstance, sudoPass string) (string, string) {
+func detectCredentials(c *sdk.Context, inst DatabaseInstance) (string, string) {
 	user := "root"
 	pass := ""
 
@@ -440,16 +414,16 @@ func detectCredentials(conn plugin.Connection, inst DatabaseInstance, sudoPass s
 	if inst.Type == "docker" {
 		// Try to extract from Env
 		if inst.Engine == "mysql" {
-			pass, _ = getDockerEnv(conn, inst.ContainerID, sudoPass, []string{"MYSQL_ROOT_PASSWORD", "MARIADB_ROOT_PASSWORD"})
+			pass, _ = getDockerEnv(c, inst.ContainerID, []string{"MYSQL_ROOT_PASSWORD", "MARIADB_ROOT_PASSWORD"})
 		} else if inst.Engine == "postgres" {
-			pass, _ = getDockerEnv(conn, inst.ContainerID, sudoPass, []string{"POSTGRES_PASSWORD"})
-			u, _ := getDockerEnv(conn, inst.ContainerID, sudoPass, []string{"POSTGRES_USER"})
+			pass, _ = getDockerEnv(c, inst.ContainerID, []string{"POSTGRES_PASSWORD"})
+			u, _ := getDockerEnv(c, inst.ContainerID, []string{"POSTGRES_USER"})
 			if u != "" {
 				user = u
 			}
 		} else if inst.Engine == "mongo" {
-			pass, _ = getDockerEnv(conn, inst.ContainerID, sudoPass, []string{"MONGO_INITDB_ROOT_PASSWORD"})
-			u, _ := getDockerEnv(conn, inst.ContainerID, sudoPass, []string{"MONGO_INITDB_ROOT_USERNAME"})
+			pass, _ = getDockerEnv(c, inst.ContainerID, []string{"MONGO_INITDB_ROOT_PASSWORD"})
+			u, _ := getDockerEnv(c, inst.ContainerID, []string{"MONGO_INITDB_ROOT_USERNAME"})
 			if u != "" {
 				user = u
 			}
@@ -459,7 +433,7 @@ func detectCredentials(conn plugin.Connection, inst DatabaseInstance, sudoPass s
 	return user, pass
 }
 
-func listDatabases(conn plugin.Connection, inst DatabaseInstance, user, pass, sudoPass string) ([]string, error) {
+func listDatabases(c *sdk.Context, inst DatabaseInstance, user, pass string) ([]string, error) {
 	var dbs []string
 	var cmd string
 
@@ -511,13 +485,13 @@ func listDatabases(conn plugin.Connection, inst DatabaseInstance, user, pass, su
 		}
 	}
 
-	result := conn.RunSudo(cmd, sudoPass)
+	result := c.Sudo(cmd)
 
 	// Fallback for mongo if mongosh fails
 	if inst.Engine == "mongo" && !result.Success {
 		if strings.Contains(cmd, "mongosh") {
 			cmd = strings.ReplaceAll(cmd, "mongosh", "mongo")
-			result = conn.RunSudo(cmd, sudoPass)
+			result = c.Sudo(cmd)
 		}
 	}
 
@@ -537,9 +511,9 @@ func listDatabases(conn plugin.Connection, inst DatabaseInstance, user, pass, su
 
 // Helpers
 
-func getDockerEnv(conn plugin.Connection, id, sudoPass string, keys []string) (string, error) {
+func getDockerEnv(c *sdk.Context, id string, keys []string) (string, error) {
 	inspectCmd := fmt.Sprintf("docker inspect %s --format '{{range .Config.Env}}{{println .}}{{end}}'", id)
-	result := conn.RunSudo(inspectCmd, sudoPass)
+	result := c.Sudo(inspectCmd)
 	if !result.Success {
 		return "", fmt.Errorf("inspect failed")
 	}
@@ -564,12 +538,12 @@ func isSystemDB(name string) bool {
 }
 
 func (p *Plugin) restoreDbHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	// 1. List Snapshots
 	fmt.Println("📋 Fetching available snapshots...")
 	cmd := "bash -c 'source /etc/vps-init/restic.env && restic snapshots --json'"
-	result := conn.RunSudo(cmd, pass)
+	result := c.Sudo(cmd)
 	if !result.Success {
 		return fmt.Errorf("failed to list snapshots: %s", result.Stderr)
 	}
@@ -625,7 +599,7 @@ func (p *Plugin) restoreDbHandler(ctx context.Context, conn plugin.Connection, a
 
 	// 3. Discover Target Instances
 	fmt.Println("\n🔍 Scanning for database instances...")
-	instances, err := discoverInstances(conn, pass)
+	instances, err := discoverInstances(c)
 	if err != nil {
 		return fmt.Errorf("failed to discover instances: %v", err)
 	}
@@ -661,7 +635,7 @@ func (p *Plugin) restoreDbHandler(ctx context.Context, conn plugin.Connection, a
 	targetInst := matchingInst[instIdx-1]
 
 	// 5. Get Credentials
-	detectedUser, detectedPass := detectCredentials(conn, targetInst, pass)
+	detectedUser, detectedPass := detectCredentials(c, targetInst)
 	var user, dbPass string
 	fmt.Printf("Database User [%s]: ", detectedUser)
 	fmt.Scanln(&user)
@@ -736,7 +710,7 @@ func (p *Plugin) restoreDbHandler(ctx context.Context, conn plugin.Connection, a
 		}
 	}
 
-	result = conn.RunSudo(restoreCmd, pass)
+	result = c.Sudo(restoreCmd)
 	if !result.Success {
 		return fmt.Errorf("restore failed: %s", result.Stderr)
 	}
@@ -754,15 +728,3 @@ func (p *Plugin) unlockHandler(ctx context.Context, conn plugin.Connection, args
 	conn.RunInteractive("sudo bash -c 'source /etc/vps-init/restic.env && restic unlock'")
 	return nil
 }
-
-func getSudoPass(flags map[string]interface{}) string {
-	if v, ok := flags["sudo-password"]; ok {
-		return v.(string)
-	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-	return pkgmgr.GetPackageManager(distroInfo)
-}
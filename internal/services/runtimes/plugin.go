@@ -7,9 +7,9 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
 	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -75,10 +75,8 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
-		Tags:              []string{"TODO", "add", "relevant", "tags"},
+		MinVPSInitVersion: "0.1.0",
+		Tags:              []string{"runtimes", "nodejs", "python", "go", "php"},
 	}
 }
 
@@ -90,7 +88,7 @@ func (p *Plugin) GetMetadata() plugin.PluginMetadata {
 		Author:      p.Author(),
 		License:     "MIT",
 		Repository:  "github.com/wasilwamark/vps-init-plugins/" + p.Name(),
-		Tags:        []string{"TODO", "add", "tags"},
+		Tags:        []string{"runtimes", "nodejs", "python", "go", "php"},
 		Validated:   true,
 		TrustLevel:  "official",
 		BuildInfo: plugin.BuildInfo{
@@ -142,57 +140,57 @@ func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, arg
 	language := strings.ToLower(args[0])
 	version := args[1]
 
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	switch language {
 	case "node", "nodejs", "node.js":
-		return p.installNode(ctx, conn, version, pass)
+		return p.installNode(c, version)
 	case "python", "py", "python3":
-		return p.installPython(ctx, conn, version, pass)
+		return p.installPython(c, version)
 	case "go", "golang":
-		return p.installGo(ctx, conn, version, pass)
+		return p.installGo(c, version)
 	case "java", "jdk":
-		return p.installJava(ctx, conn, version, pass)
+		return p.installJava(c, version)
 	case "rust":
-		return p.installRust(ctx, conn, version, pass)
+		return p.installRust(c, version)
 	case "php":
-		return p.installPHP(ctx, conn, version, pass)
+		return p.installPHP(c, version)
 	case "ruby":
-		return p.installRuby(ctx, conn, version, pass)
+		return p.installRuby(c, version)
 	case "dotnet", ".net":
-		return p.installDotNet(ctx, conn, version, pass)
+		return p.installDotNet(c, version)
 	default:
 		return fmt.Errorf("unsupported language: %s. Supported languages: node, python, go, java, rust, php, ruby, dotnet", language)
 	}
 }
 
-func (p *Plugin) installNode(ctx context.Context, conn plugin.Connection, version string, pass string) error {
+func (p *Plugin) installNode(c *sdk.Context, version string) error {
 	fmt.Printf("📦 Installing Node.js %s...\n", version)
 
 	// Check if nvm exists
-	if result := conn.RunCommand("command -v nvm", plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand("command -v nvm", plugin.WithHideOutput()); !result.Success {
 		fmt.Println("🔧 Installing NVM (Node Version Manager)...")
 		installCmd := `curl -o- https://raw.githubusercontent.com/nvm-sh/nvm/v0.39.0/install.sh | bash`
-		if result := conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
+		if result := c.Conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
 			return fmt.Errorf("failed to install NVM: %s", result.Stderr)
 		}
 
 		// Add NVM to shell profile
 		profileCmd := `echo 'export NVM_DIR="$HOME/.nvm"' >> ~/.bashrc && echo '[ -s "$NVM_DIR/nvm.sh" ] && \. "$NVM_DIR/nvm.sh"' >> ~/.bashrc && echo '[ -s "$NVM_DIR/bash_completion" ] && \. "$NVM_DIR/bash_completion"' >> ~/.bashrc`
-		if result := conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
+		if result := c.Conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
 			fmt.Printf("⚠️  Failed to update bashrc: %s\n", result.Stderr)
 		}
 	}
 
 	// Install Node.js using nvm
 	installCmd := fmt.Sprintf(`bash -c 'source ~/.nvm/nvm.sh && nvm install %s && nvm use %s && nvm alias default %s'`, version, version, version)
-	if result := conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
 		return fmt.Errorf("failed to install Node.js %s: %s", version, result.Stderr)
 	}
 
 	// Verify installation
 	verifyCmd := fmt.Sprintf(`bash -c 'source ~/.nvm/nvm.sh && node --version && npm --version'`)
-	if result := conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to verify Node.js installation: %s\n", result.Stderr)
 	} else {
 		fmt.Printf("✅ Node.js %s installed successfully!\n", version)
@@ -202,49 +200,47 @@ func (p *Plugin) installNode(ctx context.Context, conn plugin.Connection, versio
 	return nil
 }
 
-func (p *Plugin) installPython(ctx context.Context, conn plugin.Connection, version string, pass string) error {
+func (p *Plugin) installPython(c *sdk.Context, version string) error {
 	fmt.Printf("📦 Installing Python %s...\n", version)
 
 	// Install uv if not exists
-	if result := conn.RunCommand("command -v uv", plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand("command -v uv", plugin.WithHideOutput()); !result.Success {
 		fmt.Println("🔧 Installing uv...")
-		installDeps := `apt-get update 2>/dev/null || true && apt-get install -y curl`
-		result = conn.RunSudo(installDeps, pass)
-		if !result.Success {
-			return fmt.Errorf("failed to install dependencies for uv: %s", result.Stderr)
+		if err := c.Install("curl"); err != nil {
+			return fmt.Errorf("failed to install dependencies for uv: %w", err)
 		}
 
 		installCmd := `curl -LsSf https://astral.sh/uv/install.sh | sh`
-		if result := conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
+		if result := c.Conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
 			return fmt.Errorf("failed to install uv: %s", result.Stderr)
 		}
 
 		// Add uv to PATH (uv installs to ~/.local/bin)
 		profileCmd := `echo 'export PATH="$HOME/.local/bin:$PATH"' >> ~/.bashrc`
-		if result := conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
+		if result := c.Conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
 			fmt.Printf("⚠️  Failed to update bashrc: %s\n", result.Stderr)
 		}
 
 		// Export PATH for current session
 		exportCmd := `export PATH="$HOME/.local/bin:$PATH"`
-		conn.RunCommand(exportCmd, plugin.WithHideOutput())
+		c.Conn.RunCommand(exportCmd, plugin.WithHideOutput())
 	}
 
 	// Install Python version using uv
 	installCmd := fmt.Sprintf(`bash -c 'export PATH="$HOME/.local/bin:$PATH" && uv python install %s'`, version)
-	if result := conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
 		return fmt.Errorf("failed to install Python %s: %s", version, result.Stderr)
 	}
 
 	// Set the Python version as default
 	pinCmd := fmt.Sprintf(`bash -c 'export PATH="$HOME/.local/bin:$PATH" && uv python pin %s'`, version)
-	if result := conn.RunCommand(pinCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(pinCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to pin Python %s: %s\n", version, result.Stderr)
 	}
 
 	// Verify installation
 	verifyCmd := fmt.Sprintf(`bash -c 'export PATH="$HOME/.local/bin:$PATH" && uv run python --version && uv run pip --version'`)
-	if result := conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to verify Python installation: %s\n", result.Stderr)
 	} else {
 		fmt.Printf("✅ Python %s installed successfully!\n", version)
@@ -254,7 +250,7 @@ func (p *Plugin) installPython(ctx context.Context, conn plugin.Connection, vers
 	return nil
 }
 
-func (p *Plugin) installGo(ctx context.Context, conn plugin.Connection, version string, pass string) error {
+func (p *Plugin) installGo(c *sdk.Context, version string) error {
 	fmt.Printf("📦 Installing Go %s...\n", version)
 
 	// Format version string for Go download URLs
@@ -267,30 +263,30 @@ func (p *Plugin) installGo(ctx context.Context, conn plugin.Connection, version
 
 	// Download and install Go
 	downloadCmd := fmt.Sprintf(`wget https://go.dev/dl/go%s.linux-amd64.tar.gz -O /tmp/go%s.linux-amd64.tar.gz`, goVersion, goVersion)
-	if result := conn.RunCommand(downloadCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(downloadCmd, plugin.WithHideOutput()); !result.Success {
 		return fmt.Errorf("failed to download Go: %s", result.Stderr)
 	}
 
 	// Extract Go to /usr/local
-	extractCmd := fmt.Sprintf(`sudo tar -C /usr/local -xzf /tmp/go%s.linux-amd64.tar.gz`, goVersion)
-	result := conn.RunSudo(extractCmd, pass)
+	extractCmd := fmt.Sprintf(`tar -C /usr/local -xzf /tmp/go%s.linux-amd64.tar.gz`, goVersion)
+	result := c.Sudo(extractCmd)
 	if !result.Success {
 		return fmt.Errorf("failed to extract Go: %s", result.Stderr)
 	}
 
 	// Remove the tar file
 	cleanupCmd := fmt.Sprintf(`rm /tmp/go%s.linux-amd64.tar.gz`, goVersion)
-	conn.RunCommand(cleanupCmd, plugin.WithHideOutput())
+	c.Conn.RunCommand(cleanupCmd, plugin.WithHideOutput())
 
 	// Add Go to PATH
 	profileCmd := `echo 'export PATH=$PATH:/usr/local/go/bin' >> ~/.bashrc && echo 'export GOPATH=$HOME/go' >> ~/.bashrc && echo 'export PATH=$PATH:$GOPATH/bin' >> ~/.bashrc`
-	if result := conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to update bashrc: %s\n", result.Stderr)
 	}
 
 	// Verify installation
 	verifyCmd := `/usr/local/go/bin/go version`
-	if result := conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to verify Go installation: %s\n", result.Stderr)
 	} else {
 		fmt.Printf("✅ Go %s installed successfully!\n", version)
@@ -301,38 +297,36 @@ func (p *Plugin) installGo(ctx context.Context, conn plugin.Connection, version
 	return nil
 }
 
-func (p *Plugin) installJava(ctx context.Context, conn plugin.Connection, version string, pass string) error {
+func (p *Plugin) installJava(c *sdk.Context, version string) error {
 	fmt.Printf("📦 Installing Java %s...\n", version)
 
 	// Install OpenJDK
-	var installCmd string
+	var pkg string
 	if strings.HasPrefix(version, "8") {
-		installCmd = "apt-get update 2>/dev/null || true && apt-get install -y openjdk-8-jdk"
+		pkg = "openjdk-8-jdk"
 	} else if strings.HasPrefix(version, "11") {
-		installCmd = "apt-get update 2>/dev/null || true && apt-get install -y openjdk-11-jdk"
+		pkg = "openjdk-11-jdk"
 	} else if strings.HasPrefix(version, "17") {
-		installCmd = "apt-get update 2>/dev/null || true && apt-get install -y openjdk-17-jdk"
+		pkg = "openjdk-17-jdk"
 	} else if strings.HasPrefix(version, "21") {
-		installCmd = "apt-get update 2>/dev/null || true && apt-get install -y openjdk-21-jdk"
+		pkg = "openjdk-21-jdk"
 	} else {
 		return fmt.Errorf("unsupported Java version: %s. Supported versions: 8, 11, 17, 21", version)
 	}
 
-	// Install Java with single command (like other runtimes)
-	result := conn.RunSudo(installCmd, pass)
-	if !result.Success {
-		return fmt.Errorf("failed to install Java %s: %s", version, result.Stderr)
+	if err := c.Install(pkg); err != nil {
+		return fmt.Errorf("failed to install Java %s: %w", version, err)
 	}
 
 	// Set JAVA_HOME
 	homeCmd := `echo 'export JAVA_HOME=/usr/lib/jvm/java-'$(ls /usr/lib/jvm | grep openjdk | head -n 1 | cut -d'-' -f2)'-openjdk-amd64' >> ~/.bashrc`
-	if result := conn.RunCommand(homeCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(homeCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to set JAVA_HOME: %s\n", result.Stderr)
 	}
 
 	// Verify installation
 	verifyCmd := `bash -c 'source ~/.bashrc && java -version && javac -version'`
-	if result := conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to verify Java installation: %s\n", result.Stderr)
 	} else {
 		fmt.Printf("✅ Java %s installed successfully!\n", version)
@@ -342,28 +336,28 @@ func (p *Plugin) installJava(ctx context.Context, conn plugin.Connection, versio
 	return nil
 }
 
-func (p *Plugin) installRust(ctx context.Context, conn plugin.Connection, version string, pass string) error {
+func (p *Plugin) installRust(c *sdk.Context, version string) error {
 	fmt.Printf("📦 Installing Rust %s...\n", version)
 
 	// Install Rust using rustup
 	installCmd := `curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh -s -- -y`
-	if result := conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
 		return fmt.Errorf("failed to install Rust: %s", result.Stderr)
 	}
 
 	// Add cargo to PATH
 	profileCmd := `echo 'export PATH="$HOME/.cargo/bin:$PATH"' >> ~/.bashrc`
-	if result := conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to update bashrc: %s\n", result.Stderr)
 	}
 
 	// Export PATH for current session
 	exportCmd := `export PATH="$HOME/.cargo/bin:$PATH"`
-	conn.RunCommand(exportCmd, plugin.WithHideOutput())
+	c.Conn.RunCommand(exportCmd, plugin.WithHideOutput())
 
 	// Verify installation
 	verifyCmd := `bash -c 'export PATH="$HOME/.cargo/bin:$PATH" && rustc --version && cargo --version'`
-	if result := conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to verify Rust installation: %s\n", result.Stderr)
 	} else {
 		fmt.Printf("✅ Rust installed successfully!\n")
@@ -373,44 +367,47 @@ func (p *Plugin) installRust(ctx context.Context, conn plugin.Connection, versio
 	return nil
 }
 
-func (p *Plugin) installPHP(ctx context.Context, conn plugin.Connection, version string, pass string) error {
+// ondrejPHP is the Ubuntu PPA with current PHP versions
+var ondrejPHP = pkgmgr.Repository{
+	Name:        "ondrej-php",
+	URL:         "https://ppa.launchpadcontent.net/ondrej/php/ubuntu",
+	KeyURL:      "https://keyserver.ubuntu.com/pks/lookup?op=get&search=0x14AA40EC0831756756D7F66C4F4EA0AAE5267A6C",
+	Fingerprint: "14AA40EC0831756756D7F66C4F4EA0AAE5267A6C",
+}
+
+func (p *Plugin) installPHP(c *sdk.Context, version string) error {
 	fmt.Printf("📦 Installing PHP %s...\n", version)
 
-	// Add PPA for newer PHP versions, but handle gracefully if it fails
-	if version > "8.0" {
-		ppaCmd := `apt-get install -y software-properties-common && add-apt-repository -y ppa:ondrej/php 2>/dev/null || true`
-		result := conn.RunSudo(ppaCmd, pass)
-		if !result.Success {
-			fmt.Printf("⚠️  Failed to add PHP PPA, trying with default repositories\n")
+	// Add the PHP PPA for newer PHP versions, but handle gracefully if it fails
+	if version > "8.0" && c.Distro().IsUbuntu() {
+		if err := c.AddRepository(ondrejPHP); err != nil {
+			c.Warn("Failed to add PHP PPA (%v), trying with default repositories", err)
 		}
 	}
 
 	// Install PHP
-	var installCmd string
+	phpVersion := version
 	if version == "8" {
-		installCmd = "apt-get update 2>/dev/null || true && apt-get install -y php8.1 php8.1-cli php8.1-fpm php8.1-mbstring php8.1-xml php8.1-curl"
-	} else if strings.HasPrefix(version, "8.") {
-		installCmd = fmt.Sprintf("apt-get update 2>/dev/null || true && apt-get install -y php%s php%s-cli php%s-fpm php%s-mbstring php%s-xml php%s-curl", version, version, version, version, version, version)
-	} else {
-		installCmd = fmt.Sprintf("apt-get update 2>/dev/null || true && apt-get install -y php%s php%s-cli php%s-fpm php%s-mbstring php%s-xml php%s-curl", version, version, version, version, version, version)
+		phpVersion = "8.1"
 	}
-	result := conn.RunSudo(installCmd, pass)
-	if !result.Success {
+	var pkgs []string
+	for _, suffix := range []string{"", "-cli", "-fpm", "-mbstring", "-xml", "-curl"} {
+		pkgs = append(pkgs, "php"+phpVersion+suffix)
+	}
+	if err := c.Install(pkgs...); err != nil {
 		// Fallback to default PHP version if specific version fails
-		fmt.Printf("⚠️  PHP %s not available, trying with default PHP version...\n", version)
-		fallbackCmd := "apt-get update 2>/dev/null || true && apt-get install -y php php-cli php-fpm php-mbstring php-xml php-curl"
-		result = conn.RunSudo(fallbackCmd, pass)
-		if !result.Success {
-			return fmt.Errorf("failed to install PHP: %s", result.Stderr)
+		c.Warn("PHP %s not available, trying with default PHP version...", version)
+		if err := c.Install("php", "php-cli", "php-fpm", "php-mbstring", "php-xml", "php-curl"); err != nil {
+			return fmt.Errorf("failed to install PHP: %w", err)
 		}
 	}
 
 	// Verify installation
 	verifyCmd := fmt.Sprintf("php%s --version", version)
-	if result := conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
 		// Fallback to generic php command if version-specific fails
 		verifyCmd = "php --version"
-		if result := conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
+		if result := c.Conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
 			fmt.Printf("⚠️  Failed to verify PHP installation: %s\n", result.Stderr)
 		} else {
 			fmt.Printf("✅ PHP installed successfully!\n")
@@ -424,7 +421,7 @@ func (p *Plugin) installPHP(ctx context.Context, conn plugin.Connection, version
 	return nil
 }
 
-func (p *Plugin) installRuby(ctx context.Context, conn plugin.Connection, version string, pass string) error {
+func (p *Plugin) installRuby(c *sdk.Context, version string) error {
 	fmt.Printf("📦 Installing Ruby %s...\n", version)
 
 	// Format Ruby version for rbenv
@@ -438,35 +435,33 @@ func (p *Plugin) installRuby(ctx context.Context, conn plugin.Connection, versio
 	}
 
 	// Install Ruby using rbenv
-	installDeps := `apt-get update 2>/dev/null || true && apt-get install -y autoconf bison build-essential libssl-dev libyaml-dev libreadline6-dev zlib1g-dev libncurses5-dev libffi-dev libgdbm-dev git`
-	result := conn.RunSudo(installDeps, pass)
-	if !result.Success {
-		return fmt.Errorf("failed to install Ruby build dependencies: %s", result.Stderr)
+	if err := c.Install("autoconf", "bison", "build-essential", "libssl-dev", "libyaml-dev", "libreadline6-dev", "zlib1g-dev", "libncurses5-dev", "libffi-dev", "libgdbm-dev", "git"); err != nil {
+		return fmt.Errorf("failed to install Ruby build dependencies: %w", err)
 	}
 
 	// Install rbenv (check if already installed first)
-	if result := conn.RunCommand("test -d ~/.rbenv", plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand("test -d ~/.rbenv", plugin.WithHideOutput()); !result.Success {
 		rbenvCmd := `git clone https://github.com/rbenv/rbenv.git ~/.rbenv && git clone https://github.com/rbenv/ruby-build.git ~/.rbenv/plugins/ruby-build`
-		if result := conn.RunCommand(rbenvCmd, plugin.WithHideOutput()); !result.Success {
+		if result := c.Conn.RunCommand(rbenvCmd, plugin.WithHideOutput()); !result.Success {
 			return fmt.Errorf("failed to install rbenv: %s", result.Stderr)
 		}
 	}
 
 	// Add rbenv to PATH
 	profileCmd := `echo 'export PATH="$HOME/.rbenv/bin:$PATH"' >> ~/.bashrc && echo 'eval "$(rbenv init -)"' >> ~/.bashrc`
-	if result := conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(profileCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to update bashrc: %s\n", result.Stderr)
 	}
 
 	// Install Ruby version
 	installCmd := fmt.Sprintf(`bash -c 'export PATH="$HOME/.rbenv/bin:$PATH" && eval "$(rbenv init -)" && rbenv install %s --skip-existing && rbenv global %s'`, rubyVersion, rubyVersion)
-	if result := conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(installCmd, plugin.WithHideOutput()); !result.Success {
 		return fmt.Errorf("failed to install Ruby %s: %s", version, result.Stderr)
 	}
 
 	// Verify installation
 	verifyCmd := fmt.Sprintf(`bash -c 'export PATH="$HOME/.rbenv/bin:$PATH" && eval "$(rbenv init -)" && ruby --version && gem --version'`)
-	if result := conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to verify Ruby installation: %s\n", result.Stderr)
 	} else {
 		fmt.Printf("✅ Ruby %s installed successfully!\n", rubyVersion)
@@ -476,12 +471,12 @@ func (p *Plugin) installRuby(ctx context.Context, conn plugin.Connection, versio
 	return nil
 }
 
-func (p *Plugin) installDotNet(ctx context.Context, conn plugin.Connection, version string, pass string) error {
+func (p *Plugin) installDotNet(c *sdk.Context, version string) error {
 	fmt.Printf("📦 Installing .NET %s...\n", version)
 
 	// Detect Ubuntu version
 	ubuntuVersion := "20.04"
-	if result := conn.RunCommand(`lsb_release -rs | cut -d. -f1`, plugin.WithHideOutput()); result.Success {
+	if result := c.Conn.RunCommand(`lsb_release -rs | cut -d. -f1`, plugin.WithHideOutput()); result.Success {
 		ver := strings.TrimSpace(result.Stdout)
 		if ver == "22" || ver == "24" {
 			ubuntuVersion = ver + ".04"
@@ -489,8 +484,11 @@ func (p *Plugin) installDotNet(ctx context.Context, conn plugin.Connection, vers
 	}
 
 	// Add Microsoft package repository
-	repoCmd := fmt.Sprintf(`apt-get update 2>/dev/null || true && apt-get install -y wget && wget https://packages.microsoft.com/config/ubuntu/%s/packages-microsoft-prod.deb -O packages-microsoft-prod.deb && dpkg -i packages-microsoft-prod.deb`, ubuntuVersion)
-	result := conn.RunSudo(repoCmd, pass)
+	if err := c.Install("wget"); err != nil {
+		return err
+	}
+	repoCmd := fmt.Sprintf(`wget https://packages.microsoft.com/config/ubuntu/%s/packages-microsoft-prod.deb -O /tmp/packages-microsoft-prod.deb && dpkg -i /tmp/packages-microsoft-prod.deb && rm -f /tmp/packages-microsoft-prod.deb`, ubuntuVersion)
+	result := c.Shell(repoCmd)
 	if !result.Success {
 		return fmt.Errorf("failed to add Microsoft repository: %s", result.Stderr)
 	}
@@ -500,15 +498,17 @@ func (p *Plugin) installDotNet(ctx context.Context, conn plugin.Connection, vers
 	if len(strings.Split(version, ".")) == 1 {
 		dotnetVersion = version + ".0"
 	}
-	installCmd := fmt.Sprintf("apt-get update 2>/dev/null || true && apt-get install -y dotnet-sdk-%s", dotnetVersion)
-	result = conn.RunSudo(installCmd, pass)
-	if !result.Success {
-		return fmt.Errorf("failed to install .NET %s: %s", version, result.Stderr)
+	// The new repository's package lists are needed before installing
+	if err := c.UpdatePackages(); err != nil {
+		return err
+	}
+	if err := c.Install("dotnet-sdk-" + dotnetVersion); err != nil {
+		return fmt.Errorf("failed to install .NET %s: %w", version, err)
 	}
 
 	// Verify installation
 	verifyCmd := fmt.Sprintf("dotnet --version")
-	if result := conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
+	if result := c.Conn.RunCommand(verifyCmd, plugin.WithHideOutput()); !result.Success {
 		fmt.Printf("⚠️  Failed to verify .NET installation: %s\n", result.Stderr)
 	} else {
 		fmt.Printf("✅ .NET %s installed successfully!\n", version)
@@ -637,7 +637,7 @@ func (p *Plugin) removeHandler(ctx context.Context, conn plugin.Connection, args
 
 	language := strings.ToLower(args[0])
 	version := args[1]
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	switch language {
 	case "node", "nodejs", "node.js":
@@ -662,8 +662,8 @@ func (p *Plugin) removeHandler(ctx context.Context, conn plugin.Connection, args
 		}
 
 		// Remove Go installation
-		removeCmd := fmt.Sprintf(`sudo rm -rf /usr/local/go%s && sudo rm -rf /usr/local/go`, version)
-		result := conn.RunSudo(removeCmd, pass)
+		removeCmd := fmt.Sprintf(`rm -rf /usr/local/go%s /usr/local/go`, version)
+		result := c.Sudo(removeCmd)
 		if !result.Success {
 			return fmt.Errorf("failed to remove Go %s: %s", version, result.Stderr)
 		}
@@ -685,8 +685,8 @@ func (p *Plugin) removeHandler(ctx context.Context, conn plugin.Connection, args
 		javaDir := strings.TrimSpace(result.Stdout)
 
 		// Remove Java installation
-		removeCmd := fmt.Sprintf(`sudo rm -rf /usr/lib/jvm/%s`, javaDir)
-		result = conn.RunSudo(removeCmd, pass)
+		removeCmd := fmt.Sprintf(`rm -rf /usr/lib/jvm/%s`, javaDir)
+		result = c.Sudo(removeCmd)
 		if !result.Success {
 			return fmt.Errorf("failed to remove Java %s: %s", version, result.Stderr)
 		}
@@ -716,10 +716,12 @@ func (p *Plugin) removeHandler(ctx context.Context, conn plugin.Connection, args
 
 	case "php":
 		// Remove PHP packages
-		removeCmd := fmt.Sprintf(`sudo apt-get remove -y php%s php%s-cli php%s-fpm php%s-mbstring php%s-xml php%s-curl`, version, version, version, version, version, version)
-		result := conn.RunSudo(removeCmd, pass)
-		if !result.Success {
-			return fmt.Errorf("failed to remove PHP %s: %s", version, result.Stderr)
+		var pkgs []string
+		for _, suffix := range []string{"", "-cli", "-fpm", "-mbstring", "-xml", "-curl"} {
+			pkgs = append(pkgs, "php"+version+suffix)
+		}
+		if err := c.Remove(pkgs...); err != nil {
+			return fmt.Errorf("failed to remove PHP %s: %w", version, err)
 		}
 
 		fmt.Printf("✅ PHP %s uninstalled\n", version)
@@ -735,10 +737,8 @@ func (p *Plugin) removeHandler(ctx context.Context, conn plugin.Connection, args
 
 	case "dotnet", ".net", "net":
 		// Remove .NET SDK
-		removeCmd := fmt.Sprintf(`sudo apt-get remove -y dotnet-sdk-%s`, version)
-		result := conn.RunSudo(removeCmd, pass)
-		if !result.Success {
-			return fmt.Errorf("failed to remove .NET %s: %s", version, result.Stderr)
+		if err := c.Remove("dotnet-sdk-" + version); err != nil {
+			return fmt.Errorf("failed to remove .NET %s: %w", version, err)
 		}
 
 		fmt.Printf("✅ .NET %s uninstalled\n", version)
@@ -816,14 +816,3 @@ func (p *Plugin) updateHandler(ctx context.Context, conn plugin.Connection, args
 }
 
 // Helper
-func getSudoPass(flags map[string]interface{}) string {
-	if v, ok := flags["sudo-password"]; ok {
-		return v.(string)
-	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-	return pkgmgr.GetPackageManager(distroInfo)
-}
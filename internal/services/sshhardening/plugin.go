@@ -0,0 +1,398 @@
+package sshhardening
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wasilwamark/vps-init/internal/config"
+	"github.com/wasilwamark/vps-init/internal/ssh"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+const (
+	// defaultTimeout is how long a new configuration has to prove itself
+	// before it is rolled back
+	defaultTimeout = 60 * time.Second
+	minTimeout     = 30 * time.Second
+	// rollbackMargin is kept between the last verification attempt and the
+	// rollback, so a late confirmation can't race it
+	rollbackMargin = 15 * time.Second
+	verifyInterval = 3 * time.Second
+)
+
+// statusKeys are the sshd settings status reports
+var statusKeys = []string{
+	"port", "permitrootlogin", "passwordauthentication", "kbdinteractiveauthentication",
+	"challengeresponseauthentication", "pubkeyauthentication", "allowusers", "allowgroups",
+	"ciphers", "macs", "kexalgorithms",
+}
+
+type Plugin struct {
+	// target is the alias or user@host the command was run against
+	target string
+}
+
+func (p *Plugin) Name() string {
+	return "ssh-hardening"
+}
+
+func (p *Plugin) Description() string {
+	return "Harden sshd with automatic rollback"
+}
+
+func (p *Plugin) Author() string {
+	return "VPS-Init"
+}
+
+func (p *Plugin) Version() string {
+	return "0.0.1"
+}
+
+func (p *Plugin) Initialize(config map[string]interface{}) error {
+	p.target, _ = config["target"].(string)
+	return nil
+}
+
+func (p *Plugin) Start(ctx context.Context) error {
+	return nil
+}
+
+func (p *Plugin) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (p *Plugin) GetRootCommand() *cobra.Command {
+	return nil
+}
+
+func (p *Plugin) Validate() error {
+	return nil
+}
+
+func (p *Plugin) Dependencies() []plugin.Dependency {
+	return []plugin.Dependency{}
+}
+
+func (p *Plugin) Compatibility() plugin.Compatibility {
+	return plugin.Compatibility{
+		MinVPSInitVersion: "0.1.0",
+		Tags:              []string{"ssh", "security", "hardening"},
+	}
+}
+
+func (p *Plugin) GetMetadata() plugin.PluginMetadata {
+	return plugin.PluginMetadata{
+		Name:        p.Name(),
+		Description: p.Description(),
+		Version:     p.Version(),
+		Author:      p.Author(),
+		License:     "MIT",
+		Repository:  "github.com/wasilwamark/vps-init-plugins/" + p.Name(),
+		Tags:        []string{"ssh", "security", "hardening"},
+		Validated:   true,
+		TrustLevel:  "official",
+		BuildInfo: plugin.BuildInfo{
+			GoVersion: "1.21",
+		},
+	}
+}
+
+func (p *Plugin) GetCommands() []plugin.Command {
+	return []plugin.Command{
+		{
+			Name:        "apply",
+			Description: "Harden sshd [--port N] [--allow-users a,b] [--allow-groups g] [--permit-root] [--keep-passwords] [--timeout 60s] [--dry-run]",
+			Handler:     p.applyHandler,
+		},
+		{
+			Name:        "status",
+			Description: "Show the effective sshd security settings",
+			Handler:     p.statusHandler,
+		},
+		{
+			Name:        "rollback",
+			Description: "Restore the sshd configuration from before the last apply",
+			Handler:     p.rollbackHandler,
+		},
+	}
+}
+
+// splitList splits a comma separated option
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (p *Plugin) applyHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	_, options := sdk.ParseOptions(args, "permit-root", "keep-passwords", "dry-run")
+
+	timeout := defaultTimeout
+	if value := options["timeout"]; value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil || timeout < minTimeout {
+			return fmt.Errorf("invalid timeout '%s'; use a duration of at least %s", value, minTimeout)
+		}
+	}
+	newPort := 0
+	if value := options["port"]; value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port '%s'", value)
+		}
+		if port != conn.Port() {
+			newPort = port
+		}
+	}
+
+	c := sdk.New(ctx, conn, flags)
+	effective, err := effectiveConfig(c)
+	if err != nil {
+		return err
+	}
+
+	s := settings{
+		port:        newPort,
+		permitRoot:  "no",
+		passwords:   options["keep-passwords"] == "true",
+		kbdKeyword:  "KbdInteractiveAuthentication",
+		ciphers:     supported(c, "cipher", preferredCiphers),
+		macs:        supported(c, "mac", preferredMACs),
+		kex:         supported(c, "kex", preferredKex),
+		allowUsers:  splitList(options["allow-users"]),
+		allowGroups: splitList(options["allow-groups"]),
+	}
+	if _, ok := effective["kbdinteractiveauthentication"]; !ok {
+		s.kbdKeyword = "ChallengeResponseAuthentication"
+	}
+	if options["permit-root"] == "true" || conn.User() == "root" {
+		s.permitRoot = "prohibit-password"
+	}
+	if err := p.preflight(c, &s); err != nil {
+		return err
+	}
+
+	content := s.render()
+	if options["dry-run"] == "true" {
+		fmt.Printf("📋 %s would contain:\n\n%s", dropIn, content)
+		return nil
+	}
+
+	reload, err := reloadCommand(c)
+	if err != nil {
+		return err
+	}
+	c.Step("Saving the current sshd configuration")
+	if err := snapshot(c, conn.Port(), reload); err != nil {
+		return err
+	}
+
+	// Until the rollback is armed, any failure restores the files without
+	// sshd ever seeing them
+	fail := func(err error) error {
+		if rbErr := rollback(c); rbErr != nil {
+			return fmt.Errorf("%w; %v", err, rbErr)
+		}
+		c.Info("Restored the previous sshd configuration")
+		return err
+	}
+
+	if err := ensureInclude(c, newPort != 0); err != nil {
+		return fail(err)
+	}
+	if err := c.WriteFile(dropIn, content, 0600); err != nil {
+		return fail(err)
+	}
+	c.Step("Validating the new configuration")
+	if result := c.Sudo("sshd -t"); !result.Success {
+		return fail(fmt.Errorf("sshd rejected the new configuration: %s", strings.TrimSpace(result.Stderr)))
+	}
+	if effective, err = effectiveConfig(c); err != nil {
+		return fail(err)
+	}
+	if err := checkEffective(effective, s); err != nil {
+		return fail(err)
+	}
+
+	if newPort != 0 {
+		if newPort != 22 {
+			if err := enableSELinuxPort(c, newPort); err != nil {
+				return fail(err)
+			}
+		}
+		// The firewall must let the new port in before sshd moves to it
+		if err := plugin.Publish(c, conn, flags, plugin.PortOpened{Port: newPort, Protocol: "tcp", Service: "ssh"}); err != nil {
+			return fail(err)
+		}
+	}
+
+	c.Step("Scheduling a rollback in %s", timeout)
+	if err := armRollback(c, int(timeout.Seconds())); err != nil {
+		return fail(err)
+	}
+	deadline := time.Now().Add(timeout)
+
+	c.Step("Reloading sshd")
+	if result := c.Shell(reload); !result.Success {
+		return p.abandon(c, deadline, fmt.Errorf("failed to reload sshd: %s", strings.TrimSpace(result.Stderr)))
+	}
+
+	port := conn.Port()
+	if newPort != 0 {
+		port = newPort
+	}
+	verified, err := verify(ctx, conn, port, deadline.Add(-rollbackMargin))
+	if err != nil {
+		return p.abandon(c, deadline, err)
+	}
+	vc := sdk.New(ctx, verified, flags)
+	if err := confirm(vc); err != nil {
+		return p.abandon(c, deadline, err)
+	}
+	c.Success("sshd accepted a new login on port %d; the configuration is kept", port)
+
+	if newPort != 0 {
+		vc.Publish(plugin.PortClosed{Port: conn.Port(), Protocol: "tcp", Service: "ssh"})
+		p.updateAlias(c, conn, newPort)
+	}
+	return nil
+}
+
+// preflight refuses settings that would keep the connecting user out
+func (p *Plugin) preflight(c *sdk.Context, s *settings) error {
+	user := c.Conn.User()
+
+	if len(s.allowUsers) > 0 && !slices.Contains(s.allowUsers, user) {
+		c.Info("Adding %s to AllowUsers, as vps-init logs in with it", user)
+		s.allowUsers = append(s.allowUsers, user)
+	}
+	if len(s.allowGroups) > 0 {
+		groups := strings.Fields(c.Run("id -Gn").Stdout)
+		member := false
+		for _, g := range s.allowGroups {
+			member = member || slices.Contains(groups, g)
+		}
+		if !member {
+			return fmt.Errorf("%s is in none of the groups %s, so AllowGroups would lock vps-init out", user, strings.Join(s.allowGroups, ", "))
+		}
+	}
+	if !s.passwords && !c.Run("test -s ~/.ssh/authorized_keys").Success {
+		return fmt.Errorf("%s has no ~/.ssh/authorized_keys; add a key with 'users keys add' before disabling passwords, or pass --keep-passwords", user)
+	}
+	return nil
+}
+
+// verify logs in again on the new settings until a login works or the
+// deadline passes. Every attempt is a new SSH connection.
+func verify(ctx context.Context, conn plugin.Connection, port int, deadline time.Time) (plugin.Connection, error) {
+	fmt.Printf("🔍 Verifying a new login to %s@%s:%d...\n", conn.User(), conn.Host(), port)
+	for {
+		verified := ssh.NewConnection(ssh.Config{Host: conn.Host(), User: conn.User(), Port: port})
+		if verified.Connect() {
+			return verified, nil
+		}
+		if time.Now().Add(verifyInterval).After(deadline) {
+			return nil, fmt.Errorf("could not log in to %s on port %d with the new configuration", conn.Host(), port)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(verifyInterval):
+		}
+	}
+}
+
+// abandon gives up on an armed configuration: it is restored now if the
+// original connection still works, and by the rollback timer otherwise
+func (p *Plugin) abandon(c *sdk.Context, deadline time.Time, err error) error {
+	if rollback(c) == nil {
+		c.Info("Restored the previous sshd configuration")
+		return err
+	}
+	return fmt.Errorf("%w; the previous sshd configuration is restored automatically at %s", err, deadline.Format("15:04:05"))
+}
+
+// updateAlias points the alias the command ran against at the new port
+func (p *Plugin) updateAlias(c *sdk.Context, conn plugin.Connection, port int) {
+	moved := config.Connection{User: conn.User(), Host: conn.Host(), Port: port}
+	if p.target == "" || strings.Contains(p.target, "@") {
+		c.Info("Connect with %s from now on", moved.String())
+		return
+	}
+	if err := config.New().SetAlias(p.target, moved.String()); err != nil {
+		c.Warn("Failed to update alias '%s': %v; connect with %s", p.target, err, moved.String())
+		return
+	}
+	c.Success("Alias '%s' now points to %s", p.target, moved.String())
+}
+
+func (p *Plugin) statusHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+	effective, err := effectiveConfig(c)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔐 sshd settings on %s:\n", conn.Host())
+	for _, key := range statusKeys {
+		if values, ok := effective[key]; ok {
+			fmt.Printf("  %-32s %s\n", key, strings.Join(values, " "))
+		}
+	}
+
+	fmt.Println()
+	if !c.Run("test -f " + dropIn).Success {
+		fmt.Println("ℹ️  Not hardened by vps-init; run 'vps-init <target> ssh-hardening apply'")
+		return nil
+	}
+	switch {
+	case c.Run("test -f " + rolledBackFile).Success:
+		c.Warn("The last apply was rolled back because it was never confirmed")
+	case c.Run("test -f " + confirmedFile).Success:
+		c.Success("Hardened by vps-init (%s)", dropIn)
+	default:
+		c.Warn("The last apply is unconfirmed and will be rolled back")
+	}
+	return nil
+}
+
+func (p *Plugin) rollbackHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+	if !c.Run("test -f " + rollbackScript).Success {
+		return fmt.Errorf("nothing to roll back; ssh-hardening apply has not run on %s", conn.Host())
+	}
+	previous, _ := strconv.Atoi(strings.TrimSpace(c.Sudo("cat " + portFile).Stdout))
+
+	// Open the previous port before sshd moves back to it
+	moved := previous != 0 && previous != conn.Port()
+	if moved {
+		if err := plugin.Publish(c, conn, flags, plugin.PortOpened{Port: previous, Protocol: "tcp", Service: "ssh"}); err != nil {
+			return err
+		}
+	}
+
+	c.Step("Restoring the previous sshd configuration")
+	if err := rollback(c); err != nil {
+		return err
+	}
+	if result := c.Sudo("rm -rf " + rollbackDir); !result.Success {
+		c.Warn("Failed to remove %s: %s", rollbackDir, strings.TrimSpace(result.Stderr))
+	}
+	c.Success("Restored the previous sshd configuration")
+
+	if moved {
+		p.updateAlias(c, conn, previous)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package sshhardening
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+const (
+	sshdConfig = "/etc/ssh/sshd_config"
+	// dropIn sorts first, and sshd keeps the first value it reads for most
+	// keywords
+	dropIn      = "/etc/ssh/sshd_config.d/00-vps-init-hardening.conf"
+	includeLine = "Include /etc/ssh/sshd_config.d/*.conf"
+	// rollbackDir holds the configuration from before the last apply and the
+	// script that restores it
+	rollbackDir    = "/etc/ssh/vps-init-rollback"
+	rollbackScript = rollbackDir + "/rollback.sh"
+	confirmedFile  = rollbackDir + "/confirmed"
+	rolledBackFile = rollbackDir + "/rolled-back"
+	portFile       = rollbackDir + "/port"
+	// rollbackUnit is the transient systemd timer that runs the rollback
+	rollbackUnit = "vps-init-ssh-rollback"
+)
+
+// Preferred algorithms, strongest first. Only those the server's OpenSSH
+// supports are configured.
+var (
+	preferredCiphers = []string{
+		"chacha20-poly1305@openssh.com",
+		"aes256-gcm@openssh.com",
+		"aes128-gcm@openssh.com",
+		"aes256-ctr",
+		"aes192-ctr",
+		"aes128-ctr",
+	}
+	preferredMACs = []string{
+		"hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256-etm@openssh.com",
+		"umac-128-etm@openssh.com",
+	}
+	preferredKex = []string{
+		"sntrup761x25519-sha512@openssh.com",
+		"curve25519-sha256",
+		"curve25519-sha256@libssh.org",
+		"diffie-hellman-group16-sha512",
+		"diffie-hellman-group18-sha512",
+		"diffie-hellman-group-exchange-sha256",
+	}
+)
+
+// settings are the sshd options the drop-in sets
+type settings struct {
+	port        int // 0 keeps the current port
+	permitRoot  string
+	passwords   bool
+	kbdKeyword  string
+	ciphers     []string
+	macs        []string
+	kex         []string
+	allowUsers  []string
+	allowGroups []string
+}
+
+func (s settings) render() string {
+	var b strings.Builder
+	b.WriteString("# Managed by vps-init ssh-hardening. Run 'vps-init <target> ssh-hardening rollback'\n")
+	b.WriteString("# to restore the previous configuration.\n")
+	if s.port != 0 {
+		fmt.Fprintf(&b, "Port %d\n", s.port)
+	}
+	fmt.Fprintf(&b, "PermitRootLogin %s\n", s.permitRoot)
+	if !s.passwords {
+		b.WriteString("PasswordAuthentication no\n")
+		fmt.Fprintf(&b, "%s no\n", s.kbdKeyword)
+	}
+	b.WriteString("PermitEmptyPasswords no\n")
+	b.WriteString("PubkeyAuthentication yes\n")
+	b.WriteString("MaxAuthTries 3\n")
+	b.WriteString("LoginGraceTime 30\n")
+	b.WriteString("X11Forwarding no\n")
+	if len(s.ciphers) > 0 {
+		fmt.Fprintf(&b, "Ciphers %s\n", strings.Join(s.ciphers, ","))
+	}
+	if len(s.macs) > 0 {
+		fmt.Fprintf(&b, "MACs %s\n", strings.Join(s.macs, ","))
+	}
+	if len(s.kex) > 0 {
+		fmt.Fprintf(&b, "KexAlgorithms %s\n", strings.Join(s.kex, ","))
+	}
+	if len(s.allowUsers) > 0 {
+		fmt.Fprintf(&b, "AllowUsers %s\n", strings.Join(s.allowUsers, " "))
+	}
+	if len(s.allowGroups) > 0 {
+		fmt.Fprintf(&b, "AllowGroups %s\n", strings.Join(s.allowGroups, " "))
+	}
+	return b.String()
+}
+
+// effectiveConfig reads sshd's effective configuration. Keywords are lower
+// case; repeated keywords such as port have several values.
+func effectiveConfig(c *sdk.Context) (map[string][]string, error) {
+	result := c.Sudo("sshd -T")
+	if !result.Success {
+		return nil, fmt.Errorf("failed to read the sshd configuration: %s", strings.TrimSpace(result.Stderr))
+	}
+	return parseEffectiveConfig(result.Stdout), nil
+}
+
+func parseEffectiveConfig(out string) map[string][]string {
+	config := map[string][]string{}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if ok {
+			config[key] = append(config[key], value)
+		}
+	}
+	return config
+}
+
+// checkEffective compares sshd's view of the new configuration with what
+// the drop-in sets, catching earlier lines in sshd_config that win over it
+func checkEffective(config map[string][]string, s settings) error {
+	want := map[string]string{"permitrootlogin": s.permitRoot}
+	if !s.passwords {
+		want["passwordauthentication"] = "no"
+	}
+	for key, value := range want {
+		got := strings.Join(config[key], " ")
+		// OpenSSH before 7.0 calls prohibit-password without-password
+		if got == value || (value == "prohibit-password" && got == "without-password") {
+			continue
+		}
+		return fmt.Errorf("sshd uses '%s %s' instead of '%s'; an earlier line in %s overrides the drop-in", key, got, value, sshdConfig)
+	}
+	if s.port != 0 {
+		if ports := config["port"]; len(ports) != 1 || ports[0] != strconv.Itoa(s.port) {
+			return fmt.Errorf("sshd would listen on port %s instead of %d; check for other Port lines in /etc/ssh", strings.Join(ports, ", "), s.port)
+		}
+	}
+	return nil
+}
+
+// supported returns the preferred algorithms of a kind (cipher, mac or kex)
+// that the server's OpenSSH knows
+func supported(c *sdk.Context, kind string, preferred []string) []string {
+	available := strings.Fields(c.Run("ssh -Q " + kind).Stdout)
+	var algorithms []string
+	for _, a := range preferred {
+		if slices.Contains(available, a) {
+			algorithms = append(algorithms, a)
+		}
+	}
+	return algorithms
+}
+
+// serviceName is the name of the sshd service
+func serviceName(info *distro.DistroInfo) string {
+	if info.Family == distro.DistroFamilyDebian {
+		return "ssh"
+	}
+	return "sshd"
+}
+
+// reloadCommand makes sshd pick up a new configuration. Existing sessions
+// survive a reload. With socket activation (Ubuntu 22.10 and later) the
+// port lives in ssh.socket, which is regenerated from sshd_config.
+func reloadCommand(c *sdk.Context) (string, error) {
+	name := serviceName(c.Distro())
+	if c.Run("systemctl is-active ssh.socket").Success {
+		return fmt.Sprintf("systemctl daemon-reload && systemctl restart ssh.socket && systemctl try-reload-or-restart %s", name), nil
+	}
+	return c.ServiceManager().Reload(name)
+}
+
+// rollbackScriptContent restores the files saved by snapshot. It does
+// nothing once the new configuration is confirmed, unless FORCE is set.
+func rollbackScriptContent(reload string) string {
+	return fmt.Sprintf(`#!/bin/sh
+# Written by vps-init ssh-hardening: restores the sshd configuration from
+# before the last apply
+dir=%[1]s
+if [ -f "$dir/confirmed" ] && [ -z "$FORCE" ]; then
+	exit 0
+fi
+cp -p "$dir/sshd_config" %[2]s
+if [ -f "$dir/drop-in" ]; then
+	cp -p "$dir/drop-in" %[3]s
+else
+	rm -f %[3]s
+fi
+%[4]s
+touch "$dir/rolled-back"
+`, rollbackDir, sshdConfig, dropIn, reload)
+}
+
+// snapshot saves the current configuration and the script that restores it
+func snapshot(c *sdk.Context, port int, reload string) error {
+	script := fmt.Sprintf("set -e; rm -rf %[1]s; mkdir -m 700 %[1]s; cp -p %[2]s %[1]s/sshd_config; if [ -f %[3]s ]; then cp -p %[3]s %[1]s/drop-in; fi; echo %[4]d > %[5]s",
+		rollbackDir, sshdConfig, dropIn, port, portFile)
+	if result := c.Shell(script); !result.Success {
+		return fmt.Errorf("failed to save the current sshd configuration: %s", strings.TrimSpace(result.Stderr))
+	}
+	return c.WriteFile(rollbackScript, rollbackScriptContent(reload), 0700)
+}
+
+// armRollback schedules the rollback script, so the previous configuration
+// comes back unless the new one is confirmed in time
+func armRollback(c *sdk.Context, seconds int) error {
+	script := fmt.Sprintf(`if command -v systemd-run >/dev/null 2>&1; then
+	systemctl stop %[1]s.timer %[1]s.service >/dev/null 2>&1
+	systemctl reset-failed %[1]s.service >/dev/null 2>&1
+	systemd-run --quiet --unit=%[1]s --on-active=%[2]ds /bin/sh %[3]s
+else
+	nohup sh -c 'sleep %[2]d; sh %[3]s' >/dev/null 2>&1 &
+fi`, rollbackUnit, seconds, rollbackScript)
+	if result := c.Shell(script); !result.Success {
+		return fmt.Errorf("failed to schedule the rollback: %s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// confirm keeps the new configuration by disarming the rollback
+func confirm(c *sdk.Context) error {
+	script := fmt.Sprintf("touch %s && (systemctl stop %s.timer >/dev/null 2>&1 || true)", confirmedFile, rollbackUnit)
+	if result := c.Shell(script); !result.Success {
+		return fmt.Errorf("failed to confirm the new configuration: %s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// rollback runs the rollback script now
+func rollback(c *sdk.Context) error {
+	if result := c.Shell("FORCE=1 sh " + rollbackScript); !result.Success {
+		return fmt.Errorf("failed to restore the previous sshd configuration: %s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// ensureInclude makes sshd_config read the drop-in directory before its own
+// settings. Moving sshd also comments out the Port lines of sshd_config, as
+// Port adds to the ports rather than replacing them.
+func ensureInclude(c *sdk.Context, movePort bool) error {
+	script := fmt.Sprintf(`grep -Eq '^[[:space:]]*Include[[:space:]]+/etc/ssh/sshd_config\.d/\*\.conf' %[1]s || sed -i '1i %[2]s' %[1]s`, sshdConfig, includeLine)
+	if movePort {
+		script += fmt.Sprintf(` && sed -i -E 's/^([[:space:]]*Port[[:space:]])/# Moved by vps-init ssh-hardening: \1/' %s`, sshdConfig)
+	}
+	if result := c.Shell(script); !result.Success {
+		return fmt.Errorf("failed to update %s: %s", sshdConfig, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// enableSELinuxPort labels a new port for sshd where SELinux enforces it
+func enableSELinuxPort(c *sdk.Context, port int) error {
+	if !c.Run("selinuxenabled").Success {
+		return nil
+	}
+	if !c.Run("command -v semanage").Success {
+		return fmt.Errorf("SELinux is enabled but semanage is missing; install policycoreutils-python-utils to move sshd")
+	}
+	script := fmt.Sprintf("semanage port -a -t ssh_port_t -p tcp %[1]d 2>/dev/null || semanage port -m -t ssh_port_t -p tcp %[1]d", port)
+	if result := c.Shell(script); !result.Success {
+		return fmt.Errorf("failed to allow sshd on port %d in SELinux: %s", port, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
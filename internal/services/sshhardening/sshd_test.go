@@ -0,0 +1,97 @@
+package sshhardening
+
+import (
+	"reflect"
+	"testing"
+)
+
+// sshdT is an excerpt of "sshd -T" on Ubuntu 22.04 after applying the drop-in
+const sshdT = `port 2222
+addressfamily any
+listenaddress [::]:2222
+listenaddress 0.0.0.0:2222
+permitrootlogin prohibit-password
+passwordauthentication no
+kbdinteractiveauthentication no
+pubkeyauthentication yes
+maxauthtries 3
+ciphers chacha20-poly1305@openssh.com,aes256-gcm@openssh.com
+allowusers deploy admin
+`
+
+func TestParseEffectiveConfig(t *testing.T) {
+	config := parseEffectiveConfig(sshdT)
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"port", []string{"2222"}},
+		{"listenaddress", []string{"[::]:2222", "0.0.0.0:2222"}},
+		{"permitrootlogin", []string{"prohibit-password"}},
+		{"ciphers", []string{"chacha20-poly1305@openssh.com,aes256-gcm@openssh.com"}},
+		{"allowusers", []string{"deploy admin"}},
+		{"x11forwarding", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := config[tt.key]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("config[%q] = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckEffective(t *testing.T) {
+	hardened := settings{port: 2222, permitRoot: "prohibit-password"}
+	tests := []struct {
+		name    string
+		out     string
+		s       settings
+		wantErr bool
+	}{
+		{name: "applied", out: sshdT, s: hardened},
+		{name: "current port kept", out: sshdT, s: settings{permitRoot: "prohibit-password"}},
+		{
+			name: "old OpenSSH spelling",
+			out:  "port 22\npermitrootlogin without-password\npasswordauthentication no\n",
+			s:    settings{permitRoot: "prohibit-password"},
+		},
+		{
+			name:    "earlier PermitRootLogin wins",
+			out:     "port 2222\npermitrootlogin yes\npasswordauthentication no\n",
+			s:       hardened,
+			wantErr: true,
+		},
+		{
+			name:    "cloud-init re-enables passwords",
+			out:     "port 2222\npermitrootlogin prohibit-password\npasswordauthentication yes\n",
+			s:       hardened,
+			wantErr: true,
+		},
+		{
+			name: "passwords kept on purpose",
+			out:  "port 2222\npermitrootlogin prohibit-password\npasswordauthentication yes\n",
+			s:    settings{port: 2222, permitRoot: "prohibit-password", passwords: true},
+		},
+		{
+			name:    "another Port line",
+			out:     "port 22\nport 2222\npermitrootlogin prohibit-password\npasswordauthentication no\n",
+			s:       hardened,
+			wantErr: true,
+		},
+		{
+			name:    "port not applied",
+			out:     "port 22\npermitrootlogin prohibit-password\npasswordauthentication no\n",
+			s:       hardened,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkEffective(parseEffectiveConfig(tt.out), tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkEffective() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
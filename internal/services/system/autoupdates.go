@@ -0,0 +1,349 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+const (
+	// aptPeriodicFile turns the daily unattended-upgrades run on and off
+	aptPeriodicFile = "/etc/apt/apt.conf.d/20auto-upgrades"
+	// aptUnattendedFile sorts after 50unattended-upgrades, so its settings win
+	aptUnattendedFile = "/etc/apt/apt.conf.d/52vps-init-unattended-upgrades"
+
+	dnfAutomaticFile  = "/etc/dnf/automatic.conf"
+	dnfAutomaticTimer = "dnf-automatic.timer"
+	dnfTimerDropIn    = "/etc/systemd/system/dnf-automatic.timer.d/vps-init.conf"
+)
+
+var (
+	rebootTimePattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+	// timerCalendarPattern finds the time of day in systemctl's TimersCalendar
+	timerCalendarPattern = regexp.MustCompile(`OnCalendar=\S+ (\d?\d:\d\d)`)
+)
+
+// autoUpdates is how unattended upgrades are set up on a server
+type autoUpdates struct {
+	enabled      bool
+	securityOnly bool
+	// rebootTime is the HH:MM window for reboots that updates need; "" never
+	// reboots
+	rebootTime string
+}
+
+func (a autoUpdates) print() {
+	if !a.enabled {
+		fmt.Println("🔄 Automatic updates: disabled")
+		return
+	}
+	fmt.Println("🔄 Automatic updates: enabled")
+	if a.securityOnly {
+		fmt.Println("   Updates: security only")
+	} else {
+		fmt.Println("   Updates: all")
+	}
+	if a.rebootTime != "" {
+		fmt.Printf("   Reboot:  at %s when required\n", a.rebootTime)
+	} else {
+		fmt.Println("   Reboot:  never")
+	}
+}
+
+func (p *Plugin) handleAutoUpdates(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	args, options := sdk.ParseOptions(args, "security-only")
+	if len(args) < 1 {
+		return fmt.Errorf("usage: auto-updates enable [--security-only] [--reboot-time HH:MM] | auto-updates disable | auto-updates status")
+	}
+
+	c := sdk.New(ctx, conn, flags)
+	pm := c.Distro().PackageMgr
+	if pm != distro.PackageManagerAPT && pm != distro.PackageManagerDNF {
+		return fmt.Errorf("automatic updates are supported with apt (Debian, Ubuntu) and dnf (RHEL family), not %s", c.Distro().Name)
+	}
+
+	switch args[0] {
+	case "enable":
+		settings := autoUpdates{
+			enabled:      true,
+			securityOnly: options["security-only"] == "true",
+			rebootTime:   options["reboot-time"],
+		}
+		if settings.rebootTime != "" && !rebootTimePattern.MatchString(settings.rebootTime) {
+			return fmt.Errorf("invalid reboot time '%s'; use HH:MM, e.g. 03:30", settings.rebootTime)
+		}
+		var err error
+		if pm == distro.PackageManagerAPT {
+			err = enableAptAutoUpdates(c, settings)
+		} else {
+			err = enableDnfAutoUpdates(c, settings)
+		}
+		if err != nil {
+			return err
+		}
+		c.Success("Automatic updates enabled")
+		settings.print()
+		return nil
+
+	case "disable":
+		var err error
+		if pm == distro.PackageManagerAPT {
+			err = disableAptAutoUpdates(c)
+		} else {
+			err = disableDnfAutoUpdates(c)
+		}
+		if err != nil {
+			return err
+		}
+		c.Success("Automatic updates disabled")
+		return nil
+
+	case "status":
+		var settings autoUpdates
+		var err error
+		if pm == distro.PackageManagerAPT {
+			settings, err = aptAutoUpdates(c)
+		} else {
+			settings, err = dnfAutoUpdates(c)
+		}
+		if err != nil {
+			return err
+		}
+		settings.print()
+		return nil
+
+	default:
+		return fmt.Errorf("unknown auto-updates action '%s'; use enable, disable or status", args[0])
+	}
+}
+
+// aptPeriodicConfig switches the daily package list refresh and
+// unattended-upgrades run on or off
+func aptPeriodicConfig(enabled bool) string {
+	value := "0"
+	if enabled {
+		value = "1"
+	}
+	return fmt.Sprintf("// Managed by vps-init\nAPT::Periodic::Update-Package-Lists \"%s\";\nAPT::Periodic::Unattended-Upgrade \"%s\";\n", value, value)
+}
+
+// aptUnattendedConfig replaces the distribution's origins with security
+// updates, plus regular updates unless securityOnly is set. Patterns for
+// both Ubuntu and Debian are listed; the ones for the other distribution
+// never match.
+func aptUnattendedConfig(settings autoUpdates) string {
+	origins := []string{
+		"origin=${distro_id},archive=${distro_codename}-security",
+		"origin=Debian,codename=${distro_codename}-security,label=Debian-Security",
+	}
+	if !settings.securityOnly {
+		origins = append(origins,
+			"origin=${distro_id},archive=${distro_codename}",
+			"origin=${distro_id},archive=${distro_codename}-updates",
+			"origin=Debian,codename=${distro_codename},label=Debian",
+			"origin=Debian,codename=${distro_codename}-updates",
+		)
+	}
+
+	var b strings.Builder
+	b.WriteString("// Managed by vps-init\n")
+	b.WriteString("#clear Unattended-Upgrade::Origins-Pattern;\n")
+	b.WriteString("Unattended-Upgrade::Origins-Pattern {\n")
+	for _, origin := range origins {
+		fmt.Fprintf(&b, "\t%q;\n", origin)
+	}
+	b.WriteString("};\n")
+	if settings.rebootTime != "" {
+		b.WriteString("Unattended-Upgrade::Automatic-Reboot \"true\";\n")
+		fmt.Fprintf(&b, "Unattended-Upgrade::Automatic-Reboot-Time %q;\n", settings.rebootTime)
+	} else {
+		b.WriteString("Unattended-Upgrade::Automatic-Reboot \"false\";\n")
+	}
+	return b.String()
+}
+
+func enableAptAutoUpdates(c *sdk.Context, settings autoUpdates) error {
+	if err := c.Install("unattended-upgrades"); err != nil {
+		return err
+	}
+	if err := c.WriteFile(aptPeriodicFile, aptPeriodicConfig(true), 0644); err != nil {
+		return err
+	}
+	if err := c.WriteFile(aptUnattendedFile, aptUnattendedConfig(settings), 0644); err != nil {
+		return err
+	}
+	// Without systemd, unattended-upgrades runs from cron.daily instead
+	if c.Distro().ServiceMgr == distro.ServiceManagerSystemd {
+		for _, timer := range []string{"apt-daily.timer", "apt-daily-upgrade.timer"} {
+			if err := c.EnableService(timer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func disableAptAutoUpdates(c *sdk.Context) error {
+	if err := c.WriteFile(aptPeriodicFile, aptPeriodicConfig(false), 0644); err != nil {
+		return err
+	}
+	return c.Exec("rm -f " + aptUnattendedFile + " " + aptUnattendedFile + sdk.BackupSuffix)
+}
+
+// aptAutoUpdates reads the settings apt actually uses, whoever wrote them
+func aptAutoUpdates(c *sdk.Context) (autoUpdates, error) {
+	var settings autoUpdates
+	if !c.IsInstalled("unattended-upgrades") {
+		return settings, nil
+	}
+	out, err := c.Query("apt-config dump")
+	if err != nil {
+		return settings, fmt.Errorf("failed to read apt configuration: %w", err)
+	}
+
+	config := parseAptConfig(out)
+	enabled := config["APT::Periodic::Unattended-Upgrade"]
+	settings.enabled = len(enabled) > 0 && enabled[0] != "0"
+	settings.securityOnly = true
+	for _, origin := range append(config["Unattended-Upgrade::Origins-Pattern"], config["Unattended-Upgrade::Allowed-Origins"]...) {
+		if !strings.Contains(strings.ToLower(origin), "security") {
+			settings.securityOnly = false
+		}
+	}
+	if reboot := config["Unattended-Upgrade::Automatic-Reboot"]; len(reboot) > 0 && reboot[0] == "true" {
+		settings.rebootTime = "now"
+		if t := config["Unattended-Upgrade::Automatic-Reboot-Time"]; len(t) > 0 {
+			settings.rebootTime = t[0]
+		}
+	}
+	return settings, nil
+}
+
+// parseAptConfig parses `apt-config dump` lines such as
+// `APT::Periodic::Unattended-Upgrade "1";` into values by name. List entries
+// are dumped as `Name:: "value";` and collected under Name.
+func parseAptConfig(out string) map[string][]string {
+	config := map[string][]string{}
+	for _, line := range strings.Split(out, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSuffix(name, "::")
+		value = strings.Trim(strings.TrimSuffix(value, ";"), `"`)
+		// Lists start with an empty `Name "";` entry
+		if value != "" {
+			config[name] = append(config[name], value)
+		}
+	}
+	return config
+}
+
+// dnfAutomaticConfig applies updates of the chosen type on every run and
+// reboots when they need it if a reboot window is set
+func dnfAutomaticConfig(settings autoUpdates) string {
+	upgradeType := "default"
+	if settings.securityOnly {
+		upgradeType = "security"
+	}
+	reboot := "never"
+	if settings.rebootTime != "" {
+		reboot = "when-needed"
+	}
+	return fmt.Sprintf(`# Managed by vps-init
+[commands]
+upgrade_type = %s
+download_updates = yes
+apply_updates = yes
+reboot = %s
+
+[emitters]
+emit_via = stdio
+
+[base]
+debuglevel = 1
+`, upgradeType, reboot)
+}
+
+// dnfTimerConfig moves the dnf-automatic run, and so any reboot it makes,
+// into the reboot window
+func dnfTimerConfig(rebootTime string) string {
+	return fmt.Sprintf("# Managed by vps-init\n[Timer]\nOnCalendar=\nOnCalendar=*-*-* %s:00\nRandomizedDelaySec=0\n", rebootTime)
+}
+
+func enableDnfAutoUpdates(c *sdk.Context, settings autoUpdates) error {
+	if err := c.Install("dnf-automatic"); err != nil {
+		return err
+	}
+	if err := c.WriteFile(dnfAutomaticFile, dnfAutomaticConfig(settings), 0644); err != nil {
+		return err
+	}
+	var err error
+	if settings.rebootTime != "" {
+		err = c.WriteFile(dnfTimerDropIn, dnfTimerConfig(settings.rebootTime), 0644)
+	} else {
+		err = c.Exec("rm -f " + dnfTimerDropIn)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.Exec("systemctl daemon-reload"); err != nil {
+		return err
+	}
+	return c.EnableService(dnfAutomaticTimer)
+}
+
+func disableDnfAutoUpdates(c *sdk.Context) error {
+	if err := c.DisableService(dnfAutomaticTimer); err != nil {
+		return err
+	}
+	if err := c.Exec("rm -f " + dnfTimerDropIn + " " + dnfTimerDropIn + sdk.BackupSuffix); err != nil {
+		return err
+	}
+	return c.Exec("systemctl daemon-reload")
+}
+
+func dnfAutoUpdates(c *sdk.Context) (autoUpdates, error) {
+	var settings autoUpdates
+	settings.enabled = c.ServiceEnabled(dnfAutomaticTimer)
+	if !settings.enabled {
+		return settings, nil
+	}
+
+	out, err := c.Query("cat " + dnfAutomaticFile)
+	if err != nil {
+		return settings, fmt.Errorf("failed to read %s: %w", dnfAutomaticFile, err)
+	}
+	config := parseINI(out)
+	settings.securityOnly = config["upgrade_type"] == "security"
+	if reboot := config["reboot"]; reboot != "" && reboot != "never" {
+		settings.rebootTime = "the scheduled run"
+		if out, err := c.Query("systemctl show -p TimersCalendar " + dnfAutomaticTimer); err == nil {
+			if m := timerCalendarPattern.FindStringSubmatch(out); m != nil {
+				settings.rebootTime = m[1]
+			}
+		}
+	}
+	return settings, nil
+}
+
+// parseINI returns the key = value settings of an ini file. Keys are
+// assumed unique across sections, which holds for dnf's automatic.conf.
+func parseINI(out string) map[string]string {
+	config := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			config[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return config
+}
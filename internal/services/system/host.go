@@ -0,0 +1,447 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+const (
+	zoneinfoDir = "/usr/share/zoneinfo/"
+	// hostsAddress is the loopback address Debian maps the hostname to
+	hostsAddress = "127.0.1.1"
+	// cloudHostnameFile stops cloud-init resetting the hostname on boot
+	cloudHostnameFile = "/etc/cloud/cloud.cfg.d/99-vps-init-hostname.cfg"
+	// alpineLocaleFile sorts before the locale.sh of musl-locales, which only
+	// sets LANG if it isn't set yet
+	alpineLocaleFile = "/etc/profile.d/00-vps-init-locale.sh"
+)
+
+var (
+	timezonePattern = regexp.MustCompile(`^[A-Za-z0-9_+-]+(/[A-Za-z0-9_+-]+)*$`)
+	hostnameLabel   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+	localePattern   = regexp.MustCompile(`^(C|POSIX|[a-z]{2,3}_[A-Z]{2})(\.[A-Za-z0-9-]+)?(@[a-z]+)?$`)
+	// timeSyncDaemons are the NTP clients left in charge if already running
+	timeSyncDaemons = []string{"chronyd", "chrony", "ntpd", "ntp", "ntpsec", "systemd-timesyncd"}
+)
+
+func (p *Plugin) handleTimezone(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+	if len(args) == 0 {
+		fmt.Printf("🕐 Timezone: %s\n", currentTimezone(c))
+		fmt.Printf("   Time:     %s\n", strings.TrimSpace(c.Run("date").Stdout))
+		return nil
+	}
+
+	zone := args[0]
+	if !timezonePattern.MatchString(zone) || strings.Contains(zone, "..") {
+		return fmt.Errorf("invalid timezone '%s'; use a name such as Europe/Berlin or UTC", zone)
+	}
+	// Minimal images, Alpine's in particular, ship without zoneinfo
+	if !c.Run("test -d " + zoneinfoDir).Success {
+		if err := c.Install("tzdata"); err != nil {
+			return err
+		}
+	}
+	if !c.Run("test -f " + sdk.Quote(zoneinfoDir+zone)).Success {
+		return fmt.Errorf("unknown timezone '%s'; see %s on the server", zone, zoneinfoDir)
+	}
+
+	c.Step("Setting the timezone to %s", zone)
+	if c.Distro().ServiceMgr == distro.ServiceManagerSystemd && c.Run("command -v timedatectl").Success {
+		if err := c.Exec("timedatectl set-timezone " + zone); err != nil {
+			return err
+		}
+	} else {
+		script := fmt.Sprintf("ln -sf %s%s /etc/localtime && echo %s > /etc/timezone", zoneinfoDir, zone, zone)
+		if err := p.checkSudoResult(c.Shell(script), c); err != nil {
+			return err
+		}
+	}
+	c.Success("Timezone set to %s", zone)
+	return nil
+}
+
+// currentTimezone reads the zone /etc/localtime links to, falling back to
+// /etc/timezone
+func currentTimezone(c *sdk.Context) string {
+	if link := strings.TrimSpace(c.Run("readlink /etc/localtime").Stdout); link != "" {
+		if _, zone, ok := strings.Cut(link, "zoneinfo/"); ok {
+			return zone
+		}
+	}
+	if zone := strings.TrimSpace(c.Run("cat /etc/timezone 2>/dev/null").Stdout); zone != "" {
+		return zone
+	}
+	return "UTC"
+}
+
+func (p *Plugin) handleNTP(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: ntp enable | ntp status")
+	}
+	c := sdk.New(ctx, conn, flags)
+
+	switch args[0] {
+	case "enable":
+		if err := enableTimeSync(c); err != nil {
+			return err
+		}
+		return printTimeSync(c)
+	case "status":
+		return printTimeSync(c)
+	default:
+		return fmt.Errorf("unknown ntp action '%s'; use enable or status", args[0])
+	}
+}
+
+// enableTimeSync turns on an NTP client: an installed one if there is one,
+// otherwise systemd-timesyncd on Debian and Arch and chrony elsewhere
+func enableTimeSync(c *sdk.Context) error {
+	for _, daemon := range timeSyncDaemons {
+		if c.ServiceActive(daemon) {
+			if !c.ServiceEnabled(daemon) {
+				if err := c.Service("enable", daemon); err != nil {
+					return err
+				}
+			}
+			c.Info("Time sync already runs with %s", daemon)
+			return nil
+		}
+	}
+
+	info := c.Distro()
+	systemd := info.ServiceMgr == distro.ServiceManagerSystemd
+	switch {
+	case systemd && (info.Family == distro.DistroFamilyDebian || info.Family == distro.DistroFamilyArch):
+		// systemd-timesyncd is a separate package from Debian 11 and Ubuntu
+		// 20.04 on
+		if !c.Run("test -e /lib/systemd/systemd-timesyncd || test -e /usr/lib/systemd/systemd-timesyncd").Success {
+			if err := c.Install("systemd-timesyncd"); err != nil {
+				return err
+			}
+		}
+		if err := c.Exec("timedatectl set-ntp true"); err != nil {
+			return err
+		}
+	default:
+		if err := c.Install("chrony"); err != nil {
+			return err
+		}
+		if err := c.EnableService("chronyd"); err != nil {
+			return err
+		}
+	}
+	c.Success("Time sync enabled")
+	return nil
+}
+
+// parseTimedatectl reads the "key: value" lines of timedatectl status
+func parseTimedatectl(out string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+func printTimeSync(c *sdk.Context) error {
+	fmt.Printf("🕐 Time sync on %s:\n", c.Conn.Host())
+
+	daemon := ""
+	for _, d := range timeSyncDaemons {
+		if c.ServiceActive(d) {
+			daemon = d
+			break
+		}
+	}
+	if daemon == "" {
+		fmt.Println("   Service:      none")
+	} else {
+		fmt.Printf("   Service:      %s\n", daemon)
+	}
+
+	synced := "unknown"
+	if result := c.Run("timedatectl status"); result.Success {
+		status := parseTimedatectl(result.Stdout)
+		// Older systemd says "NTP synchronized"
+		for _, key := range []string{"System clock synchronized", "NTP synchronized"} {
+			if value, ok := status[key]; ok {
+				synced = value
+			}
+		}
+	} else if result := c.Run("chronyc tracking"); result.Success {
+		synced = "no"
+		if parseTimedatectl(result.Stdout)["Leap status"] == "Normal" {
+			synced = "yes"
+		}
+	}
+	fmt.Printf("   Synchronized: %s\n", synced)
+	fmt.Printf("   Time:         %s\n", strings.TrimSpace(c.Run("date").Stdout))
+	return nil
+}
+
+func (p *Plugin) handleHostname(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+	current := strings.TrimSpace(c.Run("hostname").Stdout)
+	if len(args) == 0 {
+		fmt.Printf("🖥️  Hostname: %s\n", current)
+		return nil
+	}
+
+	name := strings.ToLower(args[0])
+	if !validHostname(name) {
+		return fmt.Errorf("invalid hostname '%s'; use letters, digits and hyphens, with dots between labels", args[0])
+	}
+
+	c.Step("Setting the hostname to %s", name)
+	if c.Distro().ServiceMgr == distro.ServiceManagerSystemd && c.Run("command -v hostnamectl").Success {
+		if err := c.Exec("hostnamectl set-hostname " + name); err != nil {
+			return err
+		}
+	} else {
+		script := fmt.Sprintf("echo %s > /etc/hostname && hostname %s", name, name)
+		if err := p.checkSudoResult(c.Shell(script), c); err != nil {
+			return err
+		}
+	}
+
+	if result := c.Run("cat /etc/hosts"); !result.Success {
+		c.Warn("Failed to read /etc/hosts, so it was not updated: %s", strings.TrimSpace(result.Stderr))
+	} else if err := c.WriteFile("/etc/hosts", updateHosts(result.Stdout, current, name), 0644); err != nil {
+		return err
+	}
+	if c.Run("test -d /etc/cloud/cloud.cfg.d").Success {
+		if err := c.WriteFile(cloudHostnameFile, "# Managed by vps-init\npreserve_hostname: true\n", 0644); err != nil {
+			return err
+		}
+	}
+	c.Success("Hostname set to %s", name)
+	return nil
+}
+
+// validHostname checks a hostname or fully qualified domain name
+func validHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !hostnameLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// loopbackName reports whether name is one of the names distributions give
+// the loopback addresses, which must stay in /etc/hosts even when they are
+// also the hostname, as on fresh RHEL (localhost.localdomain) or Alpine
+// (localhost) installs
+func loopbackName(name string) bool {
+	switch name {
+	case "localhost", "localhost.localdomain", "localhost4", "localhost4.localdomain4", "localhost6", "localhost6.localdomain6":
+		return true
+	}
+	return strings.HasPrefix(name, "ip6-")
+}
+
+// updateHosts maps the new hostname, and its short form if it has dots, to
+// 127.0.1.1. The old hostname is removed from the other loopback lines,
+// except for the loopback names themselves; lines left without names are
+// dropped.
+func updateHosts(hosts, old, name string) string {
+	names := []string{name}
+	if short, _, ok := strings.Cut(name, "."); ok {
+		names = append(names, short)
+	}
+	oldNames := map[string]bool{}
+	if old != "" {
+		oldNames[old] = true
+		short, _, _ := strings.Cut(old, ".")
+		oldNames[short] = true
+	}
+	for n := range oldNames {
+		if loopbackName(n) {
+			delete(oldNames, n)
+		}
+	}
+	for _, n := range names {
+		delete(oldNames, n)
+	}
+
+	entry := hostsAddress + "\t" + strings.Join(names, " ")
+	var lines []string
+	added := false
+	for _, line := range strings.Split(strings.TrimRight(hosts, "\n"), "\n") {
+		entryPart, comment, hasComment := strings.Cut(line, "#")
+		fields := strings.Fields(entryPart)
+		if len(fields) == 0 {
+			lines = append(lines, line)
+			continue
+		}
+		address := fields[0]
+		if address == hostsAddress {
+			if !added {
+				lines = append(lines, entry)
+				added = true
+			}
+			continue
+		}
+		if !strings.HasPrefix(address, "127.") && address != "::1" {
+			lines = append(lines, line)
+			continue
+		}
+
+		kept := fields[:1]
+		for _, f := range fields[1:] {
+			if !oldNames[f] {
+				kept = append(kept, f)
+			}
+		}
+		switch {
+		case len(kept) == len(fields):
+			lines = append(lines, line)
+		case len(kept) > 1:
+			updated := strings.Join(kept, "\t")
+			if hasComment {
+				updated += " #" + comment
+			}
+			lines = append(lines, updated)
+		}
+	}
+	if !added {
+		lines = append(lines, entry)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (p *Plugin) handleLocale(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+	if len(args) == 0 {
+		locale := currentLocale(c)
+		if locale == "" {
+			locale = "not set"
+		}
+		fmt.Printf("🌐 Locale: %s\n", locale)
+		return nil
+	}
+
+	locale := args[0]
+	if !localePattern.MatchString(locale) {
+		return fmt.Errorf("invalid locale '%s'; use a name such as en_US.UTF-8", locale)
+	}
+
+	info := c.Distro()
+	if info.Family != distro.DistroFamilyAlpine && !localeAvailable(c, locale) {
+		if err := p.generateLocale(c, info, locale); err != nil {
+			return err
+		}
+		if !localeAvailable(c, locale) {
+			return fmt.Errorf("locale %s is not available on %s", locale, info.Name)
+		}
+	}
+
+	c.Step("Setting the locale to %s", locale)
+	if err := p.setLocale(c, info, locale); err != nil {
+		return err
+	}
+	c.Success("Locale set to %s; new logins use it", locale)
+	return nil
+}
+
+// normalizeLocale compares locale names the way glibc does, so en_US.UTF-8
+// matches the en_US.utf8 of locale -a
+func normalizeLocale(name string) string {
+	lang, charset, ok := strings.Cut(name, ".")
+	if !ok {
+		return name
+	}
+	return lang + "." + strings.ToLower(strings.ReplaceAll(charset, "-", ""))
+}
+
+func localeAvailable(c *sdk.Context, locale string) bool {
+	want := normalizeLocale(locale)
+	for _, l := range strings.Fields(c.Run("locale -a").Stdout) {
+		if normalizeLocale(l) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// generateLocale installs or compiles a locale. Debian and Arch build
+// locales from /etc/locale.gen; Fedora and RHEL ship them as langpacks.
+func (p *Plugin) generateLocale(c *sdk.Context, info *distro.DistroInfo, locale string) error {
+	switch info.Family {
+	case distro.DistroFamilyDebian, distro.DistroFamilyArch:
+		if info.Family == distro.DistroFamilyDebian {
+			if err := c.Install("locales"); err != nil {
+				return err
+			}
+		}
+		if info.ID == "ubuntu" {
+			return c.Exec("locale-gen " + locale)
+		}
+		_, charset, ok := strings.Cut(locale, ".")
+		if !ok {
+			return fmt.Errorf("locale %s needs a charset, such as %s.UTF-8", locale, locale)
+		}
+		line := locale + " " + strings.SplitN(charset, "@", 2)[0]
+		pattern := strings.ReplaceAll(locale, ".", `\.`)
+		script := fmt.Sprintf(`if ! grep -q '^%[1]s ' /etc/locale.gen; then
+	if grep -q '^# *%[1]s ' /etc/locale.gen; then sed -i 's/^# *\(%[1]s \)/\1/' /etc/locale.gen; else echo '%[2]s' >> /etc/locale.gen; fi
+fi
+locale-gen`, pattern, line)
+		c.Step("Generating %s", locale)
+		return p.checkSudoResult(c.Shell(script), c)
+
+	case distro.DistroFamilyRedHat:
+		if info.PackageMgr != distro.PackageManagerDNF {
+			return nil
+		}
+		lang, _, _ := strings.Cut(locale, "_")
+		return c.Install("glibc-langpack-" + lang)
+
+	case distro.DistroFamilySUSE:
+		return c.Install("glibc-locale")
+	}
+	return nil
+}
+
+// setLocale makes a locale the default for new logins: localectl where it
+// works, then each family's own file
+func (p *Plugin) setLocale(c *sdk.Context, info *distro.DistroInfo, locale string) error {
+	if info.Family == distro.DistroFamilyAlpine {
+		// musl-locales adds translations; LANG works without it
+		if err := c.Install("musl-locales"); err != nil {
+			c.Warn("Failed to install musl-locales: %v", err)
+		}
+		return c.WriteFile(alpineLocaleFile, fmt.Sprintf("# Managed by vps-init\nexport LANG=%s\n", locale), 0644)
+	}
+	if c.Run("command -v localectl").Success && c.Sudo("localectl set-locale LANG="+locale).Success {
+		return nil
+	}
+	if info.Family == distro.DistroFamilyDebian {
+		return c.Exec("update-locale LANG=" + locale)
+	}
+	return c.WriteFile("/etc/locale.conf", fmt.Sprintf("LANG=%s\n", locale), 0644)
+}
+
+// currentLocale reads LANG from the files the locale is set in
+func currentLocale(c *sdk.Context) string {
+	out := c.Run(fmt.Sprintf("cat /etc/default/locale /etc/locale.conf %s 2>/dev/null", alpineLocaleFile)).Stdout
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimPrefix(strings.TrimSpace(line), "export ")
+		if value, ok := strings.CutPrefix(line, "LANG="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package system
+
+import "testing"
+
+func TestUpdateHosts(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts string
+		old   string
+		new   string
+		want  string
+	}{
+		{
+			name: "rhel default hostname",
+			hosts: `127.0.0.1   localhost localhost.localdomain localhost4 localhost4.localdomain4
+::1         localhost localhost.localdomain localhost6 localhost6.localdomain6
+`,
+			old: "localhost.localdomain",
+			new: "web1.example.com",
+			want: `127.0.0.1   localhost localhost.localdomain localhost4 localhost4.localdomain4
+::1         localhost localhost.localdomain localhost6 localhost6.localdomain6
+127.0.1.1	web1.example.com web1
+`,
+		},
+		{
+			name: "alpine default hostname",
+			hosts: `127.0.0.1	localhost localhost.localdomain
+::1		localhost localhost.localdomain
+`,
+			old: "localhost",
+			new: "web1",
+			want: `127.0.0.1	localhost localhost.localdomain
+::1		localhost localhost.localdomain
+127.0.1.1	web1
+`,
+		},
+		{
+			name: "debian with an existing entry",
+			hosts: `127.0.0.1	localhost
+127.0.1.1	debian.example.com	debian
+
+# The following lines are desirable for IPv6 capable hosts
+::1     localhost ip6-localhost ip6-loopback
+ff02::1 ip6-allnodes
+ff02::2 ip6-allrouters
+`,
+			old: "debian",
+			new: "web1",
+			want: `127.0.0.1	localhost
+127.0.1.1	web1
+
+# The following lines are desirable for IPv6 capable hosts
+::1     localhost ip6-localhost ip6-loopback
+ff02::1 ip6-allnodes
+ff02::2 ip6-allrouters
+`,
+		},
+		{
+			name: "old hostname on the localhost line",
+			hosts: `127.0.0.1 localhost old-name # added by hand
+::1 localhost old-name
+`,
+			old: "old-name",
+			new: "web1.example.com",
+			want: `127.0.0.1	localhost # added by hand
+::1	localhost
+127.0.1.1	web1.example.com web1
+`,
+		},
+		{
+			name: "old hostname alone on a loopback line",
+			hosts: `127.0.0.1 localhost
+127.0.0.2 old-name.example.com old-name
+`,
+			old: "old-name.example.com",
+			new: "web1",
+			want: `127.0.0.1 localhost
+127.0.1.1	web1
+`,
+		},
+		{
+			name: "other addresses are kept",
+			hosts: `127.0.0.1 localhost
+10.0.0.5 old-name db
+`,
+			old: "old-name",
+			new: "web1",
+			want: `127.0.0.1 localhost
+10.0.0.5 old-name db
+127.0.1.1	web1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := updateHosts(tt.hosts, tt.old, tt.new); got != tt.want {
+				t.Errorf("updateHosts() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,9 +7,9 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
 	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 // Plugin implements the system upgrade plugin
@@ -52,10 +52,8 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
-		Tags:              []string{"TODO", "add", "relevant", "tags"},
+		MinVPSInitVersion: "0.1.0",
+		Tags:              []string{"system", "packages", "updates", "swap"},
 	}
 }
 
@@ -67,7 +65,7 @@ func (p *Plugin) GetMetadata() plugin.PluginMetadata {
 		Author:      p.Author(),
 		License:     "MIT",
 		Repository:  "github.com/wasilwamark/vps-init-plugins/" + p.Name(),
-		Tags:        []string{"TODO", "add", "tags"},
+		Tags:        []string{"system", "packages", "updates", "swap"},
 		Validated:   true,
 		TrustLevel:  "official",
 		BuildInfo: plugin.BuildInfo{
@@ -90,7 +88,7 @@ func (p *Plugin) GetCommands() []plugin.Command {
 		},
 		{
 			Name:        "upgrade",
-			Description: "Upgrade installed packages (apt upgrade)",
+			Description: "Upgrade installed packages (apt upgrade); --preview [--json] [--changelog] lists them without upgrading",
 			Handler:     p.handleUpgrade,
 		},
 		{
@@ -118,6 +116,51 @@ func (p *Plugin) GetCommands() []plugin.Command {
 			Description: "Uninstall packages (apt remove)",
 			Handler:     p.handleUninstall,
 		},
+		{
+			Name:        "repo",
+			Description: "Manage third-party package repositories [add|list|remove]",
+			Handler:     p.handleRepo,
+		},
+		{
+			Name:        "auto-updates",
+			Description: "Configure unattended upgrades [enable|disable|status] [--security-only] [--reboot-time HH:MM]",
+			Handler:     p.handleAutoUpdates,
+		},
+		{
+			Name:        "reboot-required",
+			Description: "Check whether updates are waiting for a reboot",
+			Handler:     p.handleRebootRequired,
+		},
+		{
+			Name:        "reboot",
+			Description: "Reboot the server [--wait] [--timeout 5m]",
+			Handler:     p.handleReboot,
+		},
+		{
+			Name:        "swap",
+			Description: "Manage the swapfile [create|remove|status] [--size 2G] [--swappiness 10]",
+			Handler:     p.handleSwap,
+		},
+		{
+			Name:        "timezone",
+			Description: "Show or set the timezone [Area/City]",
+			Handler:     p.handleTimezone,
+		},
+		{
+			Name:        "ntp",
+			Description: "Manage time sync [enable|status]",
+			Handler:     p.handleNTP,
+		},
+		{
+			Name:        "hostname",
+			Description: "Show or set the hostname, updating /etc/hosts [name]",
+			Handler:     p.handleHostname,
+		},
+		{
+			Name:        "locale",
+			Description: "Show or set the default locale [en_US.UTF-8]",
+			Handler:     p.handleLocale,
+		},
 	}
 }
 
@@ -138,13 +181,13 @@ func (p *Plugin) Stop(ctx context.Context) error {
 // Command Handlers
 
 // Helper for sudo errors
-func (p *Plugin) checkSudoResult(result plugin.Result, flags map[string]interface{}) error {
+func (p *Plugin) checkSudoResult(result plugin.Result, c *sdk.Context) error {
 	if result.Success {
 		return nil
 	}
 
 	// Check if sudo password was provided
-	sudoPass, _ := flags["sudo-password"].(string)
+	sudoPass := c.SudoPassword()
 
 	errMsg := fmt.Sprintf("failed to execute command: %s", result.Stderr)
 
@@ -160,34 +203,24 @@ func (p *Plugin) checkSudoResult(result plugin.Result, flags map[string]interfac
 		}
 	}
 
-	return fmt.Errorf(errMsg)
-}
-
-// Helper to get package manager for connection
-func (p *Plugin) getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-
-	pkgMgr := pkgmgr.GetPackageManager(distroInfo)
-	fmt.Printf("ℹ️  Detected Distribution: %s %s\n", distroInfo.Name, distroInfo.Version)
-	fmt.Printf("📦 Using Package Manager: %s\n", distroInfo.PackageMgr)
-
-	return pkgMgr
+	return fmt.Errorf("%s", errMsg)
 }
 
-func (p *Plugin) logCommand(cmd string) {
+// Helper to run a package manager command with sudo error hints
+func (p *Plugin) runPackageCommand(c *sdk.Context, cmd string, err error) error {
+	if err != nil {
+		return err
+	}
 	fmt.Printf("⚡ Executing: %s\n", cmd)
+	return p.checkSudoResult(c.Sudo(cmd), c)
 }
 
 func (p *Plugin) handleUpdate(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🔄 Updating package lists...")
 
-	sudoPass, _ := flags["sudo-password"].(string)
-	pkgMgr := p.getPackageManager(conn)
-	cmd, _ := pkgMgr.Update()
-	p.logCommand(cmd)
-	result := conn.RunSudo(cmd, sudoPass)
-
-	if err := p.checkSudoResult(result, flags); err != nil {
+	c := sdk.New(ctx, conn, flags)
+	cmd, err := c.PackageManager().Update()
+	if err := p.runPackageCommand(c, cmd, err); err != nil {
 		return err
 	}
 
@@ -196,14 +229,15 @@ func (p *Plugin) handleUpdate(ctx context.Context, conn plugin.Connection, args
 }
 
 func (p *Plugin) handleUpgrade(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	fmt.Println("⬆️  Upgrading packages...")
+	_, options := sdk.ParseOptions(args, "preview", "json", "changelog")
+	c := sdk.New(ctx, conn, flags)
+	if options["preview"] == "true" {
+		return p.previewUpgrade(c, options["json"] == "true", options["changelog"] == "true")
+	}
 
-	sudoPass, _ := flags["sudo-password"].(string)
-	pkgMgr := p.getPackageManager(conn)
-	cmd, _ := pkgMgr.Upgrade()
-	p.logCommand(cmd)
-	result := conn.RunSudo(cmd, sudoPass)
-	if err := p.checkSudoResult(result, flags); err != nil {
+	fmt.Println("⬆️  Upgrading packages...")
+	cmd, err := c.PackageManager().Upgrade()
+	if err := p.runPackageCommand(c, cmd, err); err != nil {
 		return err
 	}
 
@@ -214,12 +248,9 @@ func (p *Plugin) handleUpgrade(ctx context.Context, conn plugin.Connection, args
 func (p *Plugin) handleFullUpgrade(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🚀 Performing full system upgrade...")
 
-	sudoPass, _ := flags["sudo-password"].(string)
-	pkgMgr := p.getPackageManager(conn)
-	cmd, _ := pkgMgr.DistUpgrade()
-	p.logCommand(cmd)
-	result := conn.RunSudo(cmd, sudoPass)
-	if err := p.checkSudoResult(result, flags); err != nil {
+	c := sdk.New(ctx, conn, flags)
+	cmd, err := c.PackageManager().DistUpgrade()
+	if err := p.runPackageCommand(c, cmd, err); err != nil {
 		return err
 	}
 
@@ -230,12 +261,9 @@ func (p *Plugin) handleFullUpgrade(ctx context.Context, conn plugin.Connection,
 func (p *Plugin) handleAutoremove(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🧹 Removing unused packages...")
 
-	sudoPass, _ := flags["sudo-password"].(string)
-	pkgMgr := p.getPackageManager(conn)
-	cmd, _ := pkgMgr.Autoremove()
-	p.logCommand(cmd)
-	result := conn.RunSudo(cmd, sudoPass)
-	if err := p.checkSudoResult(result, flags); err != nil {
+	c := sdk.New(ctx, conn, flags)
+	cmd, err := c.PackageManager().Autoremove()
+	if err := p.runPackageCommand(c, cmd, err); err != nil {
 		return err
 	}
 
@@ -256,16 +284,9 @@ func (p *Plugin) handleInstall(ctx context.Context, conn plugin.Connection, args
 	packages := strings.Join(args, " ")
 	fmt.Printf("📦 Installing: %s...\n", packages)
 
-	sudoPass, _ := flags["sudo-password"].(string)
-	pkgMgr := p.getPackageManager(conn)
-	cmd, err := pkgMgr.Install(args...)
-	if err != nil {
-		return err
-	}
-	p.logCommand(cmd)
-	result := conn.RunSudo(cmd, sudoPass)
-
-	if err := p.checkSudoResult(result, flags); err != nil {
+	c := sdk.New(ctx, conn, flags)
+	cmd, err := c.PackageManager().Install(args...)
+	if err := p.runPackageCommand(c, cmd, err); err != nil {
 		return err
 	}
 
@@ -281,19 +302,71 @@ func (p *Plugin) handleUninstall(ctx context.Context, conn plugin.Connection, ar
 	packages := strings.Join(args, " ")
 	fmt.Printf("🗑️  Uninstalling: %s...\n", packages)
 
-	sudoPass, _ := flags["sudo-password"].(string)
-	pkgMgr := p.getPackageManager(conn)
-	cmd, err := pkgMgr.Remove(args...)
-	if err != nil {
-		return err
-	}
-	p.logCommand(cmd)
-	result := conn.RunSudo(cmd, sudoPass)
-
-	if err := p.checkSudoResult(result, flags); err != nil {
+	c := sdk.New(ctx, conn, flags)
+	cmd, err := c.PackageManager().Remove(args...)
+	if err := p.runPackageCommand(c, cmd, err); err != nil {
 		return err
 	}
 
 	fmt.Println("✅ Uninstallation complete")
 	return nil
 }
+
+func (p *Plugin) handleRepo(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	// Split off --key, --fingerprint, --suite and --components
+	args, options := sdk.ParseOptions(args)
+	if len(args) < 1 {
+		return fmt.Errorf("usage: repo add <name> <url> --key <url> --fingerprint <fingerprint> [--suite <suite>] [--components <a,b>] | repo list | repo remove <name>")
+	}
+
+	c := sdk.New(ctx, conn, flags)
+	switch args[0] {
+	case "list":
+		repos, err := c.Repositories()
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			fmt.Println("No third-party repositories configured")
+			return nil
+		}
+		fmt.Println("📚 Package repositories:")
+		for _, repo := range repos {
+			fmt.Printf("  %-20s %s\n", repo.Name, strings.Join(append([]string{repo.URL, repo.Suite}, repo.Components...), " "))
+		}
+		return nil
+
+	case "add":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: repo add <name> <url> --key <url> --fingerprint <fingerprint>")
+		}
+		repo := pkgmgr.Repository{
+			Name:        args[1],
+			URL:         args[2],
+			Suite:       options["suite"],
+			KeyURL:      options["key"],
+			Fingerprint: options["fingerprint"],
+		}
+		if components := options["components"]; components != "" {
+			repo.Components = strings.Split(components, ",")
+		}
+		if err := c.AddRepository(repo); err != nil {
+			return err
+		}
+		c.Success("Repository %s added", repo.Name)
+		return c.UpdatePackages()
+
+	case "remove":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: repo remove <name>")
+		}
+		if err := c.RemoveRepository(args[1]); err != nil {
+			return err
+		}
+		c.Success("Repository %s removed", args[1])
+		return nil
+
+	default:
+		return fmt.Errorf("unknown repo action '%s'; use add, list or remove", args[0])
+	}
+}
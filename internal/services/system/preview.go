@@ -0,0 +1,224 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/internal/pkgmgr"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+var (
+	// rebootPackages only take effect after a reboot: kernels, the C
+	// library, D-Bus, microcode and firmware
+	rebootPackages = regexp.MustCompile(`^(linux-(image|modules)-.+|linux(-(generic|virtual|lts|virt|zen|hardened))?|kernel(-core|-default|-modules.*)?|libc6|glibc|musl|dbus|dbus-broker|.+-microcode|microcode_ctl|linux-firmware)$`)
+	// serviceFiles are the paths that make a package own a service
+	serviceFiles = regexp.MustCompile(`^(/usr)?/lib/systemd/system/([^/@]+)\.service$|^/etc/init\.d/([^/]+)$`)
+	// changelogHeader starts a Debian changelog entry: "pkg (version) suite; urgency=..."
+	changelogHeader = regexp.MustCompile(`^\S+ \(([^)]+)\) `)
+)
+
+// upgradePreview is what `system upgrade` would change. It has no timestamps,
+// so previews of servers with the same updates compare equal.
+type upgradePreview struct {
+	Host           string           `json:"host"`
+	Distro         string           `json:"distro"`
+	Packages       []previewPackage `json:"packages"`
+	RebootRequired bool             `json:"reboot_required"`
+	Restarts       []string         `json:"restarts"`
+}
+
+type previewPackage struct {
+	pkgmgr.Upgrade
+	// Reboot is set for kernel, libc and similar updates
+	Reboot bool `json:"reboot,omitempty"`
+	// Services are the running services the upgrade restarts
+	Services []string `json:"services,omitempty"`
+	// Held packages are listed but not upgraded
+	Held      bool   `json:"held,omitempty"`
+	Changelog string `json:"changelog,omitempty"`
+}
+
+// previewUpgrade refreshes the package lists and describes the pending
+// upgrades. Nothing but the JSON is printed in JSON mode, so progress goes
+// through the package manager directly rather than the sdk helpers.
+func (p *Plugin) previewUpgrade(c *sdk.Context, asJSON, changelog bool) error {
+	info := c.Distro()
+	pm := pkgmgr.GetPackageManager(info)
+
+	updateCmd, err := pm.Update()
+	if err != nil {
+		return err
+	}
+	if !asJSON {
+		fmt.Println("🔄 Refreshing package lists...")
+	}
+	if err := p.checkSudoResult(c.Sudo(updateCmd), c); err != nil {
+		return err
+	}
+
+	upgrades, err := pm.ListUpgradable(c.Query)
+	if err != nil {
+		return fmt.Errorf("failed to list upgradable packages: %w", err)
+	}
+	sort.Slice(upgrades, func(i, j int) bool { return upgrades[i].Name < upgrades[j].Name })
+
+	names := make([]string, len(upgrades))
+	for i, u := range upgrades {
+		names[i] = u.Name
+	}
+	held, _ := pm.Held(c.Query)
+	files, err := pm.Files(c.Query, names...)
+	if err != nil {
+		return fmt.Errorf("failed to list package files: %w", err)
+	}
+
+	preview := upgradePreview{
+		Host:     c.Conn.Host(),
+		Distro:   strings.TrimSpace(info.Name + " " + info.Version),
+		Packages: []previewPackage{},
+		Restarts: []string{},
+	}
+	active := map[string]bool{}
+	for _, u := range upgrades {
+		pkg := previewPackage{Upgrade: u, Held: slices.Contains(held, u.Name)}
+		if !pkg.Held {
+			pkg.Reboot = rebootPackages.MatchString(u.Name)
+			for _, service := range packageServices(files[u.Name]) {
+				running, checked := active[service]
+				if !checked {
+					running = c.ServiceActive(service)
+					active[service] = running
+				}
+				if running {
+					pkg.Services = append(pkg.Services, service)
+				}
+			}
+			if changelog {
+				pkg.Changelog = packageChangelog(c, info, u)
+			}
+		}
+		preview.RebootRequired = preview.RebootRequired || pkg.Reboot
+		preview.Packages = append(preview.Packages, pkg)
+	}
+	for service, running := range active {
+		if running {
+			preview.Restarts = append(preview.Restarts, service)
+		}
+	}
+	sort.Strings(preview.Restarts)
+
+	if asJSON {
+		data, err := json.MarshalIndent(preview, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	preview.print()
+	return nil
+}
+
+func (u upgradePreview) print() {
+	if len(u.Packages) == 0 {
+		fmt.Printf("✅ %s is up to date\n", u.Host)
+		return
+	}
+
+	fmt.Printf("📋 %d upgradable packages on %s (%s):\n\n", len(u.Packages), u.Host, u.Distro)
+	nameWidth, currentWidth, availableWidth := len("PACKAGE"), len("CURRENT"), len("AVAILABLE")
+	for _, pkg := range u.Packages {
+		nameWidth = max(nameWidth, len(pkg.Name))
+		currentWidth = max(currentWidth, len(pkg.Current))
+		availableWidth = max(availableWidth, len(pkg.Available))
+	}
+	row := fmt.Sprintf("  %%-%ds  %%-%ds  %%-%ds  %%s\n", nameWidth, currentWidth, availableWidth)
+	fmt.Printf(row, "PACKAGE", "CURRENT", "AVAILABLE", "NOTES")
+
+	var rebootFor []string
+	for _, pkg := range u.Packages {
+		var notes []string
+		if pkg.Held {
+			notes = append(notes, "held")
+		}
+		if pkg.Reboot {
+			notes = append(notes, "reboot")
+			rebootFor = append(rebootFor, pkg.Name)
+		}
+		if len(pkg.Services) > 0 {
+			notes = append(notes, "restarts "+strings.Join(pkg.Services, ", "))
+		}
+		fmt.Printf(row, pkg.Name, pkg.Current, pkg.Available, strings.Join(notes, "; "))
+		if pkg.Changelog != "" {
+			fmt.Printf("      %s\n", strings.ReplaceAll(strings.TrimRight(pkg.Changelog, "\n"), "\n", "\n      "))
+		}
+	}
+
+	fmt.Println()
+	if u.RebootRequired {
+		fmt.Printf("🔁 Reboot required after upgrading: %s\n", strings.Join(rebootFor, ", "))
+	}
+	if len(u.Restarts) > 0 {
+		fmt.Printf("🔄 Services restarted: %s\n", strings.Join(u.Restarts, ", "))
+	}
+	fmt.Println("Run 'vps-init <target> system upgrade' to apply.")
+}
+
+// packageServices names the services among a package's files
+func packageServices(files []string) []string {
+	var services []string
+	for _, file := range files {
+		m := serviceFiles.FindStringSubmatch(file)
+		if m == nil {
+			continue
+		}
+		name := m[2]
+		if name == "" {
+			name = path.Base(m[3])
+		}
+		if !slices.Contains(services, name) {
+			services = append(services, name)
+		}
+	}
+	return services
+}
+
+// packageChangelog returns the changelog entries newer than the installed
+// version, or "" where the package manager can't show them
+func packageChangelog(c *sdk.Context, info *distro.DistroInfo, u pkgmgr.Upgrade) string {
+	switch info.PackageMgr {
+	case distro.PackageManagerAPT:
+		out, err := c.Query(fmt.Sprintf("apt-get changelog -qq %s 2>/dev/null | head -n 200", u.Name))
+		if err != nil {
+			return ""
+		}
+		return changelogSince(out, u.Current)
+	case distro.PackageManagerDNF:
+		out, err := c.Query(fmt.Sprintf("dnf -q changelog --upgrades %s 2>/dev/null", u.Name))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(out)
+	default:
+		return ""
+	}
+}
+
+// changelogSince cuts a Debian changelog at the entry for the current version
+func changelogSince(changelog, current string) string {
+	var lines []string
+	for _, line := range strings.Split(changelog, "\n") {
+		if m := changelogHeader.FindStringSubmatch(line); m != nil && current != "" && m[1] == current {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
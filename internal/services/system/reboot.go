@@ -0,0 +1,148 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+const (
+	// defaultRebootTimeout is how long reboot --wait waits for SSH to return
+	defaultRebootTimeout = 5 * time.Minute
+	rebootPollInterval   = 5 * time.Second
+	bootIDCommand        = "cat /proc/sys/kernel/random/boot_id"
+)
+
+// rebootRequired reports whether installed updates wait for a reboot, with
+// the reason the distribution gives
+func rebootRequired(c *sdk.Context) (bool, string, error) {
+	switch c.Distro().PackageMgr {
+	case distro.PackageManagerAPT:
+		if !c.Run("test -f /var/run/reboot-required").Success {
+			return false, "", nil
+		}
+		pkgs := strings.Fields(c.Run("cat /var/run/reboot-required.pkgs 2>/dev/null").Stdout)
+		if len(pkgs) == 0 {
+			return true, "", nil
+		}
+		return true, "updated packages: " + strings.Join(pkgs, ", "), nil
+
+	case distro.PackageManagerDNF, distro.PackageManagerYUM:
+		// needs-restarting -r exits 1 when a reboot is required
+		cmd := "needs-restarting -r"
+		if !c.Run("command -v needs-restarting").Success {
+			// Without dnf-plugins-core the subcommand is missing, and dnf
+			// exits 1 for that too
+			pm := string(c.Distro().PackageMgr)
+			if !c.Run(pm + " needs-restarting --help").Success {
+				return false, "", fmt.Errorf("needs-restarting is not available; install it with 'system install dnf-plugins-core' (yum-utils on yum systems)")
+			}
+			cmd = pm + " needs-restarting -r"
+		}
+		result := c.Run(cmd)
+		if strings.Contains(result.Stderr, "No such command") || strings.Contains(result.Stderr, "Unknown argument") {
+			return false, "", fmt.Errorf("needs-restarting failed: %s", strings.TrimSpace(result.Stderr))
+		}
+		switch result.ExitCode {
+		case 0:
+			return false, "", nil
+		case 1:
+			return true, strings.TrimSpace(result.Stdout), nil
+		default:
+			return false, "", fmt.Errorf("needs-restarting failed: %s", strings.TrimSpace(result.Stderr))
+		}
+
+	case distro.PackageManagerZypper:
+		// zypper exits 102 when a reboot is required
+		result := c.Sudo("zypper needs-rebooting")
+		switch result.ExitCode {
+		case 0:
+			return false, "", nil
+		case 102:
+			return true, strings.TrimSpace(result.Stdout), nil
+		default:
+			return false, "", fmt.Errorf("zypper needs-rebooting failed: %s", strings.TrimSpace(result.Stderr))
+		}
+
+	default:
+		// Elsewhere a kernel upgrade removes the running kernel's modules
+		kernel := strings.TrimSpace(c.Run("uname -r").Stdout)
+		if kernel == "" || c.Run("test -d /lib/modules/"+kernel).Success {
+			return false, "", nil
+		}
+		return true, fmt.Sprintf("running kernel %s is no longer installed", kernel), nil
+	}
+}
+
+func (p *Plugin) handleRebootRequired(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+	required, reason, err := rebootRequired(c)
+	if err != nil {
+		return err
+	}
+	if !required {
+		c.Success("No reboot required")
+		return nil
+	}
+
+	fmt.Println("🔁 Reboot required")
+	if reason != "" {
+		fmt.Printf("   %s\n", strings.ReplaceAll(reason, "\n", "\n   "))
+	}
+	fmt.Println("Run 'vps-init <target> system reboot --wait' to reboot now.")
+	return nil
+}
+
+func (p *Plugin) handleReboot(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	_, options := sdk.ParseOptions(args, "wait")
+	timeout := defaultRebootTimeout
+	if value := options["timeout"]; value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout '%s'; use a duration such as 5m", value)
+		}
+	}
+
+	c := sdk.New(ctx, conn, flags)
+	bootID := strings.TrimSpace(c.Run(bootIDCommand).Stdout)
+
+	// Reboot in the background, so the SSH command returns before the
+	// connection drops
+	c.Step("Rebooting %s", conn.Host())
+	if err := p.checkSudoResult(c.Shell("nohup sh -c 'sleep 2; reboot' >/dev/null 2>&1 &"), c); err != nil {
+		return err
+	}
+	if options["wait"] != "true" {
+		c.Success("Reboot started")
+		return nil
+	}
+
+	fmt.Printf("⏳ Waiting up to %s for %s to come back...\n", timeout, conn.Host())
+	start := time.Now()
+	down := false
+	for time.Since(start) < timeout {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rebootPollInterval):
+		}
+
+		// A new boot ID proves the reboot happened; without one, wait for
+		// the server to go away first
+		result := c.Run(bootIDCommand)
+		if !result.Success {
+			down = true
+			continue
+		}
+		if id := strings.TrimSpace(result.Stdout); (bootID != "" && id != bootID) || (bootID == "" && down) {
+			c.Success("%s is back after %s", conn.Host(), time.Since(start).Round(time.Second))
+			return nil
+		}
+	}
+	return fmt.Errorf("%s did not come back within %s", conn.Host(), timeout)
+}
@@ -0,0 +1,206 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+const (
+	swapFile = "/swapfile"
+	// swappinessFile keeps the swappiness across reboots
+	swappinessFile = "/etc/sysctl.d/99-vps-init-swap.conf"
+	// swapDiskReserve is the space, in MiB, left free on the disk after the
+	// swapfile is created
+	swapDiskReserve = 512
+)
+
+var swapSizePattern = regexp.MustCompile(`^(\d+)([MmGg])(i?[Bb])?$`)
+
+// swapArea is a line of /proc/swaps. Sizes are in KiB.
+type swapArea struct {
+	name string
+	kind string
+	size int
+	used int
+}
+
+// parseSwapSize reads a size such as 512M or 2G as MiB
+func parseSwapSize(value string) (int, error) {
+	m := swapSizePattern.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("invalid size '%s'; use megabytes or gigabytes, e.g. 512M or 2G", value)
+	}
+	size, err := strconv.Atoi(m[1])
+	if err != nil || size == 0 {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+	if strings.EqualFold(m[2], "G") {
+		size *= 1024
+	}
+	return size, nil
+}
+
+// parseProcSwaps reads /proc/swaps, skipping its header
+func parseProcSwaps(out string) []swapArea {
+	var areas []swapArea
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] == "Filename" {
+			continue
+		}
+		size, _ := strconv.Atoi(fields[2])
+		used, _ := strconv.Atoi(fields[3])
+		areas = append(areas, swapArea{name: fields[0], kind: fields[1], size: size, used: used})
+	}
+	return areas
+}
+
+// formatKiB prints a size in KiB as megabytes or gigabytes
+func formatKiB(kib int) string {
+	if kib >= 1024*1024 {
+		return fmt.Sprintf("%.1fG", float64(kib)/(1024*1024))
+	}
+	return fmt.Sprintf("%dM", kib/1024)
+}
+
+func (p *Plugin) handleSwap(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	args, options := sdk.ParseOptions(args)
+	if len(args) < 1 {
+		return fmt.Errorf("usage: swap create --size 2G [--swappiness 10] | swap remove | swap status")
+	}
+	c := sdk.New(ctx, conn, flags)
+
+	swappiness := -1
+	if value := options["swappiness"]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > 100 {
+			return fmt.Errorf("invalid swappiness '%s'; use 0-100", value)
+		}
+		swappiness = n
+	}
+
+	switch args[0] {
+	case "create":
+		if options["size"] == "" {
+			return fmt.Errorf("usage: swap create --size 2G [--swappiness 10]")
+		}
+		size, err := parseSwapSize(options["size"])
+		if err != nil {
+			return err
+		}
+		if err := createSwap(c, size); err != nil {
+			return err
+		}
+		if swappiness >= 0 {
+			if err := setSwappiness(c, swappiness); err != nil {
+				return err
+			}
+		}
+		return printSwap(c)
+
+	case "remove":
+		return removeSwap(c)
+
+	case "status":
+		return printSwap(c)
+
+	default:
+		return fmt.Errorf("unknown swap action '%s'; use create, remove or status", args[0])
+	}
+}
+
+// createSwap creates, enables and persists a swapfile of size MiB. An
+// existing swapfile of the same size is kept.
+func createSwap(c *sdk.Context, size int) error {
+	if c.Run("test -e " + swapFile).Success {
+		current, _ := strconv.Atoi(strings.TrimSpace(c.Run("stat -c %s " + swapFile).Stdout))
+		if current == size*1024*1024 {
+			c.Info("%s already exists with that size", swapFile)
+			return nil
+		}
+		return fmt.Errorf("%s already exists with a different size; run 'system swap remove' first", swapFile)
+	}
+
+	available, err := strconv.Atoi(strings.TrimSpace(c.Run("df -Pm / | awk 'NR==2 {print $4}'").Stdout))
+	if err == nil && available < size+swapDiskReserve {
+		return fmt.Errorf("not enough disk space for a %dM swapfile: %dM free", size, available)
+	}
+
+	// dd rather than fallocate, as swapon rejects preallocated files on
+	// some filesystems. Copy-on-write is turned off first for btrfs.
+	c.Step("Creating a %dM swapfile at %s", size, swapFile)
+	script := fmt.Sprintf(`set -e
+umask 077
+touch %[1]s
+chattr +C %[1]s 2>/dev/null || true
+dd if=/dev/zero of=%[1]s bs=1M count=%[2]d 2>/dev/null
+chmod 600 %[1]s
+mkswap %[1]s >/dev/null
+swapon %[1]s`, swapFile, size)
+	if result := c.Shell(script); !result.Success {
+		c.Shell("rm -f " + swapFile)
+		return fmt.Errorf("failed to create %s: %s (containers such as OpenVZ and LXC can't use swap)", swapFile, strings.TrimSpace(result.Stderr))
+	}
+
+	fstab := fmt.Sprintf(`grep -q '^%[1]s[[:space:]]' /etc/fstab || echo '%[1]s none swap defaults 0 0' >> /etc/fstab`, swapFile)
+	if result := c.Shell(fstab); !result.Success {
+		return fmt.Errorf("failed to add %s to /etc/fstab: %s", swapFile, strings.TrimSpace(result.Stderr))
+	}
+	// OpenRC only enables fstab swap with the swap service
+	if c.Distro().ServiceMgr == distro.ServiceManagerOpenRC {
+		if err := c.Exec("rc-update add swap boot"); err != nil {
+			return err
+		}
+	}
+	c.Success("Swap enabled")
+	return nil
+}
+
+// removeSwap disables and deletes the swapfile
+func removeSwap(c *sdk.Context) error {
+	if !c.Run("test -e " + swapFile).Success {
+		c.Info("No swapfile at %s", swapFile)
+		return nil
+	}
+
+	c.Step("Removing %s", swapFile)
+	script := fmt.Sprintf(`set -e
+if grep -q '^%[1]s ' /proc/swaps; then swapoff %[1]s; fi
+sed -i '\#^%[1]s[[:space:]]#d' /etc/fstab
+rm -f %[1]s %[2]s`, swapFile, swappinessFile)
+	if result := c.Shell(script); !result.Success {
+		return fmt.Errorf("failed to remove %s: %s (swapoff needs enough free memory for what is swapped out)", swapFile, strings.TrimSpace(result.Stderr))
+	}
+	c.Success("Swap removed")
+	return nil
+}
+
+// setSwappiness applies the swappiness now and on boot
+func setSwappiness(c *sdk.Context, value int) error {
+	if err := c.WriteFile(swappinessFile, fmt.Sprintf("# Managed by vps-init\nvm.swappiness = %d\n", value), 0644); err != nil {
+		return err
+	}
+	return c.Exec(fmt.Sprintf("sysctl -w vm.swappiness=%d", value))
+}
+
+func printSwap(c *sdk.Context) error {
+	areas := parseProcSwaps(c.Run("cat /proc/swaps").Stdout)
+	swappiness := strings.TrimSpace(c.Run("cat /proc/sys/vm/swappiness").Stdout)
+
+	fmt.Printf("💾 Swap on %s:\n", c.Conn.Host())
+	if len(areas) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, a := range areas {
+		fmt.Printf("  %-24s %-10s %6s  used %s\n", a.name, a.kind, formatKiB(a.size), formatKiB(a.used))
+	}
+	fmt.Printf("  Swappiness: %s\n", swappiness)
+	return nil
+}
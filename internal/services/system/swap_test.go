@@ -0,0 +1,58 @@
+package system
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSwapSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "512M", want: 512},
+		{value: "512m", want: 512},
+		{value: "512MB", want: 512},
+		{value: "512MiB", want: 512},
+		{value: "2G", want: 2048},
+		{value: "2g", want: 2048},
+		{value: "2GiB", want: 2048},
+		{value: "2GB", want: 2048},
+		{value: "0G", wantErr: true},
+		{value: "1.5G", wantErr: true},
+		{value: "2048", wantErr: true},
+		{value: "2T", wantErr: true},
+		{value: "-1G", wantErr: true},
+		{value: "G", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSwapSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSwapSize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSwapSize(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProcSwaps(t *testing.T) {
+	out := `Filename				Type		Size		Used		Priority
+/swapfile                               file		2097148		10240		-2
+/dev/zram0                              partition	1003516		0		100
+`
+	want := []swapArea{
+		{name: "/swapfile", kind: "file", size: 2097148, used: 10240},
+		{name: "/dev/zram0", kind: "partition", size: 1003516, used: 0},
+	}
+	if got := parseProcSwaps(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseProcSwaps() = %+v, want %+v", got, want)
+	}
+	if got := parseProcSwaps("Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n"); got != nil {
+		t.Errorf("parseProcSwaps() without swap = %+v, want nil", got)
+	}
+}
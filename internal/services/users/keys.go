@@ -0,0 +1,339 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+// authorizedKey is one line of an authorized_keys file. Lines that are not
+// keys, such as comments, have a nil key.
+type authorizedKey struct {
+	line    string
+	key     ssh.PublicKey
+	comment string
+}
+
+// id identifies the key itself, ignoring options and comments
+func (k authorizedKey) id() string {
+	if k.key == nil {
+		return ""
+	}
+	return string(k.key.Marshal())
+}
+
+func (k authorizedKey) String() string {
+	return fmt.Sprintf("%s %s %s", ssh.FingerprintSHA256(k.key), k.key.Type(), k.comment)
+}
+
+func parseAuthorizedKeys(data string) []authorizedKey {
+	var keys []authorizedKey
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		k := authorizedKey{line: line}
+		if !strings.HasPrefix(line, "#") {
+			if key, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line)); err == nil {
+				k.key, k.comment = key, comment
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// readLocalKeys reads public key files, each holding one or more keys.
+// Anything that isn't a key or a comment is an error, so a private key or
+// typo never ends up on the server.
+func readLocalKeys(files ...string) ([]authorizedKey, error) {
+	var keys []authorizedKey
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range parseAuthorizedKeys(string(data)) {
+			if k.key != nil {
+				keys = append(keys, k)
+			} else if !strings.HasPrefix(k.line, "#") {
+				return nil, fmt.Errorf("%s is not an SSH public key file", file)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// readKeyDir reads the *.pub files of a directory
+func readKeyDir(dir string) ([]authorizedKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pub"))
+	if err != nil {
+		return nil, err
+	}
+	return readLocalKeys(files...)
+}
+
+func authorizedKeysPath(u *account) string {
+	return path.Join(u.home, ".ssh", "authorized_keys")
+}
+
+// remoteKeys reads a user's authorized_keys; a missing file has no keys
+func remoteKeys(c *sdk.Context, u *account) []authorizedKey {
+	file := sdk.Quote(authorizedKeysPath(u))
+	return parseAuthorizedKeys(c.Shell(fmt.Sprintf("cat %s 2>/dev/null || true", file)).Stdout)
+}
+
+// writeKeys replaces a user's authorized_keys, with the ownership, modes and
+// SELinux labels sshd insists on
+func writeKeys(c *sdk.Context, u *account, keys []authorizedKey) error {
+	var content strings.Builder
+	for _, k := range keys {
+		content.WriteString(k.line + "\n")
+	}
+
+	dir := sdk.Quote(path.Join(u.home, ".ssh"))
+	owner := u.name + ":" + u.group
+	if err := c.Exec(fmt.Sprintf("install -d -m 700 -o %s -g %s %s", u.name, u.group, dir)); err != nil {
+		return fmt.Errorf("failed to create %s/.ssh: %w", u.home, err)
+	}
+	file := authorizedKeysPath(u)
+	if err := c.WriteFile(file, content.String(), 0600); err != nil {
+		return err
+	}
+	if err := c.Exec(fmt.Sprintf("chown %s %s", owner, sdk.Quote(file))); err != nil {
+		return fmt.Errorf("failed to set the owner of %s: %w", file, err)
+	}
+	if c.Run("command -v restorecon").Success {
+		if err := c.Exec("restorecon -R " + dir); err != nil {
+			c.Warn("Failed to restore SELinux labels on %s: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+// guardLockout refuses to leave the account vps-init logs in with without
+// any key
+func guardLockout(conn plugin.Connection, u *account, keys []authorizedKey) error {
+	if u.name != conn.User() {
+		return nil
+	}
+	for _, k := range keys {
+		if k.key != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("refusing to remove every key of '%s', which vps-init logs in as", u.name)
+}
+
+func (p *Plugin) keysHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	args, options := sdk.ParseOptions(args, "dry-run")
+	if len(args) < 1 {
+		return fmt.Errorf("usage: keys add <user> <key.pub...> | keys remove <user> <key.pub|SHA256:fingerprint...> | keys list <user> | keys sync <dir> [--dry-run]")
+	}
+	c := sdk.New(ctx, conn, flags)
+
+	switch args[0] {
+	case "list":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: keys list <user>")
+		}
+		u, err := lookup(c, args[1])
+		if err != nil {
+			return err
+		}
+		keys := remoteKeys(c, u)
+		fmt.Printf("🔑 Authorized keys for %s:\n", u.name)
+		count := 0
+		for _, k := range keys {
+			if k.key != nil {
+				fmt.Printf("  %s\n", k)
+				count++
+			}
+		}
+		if count == 0 {
+			fmt.Println("  (none)")
+		}
+		return nil
+
+	case "add":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: keys add <user> <key.pub...>")
+		}
+		u, err := lookup(c, args[1])
+		if err != nil {
+			return err
+		}
+		local, err := readLocalKeys(args[2:]...)
+		if err != nil {
+			return err
+		}
+		keys := remoteKeys(c, u)
+		present := map[string]bool{}
+		for _, k := range keys {
+			present[k.id()] = true
+		}
+		added := 0
+		for _, k := range local {
+			if present[k.id()] {
+				c.Info("Already authorized: %s", k)
+				continue
+			}
+			present[k.id()] = true
+			keys = append(keys, k)
+			fmt.Printf("  + %s\n", k)
+			added++
+		}
+		if added == 0 {
+			return nil
+		}
+		if err := writeKeys(c, u, keys); err != nil {
+			return err
+		}
+		c.Success("Added %d key(s) for %s", added, u.name)
+		return nil
+
+	case "remove":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: keys remove <user> <key.pub|SHA256:fingerprint...>")
+		}
+		u, err := lookup(c, args[1])
+		if err != nil {
+			return err
+		}
+		drop := map[string]bool{}
+		for _, arg := range args[2:] {
+			if strings.HasPrefix(arg, "SHA256:") {
+				drop[arg] = true
+				continue
+			}
+			local, err := readLocalKeys(arg)
+			if err != nil {
+				return err
+			}
+			for _, k := range local {
+				drop[ssh.FingerprintSHA256(k.key)] = true
+			}
+		}
+
+		var kept []authorizedKey
+		removed := 0
+		for _, k := range remoteKeys(c, u) {
+			if k.key != nil && drop[ssh.FingerprintSHA256(k.key)] {
+				fmt.Printf("  - %s\n", k)
+				removed++
+				continue
+			}
+			kept = append(kept, k)
+		}
+		if removed == 0 {
+			c.Info("No matching keys for %s", u.name)
+			return nil
+		}
+		if err := guardLockout(conn, u, kept); err != nil {
+			return err
+		}
+		if err := writeKeys(c, u, kept); err != nil {
+			return err
+		}
+		c.Success("Removed %d key(s) for %s", removed, u.name)
+		return nil
+
+	case "sync":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: keys sync <dir> [--dry-run]")
+		}
+		return syncKeys(c, conn, args[1], options["dry-run"] == "true")
+
+	default:
+		return fmt.Errorf("unknown keys action '%s'; use add, remove, list or sync", args[0])
+	}
+}
+
+// syncKeys makes each user's authorized_keys hold exactly the keys in
+// dir/<user>/*.pub. Users without a directory are left alone; an empty
+// directory removes every key.
+func syncKeys(c *sdk.Context, conn plugin.Connection, dir string, dryRun bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		u, err := lookup(c, entry.Name())
+		if err != nil {
+			c.Warn("Skipping %s: %v", entry.Name(), err)
+			continue
+		}
+		want, err := readKeyDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		wanted := map[string]bool{}
+		unique := want[:0]
+		for _, k := range want {
+			if !wanted[k.id()] {
+				wanted[k.id()] = true
+				unique = append(unique, k)
+			}
+		}
+		want = unique
+		have := map[string]bool{}
+		var added, removed []authorizedKey
+		for _, k := range remoteKeys(c, u) {
+			if k.key == nil {
+				continue
+			}
+			have[k.id()] = true
+			if !wanted[k.id()] {
+				removed = append(removed, k)
+			}
+		}
+		for _, k := range want {
+			if !have[k.id()] {
+				added = append(added, k)
+				have[k.id()] = true
+			}
+		}
+
+		if len(added) == 0 && len(removed) == 0 {
+			fmt.Printf("✅ %s: up to date (%d keys)\n", u.name, len(want))
+			continue
+		}
+		changed = true
+		fmt.Printf("🔑 %s:\n", u.name)
+		for _, k := range added {
+			fmt.Printf("  + %s\n", k)
+		}
+		for _, k := range removed {
+			fmt.Printf("  - %s\n", k)
+		}
+		if dryRun {
+			continue
+		}
+		if err := guardLockout(conn, u, want); err != nil {
+			return err
+		}
+		if err := writeKeys(c, u, want); err != nil {
+			return err
+		}
+	}
+
+	if dryRun && changed {
+		c.Info("Dry run; no keys were changed")
+	}
+	return nil
+}
@@ -0,0 +1,486 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
+)
+
+// validName matches the user and group names useradd accepts by default
+var validName = regexp.MustCompile(`^[a-z_][a-z0-9_.-]{0,31}$`)
+
+type Plugin struct{}
+
+func (p *Plugin) Name() string {
+	return "users"
+}
+
+func (p *Plugin) Description() string {
+	return "Manage users, groups, sudo access and SSH keys"
+}
+
+func (p *Plugin) Author() string {
+	return "VPS-Init"
+}
+
+func (p *Plugin) Version() string {
+	return "0.0.1"
+}
+
+func (p *Plugin) Initialize(config map[string]interface{}) error {
+	return nil
+}
+
+func (p *Plugin) Start(ctx context.Context) error {
+	return nil
+}
+
+func (p *Plugin) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (p *Plugin) GetRootCommand() *cobra.Command {
+	return nil
+}
+
+func (p *Plugin) Validate() error {
+	return nil
+}
+
+func (p *Plugin) Dependencies() []plugin.Dependency {
+	return []plugin.Dependency{}
+}
+
+func (p *Plugin) Compatibility() plugin.Compatibility {
+	return plugin.Compatibility{
+		MinVPSInitVersion: "0.1.0",
+		Tags:              []string{"users", "ssh", "sudo", "security"},
+	}
+}
+
+func (p *Plugin) GetMetadata() plugin.PluginMetadata {
+	return plugin.PluginMetadata{
+		Name:        p.Name(),
+		Description: p.Description(),
+		Version:     p.Version(),
+		Author:      p.Author(),
+		License:     "MIT",
+		Repository:  "github.com/wasilwamark/vps-init-plugins/" + p.Name(),
+		Tags:        []string{"users", "ssh", "sudo", "security"},
+		Validated:   true,
+		TrustLevel:  "official",
+		BuildInfo: plugin.BuildInfo{
+			GoVersion: "1.21",
+		},
+	}
+}
+
+func (p *Plugin) GetCommands() []plugin.Command {
+	return []plugin.Command{
+		{
+			Name:        "add",
+			Description: "Create a user [--shell /bin/bash] [--groups a,b] [--comment text]",
+			Handler:     p.addHandler,
+		},
+		{
+			Name:        "remove",
+			Description: "Delete a user and their home directory [--keep-home]",
+			Handler:     p.removeHandler,
+		},
+		{
+			Name:        "lock",
+			Description: "Lock a user's password and SSH logins",
+			Handler:     p.lockHandler,
+		},
+		{
+			Name:        "unlock",
+			Description: "Unlock a user locked with lock",
+			Handler:     p.unlockHandler,
+		},
+		{
+			Name:        "list",
+			Description: "List login users with their groups",
+			Handler:     p.listHandler,
+		},
+		{
+			Name:        "group",
+			Description: "Manage group membership [add|remove] <user> <group...>",
+			Handler:     p.groupHandler,
+		},
+		{
+			Name:        "sudo",
+			Description: "Manage sudo access [grant|revoke] <user> [--nopasswd] [--commands /bin/a,/bin/b]",
+			Handler:     p.sudoHandler,
+		},
+		{
+			Name:        "keys",
+			Description: "Manage authorized SSH keys [add|remove|list|sync]",
+			Handler:     p.keysHandler,
+		},
+	}
+}
+
+// account is a user on the target
+type account struct {
+	name  string
+	uid   int
+	group string
+	home  string
+	shell string
+}
+
+// lookup reads a user from the target's passwd database
+func lookup(c *sdk.Context, name string) (*account, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid user name '%s'", name)
+	}
+	result := c.Run("getent passwd " + name)
+	if !result.Success {
+		return nil, fmt.Errorf("user '%s' does not exist", name)
+	}
+	u, ok := parsePasswd(result.Stdout)
+	if !ok {
+		return nil, fmt.Errorf("failed to read user '%s'", name)
+	}
+	u.group = strings.TrimSpace(c.Run("id -gn " + name).Stdout)
+	return u, nil
+}
+
+// parsePasswd reads a passwd line: name:x:uid:gid:gecos:home:shell
+func parsePasswd(line string) (*account, bool) {
+	fields := strings.Split(strings.TrimSpace(line), ":")
+	if len(fields) < 7 {
+		return nil, false
+	}
+	uid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, false
+	}
+	return &account{name: fields[0], uid: uid, home: fields[5], shell: fields[6]}, true
+}
+
+// ensureShadowTools installs useradd and friends where the base system only
+// has busybox's adduser
+func ensureShadowTools(c *sdk.Context) error {
+	if c.Run("command -v useradd").Success {
+		return nil
+	}
+	return c.Install("shadow")
+}
+
+// protect refuses to lock out the account vps-init itself logs in with
+func protect(conn plugin.Connection, name, action string) error {
+	if name == "root" || name == conn.User() {
+		return fmt.Errorf("refusing to %s '%s', which vps-init needs to log in", action, name)
+	}
+	return nil
+}
+
+func (p *Plugin) addHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	args, options := sdk.ParseOptions(args)
+	if len(args) < 1 {
+		return fmt.Errorf("usage: add <user> [--shell /bin/bash] [--groups a,b] [--comment text]")
+	}
+	name := args[0]
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid user name '%s'", name)
+	}
+
+	c := sdk.New(ctx, conn, flags)
+	if err := ensureShadowTools(c); err != nil {
+		return err
+	}
+
+	if c.Run("id -u " + name).Success {
+		c.Info("User %s already exists", name)
+	} else {
+		shell := options["shell"]
+		if shell == "" {
+			shell = "/bin/bash"
+			if !c.Run("test -x /bin/bash").Success {
+				shell = "/bin/sh"
+			}
+		}
+		cmd := fmt.Sprintf("useradd -m -s %s", sdk.Quote(shell))
+		if comment := options["comment"]; comment != "" {
+			cmd += " -c " + sdk.Quote(comment)
+		}
+		c.Step("Creating user %s", name)
+		if err := c.Exec(cmd + " " + name); err != nil {
+			return fmt.Errorf("failed to create user %s: %w", name, err)
+		}
+	}
+
+	if groups := options["groups"]; groups != "" {
+		if err := addToGroups(c, name, strings.Split(groups, ",")); err != nil {
+			return err
+		}
+	}
+
+	c.Success("User %s ready", name)
+	fmt.Printf("Add SSH keys with: vps-init <target> users keys add %s ~/.ssh/id_ed25519.pub\n", name)
+	return nil
+}
+
+func (p *Plugin) removeHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	args, options := sdk.ParseOptions(args, "keep-home")
+	if len(args) < 1 {
+		return fmt.Errorf("usage: remove <user> [--keep-home]")
+	}
+	name := args[0]
+	if err := protect(conn, name, "remove"); err != nil {
+		return err
+	}
+
+	c := sdk.New(ctx, conn, flags)
+	if _, err := lookup(c, name); err != nil {
+		return err
+	}
+	if err := ensureShadowTools(c); err != nil {
+		return err
+	}
+
+	cmd := "userdel -r " + name
+	if options["keep-home"] == "true" {
+		cmd = "userdel " + name
+	}
+	c.Step("Removing user %s", name)
+	if err := c.Exec(cmd); err != nil {
+		return fmt.Errorf("failed to remove user %s: %w", name, err)
+	}
+	if err := c.Exec("rm -f " + sudoersFile(name)); err != nil {
+		c.Warn("Failed to remove sudo rules for %s: %v", name, err)
+	}
+
+	c.Success("User %s removed", name)
+	return nil
+}
+
+// lockHandler locks the password and expires the account, which also stops
+// SSH key logins
+func (p *Plugin) lockHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: lock <user>")
+	}
+	name := args[0]
+	if err := protect(conn, name, "lock"); err != nil {
+		return err
+	}
+
+	c := sdk.New(ctx, conn, flags)
+	if _, err := lookup(c, name); err != nil {
+		return err
+	}
+	if err := ensureShadowTools(c); err != nil {
+		return err
+	}
+	if err := c.Exec("usermod --lock --expiredate 1 " + name); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+
+	c.Success("User %s locked", name)
+	return nil
+}
+
+func (p *Plugin) unlockHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: unlock <user>")
+	}
+	name := args[0]
+
+	c := sdk.New(ctx, conn, flags)
+	if _, err := lookup(c, name); err != nil {
+		return err
+	}
+	if err := ensureShadowTools(c); err != nil {
+		return err
+	}
+	if err := c.Exec("usermod --unlock --expiredate '' " + name); err != nil {
+		return fmt.Errorf("failed to unlock %s: %w", name, err)
+	}
+
+	c.Success("User %s unlocked", name)
+	return nil
+}
+
+// listHandler shows root and the regular users, skipping system accounts
+func (p *Plugin) listHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	c := sdk.New(ctx, conn, flags)
+	out, err := c.Query("getent passwd")
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+
+	fmt.Println("👤 Users:")
+	for _, line := range strings.Split(out, "\n") {
+		u, ok := parsePasswd(line)
+		if !ok || (u.uid != 0 && (u.uid < 1000 || u.uid >= 65534)) {
+			continue
+		}
+		groups := strings.Fields(c.Run("id -nG " + u.name).Stdout)
+		status := ""
+		// passwd -S reports L (or LK) for a locked password
+		if fields := strings.Fields(c.Sudo("passwd -S " + u.name).Stdout); len(fields) > 1 && strings.HasPrefix(fields[1], "L") {
+			status = " 🔒 locked"
+		}
+		fmt.Printf("  %-16s uid %-6d %-14s %s%s\n", u.name, u.uid, u.shell, strings.Join(groups, ","), status)
+	}
+	return nil
+}
+
+func (p *Plugin) groupHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	if len(args) < 3 {
+		return fmt.Errorf("usage: group add <user> <group...> | group remove <user> <group...>")
+	}
+	action, name, groups := args[0], args[1], args[2:]
+
+	c := sdk.New(ctx, conn, flags)
+	if _, err := lookup(c, name); err != nil {
+		return err
+	}
+	if err := ensureShadowTools(c); err != nil {
+		return err
+	}
+
+	switch action {
+	case "add":
+		if err := addToGroups(c, name, groups); err != nil {
+			return err
+		}
+		c.Success("%s added to %s (takes effect at the next login)", name, strings.Join(groups, ", "))
+		return nil
+	case "remove":
+		for _, group := range groups {
+			if !validName.MatchString(group) {
+				return fmt.Errorf("invalid group name '%s'", group)
+			}
+			if err := c.Exec(fmt.Sprintf("gpasswd -d %s %s", name, group)); err != nil {
+				return fmt.Errorf("failed to remove %s from %s: %w", name, group, err)
+			}
+		}
+		c.Success("%s removed from %s", name, strings.Join(groups, ", "))
+		return nil
+	default:
+		return fmt.Errorf("unknown group action '%s'; use add or remove", action)
+	}
+}
+
+// addToGroups adds a user to groups, creating groups that don't exist
+func addToGroups(c *sdk.Context, name string, groups []string) error {
+	for _, group := range groups {
+		group = strings.TrimSpace(group)
+		if !validName.MatchString(group) {
+			return fmt.Errorf("invalid group name '%s'", group)
+		}
+		if !c.Run("getent group " + group).Success {
+			if err := c.Exec("groupadd " + group); err != nil {
+				return fmt.Errorf("failed to create group %s: %w", group, err)
+			}
+		}
+		if err := c.Exec(fmt.Sprintf("usermod -aG %s %s", group, name)); err != nil {
+			return fmt.Errorf("failed to add %s to %s: %w", name, group, err)
+		}
+	}
+	return nil
+}
+
+// sudoersFile is the drop-in holding a user's sudo rules. sudo skips
+// drop-ins with a dot in their name, so dots in the user name are replaced.
+func sudoersFile(name string) string {
+	return "/etc/sudoers.d/vps-init-" + strings.ReplaceAll(name, ".", "_")
+}
+
+// sudoersRule lets a user run all commands, or only the given ones, as root
+func sudoersRule(name string, nopasswd bool, commands []string) string {
+	tag := ""
+	if nopasswd {
+		tag = "NOPASSWD: "
+	}
+	allowed := "ALL"
+	if len(commands) > 0 {
+		allowed = strings.Join(commands, ", ")
+	}
+	return fmt.Sprintf("# Managed by vps-init\n%s ALL=(ALL) %s%s\n", name, tag, allowed)
+}
+
+func (p *Plugin) sudoHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+	args, options := sdk.ParseOptions(args, "nopasswd")
+	if len(args) < 2 {
+		return fmt.Errorf("usage: sudo grant <user> [--nopasswd] [--commands /usr/bin/systemctl,...] | sudo revoke <user>")
+	}
+	action, name := args[0], args[1]
+
+	c := sdk.New(ctx, conn, flags)
+	if _, err := lookup(c, name); err != nil {
+		return err
+	}
+	file := sudoersFile(name)
+
+	switch action {
+	case "grant":
+		var commands []string
+		if list := options["commands"]; list != "" {
+			for _, cmd := range strings.Split(list, ",") {
+				cmd = strings.TrimSpace(cmd)
+				if !strings.HasPrefix(cmd, "/") || strings.ContainsAny(cmd, "\n#") {
+					return fmt.Errorf("sudo commands must be absolute paths, got '%s'", cmd)
+				}
+				commands = append(commands, cmd)
+			}
+		}
+		if err := installSudoers(c, file, sudoersRule(name, options["nopasswd"] == "true", commands)); err != nil {
+			return err
+		}
+		c.Success("Sudo access granted to %s", name)
+		return nil
+
+	case "revoke":
+		if err := protect(conn, name, "revoke sudo from"); err != nil {
+			return err
+		}
+		if err := c.Exec("rm -f " + file); err != nil {
+			return fmt.Errorf("failed to revoke sudo from %s: %w", name, err)
+		}
+		c.Success("Sudo access revoked from %s", name)
+		if c.Run(fmt.Sprintf("id -nG %s | grep -qwE 'sudo|wheel|admin'", name)).Success {
+			c.Warn("%s is still in a sudo group; remove it with: users group remove %s sudo wheel", name, name)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown sudo action '%s'; use grant or revoke", action)
+	}
+}
+
+// installSudoers checks a drop-in with visudo before sudo can see it: it is
+// staged under a name with a dot, which sudo ignores, and only moved into
+// place once it parses. A broken sudoers file would lock vps-init out.
+func installSudoers(c *sdk.Context, file, content string) error {
+	if !c.Run("command -v visudo").Success {
+		return fmt.Errorf("visudo not found; install sudo first")
+	}
+
+	staged := file + ".new"
+	if err := c.WriteFile(staged, content, 0440); err != nil {
+		return err
+	}
+	check := c.Shell(fmt.Sprintf("visudo -cf %s && mv -f %s %s", staged, staged, file))
+	if !check.Success {
+		c.Exec("rm -f " + staged + " " + staged + sdk.BackupSuffix)
+		return fmt.Errorf("sudoers rule rejected by visudo: %s", strings.TrimSpace(check.Stdout+check.Stderr))
+	}
+	c.Exec("rm -f " + staged + sdk.BackupSuffix)
+
+	if check := c.Sudo("visudo -c"); !check.Success {
+		c.Exec("rm -f " + file)
+		return fmt.Errorf("sudoers configuration invalid after adding %s, removed it: %s", file, strings.TrimSpace(check.Stdout+check.Stderr))
+	}
+	return nil
+}
@@ -4,14 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net/smtp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -37,9 +37,7 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
+		MinVPSInitVersion: "0.1.0",
 		Tags:              []string{"vpn", "networking", "security"},
 	}
 }
@@ -105,33 +103,21 @@ func (p *Plugin) GetCommands() []plugin.Command {
 
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🛡️  Installing Wireguard & Tools...")
-	pass := getSudoPass(flags)
-	pkgMgr := getPackageManager(conn)
+	c := sdk.New(ctx, conn, flags)
 
-	// Update first
-	updateCmd, _ := pkgMgr.Update()
-	result := conn.RunSudo(updateCmd, pass)
-	if !result.Success {
-		return fmt.Errorf("package update failed: %s", result.Stderr)
-	}
-
-	// Install packages: wireguard, wireguard-tools, qrencode (for QR display)
-	pkgs := []string{"wireguard", "wireguard-tools", "qrencode", "iptables"}
-	installCmd, err := pkgMgr.Install(pkgs...)
-	if err != nil {
+	// Install packages: wireguard tools, qrencode (for QR display)
+	if err := c.Install("wireguard", "qrencode", "iptables"); err != nil {
 		return err
 	}
-	if result = conn.RunSudo(installCmd, pass); !result.Success {
-		return fmt.Errorf("installation failed: %s", result.Stderr)
-	}
 
 	fmt.Println("✅ Wireguard installed.")
+	c.Publish(plugin.ServiceInstalled{Service: "wireguard"})
 	return nil
 }
 
 func (p *Plugin) setupHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("⚙️  Setting up Wireguard Server...")
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	// 1. Generate Server Keys
 	privKey, pubKey, err := generateKeys(conn)
@@ -163,43 +149,27 @@ PrivateKey = %s
 `, cidr, postUp, postDown, port, privKey)
 
 	// Write Config
-	tmpPath := "/tmp/wg0.conf"
-	if err := conn.WriteFile(config, tmpPath); err != nil {
-		// Try home directory as fallback
-		homeTmpPath := "$HOME/wg0.conf"
-		if err := conn.WriteFile(config, homeTmpPath); err != nil {
-			return fmt.Errorf("failed to write tmp config")
-		}
-		tmpPath = homeTmpPath
-	}
-
-	// Move to /etc/wireguard/
-	conn.RunSudo("mkdir -p /etc/wireguard", pass)
-	if result := conn.RunSudo(fmt.Sprintf("mv %s /etc/wireguard/wg0.conf", tmpPath), pass); !result.Success {
-		return fmt.Errorf("failed to move config: %s", result.Stderr)
+	if err := c.WriteFile("/etc/wireguard/wg0.conf", config, 0600); err != nil {
+		return err
 	}
-	conn.RunSudo("chmod 600 /etc/wireguard/wg0.conf", pass)
 
 	// 4. Enable IP Forwarding
-	conn.RunSudo("sysctl -w net.ipv4.ip_forward=1", pass)
+	c.Sudo("sysctl -w net.ipv4.ip_forward=1")
 	// Make persistent
-	conn.RunSudo("echo 'net.ipv4.ip_forward=1' > /etc/sysctl.d/99-wireguard.conf", pass)
-
-	// 5. Firewall Rules (UFW) if installed
-	// Try to allow 51820/udp
-	conn.RunSudo(fmt.Sprintf("ufw allow %s/udp", port), pass)
-
-	// 6. Start Service
-	result := conn.RunSudo("systemctl enable wg-quick@wg0", pass)
-	if !result.Success {
-		return fmt.Errorf("failed to enable service: %s", result.Stderr)
+	if err := c.WriteFile("/etc/sysctl.d/99-wireguard.conf", "net.ipv4.ip_forward=1\n", 0644); err != nil {
+		c.Warn("IP forwarding will not persist across reboots: %v", err)
 	}
-	result = conn.RunSudo("systemctl start wg-quick@wg0", pass)
-	if !result.Success {
-		return fmt.Errorf("failed to start service: %s", result.Stderr)
+
+	// 5. Start Service
+	if err := c.EnableService("wg-quick@wg0"); err != nil {
+		return err
 	}
 
 	fmt.Printf("✅ Wireguard Server configured and running!\nPublic Key: %s\n", pubKey)
+
+	// 6. Let the firewall (if any) open the listen port
+	listenPort, _ := strconv.Atoi(port)
+	c.Publish(plugin.PortOpened{Port: listenPort, Protocol: "udp", Service: "wireguard"})
 	return nil
 }
 
@@ -208,13 +178,10 @@ func (p *Plugin) addPeerHandler(ctx context.Context, conn plugin.Connection, arg
 		return fmt.Errorf("usage: add-peer <name> [--email=email@example.com] [--smtp-host=smtp.gmail.com:587] [--smtp-user=user] [--smtp-pass=password] [--smtp-from=from@example.com]")
 	}
 	name := args[0]
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	// Get optional email parameter
-	email := ""
-	if val, ok := flags["email"].(string); ok {
-		email = val
-	}
+	email := c.String("email")
 
 	// Generate Client Keys
 	cPriv, cPub, err := generateKeys(conn)
@@ -223,7 +190,7 @@ func (p *Plugin) addPeerHandler(ctx context.Context, conn plugin.Connection, arg
 	}
 
 	// Get Server Public Key
-	result := conn.RunSudo("cat /etc/wireguard/wg0.conf", pass)
+	result := c.Sudo("cat /etc/wireguard/wg0.conf")
 	if !result.Success {
 		return fmt.Errorf("failed to read server config: %s", result.Stderr)
 	}
@@ -254,7 +221,7 @@ func (p *Plugin) addPeerHandler(ctx context.Context, conn plugin.Connection, arg
 	sPub := strings.TrimSpace(sPubRes.Stdout)
 
 	// Find available IP by checking existing peers
-	result = conn.RunSudo("grep AllowedIPs /etc/wireguard/wg0.conf | grep -oE '10\\.100\\.0\\.[0-9]+' | sort -V | tail -1", pass)
+	result = c.Sudo("grep AllowedIPs /etc/wireguard/wg0.conf | grep -oE '10\\.100\\.0\\.[0-9]+' | sort -V | tail -1")
 	lastIP := strings.TrimSpace(result.Stdout)
 	var ipSuffix int
 	if lastIP != "" {
@@ -282,7 +249,7 @@ func (p *Plugin) addPeerHandler(ctx context.Context, conn plugin.Connection, arg
 		name   string
 	}
 
-	configRes := conn.RunSudo("cat /etc/wireguard/wg0.conf", pass)
+	configRes := c.Sudo("cat /etc/wireguard/wg0.conf")
 	if configRes.Success {
 		lines := strings.Split(configRes.Stdout, "\n")
 		var currentName string
@@ -308,18 +275,18 @@ func (p *Plugin) addPeerHandler(ctx context.Context, conn plugin.Connection, arg
 	}
 
 	// Add peer to runtime first
-	if result = conn.RunSudo(fmt.Sprintf("wg set wg0 peer %s allowed-ips %s", cPub, clientIP), pass); !result.Success {
+	if result = c.Sudo(fmt.Sprintf("wg set wg0 peer %s allowed-ips %s", cPub, clientIP)); !result.Success {
 		return fmt.Errorf("failed to add peer to runtime: %s", result.Stderr)
 	}
 
 	// Save runtime config (this will strip comments)
-	saveRes := conn.RunSudo("wg-quick save wg0", pass)
+	saveRes := c.Sudo("wg-quick save wg0")
 	if !saveRes.Success {
 		return fmt.Errorf("failed to save runtime config: %s", saveRes.Stderr)
 	}
 
 	// Read the saved config and restore all name comments including the new one
-	updatedConfigRes := conn.RunSudo("cat /etc/wireguard/wg0.conf", pass)
+	updatedConfigRes := c.Sudo("cat /etc/wireguard/wg0.conf")
 	if updatedConfigRes.Success {
 		lines := strings.Split(updatedConfigRes.Stdout, "\n")
 		var newConfig []string
@@ -351,21 +318,9 @@ func (p *Plugin) addPeerHandler(ctx context.Context, conn plugin.Connection, arg
 
 		// Write the updated config with all names preserved
 		newConfigStr := strings.Join(newConfig, "\n")
-		tmpConfig := "/tmp/wg0_with_all_names.conf"
-		if err := conn.WriteFile(newConfigStr, tmpConfig); err != nil {
-			// Try home directory as fallback
-			homeTmpConfig := "$HOME/wg0_with_all_names.conf"
-			if err := conn.WriteFile(newConfigStr, homeTmpConfig); err != nil {
-				return fmt.Errorf("failed to write updated config: %w", err)
-			}
-			tmpConfig = homeTmpConfig
-		}
-
-		// Replace the config file
-		if result = conn.RunSudo(fmt.Sprintf("mv %s /etc/wireguard/wg0.conf", tmpConfig), pass); !result.Success {
-			return fmt.Errorf("failed to update config with names: %s", result.Stderr)
+		if err := c.WriteFile("/etc/wireguard/wg0.conf", newConfigStr, 0600); err != nil {
+			return fmt.Errorf("failed to update config with names: %w", err)
 		}
-		conn.RunSudo("chmod 600 /etc/wireguard/wg0.conf", pass)
 	}
 
 	// create Client Config
@@ -385,6 +340,7 @@ PersistentKeepalive = 25
 
 	// Display client information
 	fmt.Printf("\n✅ Peer %s added successfully!\n\n", name)
+	c.Publish(plugin.PeerAdded{Name: name, PublicKey: cPub, Address: clientAddr})
 
 	fmt.Printf("📱 Client Configuration:\n")
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
@@ -422,15 +378,15 @@ PersistentKeepalive = 25
 	}
 
 	// Clean up
-	conn.RunSudo(fmt.Sprintf("rm %s", tmpClient), pass)
+	c.Run(fmt.Sprintf("rm -f %s", tmpClient))
 	return nil
 }
 
 func (p *Plugin) removePeerHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	// Get the config file to list peers
-	configRes := conn.RunSudo("cat /etc/wireguard/wg0.conf", pass)
+	configRes := c.Sudo("cat /etc/wireguard/wg0.conf")
 	if !configRes.Success {
 		return fmt.Errorf("failed to read config file: %s", configRes.Stderr)
 	}
@@ -531,7 +487,7 @@ func (p *Plugin) removePeerHandler(ctx context.Context, conn plugin.Connection,
 
 	// Remove peer from runtime
 	fmt.Printf("🗑️  Removing peer '%s' from WireGuard...\n", displayName)
-	removeRes := conn.RunSudo(fmt.Sprintf("wg set wg0 peer %s remove", selectedPeer.pubKey), pass)
+	removeRes := c.Sudo(fmt.Sprintf("wg set wg0 peer %s remove", selectedPeer.pubKey))
 	if !removeRes.Success {
 		return fmt.Errorf("failed to remove peer from runtime: %s", removeRes.Stderr)
 	}
@@ -602,73 +558,24 @@ func (p *Plugin) removePeerHandler(ctx context.Context, conn plugin.Connection,
 	// Write new config
 	newConfigStr := strings.Join(newConfig, "\n")
 
-	// Debug: print what we're about to write
-	fmt.Printf("🐛 Debug: Generated config length: %d\n", len(newConfigStr))
 	if len(newConfigStr) == 0 {
 		return fmt.Errorf("generated empty config - this shouldn't happen")
 	}
 
-	// Show first few lines for debugging
-	debugLines := strings.Split(newConfigStr, "\n")
-	fmt.Printf("🐛 Debug: First 3 lines of new config:\n")
-	for i, line := range debugLines {
-		if i >= 3 {
-			break
-		}
-		fmt.Printf("   %d: %s\n", i, line)
-	}
-
-	// Try to write to user's home directory first, then move with sudo
-	tmpPath := "/tmp/wg0_new.conf"
-	fmt.Printf("🐛 Debug: Writing config to %s\n", tmpPath)
-	fmt.Printf("🐛 Debug: About to write content of length %d\n", len(newConfigStr))
-
-	// First try normal write to /tmp
-	if err := conn.WriteFile(newConfigStr, tmpPath); err != nil {
-		fmt.Printf("🐛 Debug: Normal WriteFile failed: %v\n", err)
-
-		// Try writing to home directory instead
-		homeTmpPath := "$HOME/wg0_new.conf"
-		fmt.Printf("🐛 Debug: Trying to write to home directory: %s\n", homeTmpPath)
-
-		if err := conn.WriteFile(newConfigStr, homeTmpPath); err != nil {
-			fmt.Printf("🐛 Debug: Home directory WriteFile also failed: %v\n", err)
-
-			// Last resort: use sudo to write directly
-			fmt.Printf("🐛 Debug: Trying sudo write method...\n")
-			escapedContent := strings.ReplaceAll(newConfigStr, "'", "'\"'\"'")
-			sudoWriteCmd := fmt.Sprintf("echo '%s' | sudo tee %s > /dev/null", escapedContent, tmpPath)
-			result := conn.RunSudo(sudoWriteCmd, pass)
-			if !result.Success {
-				fmt.Printf("🐛 Debug: Sudo write failed: %s\n", result.Stderr)
-				return fmt.Errorf("failed to write new config (all methods): %w", err)
-			}
-			fmt.Printf("🐛 Debug: Sudo write succeeded!\n")
-		} else {
-			// If home directory worked, move it with sudo
-			fmt.Printf("🐛 Debug: Home directory write succeeded, moving with sudo...\n")
-			result := conn.RunSudo(fmt.Sprintf("mv %s %s", homeTmpPath, tmpPath), pass)
-			if !result.Success {
-				return fmt.Errorf("failed to move config from home: %s", result.Stderr)
-			}
-		}
-	}
-	fmt.Printf("🐛 Debug: Config file written successfully\n")
-
 	// Backup and replace config
 	backupPath := fmt.Sprintf("/etc/wireguard/wg0.conf.bak.%d", time.Now().Unix())
-	conn.RunSudo(fmt.Sprintf("cp /etc/wireguard/wg0.conf %s", backupPath), pass)
+	c.Sudo(fmt.Sprintf("cp /etc/wireguard/wg0.conf %s", backupPath))
 
-	if result := conn.RunSudo(fmt.Sprintf("mv %s /etc/wireguard/wg0.conf", tmpPath), pass); !result.Success {
-		return fmt.Errorf("failed to update config file: %s", result.Stderr)
+	if err := c.WriteFile("/etc/wireguard/wg0.conf", newConfigStr, 0600); err != nil {
+		return fmt.Errorf("failed to update config file: %w", err)
 	}
 
 	// Reload configuration
 	fmt.Println("🔄 Reloading WireGuard configuration...")
-	reloadRes := conn.RunSudo("wg-quick down wg0 && wg-quick up wg0", pass)
+	reloadRes := c.Shell("wg-quick down wg0 && wg-quick up wg0")
 	if !reloadRes.Success {
 		// Try alternative reload method
-		conn.RunSudo("wg syncconf wg0 <(wg-quick strip wg0)", pass)
+		c.Sudo("bash -c " + sdk.Quote("wg syncconf wg0 <(wg-quick strip wg0)"))
 	}
 
 	fmt.Printf("✅ Peer '%s' removed successfully!\n", displayName)
@@ -679,19 +586,21 @@ func (p *Plugin) removePeerHandler(ctx context.Context, conn plugin.Connection,
 
 func (p *Plugin) statusHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🔌 Wireguard Service Status:")
-	conn.RunInteractive("systemctl status wg-quick@wg0")
+	if err := sdk.New(ctx, conn, flags).ServiceStatus("wg-quick@wg0"); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
 	fmt.Println("\n📊 Interface Status:")
 	return conn.RunInteractive("sudo wg show")
 }
 
 func (p *Plugin) listPeersHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	fmt.Println("🔌 WireGuard Peers Overview")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
 	// Get configuration peers
-	configRes := conn.RunSudo("cat /etc/wireguard/wg0.conf", pass)
+	configRes := c.Sudo("cat /etc/wireguard/wg0.conf")
 	if !configRes.Success {
 		return fmt.Errorf("failed to read config file: %s", configRes.Stderr)
 	}
@@ -749,7 +658,7 @@ func (p *Plugin) listPeersHandler(ctx context.Context, conn plugin.Connection, a
 	}
 
 	// Get active peers from wg show
-	activeRes := conn.RunSudo("wg show wg0", pass)
+	activeRes := c.Sudo("wg show wg0")
 	var activePeers map[string]struct {
 		endpoint        string
 		allowedIps      string
@@ -883,12 +792,11 @@ func (p *Plugin) listPeersHandler(ctx context.Context, conn plugin.Connection, a
 
 func (p *Plugin) restartHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🔄 Restarting Wireguard service...")
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	// Restart the service
-	result := conn.RunSudo("systemctl restart wg-quick@wg0", pass)
-	if !result.Success {
-		return fmt.Errorf("failed to restart Wireguard service: %s", result.Stderr)
+	if err := c.Service("restart", "wg-quick@wg0"); err != nil {
+		return err
 	}
 
 	fmt.Println("✅ Wireguard service restarted successfully")
@@ -1002,15 +910,3 @@ func getSMTPFlag(flags map[string]interface{}, key, defaultValue string) string
 
 	return defaultValue
 }
-
-func getSudoPass(flags map[string]interface{}) string {
-	if v, ok := flags["sudo-password"]; ok {
-		return v.(string)
-	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-	return pkgmgr.GetPackageManager(distroInfo)
-}
@@ -7,9 +7,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/wasilwamark/vps-init/internal/distro"
-	"github.com/wasilwamark/vps-init/internal/pkgmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
+	"github.com/wasilwamark/vps-init/pkg/plugin/sdk"
 )
 
 type Plugin struct{}
@@ -30,12 +29,12 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 	return []plugin.Dependency{
 		{
 			Name:     "mysql",
-			Version:  ">=0.0.1",
+			Version:  ">=0.1.0",
 			Optional: false,
 		},
 		{
 			Name:     "nginx",
-			Version:  ">=0.0.1",
+			Version:  ">=0.1.0",
 			Optional: false,
 		},
 	}
@@ -43,9 +42,7 @@ func (p *Plugin) Dependencies() []plugin.Dependency {
 
 func (p *Plugin) Compatibility() plugin.Compatibility {
 	return plugin.Compatibility{
-		MinVPSInitVersion: "1.0.0",
-		GoVersion:         "1.19",
-		Platforms:         []string{"linux/amd64", "linux/arm64"},
+		MinVPSInitVersion: "0.1.0",
 		Tags:              []string{"cms", "wordpress", "php", "web"},
 	}
 }
@@ -90,28 +87,21 @@ func (p *Plugin) GetCommands() []plugin.Command {
 
 func (p *Plugin) installHandler(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
 	fmt.Println("🐘 Installing PHP and Dependencies...")
-	pass := getSudoPass(flags)
-	pkgMgr := getPackageManager(conn)
+	c := sdk.New(ctx, conn, flags)
 
-	// Update
-	updateCmd, _ := pkgMgr.Update()
-	conn.RunSudo(updateCmd, pass)
-
-	// Install PHP (and common Extensions), Curl, Unzip
+	// Update lists, then install PHP (and common Extensions), Curl, Unzip
+	if err := c.UpdatePackages(); err != nil {
+		c.Warn("%v", err)
+	}
 	pkgs := []string{"php-fpm", "php-mysql", "php-curl", "php-gd", "php-mbstring", "php-xml", "php-xmlrpc", "php-soap", "php-intl", "php-zip", "unzip", "curl"}
-	installCmd, err := pkgMgr.Install(pkgs...)
-	if err != nil {
+	if err := c.Install(pkgs...); err != nil {
 		return err
 	}
-	if result := conn.RunSudo(installCmd, pass); !result.Success {
-		return fmt.Errorf("php install failed: %s", result.Stderr)
-	}
 
 	fmt.Println("🛠️  Installing WP-CLI...")
 	// Download WP-CLI
-	conn.RunSudo("curl -O https://raw.githubusercontent.com/wp-cli/builds/gh-pages/phar/wp-cli.phar", pass)
-	conn.RunSudo("chmod +x wp-cli.phar", pass)
-	conn.RunSudo("mv wp-cli.phar /usr/local/bin/wp", pass)
+	c.Sudo("curl -fsSL -o /usr/local/bin/wp https://raw.githubusercontent.com/wp-cli/builds/gh-pages/phar/wp-cli.phar")
+	c.Sudo("chmod +x /usr/local/bin/wp")
 
 	// Verify
 	if result := conn.RunCommand("wp --info", plugin.WithHideOutput()); !result.Success {
@@ -129,7 +119,7 @@ func (p *Plugin) createSiteHandler(ctx context.Context, conn plugin.Connection,
 		return fmt.Errorf("usage: create-site <domain>")
 	}
 	domain := args[0]
-	pass := getSudoPass(flags)
+	c := sdk.New(ctx, conn, flags)
 
 	// Interactive Wizard
 	fmt.Println("🚀 Standard WordPress Deployment Wizard")
@@ -189,7 +179,7 @@ func (p *Plugin) createSiteHandler(ctx context.Context, conn plugin.Connection,
 		fmt.Sprintf("mysql -u root -e \"GRANT ALL PRIVILEGES ON %s.* TO '%s'@'localhost'; FLUSH PRIVILEGES;\"", dbName, dbUser),
 	}
 	for _, cmd := range cmds {
-		result := conn.RunSudo(cmd, pass)
+		result := c.Sudo(cmd)
 		if !result.Success {
 			return fmt.Errorf("db step failed: %s", result.Stderr)
 		}
@@ -197,20 +187,20 @@ func (p *Plugin) createSiteHandler(ctx context.Context, conn plugin.Connection,
 
 	// 2. Setup Web Root
 	fmt.Println("📂 Setting up Web Root...")
-	conn.RunSudo(fmt.Sprintf("mkdir -p %s", webRoot), pass)
+	c.Sudo(fmt.Sprintf("mkdir -p %s", webRoot))
 	// Temporarily own by current user or root for WP-CLI operations, later www-data
 	// Running WP-CLI as root requires --allow-root
 
 	// 3. Download WordPress
 	fmt.Println("⬇️  Downloading WordPress...")
-	if result := conn.RunSudo(fmt.Sprintf("wp core download --path=%s --allow-root", webRoot), pass); !result.Success {
+	if result := c.Sudo(fmt.Sprintf("wp core download --path=%s --allow-root", webRoot)); !result.Success {
 		return fmt.Errorf("wp download failed: %s", result.Stderr)
 	}
 
 	// 4. Create Config
 	fmt.Println("⚙️  Configuring wp-config.php...")
 	confCmd := fmt.Sprintf("wp config create --dbname=%s --dbuser=%s --dbpass='%s' --path=%s --allow-root", dbName, dbUser, dbPass, webRoot)
-	if result := conn.RunSudo(confCmd, pass); !result.Success {
+	if result := c.Sudo(confCmd); !result.Success {
 		return fmt.Errorf("wp config failed: %s", result.Stderr)
 	}
 
@@ -218,14 +208,14 @@ func (p *Plugin) createSiteHandler(ctx context.Context, conn plugin.Connection,
 	fmt.Println("💿 Installing WordPress Core...")
 	instCmd := fmt.Sprintf("wp core install --url=http://%s --title='%s' --admin_user=%s --admin_password='%s' --admin_email=%s --path=%s --allow-root",
 		domain, domain, adminUser, adminPass, adminEmail, webRoot)
-	if result := conn.RunSudo(instCmd, pass); !result.Success {
+	if result := c.Sudo(instCmd); !result.Success {
 		return fmt.Errorf("wp install failed: %s", result.Stderr)
 	}
 
 	// 6. Permissions
 	fmt.Println("🔒 Setting Permissions...")
-	conn.RunSudo(fmt.Sprintf("chown -R www-data:www-data %s", webRoot), pass)
-	conn.RunSudo(fmt.Sprintf("chmod -R 755 %s", webRoot), pass)
+	c.Sudo(fmt.Sprintf("chown -R www-data:www-data %s", webRoot))
+	c.Sudo(fmt.Sprintf("chmod -R 755 %s", webRoot))
 
 	// 7. Nginx Config
 	fmt.Println("🌐 Configuring Nginx...")
@@ -260,35 +250,25 @@ func (p *Plugin) createSiteHandler(ctx context.Context, conn plugin.Connection,
 }
 `, domain, webRoot, phpSock)
 
-	tmpNginx := fmt.Sprintf("/tmp/nginx_%s", domain)
-	err := conn.WriteFile(nginxConf, tmpNginx)
-	if err != nil {
-		return fmt.Errorf("failed to write nginx config: %v", err)
+	nginxPath := fmt.Sprintf("/etc/nginx/sites-available/%s", domain)
+	if err := c.WriteFile(nginxPath, nginxConf, 0644); err != nil {
+		return err
 	}
-
-	conn.RunSudo(fmt.Sprintf("mv %s /etc/nginx/sites-available/%s", tmpNginx, domain), pass)
-	conn.RunSudo(fmt.Sprintf("ln -sf /etc/nginx/sites-available/%s /etc/nginx/sites-enabled/%s", domain, domain), pass)
+	c.Sudo(fmt.Sprintf("ln -sf %s /etc/nginx/sites-enabled/%s", nginxPath, domain))
 
 	// Test & Reload Nginx
-	if result := conn.RunSudo("nginx -t", pass); !result.Success {
-		// Rollback symlink
-		conn.RunSudo(fmt.Sprintf("rm /etc/nginx/sites-enabled/%s", domain), pass)
+	if result := c.Sudo("nginx -t"); !result.Success {
+		// Rollback symlink and config
+		c.Sudo(fmt.Sprintf("rm /etc/nginx/sites-enabled/%s", domain))
+		if err := c.RestoreBackup(nginxPath); err != nil {
+			c.Warn("%v", err)
+		}
 		return fmt.Errorf("nginx config failed: %s", result.Stderr)
 	}
-	conn.RunSudo("systemctl reload nginx", pass)
+	if err := c.Service("reload", "nginx"); err != nil {
+		return err
+	}
 
 	fmt.Printf("\n✅ WordPress Site http://%s deployed successfully!\n", domain)
 	return nil
 }
-
-func getSudoPass(flags map[string]interface{}) string {
-	if v, ok := flags["sudo-password"]; ok {
-		return v.(string)
-	}
-	return ""
-}
-
-func getPackageManager(conn plugin.Connection) pkgmgr.PackageManager {
-	distroInfo := conn.GetDistroInfo().(*distro.DistroInfo)
-	return pkgmgr.GetPackageManager(distroInfo)
-}
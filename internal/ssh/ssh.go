@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/internal/pkgmgr"
+	"github.com/wasilwamark/vps-init/internal/svcmgr"
 	"github.com/wasilwamark/vps-init/pkg/plugin"
 )
 
@@ -33,6 +35,8 @@ type Connection interface {
 
 	// Command execution
 	RunSudo(cmd, password string) plugin.Result
+	RunCommandContext(ctx context.Context, cmd string, sudo bool) plugin.Result
+	RunSudoContext(ctx context.Context, cmd, password string) plugin.Result
 	RunInteractive(cmd string) error
 	Shell() error
 
@@ -170,11 +174,16 @@ func ConnectFromAlias(alias string) (Connection, error) {
 
 // RunCommand executes a command on the remote host
 func (c *connection) RunCommand(cmd string, sudo bool) plugin.Result {
+	return c.RunCommandContext(context.Background(), cmd, sudo)
+}
+
+// RunCommandContext executes a command, killing ssh when ctx is done
+func (c *connection) RunCommandContext(ctx context.Context, cmd string, sudo bool) plugin.Result {
 	if sudo {
 		sudoCmd := fmt.Sprintf("sudo -S %s", cmd)
-		return c.runCommandWithContext(context.Background(), sudoCmd)
+		return c.runCommandWithContext(ctx, sudoCmd)
 	}
-	return c.runCommandWithContext(context.Background(), cmd)
+	return c.runCommandWithContext(ctx, cmd)
 }
 
 // RunCommandWithOutput executes a command and returns output as string
@@ -188,6 +197,12 @@ func (c *connection) RunCommandWithOutput(cmd string, sudo bool) (string, error)
 
 // RunSudo executes a command with sudo privileges
 func (c *connection) RunSudo(cmd, password string) plugin.Result {
+	return c.RunSudoContext(context.Background(), cmd, password)
+}
+
+// RunSudoContext executes a command with sudo privileges, killing ssh when
+// ctx is done
+func (c *connection) RunSudoContext(ctx context.Context, cmd, password string) plugin.Result {
 	if password == "" {
 		return plugin.Result{
 			Success: false,
@@ -196,7 +211,7 @@ func (c *connection) RunSudo(cmd, password string) plugin.Result {
 	}
 
 	sudoCmd := fmt.Sprintf("echo '%s' | sudo -S %s", password, cmd)
-	return c.runCommandWithContext(context.Background(), sudoCmd)
+	return c.runCommandWithContext(ctx, sudoCmd)
 }
 
 // Host returns the remote host
@@ -624,9 +639,14 @@ func (c *connection) DirectoryExists(path string) bool {
 	return result.Success
 }
 
-// Systemctl manages systemd services
+// Systemctl runs a service action with the target's init system, which is
+// systemd, OpenRC or SysV depending on the distribution
 func (c *connection) Systemctl(action, service string) bool {
-	result := c.RunCommand(fmt.Sprintf("systemctl %s %s", action, service), true)
+	cmd, err := svcmgr.Action(svcmgr.GetServiceManager(c.GetDistroInfo().(*distro.DistroInfo)), action, service)
+	if err != nil {
+		return false
+	}
+	result := c.RunCommand(cmd, true)
 	return result.Success
 }
 
@@ -642,11 +662,11 @@ func (c *connection) GetDistroInfo() interface{} {
 		}
 
 		if c.distroInfo == nil {
+			// Without /etc/os-release there is nothing to go on; the
+			// package manager reports the distro as unsupported
 			c.distroInfo = &distro.DistroInfo{
 				ID:         "unknown",
 				Name:       "Unknown",
-				Family:     distro.DistroFamilyDebian,
-				PackageMgr: distro.PackageManagerAPT,
 				ServiceMgr: distro.ServiceManagerSystemd,
 			}
 		}
@@ -656,22 +676,9 @@ func (c *connection) GetDistroInfo() interface{} {
 
 // InstallPackage installs a package
 func (c *connection) InstallPackage(packageName string) bool {
-	distroInfo := c.GetDistroInfo().(*distro.DistroInfo)
-
-	var cmd string
-	switch distroInfo.PackageMgr {
-	case distro.PackageManagerAPT:
-		cmd = fmt.Sprintf("apt-get install -y %s", packageName)
-	case distro.PackageManagerDNF:
-		cmd = fmt.Sprintf("dnf install -y %s", packageName)
-	case distro.PackageManagerYUM:
-		cmd = fmt.Sprintf("yum install -y %s", packageName)
-	case distro.PackageManagerPacman:
-		cmd = fmt.Sprintf("pacman -S --noconfirm %s", packageName)
-	case distro.PackageManagerAPK:
-		cmd = fmt.Sprintf("apk add %s", packageName)
-	default:
-		cmd = fmt.Sprintf("apt-get install -y %s", packageName)
+	cmd, err := pkgmgr.GetPackageManager(c.GetDistroInfo().(*distro.DistroInfo)).Install(packageName)
+	if err != nil {
+		return false
 	}
 
 	result := c.RunCommand(cmd, true)
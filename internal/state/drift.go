@@ -0,0 +1,116 @@
+package state
+
+import (
+	"context"
+	"fmt"
+)
+
+// DriftKind classifies how a live resource differs from the record
+type DriftKind string
+
+const (
+	DriftAdded    DriftKind = "added"
+	DriftRemoved  DriftKind = "removed"
+	DriftModified DriftKind = "modified"
+)
+
+// Drift is one difference between the live server and what vps-init last configured
+type Drift struct {
+	Kind     DriftKind
+	Resource Resource
+	Changes  []Change
+}
+
+// DetectDrift compares the live server against the record
+func DetectDrift(ctx context.Context, env *Env, rec *Record) ([]Drift, error) {
+	var drifts []Drift
+	recorded := make(map[string]bool)
+
+	for _, r := range rec.Resources {
+		recorded[identity(r)] = true
+
+		current, err := r.Read(ctx, env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", Address(r), err)
+		}
+
+		diff := r.Diff(current)
+		switch diff.Action {
+		case ActionCreate:
+			drifts = append(drifts, Drift{Kind: DriftRemoved, Resource: r})
+		case ActionUpdate:
+			drifts = append(drifts, Drift{Kind: DriftModified, Resource: r, Changes: diff.Changes})
+		}
+	}
+
+	for _, resourceType := range rec.Tracked {
+		live, err := listers[resourceType](ctx, env)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range live {
+			if !recorded[identity(r)] {
+				drifts = append(drifts, Drift{Kind: DriftAdded, Resource: r})
+			}
+		}
+	}
+
+	return drifts, nil
+}
+
+// FixDrift restores the recorded state: removed and modified resources are
+// re-applied. Resources added by hand are only deleted with prune, and never
+// a firewall rule for the port the connection itself uses.
+func FixDrift(ctx context.Context, env *Env, drifts []Drift, prune bool) error {
+	for _, d := range drifts {
+		diff := Diff{Resource: d.Resource, Changes: d.Changes}
+		switch d.Kind {
+		case DriftAdded:
+			if !prune {
+				continue
+			}
+			if rule, ok := d.Resource.(*FirewallRuleResource); ok && rule.coversPort(env.Conn.Port()) {
+				fmt.Printf("⚠️  Keeping %s: this connection uses port %d\n", Address(d.Resource), env.Conn.Port())
+				continue
+			}
+			diff.Action = ActionDelete
+		case DriftRemoved:
+			diff.Action = ActionCreate
+		case DriftModified:
+			diff.Action = ActionUpdate
+		}
+
+		fmt.Printf("⚙️  %s %s...\n", actionVerbs[diff.Action], Address(d.Resource))
+		if err := d.Resource.Apply(ctx, env, diff); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", Address(d.Resource), err)
+		}
+	}
+	return nil
+}
+
+// PrintDrift prints the drift report
+func PrintDrift(drifts []Drift) {
+	if len(drifts) == 0 {
+		fmt.Println("✅ No drift. The server matches what vps-init last configured.")
+		return
+	}
+
+	counts := map[DriftKind]int{}
+	for _, d := range drifts {
+		counts[d.Kind]++
+		switch d.Kind {
+		case DriftAdded:
+			fmt.Printf("  + %s (added outside vps-init)\n", Address(d.Resource))
+		case DriftRemoved:
+			fmt.Printf("  - %s (removed)\n", Address(d.Resource))
+		case DriftModified:
+			fmt.Printf("  ~ %s (modified)\n", Address(d.Resource))
+			for _, c := range d.Changes {
+				fmt.Printf("      %s: %s → %s\n", c.Field, displayValue(c.To), displayValue(c.From))
+			}
+		}
+	}
+
+	fmt.Printf("\nDrift: %d added, %d removed, %d modified.\n",
+		counts[DriftAdded], counts[DriftRemoved], counts[DriftModified])
+}
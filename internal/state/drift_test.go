@@ -0,0 +1,97 @@
+package state
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// sudoRecorder records privileged commands and answers them from outputs.
+// Methods the tests do not use panic through the nil embedded interface.
+type sudoRecorder struct {
+	plugin.Connection
+	port     int
+	outputs  map[string]string
+	commands []string
+}
+
+func (c *sudoRecorder) Port() int { return c.port }
+
+func (c *sudoRecorder) RunSudo(cmd, password string) plugin.Result {
+	c.commands = append(c.commands, cmd)
+	return plugin.Result{Success: true, Stdout: c.outputs[cmd]}
+}
+
+func TestFixDrift(t *testing.T) {
+	drifts := []Drift{
+		{Kind: DriftAdded, Resource: &FirewallRuleResource{Rule: "allow 8080/tcp"}},
+		{Kind: DriftAdded, Resource: &FirewallRuleResource{Rule: "allow 2222/tcp"}},
+		{Kind: DriftAdded, Resource: &FirewallRuleResource{Rule: "limit from 10.0.0.0/8 to any port 2200:2300 proto tcp"}},
+		{Kind: DriftRemoved, Resource: &FirewallRuleResource{Port: "443", Proto: "tcp"}},
+	}
+
+	tests := []struct {
+		name  string
+		port  int
+		prune bool
+		want  []string
+	}{
+		{
+			name: "added resources are kept by default",
+			port: 2222,
+			want: []string{"ufw allow 443/tcp"},
+		},
+		{
+			name:  "prune keeps the connection's port",
+			port:  2222,
+			prune: true,
+			want:  []string{"ufw delete allow 8080/tcp", "ufw allow 443/tcp"},
+		},
+		{
+			name:  "prune deletes rules for other ports",
+			port:  22,
+			prune: true,
+			want: []string{
+				"ufw delete allow 8080/tcp",
+				"ufw delete allow 2222/tcp",
+				"ufw delete limit from 10.0.0.0/8 to any port 2200:2300 proto tcp",
+				"ufw allow 443/tcp",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &sudoRecorder{port: tt.port}
+			if err := FixDrift(context.Background(), &Env{Conn: conn}, drifts, tt.prune); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(conn.commands, tt.want) {
+				t.Errorf("commands = %q, want %q", conn.commands, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirewallRuleCoversPort(t *testing.T) {
+	tests := []struct {
+		rule string
+		port int
+		want bool
+	}{
+		{rule: "allow 22/tcp", port: 22, want: true},
+		{rule: "allow OpenSSH", port: 22, want: true},
+		{rule: "allow from 203.0.113.22 to any port 2222", port: 2222, want: true},
+		{rule: "allow 80,443,2222/tcp", port: 2222, want: true},
+		{rule: "allow 2200:2300/tcp", port: 2222, want: true},
+		{rule: "allow from 203.0.113.22 to any port 80", port: 22, want: false},
+		{rule: "allow 8022/tcp", port: 22, want: false},
+	}
+	for _, tt := range tests {
+		r := &FirewallRuleResource{Rule: tt.rule}
+		if got := r.coversPort(tt.port); got != tt.want {
+			t.Errorf("coversPort(%q, %d) = %v, want %v", tt.rule, tt.port, got, tt.want)
+		}
+	}
+}
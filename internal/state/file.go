@@ -0,0 +1,99 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileResource ensures a remote file has the given content, mode and ownership
+type FileResource struct {
+	Path    string `yaml:"path" json:"path,omitempty"`
+	Content string `yaml:"content" json:"content,omitempty"`
+	Source  string `yaml:"source" json:"source,omitempty"` // local file to upload instead of inline content
+	Mode    string `yaml:"mode" json:"mode,omitempty"`
+	Owner   string `yaml:"owner" json:"owner,omitempty"`
+	Group   string `yaml:"group" json:"group,omitempty"`
+	Ensure  string `yaml:"ensure" json:"ensure,omitempty"`
+}
+
+func (r *FileResource) Type() string { return "file" }
+func (r *FileResource) ID() string   { return r.Path }
+
+// content returns the desired file content, reading Source if set
+func (r *FileResource) content() (string, error) {
+	if r.Source == "" {
+		return r.Content, nil
+	}
+	data, err := os.ReadFile(r.Source)
+	if err != nil {
+		return "", fmt.Errorf("failed to read local source %s: %w", r.Source, err)
+	}
+	return string(data), nil
+}
+
+func (r *FileResource) desired() Attributes {
+	content, _ := r.content()
+	return Attributes{
+		"checksum": checksum(content),
+		"mode":     strings.TrimLeft(r.Mode, "0"),
+		"owner":    r.Owner,
+		"group":    r.Group,
+	}
+}
+
+func (r *FileResource) Read(ctx context.Context, env *Env) (Attributes, error) {
+	if _, err := r.content(); err != nil {
+		return nil, err
+	}
+
+	result := env.Sudo(fmt.Sprintf("stat -c '%%a %%U %%G' '%s'", r.Path))
+	if !result.Success {
+		return nil, nil
+	}
+	fields := strings.Fields(result.Stdout)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("unexpected stat output: %s", result.Stdout)
+	}
+
+	return Attributes{
+		"checksum": remoteChecksum(env, r.Path),
+		"mode":     fields[0],
+		"owner":    fields[1],
+		"group":    fields[2],
+	}, nil
+}
+
+func (r *FileResource) Diff(current Attributes) Diff {
+	return diffAttributes(r, r.desired(), current, r.Ensure == "absent")
+}
+
+func (r *FileResource) Apply(ctx context.Context, env *Env, diff Diff) error {
+	if diff.Action == ActionDelete {
+		return env.SudoAll(fmt.Sprintf("rm -f '%s'", r.Path))
+	}
+
+	if diff.Action == ActionCreate || diff.changed("checksum") {
+		content, err := r.content()
+		if err != nil {
+			return err
+		}
+		if err := installFile(env, content, r.Path); err != nil {
+			return err
+		}
+	}
+
+	var cmds []string
+	if r.Mode != "" {
+		cmds = append(cmds, fmt.Sprintf("chmod %s '%s'", r.Mode, r.Path))
+	}
+	if r.Owner != "" || r.Group != "" {
+		owner := r.Owner
+		if r.Group != "" {
+			owner = fmt.Sprintf("%s:%s", r.Owner, r.Group)
+		}
+		cmds = append(cmds, fmt.Sprintf("chown %s '%s'", owner, r.Path))
+	}
+	return env.SudoAll(cmds...)
+}
@@ -0,0 +1,100 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/services/firewall"
+)
+
+// FirewallRuleResource ensures a UFW allow/deny rule exists
+type FirewallRuleResource struct {
+	Port   string `yaml:"port" json:"port,omitempty"`
+	Proto  string `yaml:"proto" json:"proto,omitempty"`
+	From   string `yaml:"from" json:"from,omitempty"`
+	Action string `yaml:"action" json:"action,omitempty"` // allow (default) or deny
+	Rule   string `yaml:"rule" json:"rule,omitempty"`     // raw ufw rule, overrides the fields above
+	Ensure string `yaml:"ensure" json:"ensure,omitempty"`
+}
+
+func (r *FirewallRuleResource) Type() string { return "firewall" }
+func (r *FirewallRuleResource) ID() string   { return r.spec() }
+
+func (r *FirewallRuleResource) spec() string {
+	if r.Rule != "" {
+		return r.Rule
+	}
+	action := r.Action
+	if action == "" {
+		action = "allow"
+	}
+	return firewall.RuleSpec(action, r.Port, r.Proto, r.From)
+}
+
+// coversPort reports whether the rule mentions port, alone, in a list or range
+// or as the OpenSSH application profile for port 22
+func (r *FirewallRuleResource) coversPort(port int) bool {
+	for _, field := range strings.Fields(r.spec()) {
+		field = strings.SplitN(field, "/", 2)[0]
+		if port == 22 && (strings.EqualFold(field, "ssh") || strings.EqualFold(field, "OpenSSH")) {
+			return true
+		}
+		for _, item := range strings.Split(field, ",") {
+			low, high, isRange := strings.Cut(item, ":")
+			if !isRange {
+				high = low
+			}
+			from, err1 := strconv.Atoi(low)
+			to, err2 := strconv.Atoi(high)
+			if err1 == nil && err2 == nil && from <= port && port <= to {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listFirewallRules returns every user rule currently added to ufw
+func listFirewallRules(ctx context.Context, env *Env) ([]Resource, error) {
+	result := env.Sudo("ufw show added")
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list firewall rules: %s", result.Stderr)
+	}
+
+	var rules []Resource
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "ufw ") {
+			continue
+		}
+		rules = append(rules, &FirewallRuleResource{Rule: strings.TrimPrefix(line, "ufw ")})
+	}
+	return rules, nil
+}
+
+func (r *FirewallRuleResource) Read(ctx context.Context, env *Env) (Attributes, error) {
+	// "ufw show added" lists user rules in the same syntax they were added with
+	result := env.Sudo("ufw show added")
+	if !result.Success {
+		return nil, nil
+	}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if strings.TrimSpace(line) == "ufw "+r.spec() {
+			return Attributes{"rule": r.spec()}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *FirewallRuleResource) Diff(current Attributes) Diff {
+	return diffAttributes(r, Attributes{"rule": r.spec()}, current, r.Ensure == "absent")
+}
+
+func (r *FirewallRuleResource) Apply(ctx context.Context, env *Env, diff Diff) error {
+	if diff.Action == ActionDelete {
+		return env.SudoAll("ufw delete " + r.spec())
+	}
+	return env.SudoAll("ufw " + r.spec())
+}
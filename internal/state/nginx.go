@@ -0,0 +1,146 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/services/nginx"
+)
+
+// NginxSiteResource ensures an nginx site exists and is enabled
+type NginxSiteResource struct {
+	Domain  string `yaml:"domain" json:"domain,omitempty"`
+	Proxy   string `yaml:"proxy" json:"proxy,omitempty"`     // local port to reverse proxy to (default 3000)
+	Source  string `yaml:"source" json:"source,omitempty"`   // local config file to upload instead of the proxy template
+	Content string `yaml:"content" json:"content,omitempty"` // inline config instead of the proxy template
+	Enabled *bool  `yaml:"enabled" json:"enabled,omitempty"`
+	Ensure  string `yaml:"ensure" json:"ensure,omitempty"`
+}
+
+func (r *NginxSiteResource) Type() string { return "nginx_site" }
+func (r *NginxSiteResource) ID() string   { return r.Domain }
+
+func (r *NginxSiteResource) availablePath() string {
+	return fmt.Sprintf("/etc/nginx/sites-available/%s", r.Domain)
+}
+
+func (r *NginxSiteResource) enabledPath() string {
+	return fmt.Sprintf("/etc/nginx/sites-enabled/%s", r.Domain)
+}
+
+func (r *NginxSiteResource) enabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+func (r *NginxSiteResource) config() (string, error) {
+	if r.Content != "" {
+		return r.Content, nil
+	}
+	if r.Source != "" {
+		data, err := os.ReadFile(r.Source)
+		if err != nil {
+			return "", fmt.Errorf("failed to read local config file: %w", err)
+		}
+		return string(data), nil
+	}
+	port := r.Proxy
+	if port == "" {
+		port = "3000"
+	}
+	return nginx.ProxySiteConfig(r.Domain, port), nil
+}
+
+// listNginxSites returns every site in sites-available with its live config
+func listNginxSites(ctx context.Context, env *Env) ([]Resource, error) {
+	result := env.Run("ls -1 /etc/nginx/sites-available/")
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list sites: %s", result.Stderr)
+	}
+
+	var sites []Resource
+	for _, domain := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+		if domain == "" {
+			continue
+		}
+		site := &NginxSiteResource{Domain: domain}
+		content := env.Sudo(fmt.Sprintf("cat %s", site.availablePath()))
+		if !content.Success {
+			return nil, fmt.Errorf("failed to read site %s: %s", domain, content.Stderr)
+		}
+		enabled := env.Run(fmt.Sprintf("test -L %s", site.enabledPath())).Success
+		site.Content = content.Stdout
+		site.Enabled = &enabled
+		sites = append(sites, site)
+	}
+	return sites, nil
+}
+
+func (r *NginxSiteResource) Read(ctx context.Context, env *Env) (Attributes, error) {
+	if _, err := r.config(); err != nil {
+		return nil, err
+	}
+
+	sum := remoteChecksum(env, r.availablePath())
+	if sum == "" {
+		return nil, nil
+	}
+	return Attributes{
+		"checksum": sum,
+		"enabled":  boolString(env.Run(fmt.Sprintf("test -L %s", r.enabledPath())).Success),
+	}, nil
+}
+
+func (r *NginxSiteResource) Diff(current Attributes) Diff {
+	config, _ := r.config()
+	desired := Attributes{
+		"checksum": checksum(config),
+		"enabled":  boolString(r.enabled()),
+	}
+	return diffAttributes(r, desired, current, r.Ensure == "absent")
+}
+
+func (r *NginxSiteResource) Apply(ctx context.Context, env *Env, diff Diff) error {
+	reload, err := env.ServiceCommand("reload", "nginx")
+	if err != nil {
+		return err
+	}
+
+	if diff.Action == ActionDelete {
+		return env.SudoAll(
+			fmt.Sprintf("rm -f %s", r.enabledPath()),
+			fmt.Sprintf("rm -f %s", r.availablePath()),
+			"nginx -t",
+			reload,
+		)
+	}
+
+	if !env.Conn.DirectoryExists("/etc/nginx/sites-available") {
+		return fmt.Errorf("Nginx configuration directory not found. Is Nginx installed? Try running: vps-init <target> nginx install")
+	}
+
+	if diff.Action == ActionCreate || diff.changed("checksum") {
+		config, err := r.config()
+		if err != nil {
+			return err
+		}
+		if err := installFile(env, config, r.availablePath()); err != nil {
+			return err
+		}
+	}
+
+	if r.enabled() {
+		if err := env.SudoAll(fmt.Sprintf("ln -sf %s /etc/nginx/sites-enabled/", r.availablePath())); err != nil {
+			return err
+		}
+	} else if err := env.SudoAll(fmt.Sprintf("rm -f %s", r.enabledPath())); err != nil {
+		return err
+	}
+
+	if result := env.Sudo("nginx -t"); !result.Success {
+		env.Sudo(fmt.Sprintf("rm -f %s", r.enabledPath()))
+		return fmt.Errorf("nginx config test failed, site disabled:\n%s", result.Stderr)
+	}
+	return env.SudoAll(reload)
+}
@@ -0,0 +1,47 @@
+package state
+
+import (
+	"context"
+
+	"github.com/wasilwamark/vps-init/internal/pkgmgr"
+)
+
+// PackageResource ensures a system package is installed or removed
+type PackageResource struct {
+	Name   string `yaml:"name" json:"name,omitempty"`
+	Ensure string `yaml:"ensure" json:"ensure,omitempty"` // present (default) or absent
+}
+
+func (r *PackageResource) Type() string { return "package" }
+func (r *PackageResource) ID() string   { return r.Name }
+
+func (r *PackageResource) Read(ctx context.Context, env *Env) (Attributes, error) {
+	installed, err := pkgmgr.GetPackageManager(env.Distro).IsInstalled(env.Query, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !installed {
+		return nil, nil
+	}
+	return Attributes{"installed": "true"}, nil
+}
+
+func (r *PackageResource) Diff(current Attributes) Diff {
+	return diffAttributes(r, Attributes{"installed": "true"}, current, r.Ensure == "absent")
+}
+
+func (r *PackageResource) Apply(ctx context.Context, env *Env, diff Diff) error {
+	pkgMgr := pkgmgr.GetPackageManager(env.Distro)
+
+	var cmd string
+	var err error
+	if diff.Action == ActionDelete {
+		cmd, err = pkgMgr.Remove(r.Name)
+	} else {
+		cmd, err = pkgMgr.Install(r.Name)
+	}
+	if err != nil {
+		return err
+	}
+	return env.SudoAll(cmd)
+}
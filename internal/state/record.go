@@ -0,0 +1,232 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wasilwamark/vps-init/internal/config"
+)
+
+// resourceTypes creates empty resources by type name when decoding records
+var resourceTypes = map[string]func() Resource{
+	"package":        func() Resource { return &PackageResource{} },
+	"user":           func() Resource { return &UserResource{} },
+	"file":           func() Resource { return &FileResource{} },
+	"service":        func() Resource { return &ServiceResource{} },
+	"firewall":       func() Resource { return &FirewallRuleResource{} },
+	"nginx_site":     func() Resource { return &NginxSiteResource{} },
+	"wireguard_peer": func() Resource { return &WireguardPeerResource{} },
+}
+
+// listers enumerate all live resources of a type so that hand-added items can be detected
+var listers = map[string]func(ctx context.Context, env *Env) ([]Resource, error){
+	"firewall":       listFirewallRules,
+	"nginx_site":     listNginxSites,
+	"wireguard_peer": listWireguardPeers,
+}
+
+// Record is what vps-init last configured on a host
+type Record struct {
+	Target    string
+	UpdatedAt string
+	// Tracked lists resource types that were fully snapshotted, so live
+	// resources of these types that are not in the record count as drift
+	Tracked   []string
+	Resources []Resource
+}
+
+type recordFile struct {
+	Target    string        `json:"target"`
+	UpdatedAt string        `json:"updated_at"`
+	Tracked   []string      `json:"tracked,omitempty"`
+	Resources []recordEntry `json:"resources"`
+}
+
+type recordEntry struct {
+	Type string          `json:"type"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+// recordPath returns the record file for a target (user@host)
+func recordPath(target string) string {
+	name := strings.NewReplacer("@", "_", ":", "_", "/", "_").Replace(target)
+	return filepath.Join(config.Dir(), "state", name+".json")
+}
+
+// LoadRecord loads the record for a target, returning an empty record if none exists
+func LoadRecord(target string) (*Record, error) {
+	rec := &Record{Target: target}
+
+	data, err := os.ReadFile(recordPath(target))
+	if os.IsNotExist(err) {
+		return rec, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state record: %w", err)
+	}
+
+	var file recordFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse state record: %w", err)
+	}
+
+	rec.UpdatedAt = file.UpdatedAt
+	rec.Tracked = file.Tracked
+	for _, entry := range file.Resources {
+		newResource, ok := resourceTypes[entry.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown resource type in state record: %s", entry.Type)
+		}
+		r := newResource()
+		if err := json.Unmarshal(entry.Spec, r); err != nil {
+			return nil, fmt.Errorf("failed to parse %s in state record: %w", entry.Type, err)
+		}
+		rec.Resources = append(rec.Resources, r)
+	}
+	return rec, nil
+}
+
+// Save writes the record to ~/.vps-init/state/<target>.json
+func (rec *Record) Save() error {
+	rec.UpdatedAt = time.Now().Format(time.RFC3339)
+	file := recordFile{
+		Target:    rec.Target,
+		UpdatedAt: rec.UpdatedAt,
+		Tracked:   rec.Tracked,
+	}
+	for _, r := range rec.Resources {
+		spec, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		file.Resources = append(file.Resources, recordEntry{Type: r.Type(), Spec: spec})
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := recordPath(rec.Target)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Upsert records a resource as configured, replacing an earlier entry with the same identity.
+// Resources with ensure: absent are removed from the record instead.
+func (rec *Record) Upsert(r Resource) error {
+	rec.Remove(r)
+	if isAbsent(r) {
+		return nil
+	}
+
+	snap, err := snapshot(r)
+	if err != nil {
+		return err
+	}
+	rec.Resources = append(rec.Resources, snap)
+	return nil
+}
+
+// Remove drops a resource from the record
+func (rec *Record) Remove(r Resource) {
+	key := identity(r)
+	kept := rec.Resources[:0]
+	for _, existing := range rec.Resources {
+		if identity(existing) != key {
+			kept = append(kept, existing)
+		}
+	}
+	rec.Resources = kept
+}
+
+// Track replaces every recorded resource of a type with a live snapshot of the target
+func (rec *Record) Track(ctx context.Context, env *Env, resourceType string) error {
+	list, ok := listers[resourceType]
+	if !ok {
+		return fmt.Errorf("resource type %s cannot be tracked", resourceType)
+	}
+	live, err := list(ctx, env)
+	if err != nil {
+		return err
+	}
+
+	kept := rec.Resources[:0]
+	for _, existing := range rec.Resources {
+		if existing.Type() != resourceType {
+			kept = append(kept, existing)
+		}
+	}
+	rec.Resources = append(kept, live...)
+
+	if !contains(rec.Tracked, resourceType) {
+		rec.Tracked = append(rec.Tracked, resourceType)
+	}
+	return nil
+}
+
+// identity returns the key used to match recorded and live resources
+func identity(r Resource) string {
+	if peer, ok := r.(*WireguardPeerResource); ok {
+		return r.Type() + "/" + peer.iface() + "/" + peer.PublicKey
+	}
+	return r.Type() + "/" + r.ID()
+}
+
+func isAbsent(r Resource) bool {
+	switch v := r.(type) {
+	case *PackageResource:
+		return v.Ensure == "absent"
+	case *UserResource:
+		return v.Ensure == "absent"
+	case *FileResource:
+		return v.Ensure == "absent"
+	case *FirewallRuleResource:
+		return v.Ensure == "absent"
+	case *NginxSiteResource:
+		return v.Ensure == "absent"
+	case *WireguardPeerResource:
+		return v.Ensure == "absent"
+	}
+	return false
+}
+
+// snapshot inlines local sources so the record reflects what was uploaded,
+// not whatever the local file contains later
+func snapshot(r Resource) (Resource, error) {
+	switch v := r.(type) {
+	case *FileResource:
+		content, err := v.content()
+		if err != nil {
+			return nil, err
+		}
+		snap := *v
+		snap.Content, snap.Source = content, ""
+		return &snap, nil
+	case *NginxSiteResource:
+		content, err := v.config()
+		if err != nil {
+			return nil, err
+		}
+		snap := *v
+		snap.Content, snap.Source, snap.Proxy = content, "", ""
+		return &snap, nil
+	}
+	return r, nil
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
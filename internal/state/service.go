@@ -0,0 +1,73 @@
+package state
+
+import "context"
+
+// ServiceResource ensures a service is running/stopped and enabled/disabled
+type ServiceResource struct {
+	Name    string `yaml:"name" json:"name,omitempty"`
+	State   string `yaml:"state" json:"state,omitempty"` // running or stopped
+	Enabled *bool  `yaml:"enabled" json:"enabled,omitempty"`
+}
+
+func (r *ServiceResource) Type() string { return "service" }
+func (r *ServiceResource) ID() string   { return r.Name }
+
+func (r *ServiceResource) desired() Attributes {
+	attrs := Attributes{"state": r.State}
+	if r.Enabled != nil {
+		attrs["enabled"] = boolString(*r.Enabled)
+	}
+	return attrs
+}
+
+func (r *ServiceResource) Read(ctx context.Context, env *Env) (Attributes, error) {
+	isActive, err := env.ServiceCommand("is-active", r.Name)
+	if err != nil {
+		return nil, err
+	}
+	isEnabled, err := env.ServiceCommand("is-enabled", r.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	state := "stopped"
+	if env.Run(isActive).Success {
+		state = "running"
+	}
+	return Attributes{
+		"state":   state,
+		"enabled": boolString(env.Run(isEnabled).Success),
+	}, nil
+}
+
+func (r *ServiceResource) Diff(current Attributes) Diff {
+	return diffAttributes(r, r.desired(), current, false)
+}
+
+func (r *ServiceResource) Apply(ctx context.Context, env *Env, diff Diff) error {
+	var actions []string
+	if diff.changed("enabled") {
+		if *r.Enabled {
+			actions = append(actions, "enable")
+		} else {
+			actions = append(actions, "disable")
+		}
+	}
+	if diff.changed("state") {
+		if r.State == "stopped" {
+			actions = append(actions, "stop")
+		} else {
+			actions = append(actions, "start")
+		}
+	}
+
+	var cmds []string
+	for _, action := range actions {
+		cmd, err := env.ServiceCommand(action, r.Name)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return env.SudoAll(cmds...)
+}
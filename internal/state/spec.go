@@ -0,0 +1,127 @@
+package state
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the desired state of a server as described in a state file
+type Spec struct {
+	Packages       []*PackageResource       `yaml:"packages"`
+	Users          []*UserResource          `yaml:"users"`
+	Files          []*FileResource          `yaml:"files"`
+	Services       []*ServiceResource       `yaml:"services"`
+	Firewall       []*FirewallRuleResource  `yaml:"firewall"`
+	NginxSites     []*NginxSiteResource     `yaml:"nginx_sites"`
+	WireguardPeers []*WireguardPeerResource `yaml:"wireguard_peers"`
+}
+
+// LoadSpec reads and validates a state file
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+// Resources returns all resources in the order they should be applied:
+// packages first so that services, sites and peers have their software present.
+func (s *Spec) Resources() []Resource {
+	var resources []Resource
+	for _, r := range s.Packages {
+		resources = append(resources, r)
+	}
+	for _, r := range s.Users {
+		resources = append(resources, r)
+	}
+	for _, r := range s.Files {
+		resources = append(resources, r)
+	}
+	for _, r := range s.Services {
+		resources = append(resources, r)
+	}
+	for _, r := range s.Firewall {
+		resources = append(resources, r)
+	}
+	for _, r := range s.NginxSites {
+		resources = append(resources, r)
+	}
+	for _, r := range s.WireguardPeers {
+		resources = append(resources, r)
+	}
+	return resources
+}
+
+func (s *Spec) validate() error {
+	for i, r := range s.Packages {
+		if r.Name == "" {
+			return fmt.Errorf("packages[%d]: name is required", i)
+		}
+	}
+	for i, r := range s.Users {
+		if r.Name == "" {
+			return fmt.Errorf("users[%d]: name is required", i)
+		}
+		if !validName.MatchString(r.Name) {
+			return fmt.Errorf("users[%d]: invalid user name %q", i, r.Name)
+		}
+		for _, g := range r.Groups {
+			if !validName.MatchString(g) {
+				return fmt.Errorf("users[%d]: invalid group name %q", i, g)
+			}
+		}
+	}
+	for i, r := range s.Files {
+		if r.Path == "" {
+			return fmt.Errorf("files[%d]: path is required", i)
+		}
+		if r.Content != "" && r.Source != "" {
+			return fmt.Errorf("files[%d]: content and source are mutually exclusive", i)
+		}
+	}
+	for i, r := range s.Services {
+		if r.Name == "" {
+			return fmt.Errorf("services[%d]: name is required", i)
+		}
+		if r.State != "" && r.State != "running" && r.State != "stopped" {
+			return fmt.Errorf("services[%d]: state must be running or stopped", i)
+		}
+	}
+	for i, r := range s.Firewall {
+		if r.Port == "" {
+			return fmt.Errorf("firewall[%d]: port is required", i)
+		}
+		if r.Action != "" && r.Action != "allow" && r.Action != "deny" {
+			return fmt.Errorf("firewall[%d]: action must be allow or deny", i)
+		}
+	}
+	for i, r := range s.NginxSites {
+		if r.Domain == "" {
+			return fmt.Errorf("nginx_sites[%d]: domain is required", i)
+		}
+		if r.Content != "" && r.Source != "" {
+			return fmt.Errorf("nginx_sites[%d]: content and source are mutually exclusive", i)
+		}
+	}
+	for i, r := range s.WireguardPeers {
+		if r.PublicKey == "" {
+			return fmt.Errorf("wireguard_peers[%d]: public_key is required", i)
+		}
+		if r.AllowedIPs == "" && r.Ensure != "absent" {
+			return fmt.Errorf("wireguard_peers[%d]: allowed_ips is required", i)
+		}
+	}
+	return nil
+}
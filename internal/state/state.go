@@ -0,0 +1,286 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/internal/svcmgr"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// Attributes is a flat view of a resource used for diffing and display
+type Attributes map[string]string
+
+// Action describes what apply has to do with a resource
+type Action string
+
+const (
+	ActionNone   Action = "none"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+var actionVerbs = map[Action]string{
+	ActionCreate: "Creating",
+	ActionUpdate: "Updating",
+	ActionDelete: "Deleting",
+}
+
+// Change is a single attribute that differs between live and desired state
+type Change struct {
+	Field string
+	From  string
+	To    string
+}
+
+// Diff is the planned change set for one resource
+type Diff struct {
+	Resource Resource
+	Action   Action
+	Changes  []Change
+}
+
+// HasChanges reports whether applying the diff would modify the target
+func (d Diff) HasChanges() bool {
+	return d.Action != ActionNone
+}
+
+// Resource is a piece of server configuration that can be converged
+type Resource interface {
+	// Type returns the resource type, e.g. "package" or "file"
+	Type() string
+	// ID returns the identifier of the resource within its type
+	ID() string
+	// Read returns the live attributes, or nil if the resource does not exist
+	Read(ctx context.Context, env *Env) (Attributes, error)
+	// Diff compares the live attributes against the desired state
+	Diff(current Attributes) Diff
+	// Apply converges the target according to the given diff
+	Apply(ctx context.Context, env *Env, diff Diff) error
+}
+
+// Env carries everything a resource needs to talk to the target
+type Env struct {
+	Conn     plugin.Connection
+	SudoPass string
+	Distro   *distro.DistroInfo
+}
+
+// NewEnv creates an environment for the given connection and command flags
+func NewEnv(conn plugin.Connection, flags map[string]interface{}) (*Env, error) {
+	info, ok := conn.GetDistroInfo().(*distro.DistroInfo)
+	if !ok || info == nil {
+		return nil, fmt.Errorf("failed to detect the distribution of %s", conn.Host())
+	}
+	sudoPass, _ := flags["sudo-password"].(string)
+	return &Env{
+		Conn:     conn,
+		SudoPass: sudoPass,
+		Distro:   info,
+	}, nil
+}
+
+// Run executes an unprivileged command on the target
+func (e *Env) Run(cmd string) plugin.Result {
+	return e.Conn.RunCommand(cmd, false)
+}
+
+// Query runs an unprivileged command and returns its stdout, failing when
+// the command does. It is the pkgmgr.Runner for package queries.
+func (e *Env) Query(cmd string) (string, error) {
+	result := e.Run(cmd)
+	if !result.Success {
+		return result.Stdout, fmt.Errorf("'%s' failed: %s", cmd, result.Stderr)
+	}
+	return result.Stdout, nil
+}
+
+// Sudo executes a privileged command on the target
+func (e *Env) Sudo(cmd string) plugin.Result {
+	return e.Conn.RunSudo(cmd, e.SudoPass)
+}
+
+// SudoAll executes privileged commands in order and stops at the first failure
+func (e *Env) SudoAll(cmds ...string) error {
+	for _, cmd := range cmds {
+		if result := e.Sudo(cmd); !result.Success {
+			return fmt.Errorf("failed step '%s': %s", cmd, result.Stderr)
+		}
+	}
+	return nil
+}
+
+// ServiceCommand returns the command for a service action under the target's
+// init system
+func (e *Env) ServiceCommand(action, service string) (string, error) {
+	return svcmgr.Action(svcmgr.GetServiceManager(e.Distro), action, service)
+}
+
+// Plan reads every resource and computes the diffs needed to converge them
+func Plan(ctx context.Context, env *Env, resources []Resource) ([]Diff, error) {
+	var diffs []Diff
+	for _, r := range resources {
+		current, err := r.Read(ctx, env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", Address(r), err)
+		}
+		diffs = append(diffs, r.Diff(current))
+	}
+	return diffs, nil
+}
+
+// Apply converges every diff that has changes, in order
+func Apply(ctx context.Context, env *Env, diffs []Diff) error {
+	for _, d := range diffs {
+		if !d.HasChanges() {
+			continue
+		}
+		fmt.Printf("⚙️  %s %s...\n", actionVerbs[d.Action], Address(d.Resource))
+		if err := d.Resource.Apply(ctx, env, d); err != nil {
+			return fmt.Errorf("failed to apply %s: %w", Address(d.Resource), err)
+		}
+	}
+	return nil
+}
+
+// Address returns the human readable address of a resource, e.g. package[nginx]
+func Address(r Resource) string {
+	return fmt.Sprintf("%s[%s]", r.Type(), r.ID())
+}
+
+// PrintPlan prints a plan in a terraform-like format and returns the number of pending changes
+func PrintPlan(diffs []Diff) int {
+	counts := map[Action]int{}
+	for _, d := range diffs {
+		counts[d.Action]++
+		switch d.Action {
+		case ActionCreate:
+			fmt.Printf("  + %s\n", Address(d.Resource))
+		case ActionUpdate:
+			fmt.Printf("  ~ %s\n", Address(d.Resource))
+		case ActionDelete:
+			fmt.Printf("  - %s\n", Address(d.Resource))
+		default:
+			continue
+		}
+		for _, c := range d.Changes {
+			fmt.Printf("      %s: %s → %s\n", c.Field, displayValue(c.From), displayValue(c.To))
+		}
+	}
+
+	pending := counts[ActionCreate] + counts[ActionUpdate] + counts[ActionDelete]
+	if pending == 0 {
+		fmt.Println("✅ No changes. The server matches the desired state.")
+		return 0
+	}
+
+	fmt.Printf("\nPlan: %d to create, %d to update, %d to delete.\n",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionDelete])
+	return pending
+}
+
+// diffAttributes compares the desired attributes against the live ones.
+// Only attributes present in desired are compared so that unmanaged settings are left alone.
+func diffAttributes(r Resource, desired, current Attributes, absent bool) Diff {
+	diff := Diff{Resource: r, Action: ActionNone}
+
+	switch {
+	case absent && current == nil:
+		return diff
+	case absent:
+		diff.Action = ActionDelete
+		return diff
+	case current == nil:
+		diff.Action = ActionCreate
+		for _, key := range sortedKeys(desired) {
+			diff.Changes = append(diff.Changes, Change{Field: key, To: desired[key]})
+		}
+		return diff
+	}
+
+	for _, key := range sortedKeys(desired) {
+		if desired[key] != current[key] {
+			diff.Changes = append(diff.Changes, Change{Field: key, From: current[key], To: desired[key]})
+		}
+	}
+	if len(diff.Changes) > 0 {
+		diff.Action = ActionUpdate
+	}
+	return diff
+}
+
+// changed reports whether the diff touches the given field
+func (d Diff) changed(field string) bool {
+	for _, c := range d.Changes {
+		if c.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(attrs Attributes) []string {
+	keys := make([]string, 0, len(attrs))
+	for key, value := range attrs {
+		if value == "" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func displayValue(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
+
+// checksum returns the hex encoded SHA256 of content
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// remoteChecksum returns the SHA256 of a remote file, or "" if it does not exist
+func remoteChecksum(env *Env, path string) string {
+	result := env.Sudo(fmt.Sprintf("sha256sum '%s'", path))
+	if !result.Success {
+		return ""
+	}
+	fields := strings.Fields(result.Stdout)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// installFile writes content to a root-owned file. The content travels
+// base64 encoded, so the shell never interprets it, and is staged in a
+// mktemp file that install copies into place.
+func installFile(env *Env, content, path string) error {
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	script := fmt.Sprintf(`set -e; tmp=$(mktemp); trap 'rm -f "$tmp"' EXIT; echo %s | base64 -d > "$tmp"; install -o root -g root -m 0644 "$tmp" "%s"`, encoded, path)
+	return env.SudoAll("sh -c " + shellQuote(script))
+}
+
+// shellQuote quotes a string as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
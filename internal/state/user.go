@@ -0,0 +1,105 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// validName matches the user and group names useradd accepts by default, as
+// the users plugin does
+var validName = regexp.MustCompile(`^[a-z_][a-z0-9_.-]{0,31}$`)
+
+// UserResource ensures a local user account exists with the given shell and groups.
+// Groups are additive: membership in groups not listed is left untouched.
+type UserResource struct {
+	Name   string   `yaml:"name" json:"name,omitempty"`
+	Shell  string   `yaml:"shell" json:"shell,omitempty"`
+	Home   string   `yaml:"home" json:"home,omitempty"`
+	Groups []string `yaml:"groups" json:"groups,omitempty"`
+	Ensure string   `yaml:"ensure" json:"ensure,omitempty"`
+}
+
+func (r *UserResource) Type() string { return "user" }
+func (r *UserResource) ID() string   { return r.Name }
+
+func (r *UserResource) desired() Attributes {
+	groups := append([]string{}, r.Groups...)
+	sort.Strings(groups)
+	return Attributes{
+		"shell":  r.Shell,
+		"home":   r.Home,
+		"groups": strings.Join(groups, ","),
+	}
+}
+
+func (r *UserResource) Read(ctx context.Context, env *Env) (Attributes, error) {
+	result := env.Run("getent passwd " + shellQuote(r.Name))
+	if !result.Success {
+		return nil, nil
+	}
+
+	// name:x:uid:gid:gecos:home:shell
+	fields := strings.Split(strings.TrimSpace(result.Stdout), ":")
+	if len(fields) < 7 {
+		return nil, fmt.Errorf("unexpected passwd entry: %s", result.Stdout)
+	}
+
+	member := make(map[string]bool)
+	if groups := env.Run("id -nG " + shellQuote(r.Name)); groups.Success {
+		for _, g := range strings.Fields(groups.Stdout) {
+			member[g] = true
+		}
+	}
+	var groups []string
+	for _, g := range r.Groups {
+		if member[g] {
+			groups = append(groups, g)
+		}
+	}
+	sort.Strings(groups)
+
+	return Attributes{
+		"home":   fields[5],
+		"shell":  fields[6],
+		"groups": strings.Join(groups, ","),
+	}, nil
+}
+
+func (r *UserResource) Diff(current Attributes) Diff {
+	return diffAttributes(r, r.desired(), current, r.Ensure == "absent")
+}
+
+func (r *UserResource) Apply(ctx context.Context, env *Env, diff Diff) error {
+	name, groups := shellQuote(r.Name), shellQuote(strings.Join(r.Groups, ","))
+	switch diff.Action {
+	case ActionDelete:
+		return env.SudoAll("userdel " + name)
+	case ActionCreate:
+		cmd := "useradd -m"
+		if r.Shell != "" {
+			cmd += " -s " + shellQuote(r.Shell)
+		}
+		if r.Home != "" {
+			cmd += " -d " + shellQuote(r.Home)
+		}
+		if len(r.Groups) > 0 {
+			cmd += " -G " + groups
+		}
+		return env.SudoAll(cmd + " " + name)
+	}
+
+	var cmds []string
+	if diff.changed("shell") {
+		cmds = append(cmds, fmt.Sprintf("usermod -s %s %s", shellQuote(r.Shell), name))
+	}
+	if diff.changed("home") {
+		cmds = append(cmds, fmt.Sprintf("usermod -m -d %s %s", shellQuote(r.Home), name))
+	}
+	if diff.changed("groups") {
+		cmds = append(cmds, fmt.Sprintf("usermod -aG %s %s", groups, name))
+	}
+	return env.SudoAll(cmds...)
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestValidateUserNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  UserResource
+		valid bool
+	}{
+		{name: "plain", user: UserResource{Name: "deploy", Groups: []string{"sudo", "www-data"}}, valid: true},
+		{name: "command in the name", user: UserResource{Name: "deploy; rm -rf /"}},
+		{name: "substitution in a group", user: UserResource{Name: "deploy", Groups: []string{"$(id)"}}},
+		{name: "upper case", user: UserResource{Name: "Deploy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &Spec{Users: []*UserResource{&tt.user}}
+			if err := spec.validate(); (err == nil) != tt.valid {
+				t.Errorf("validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestUserApplyQuotes(t *testing.T) {
+	r := &UserResource{Name: "deploy", Shell: "/bin/bash", Home: "/srv/deploy home", Groups: []string{"sudo", "docker"}}
+
+	tests := []struct {
+		name string
+		diff Diff
+		want []string
+	}{
+		{
+			name: "create",
+			diff: Diff{Action: ActionCreate},
+			want: []string{"useradd -m -s '/bin/bash' -d '/srv/deploy home' -G 'sudo,docker' 'deploy'"},
+		},
+		{
+			name: "update",
+			diff: Diff{Action: ActionUpdate, Changes: []Change{{Field: "home"}, {Field: "groups"}}},
+			want: []string{
+				"usermod -m -d '/srv/deploy home' 'deploy'",
+				"usermod -aG 'sudo,docker' 'deploy'",
+			},
+		},
+		{
+			name: "delete",
+			diff: Diff{Action: ActionDelete},
+			want: []string{"userdel 'deploy'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &sudoRecorder{}
+			if err := r.Apply(context.Background(), &Env{Conn: conn}, tt.diff); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(conn.commands, tt.want) {
+				t.Errorf("commands = %q, want %q", conn.commands, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// WireguardPeerResource ensures a peer is configured on a WireGuard interface
+type WireguardPeerResource struct {
+	Name       string `yaml:"name" json:"name,omitempty"`
+	PublicKey  string `yaml:"public_key" json:"public_key,omitempty"`
+	AllowedIPs string `yaml:"allowed_ips" json:"allowed_ips,omitempty"`
+	Interface  string `yaml:"interface" json:"interface,omitempty"` // default wg0
+	Ensure     string `yaml:"ensure" json:"ensure,omitempty"`
+}
+
+func (r *WireguardPeerResource) Type() string { return "wireguard_peer" }
+
+func (r *WireguardPeerResource) ID() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.PublicKey
+}
+
+func (r *WireguardPeerResource) iface() string {
+	if r.Interface == "" {
+		return "wg0"
+	}
+	return r.Interface
+}
+
+// listWireguardPeers returns every peer configured on every WireGuard interface
+func listWireguardPeers(ctx context.Context, env *Env) ([]Resource, error) {
+	result := env.Sudo("wg show interfaces")
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list interfaces: %s", result.Stderr)
+	}
+
+	var peers []Resource
+	for _, iface := range strings.Fields(result.Stdout) {
+		result := env.Sudo("wg show " + shellQuote(iface) + " allowed-ips")
+		if !result.Success {
+			return nil, fmt.Errorf("failed to list peers of %s: %s", iface, result.Stderr)
+		}
+		for _, line := range strings.Split(result.Stdout, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			peers = append(peers, &WireguardPeerResource{
+				PublicKey:  fields[0],
+				AllowedIPs: strings.Join(fields[1:], ","),
+				Interface:  iface,
+			})
+		}
+	}
+	return peers, nil
+}
+
+func (r *WireguardPeerResource) Read(ctx context.Context, env *Env) (Attributes, error) {
+	result := env.Sudo("wg show " + shellQuote(r.iface()) + " allowed-ips")
+	if !result.Success {
+		if r.Ensure == "absent" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read peers of %s: %s", r.iface(), result.Stderr)
+	}
+
+	// Each line is "<public key>\t<ip> <ip>..."
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == r.PublicKey {
+			return Attributes{"allowed_ips": strings.Join(fields[1:], ",")}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *WireguardPeerResource) Diff(current Attributes) Diff {
+	desired := Attributes{"allowed_ips": strings.ReplaceAll(r.AllowedIPs, " ", "")}
+	return diffAttributes(r, desired, current, r.Ensure == "absent")
+}
+
+func (r *WireguardPeerResource) Apply(ctx context.Context, env *Env, diff Diff) error {
+	iface, key := shellQuote(r.iface()), shellQuote(r.PublicKey)
+	set := fmt.Sprintf("wg set %s peer %s allowed-ips %s", iface, key, shellQuote(strings.ReplaceAll(r.AllowedIPs, " ", "")))
+	if diff.Action == ActionDelete {
+		set = fmt.Sprintf("wg set %s peer %s remove", iface, key)
+	}
+	return env.SudoAll(set, "wg-quick save "+iface)
+}
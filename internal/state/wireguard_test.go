@@ -0,0 +1,41 @@
+package state
+
+import (
+	"context"
+	"testing"
+)
+
+func TestListWireguardPeers(t *testing.T) {
+	conn := &sudoRecorder{outputs: map[string]string{
+		"wg show interfaces":              "wg0 wg-office\n",
+		"wg show 'wg0' allowed-ips":       "aGVsbG8=\t10.8.0.2/32\nd29ybGQ=\t10.8.0.3/32 fd00::3/128\n",
+		"wg show 'wg-office' allowed-ips": "aGVsbG8=\t10.9.0.2/32\n",
+	}}
+	peers, err := listWireguardPeers(context.Background(), &Env{Conn: conn})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"wireguard_peer/wg0/aGVsbG8=",
+		"wireguard_peer/wg0/d29ybGQ=",
+		"wireguard_peer/wg-office/aGVsbG8=",
+	}
+	if len(peers) != len(want) {
+		t.Fatalf("listed %d peers, want %d", len(peers), len(want))
+	}
+	for i, peer := range peers {
+		if got := identity(peer); got != want[i] {
+			t.Errorf("peer %d = %s, want %s", i, got, want[i])
+		}
+	}
+	if got := peers[1].(*WireguardPeerResource).AllowedIPs; got != "10.8.0.3/32,fd00::3/128" {
+		t.Errorf("allowed IPs = %s", got)
+	}
+
+	// A peer recorded before interfaces were stored is on wg0
+	recorded := &WireguardPeerResource{PublicKey: "aGVsbG8="}
+	if identity(recorded) != want[0] {
+		t.Errorf("identity() = %s, want %s", identity(recorded), want[0])
+	}
+}
@@ -0,0 +1,247 @@
+package svcmgr
+
+import (
+	"fmt"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+)
+
+// defaultLogLines is how many log lines Logs shows when none are requested
+const defaultLogLines = 50
+
+// ServiceManager builds the commands that control services on the target.
+// IsActive and IsEnabled commands exit 0 when the answer is yes.
+type ServiceManager interface {
+	Start(service string) (string, error)
+	Stop(service string) (string, error)
+	Restart(service string) (string, error)
+	Reload(service string) (string, error)
+	Enable(service string) (string, error)
+	Disable(service string) (string, error)
+	IsActive(service string) (string, error)
+	IsEnabled(service string) (string, error)
+	Status(service string) (string, error)
+	Logs(service string, lines int) (string, error)
+}
+
+type Systemd struct{}
+
+func NewSystemd() *Systemd {
+	return &Systemd{}
+}
+
+func (s *Systemd) Start(service string) (string, error) {
+	return command("systemctl start %s", service)
+}
+
+func (s *Systemd) Stop(service string) (string, error) {
+	return command("systemctl stop %s", service)
+}
+
+func (s *Systemd) Restart(service string) (string, error) {
+	return command("systemctl restart %s", service)
+}
+
+func (s *Systemd) Reload(service string) (string, error) {
+	return command("systemctl reload %s", service)
+}
+
+func (s *Systemd) Enable(service string) (string, error) {
+	return command("systemctl enable %s", service)
+}
+
+func (s *Systemd) Disable(service string) (string, error) {
+	return command("systemctl disable %s", service)
+}
+
+func (s *Systemd) IsActive(service string) (string, error) {
+	return command("systemctl is-active --quiet %s", service)
+}
+
+func (s *Systemd) IsEnabled(service string) (string, error) {
+	return command("systemctl is-enabled --quiet %s", service)
+}
+
+func (s *Systemd) Status(service string) (string, error) {
+	return command("systemctl status --no-pager %s", service)
+}
+
+func (s *Systemd) Logs(service string, lines int) (string, error) {
+	if service == "" {
+		return "", fmt.Errorf("no service specified")
+	}
+	return fmt.Sprintf("journalctl -u %s -n %d --no-pager", service, logLines(lines)), nil
+}
+
+type OpenRC struct{}
+
+func NewOpenRC() *OpenRC {
+	return &OpenRC{}
+}
+
+func (o *OpenRC) Start(service string) (string, error) {
+	return command("rc-service %s start", service)
+}
+
+func (o *OpenRC) Stop(service string) (string, error) {
+	return command("rc-service %s stop", service)
+}
+
+func (o *OpenRC) Restart(service string) (string, error) {
+	return command("rc-service %s restart", service)
+}
+
+func (o *OpenRC) Reload(service string) (string, error) {
+	return command("rc-service %s reload", service)
+}
+
+func (o *OpenRC) Enable(service string) (string, error) {
+	return command("rc-update add %s default", service)
+}
+
+func (o *OpenRC) Disable(service string) (string, error) {
+	return command("rc-update del %s default", service)
+}
+
+func (o *OpenRC) IsActive(service string) (string, error) {
+	return command("rc-service --quiet %s status", service)
+}
+
+func (o *OpenRC) IsEnabled(service string) (string, error) {
+	return command("rc-update show default | grep -qw %s", service)
+}
+
+func (o *OpenRC) Status(service string) (string, error) {
+	return command("rc-service %s status", service)
+}
+
+// Logs filters the syslog, as OpenRC services have no journal of their own
+func (o *OpenRC) Logs(service string, lines int) (string, error) {
+	return syslogLines(service, lines, "/var/log/messages")
+}
+
+// SysV drives init.d scripts with service. Enabling at boot uses update-rc.d
+// on Debian and chkconfig on Red Hat.
+type SysV struct {
+	family distro.DistroFamily
+}
+
+func NewSysV(family distro.DistroFamily) *SysV {
+	return &SysV{family: family}
+}
+
+func (s *SysV) Start(service string) (string, error) {
+	return command("service %s start", service)
+}
+
+func (s *SysV) Stop(service string) (string, error) {
+	return command("service %s stop", service)
+}
+
+func (s *SysV) Restart(service string) (string, error) {
+	return command("service %s restart", service)
+}
+
+func (s *SysV) Reload(service string) (string, error) {
+	return command("service %s reload", service)
+}
+
+func (s *SysV) Enable(service string) (string, error) {
+	if s.family == distro.DistroFamilyRedHat {
+		return command("chkconfig %s on", service)
+	}
+	return command("update-rc.d %s enable", service)
+}
+
+func (s *SysV) Disable(service string) (string, error) {
+	if s.family == distro.DistroFamilyRedHat {
+		return command("chkconfig %s off", service)
+	}
+	return command("update-rc.d %s disable", service)
+}
+
+func (s *SysV) IsActive(service string) (string, error) {
+	return command("service %s status >/dev/null 2>&1", service)
+}
+
+func (s *SysV) IsEnabled(service string) (string, error) {
+	if s.family == distro.DistroFamilyRedHat {
+		return command("chkconfig %s", service)
+	}
+	return command("ls /etc/rc2.d/S[0-9][0-9]%s >/dev/null 2>&1", service)
+}
+
+func (s *SysV) Status(service string) (string, error) {
+	return command("service %s status", service)
+}
+
+func (s *SysV) Logs(service string, lines int) (string, error) {
+	return syslogLines(service, lines, "/var/log/syslog", "/var/log/messages")
+}
+
+// Action returns the command for an action name such as "start" or
+// "is-active", for callers that take the action as a string
+func Action(m ServiceManager, action, service string) (string, error) {
+	switch action {
+	case "start":
+		return m.Start(service)
+	case "stop":
+		return m.Stop(service)
+	case "restart":
+		return m.Restart(service)
+	case "reload":
+		return m.Reload(service)
+	case "enable":
+		return m.Enable(service)
+	case "disable":
+		return m.Disable(service)
+	case "is-active":
+		return m.IsActive(service)
+	case "is-enabled":
+		return m.IsEnabled(service)
+	case "status":
+		return m.Status(service)
+	case "logs":
+		return m.Logs(service, defaultLogLines)
+	default:
+		return "", fmt.Errorf("unsupported service action '%s'", action)
+	}
+}
+
+func GetServiceManager(distroInfo *distro.DistroInfo) ServiceManager {
+	switch distroInfo.ServiceMgr {
+	case distro.ServiceManagerSystemd:
+		return NewSystemd()
+	case distro.ServiceManagerOpenRC:
+		return NewOpenRC()
+	case distro.ServiceManagerInitD:
+		return NewSysV(distroInfo.Family)
+	default:
+		return NewSystemd()
+	}
+}
+
+func command(format, service string) (string, error) {
+	if service == "" {
+		return "", fmt.Errorf("no service specified")
+	}
+	return fmt.Sprintf(format, service), nil
+}
+
+func syslogLines(service string, lines int, files ...string) (string, error) {
+	if service == "" {
+		return "", fmt.Errorf("no service specified")
+	}
+	cmd := "grep -hw " + service
+	for _, f := range files {
+		cmd += " " + f
+	}
+	return fmt.Sprintf("%s 2>/dev/null | tail -n %d", cmd, logLines(lines)), nil
+}
+
+func logLines(lines int) int {
+	if lines <= 0 {
+		return defaultLogLines
+	}
+	return lines
+}
@@ -1,6 +1,7 @@
 package plugin
 
 import (
+	"fmt"
 	"sync"
 )
 
@@ -34,6 +35,64 @@ func GetBuiltinRegistry() *Registry {
 	return registry
 }
 
+// externalRegistry holds plugins loaded from outside the binary
+var externalRegistry = &struct {
+	sync.RWMutex
+	plugins map[string]Plugin
+}{
+	plugins: make(map[string]Plugin),
+}
+
+// RegisterExternal registers an externally loaded plugin. A plugin cannot
+// replace a built-in plugin of the same name.
+func RegisterExternal(p Plugin) error {
+	builtinRegistry.RLock()
+	for _, builtin := range builtinRegistry.plugins {
+		if builtin.Name() == p.Name() {
+			builtinRegistry.RUnlock()
+			return fmt.Errorf("plugin '%s' conflicts with a built-in plugin", p.Name())
+		}
+	}
+	builtinRegistry.RUnlock()
+
+	externalRegistry.Lock()
+	defer externalRegistry.Unlock()
+	externalRegistry.plugins[p.Name()] = p
+	return nil
+}
+
+// IsExternal reports whether a plugin was loaded from outside the binary
+func IsExternal(name string) bool {
+	externalRegistry.RLock()
+	defer externalRegistry.RUnlock()
+	_, ok := externalRegistry.plugins[name]
+	return ok
+}
+
+// GetRegistry returns a registry with the built-in and external plugins,
+// leaving out plugins disabled by ResolvePlugins
+func GetRegistry() *Registry {
+	registry := NewRegistry(&dummyLoader{})
+	for _, plugin := range allPlugins() {
+		if Refused(plugin.Name()) == nil {
+			registry.Register(plugin)
+		}
+	}
+	return registry
+}
+
+// allPlugins returns every built-in and external plugin
+func allPlugins() []Plugin {
+	plugins := GetBuiltinRegistry().GetAll()
+
+	externalRegistry.RLock()
+	defer externalRegistry.RUnlock()
+	for _, plugin := range externalRegistry.plugins {
+		plugins = append(plugins, plugin)
+	}
+	return plugins
+}
+
 // dummyLoader is used for built-in registry
 type dummyLoader struct{}
 
@@ -71,7 +71,7 @@ func (cc *CompatibilityChecker) checkVPSInitVersion(compat Compatibility) error
 		return fmt.Errorf("invalid current VPS-Init version: %w", err)
 	}
 
-	minVersion, err := semver.NewConstraint(compat.MinVPSInitVersion)
+	minVersion, err := minVersionConstraint(compat.MinVPSInitVersion)
 	if err != nil {
 		return fmt.Errorf("invalid minimum version constraint: %w", err)
 	}
@@ -97,6 +97,15 @@ func (cc *CompatibilityChecker) checkVPSInitVersion(compat Compatibility) error
 	return nil
 }
 
+// minVersionConstraint parses a minimum version requirement. A bare version
+// such as "0.1.0" means ">= 0.1.0"; anything else is a semver constraint.
+func minVersionConstraint(min string) (*semver.Constraints, error) {
+	if _, err := semver.StrictNewVersion(min); err == nil {
+		min = ">= " + min
+	}
+	return semver.NewConstraint(min)
+}
+
 // checkPlatformCompatibility checks platform compatibility
 func (cc *CompatibilityChecker) checkPlatformCompatibility(compat Compatibility) error {
 	if len(compat.Platforms) == 0 {
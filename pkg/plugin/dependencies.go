@@ -0,0 +1,153 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ServiceProvider is implemented by plugins that install a service on the
+// target, such as a database or web server, that other plugins depend on
+type ServiceProvider interface {
+	// ServiceInstalled reports whether the service is present on the target
+	ServiceInstalled(conn Connection) bool
+	// InstallCommand is the name of the plugin command that installs the service
+	InstallCommand() string
+}
+
+// refusedPlugins holds the plugins disabled by ResolvePlugins and why
+var refusedPlugins = &struct {
+	sync.RWMutex
+	reasons map[string]error
+}{
+	reasons: make(map[string]error),
+}
+
+// ResolvePlugins checks every registered plugin against the running vps-init
+// and resolves the dependency graph. Incompatible plugins, plugins whose
+// required dependencies are missing or have the wrong version, and plugins
+// that depend on a refused plugin are left out of GetRegistry. The returned
+// error lists every refused plugin.
+func ResolvePlugins(hostVersion string) error {
+	plugins := allPlugins()
+	checker := NewCompatibilityChecker(hostVersion, runtime.Version())
+	reasons := make(map[string]error)
+
+	for _, p := range plugins {
+		if result := checker.CheckCompatibility(p); !result.Compatible {
+			reasons[p.Name()] = errors.New(strings.Join(result.Errors, "; "))
+		}
+	}
+
+	graph, err := checker.ResolveDependencies(plugins)
+	if err != nil {
+		// Built-in plugins never form a cycle, so one must involve external
+		// plugins; drop those and resolve the rest
+		var builtins []Plugin
+		for _, p := range plugins {
+			if IsExternal(p.Name()) {
+				reasons[p.Name()] = err
+				continue
+			}
+			builtins = append(builtins, p)
+		}
+		if graph, err = checker.ResolveDependencies(builtins); err != nil {
+			return err
+		}
+	}
+
+	// Dependencies come first in load order, so a refused dependency is
+	// already known when its dependents are checked
+	order, err := graph.GetLoadOrder()
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		if reasons[name] != nil {
+			continue
+		}
+		if err := checkDependencies(graph, graph.plugins[name].Plugin, reasons); err != nil {
+			reasons[name] = err
+		}
+	}
+
+	refusedPlugins.Lock()
+	refusedPlugins.reasons = reasons
+	refusedPlugins.Unlock()
+
+	names := make([]string, 0, len(reasons))
+	for name := range reasons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		errs = append(errs, fmt.Errorf("plugin %s disabled: %w", name, reasons[name]))
+	}
+	return errors.Join(errs...)
+}
+
+// checkDependencies verifies a plugin's required dependencies are loaded,
+// enabled and within the requested version range
+func checkDependencies(graph *DependencyGraph, p Plugin, reasons map[string]error) error {
+	for _, dep := range p.Dependencies() {
+		node, exists := graph.plugins[dep.Name]
+		if !exists {
+			if dep.Optional {
+				continue
+			}
+			return fmt.Errorf("requires plugin %s, which is not installed", dep.Name)
+		}
+		if reasons[dep.Name] != nil {
+			return fmt.Errorf("requires plugin %s, which is disabled", dep.Name)
+		}
+		if dep.Version == "" {
+			continue
+		}
+
+		constraint, err := semver.NewConstraint(dep.Version)
+		if err != nil {
+			return fmt.Errorf("invalid version constraint %q for %s: %w", dep.Version, dep.Name, err)
+		}
+		version, err := semver.NewVersion(node.Plugin.Version())
+		if err != nil || !constraint.Check(version) {
+			return fmt.Errorf("requires %s %s, but %s is installed", dep.Name, dep.Version, node.Plugin.Version())
+		}
+	}
+	return nil
+}
+
+// Refused returns why a plugin was disabled by ResolvePlugins, or nil
+func Refused(name string) error {
+	refusedPlugins.RLock()
+	defer refusedPlugins.RUnlock()
+	return refusedPlugins.reasons[name]
+}
+
+// MissingServices returns the plugins p depends on whose services are not
+// present on the target. Dependencies that do not provide a service, such as
+// system, are always considered present.
+func MissingServices(conn Connection, p Plugin) []Plugin {
+	registry := GetRegistry()
+
+	var missing []Plugin
+	for _, dep := range p.Dependencies() {
+		if dep.Optional {
+			continue
+		}
+		depPlugin, exists := registry.Get(dep.Name)
+		if !exists {
+			continue
+		}
+		provider, ok := depPlugin.(ServiceProvider)
+		if ok && !provider.ServiceInstalled(conn) {
+			missing = append(missing, depPlugin)
+		}
+	}
+	return missing
+}
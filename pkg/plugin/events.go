@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Event is something a plugin did on a target that other plugins may react to
+type Event interface {
+	EventName() string
+}
+
+// SiteAdded is published when a web site is added for a domain
+type SiteAdded struct {
+	Domain string
+	SSL    bool
+}
+
+// PortOpened is published when a service starts listening on a port that
+// must be reachable from outside the server
+type PortOpened struct {
+	Port     int
+	Protocol string // "tcp" or "udp"
+	Service  string
+}
+
+// PortClosed is published when a service stops listening on a port that was
+// reachable from outside the server, such as SSH after moving to a new port
+type PortClosed struct {
+	Port     int
+	Protocol string // "tcp" or "udp"
+	Service  string
+}
+
+// ServiceInstalled is published when a plugin installs a service
+type ServiceInstalled struct {
+	Service string
+}
+
+// PeerAdded is published when a VPN peer is added
+type PeerAdded struct {
+	Name      string
+	PublicKey string
+	Address   string
+}
+
+func (SiteAdded) EventName() string        { return "site-added" }
+func (PortOpened) EventName() string       { return "port-opened" }
+func (PortClosed) EventName() string       { return "port-closed" }
+func (ServiceInstalled) EventName() string { return "service-installed" }
+func (PeerAdded) EventName() string        { return "peer-added" }
+
+// EventHandler reacts to an event on the connection of the command that published it
+type EventHandler[E Event] func(ctx context.Context, conn Connection, flags map[string]interface{}, event E) error
+
+// EventSubscriber is implemented by plugins that react to other plugins'
+// events. SubscribeEvents is called once at startup for every enabled plugin.
+type EventSubscriber interface {
+	SubscribeEvents()
+}
+
+type subscription struct {
+	name    string // subscribing plugin, for error messages
+	handler func(ctx context.Context, conn Connection, flags map[string]interface{}, event Event) error
+}
+
+// eventBus holds the subscriptions, keyed by event name
+var eventBus = &struct {
+	sync.RWMutex
+	subscribers map[string][]subscription
+}{
+	subscribers: make(map[string][]subscription),
+}
+
+// Subscribe registers a handler for events of type E on behalf of a plugin
+func Subscribe[E Event](pluginName string, handler EventHandler[E]) {
+	var zero E
+
+	eventBus.Lock()
+	defer eventBus.Unlock()
+	eventBus.subscribers[zero.EventName()] = append(eventBus.subscribers[zero.EventName()], subscription{
+		name: pluginName,
+		handler: func(ctx context.Context, conn Connection, flags map[string]interface{}, event Event) error {
+			return handler(ctx, conn, flags, event.(E))
+		},
+	})
+}
+
+// Publish delivers an event to its subscribers in the order they subscribed.
+// A failing subscriber does not stop the others; their errors are returned
+// together.
+func Publish(ctx context.Context, conn Connection, flags map[string]interface{}, event Event) error {
+	eventBus.RLock()
+	subscribers := eventBus.subscribers[event.EventName()]
+	eventBus.RUnlock()
+
+	var errs []error
+	for _, sub := range subscribers {
+		if err := sub.handler(ctx, conn, flags, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", sub.name, event.EventName(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SubscribePlugins lets every enabled plugin that implements EventSubscriber
+// register its subscriptions
+func SubscribePlugins() {
+	for _, p := range GetRegistry().GetAll() {
+		if s, ok := p.(EventSubscriber); ok {
+			s.SubscribeEvents()
+		}
+	}
+}
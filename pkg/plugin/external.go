@@ -0,0 +1,634 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// externalLoadTimeout bounds how long a plugin may take to answer "initialize"
+const externalLoadTimeout = 10 * time.Second
+
+// ExternalLoader discovers plugin executables and WASM modules in a directory
+// and loads them over the stdio plugin protocol
+type ExternalLoader struct {
+	dir         string
+	hostVersion string
+	trust       *TrustStore
+
+	// Confirm is asked before running an untrusted plugin under the confirm
+	// policy. Without it, untrusted plugins are refused.
+	Confirm func(pluginName string) bool
+	// ApprovePermissions is asked before running a WASM plugin whose manifest
+	// requests local access that was not approved at install time. Without
+	// it, such plugins run without that access.
+	ApprovePermissions func(pluginName string, perms Permissions) bool
+}
+
+// NewExternalLoader creates a loader for executables in dir
+func NewExternalLoader(dir, hostVersion string) *ExternalLoader {
+	return &ExternalLoader{dir: dir, hostVersion: hostVersion}
+}
+
+// SetTrustStore sets the keys and policy used to decide which plugins may run
+func (l *ExternalLoader) SetTrustStore(ts *TrustStore) {
+	l.trust = ts
+}
+
+// Dir returns the directory plugins are discovered in
+func (l *ExternalLoader) Dir() string {
+	return l.dir
+}
+
+// LoadPlugins loads every installed plugin and every bare executable or WASM
+// module in the plugin directory. Installed plugins are verified against the checksum in
+// their manifest first. Plugins that fail to load are skipped and reported in
+// the returned error.
+func (l *ExternalLoader) LoadPlugins() ([]Plugin, error) {
+	var errs []error
+	manifests, err := l.InstalledManifests()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	paths, err := l.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	var plugins []Plugin
+	for _, m := range manifests {
+		p, err := l.loadInstalled(m)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	for _, path := range paths {
+		p, err := l.loadBare(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, errors.Join(errs...)
+}
+
+// LoadPlugin loads an installed plugin, or a bare executable or WASM module
+// with the given file name
+func (l *ExternalLoader) LoadPlugin(name string) (Plugin, error) {
+	if m, err := LoadManifest(filepath.Join(l.dir, name, ManifestFile)); err == nil {
+		m.InstallPath = filepath.Join(l.dir, name)
+		return l.loadInstalled(m)
+	}
+
+	path := filepath.Join(l.dir, name)
+	if !isExecutable(path) && !isWasmModule(path) {
+		return nil, fmt.Errorf("external plugin '%s' not found in %s", name, l.dir)
+	}
+	return l.loadBare(path)
+}
+
+// ListAvailablePlugins returns the metadata of every loadable plugin
+func (l *ExternalLoader) ListAvailablePlugins() ([]PluginMetadata, error) {
+	plugins, err := l.LoadPlugins()
+	var metadata []PluginMetadata
+	for _, p := range plugins {
+		metadata = append(metadata, p.GetMetadata())
+	}
+	return metadata, err
+}
+
+// InstalledManifests returns the manifests of plugins installed in
+// subdirectories of the plugin directory. Invalid manifests are skipped and
+// reported in the returned error.
+func (l *ExternalLoader) InstalledManifests() ([]*Manifest, error) {
+	entries, err := os.ReadDir(l.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var manifests []*Manifest
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(l.dir, entry.Name())
+		m, err := LoadManifest(filepath.Join(dir, ManifestFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// The directory is authoritative, even if the home directory moved
+		m.InstallPath = dir
+		manifests = append(manifests, m)
+	}
+	return manifests, errors.Join(errs...)
+}
+
+// trustStore returns the configured trust store, or an empty one with the default policy
+func (l *ExternalLoader) trustStore() *TrustStore {
+	if l.trust == nil {
+		return &TrustStore{Policy: PolicyConfirm}
+	}
+	return l.trust
+}
+
+// loadInstalled verifies an installed plugin's checksum and signature and loads it
+func (l *ExternalLoader) loadInstalled(m *Manifest) (*ExternalPlugin, error) {
+	if err := m.Verify(); err != nil {
+		return nil, err
+	}
+	level, err := l.trustStore().Verify(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.checkPolicy(m.Name, level); err != nil {
+		return nil, err
+	}
+	if l.mustConfirm(level) && !m.IsWasm() {
+		return l.describeInstalled(m)
+	}
+
+	var p *ExternalPlugin
+	if m.IsWasm() {
+		p, err = LoadWasmPlugin(m.ExecutablePath(), l.hostVersion)
+	} else {
+		p, err = LoadExternalPlugin(m.ExecutablePath(), l.hostVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.Name() != m.Name {
+		return nil, fmt.Errorf("plugin %s reports name '%s', which does not match its manifest", m.Name, p.Name())
+	}
+	p.info.Metadata = m.Metadata(p.info.Metadata)
+	p.info.Metadata.TrustLevel = level
+	p.checksum = m.Checksum
+	l.applyPolicy(p)
+	if p.wasm {
+		l.applyPermissions(p, m)
+	}
+	return p, nil
+}
+
+// describeInstalled builds an untrusted native plugin from its manifest
+// without starting it. The executable is only run once the user confirms a
+// command, and its dependencies and compatibility are not known until then.
+func (l *ExternalLoader) describeInstalled(m *Manifest) (*ExternalPlugin, error) {
+	if len(m.Commands) == 0 {
+		return nil, fmt.Errorf("plugin %s is untrusted and its manifest lists no commands; reinstall it with 'vps-init plugin update %s' or trust its publisher with 'vps-init plugin trust add'", m.Name, m.Name)
+	}
+	base := PluginMetadata{Name: m.Name, Version: m.Version, Description: m.Description, Author: m.Author}
+	p := &ExternalPlugin{
+		path:        m.ExecutablePath(),
+		hostVersion: l.hostVersion,
+		info:        InitializeResult{ProtocolVersion: ProtocolVersion, Metadata: m.Metadata(base), Commands: m.Commands},
+		checksum:    m.Checksum,
+	}
+	p.info.Metadata.TrustLevel = TrustUntrusted
+	l.applyPolicy(p)
+	return p, nil
+}
+
+// loadBare loads an executable or WASM module dropped into the plugin
+// directory without a manifest. Such plugins cannot be verified and are
+// always untrusted; WASM modules get no local access. A native executable
+// has no manifest to describe it, so under the confirm policy it is not
+// loaded at all rather than started before anyone is asked.
+func (l *ExternalLoader) loadBare(path string) (*ExternalPlugin, error) {
+	if err := l.checkPolicy(filepath.Base(path), TrustUntrusted); err != nil {
+		return nil, err
+	}
+	if l.mustConfirm(TrustUntrusted) && !isWasmModule(path) {
+		return nil, fmt.Errorf("plugin %s is an untrusted executable without a manifest; install it with 'vps-init plugin install' or set the trust policy to 'allow'", filepath.Base(path))
+	}
+	var p *ExternalPlugin
+	var err error
+	if isWasmModule(path) {
+		p, err = LoadWasmPlugin(path, l.hostVersion)
+	} else {
+		p, err = LoadExternalPlugin(path, l.hostVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.info.Metadata.TrustLevel = TrustUntrusted
+	l.applyPolicy(p)
+	return p, nil
+}
+
+// checkPolicy refuses untrusted plugins before they are started under the refuse policy
+func (l *ExternalLoader) checkPolicy(name, level string) error {
+	if level == TrustUntrusted && l.trustStore().Policy == PolicyRefuse {
+		return fmt.Errorf("plugin %s is untrusted and the trust policy is 'refuse'; sign it and trust the publisher key with 'vps-init plugin trust add'", name)
+	}
+	return nil
+}
+
+// mustConfirm reports whether plugins at level ask before every run
+func (l *ExternalLoader) mustConfirm(level string) bool {
+	return level == TrustUntrusted && l.trustStore().Policy == PolicyConfirm
+}
+
+// applyPolicy makes untrusted plugins ask for confirmation under the confirm policy
+func (l *ExternalLoader) applyPolicy(p *ExternalPlugin) {
+	if !l.mustConfirm(p.info.Metadata.TrustLevel) {
+		return
+	}
+	confirm := l.Confirm
+	p.confirm = func() bool {
+		return confirm != nil && confirm(p.Name())
+	}
+}
+
+// applyPermissions gives a WASM plugin the local access its manifest requests,
+// if the user approved it at install time or approves it when a command runs
+func (l *ExternalLoader) applyPermissions(p *ExternalPlugin, m *Manifest) {
+	p.permissions = m.Permissions
+	if m.Permissions.Empty() || (m.Granted != nil && m.Granted.Covers(m.Permissions)) {
+		return
+	}
+	approve := l.ApprovePermissions
+	p.approve = func(perms Permissions) bool {
+		return approve != nil && approve(p.Name(), perms)
+	}
+}
+
+// discover returns the bare executables and WASM modules in the plugin directory
+func (l *ExternalLoader) discover() ([]string, error) {
+	entries, err := os.ReadDir(l.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		path := filepath.Join(l.dir, entry.Name())
+		if !entry.IsDir() && (isExecutable(path) || isWasmModule(path)) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 && !strings.HasSuffix(path, ".wasm")
+}
+
+func isWasmModule(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && strings.HasSuffix(path, ".wasm")
+}
+
+// ExternalPlugin is a plugin running as a separate process, or as a WASM
+// module in the sandbox. A fresh instance is started for metadata discovery
+// and for every command invocation.
+type ExternalPlugin struct {
+	path        string
+	hostVersion string
+	info        InitializeResult
+	config      map[string]interface{}
+	checksum    string      // verified again before every command, when installed from a manifest
+	confirm     func() bool // asked before every command for untrusted plugins, if set
+
+	wasm        bool
+	permissions Permissions            // local access requested by a WASM plugin's manifest
+	approve     func(Permissions) bool // asked before every command when permissions were not approved at install
+}
+
+// LoadExternalPlugin starts the executable at path and asks it to describe itself
+func LoadExternalPlugin(path, hostVersion string) (*ExternalPlugin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), externalLoadTimeout)
+	defer cancel()
+
+	proc, info, err := startExternal(ctx, path, hostVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin %s: %w", filepath.Base(path), err)
+	}
+	proc.close()
+
+	if info.Metadata.Name == "" {
+		return nil, fmt.Errorf("failed to load plugin %s: plugin did not report a name", filepath.Base(path))
+	}
+
+	info.Metadata.InstallPath = path
+	return &ExternalPlugin{path: path, hostVersion: hostVersion, info: info}, nil
+}
+
+// Path returns the plugin executable or WASM module
+func (p *ExternalPlugin) Path() string {
+	return p.path
+}
+
+// Runtime returns RuntimeWasm for sandboxed plugins and RuntimeExec otherwise
+func (p *ExternalPlugin) Runtime() string {
+	if p.wasm {
+		return RuntimeWasm
+	}
+	return RuntimeExec
+}
+
+func (p *ExternalPlugin) Name() string        { return p.info.Metadata.Name }
+func (p *ExternalPlugin) Description() string { return p.info.Metadata.Description }
+func (p *ExternalPlugin) Version() string     { return p.info.Metadata.Version }
+func (p *ExternalPlugin) Author() string      { return p.info.Metadata.Author }
+
+func (p *ExternalPlugin) Initialize(config map[string]interface{}) error {
+	p.config = config
+	return nil
+}
+
+func (p *ExternalPlugin) Validate() error {
+	if len(p.info.Commands) == 0 {
+		return fmt.Errorf("plugin %s exposes no commands", p.Name())
+	}
+	return nil
+}
+
+func (p *ExternalPlugin) GetCommands() []Command {
+	commands := make([]Command, 0, len(p.info.Commands))
+	for _, spec := range p.info.Commands {
+		name := spec.Name
+		commands = append(commands, Command{
+			Name:        spec.Name,
+			Description: spec.Description,
+			Aliases:     spec.Aliases,
+			Handler: func(ctx context.Context, conn Connection, args []string, flags map[string]interface{}) error {
+				return p.execute(ctx, conn, name, args, flags)
+			},
+		})
+	}
+	return commands
+}
+
+func (p *ExternalPlugin) GetRootCommand() *cobra.Command { return nil }
+
+func (p *ExternalPlugin) Start(ctx context.Context) error { return nil }
+func (p *ExternalPlugin) Stop(ctx context.Context) error  { return nil }
+
+func (p *ExternalPlugin) Dependencies() []Dependency   { return p.info.Dependencies }
+func (p *ExternalPlugin) Compatibility() Compatibility { return p.info.Compatibility }
+func (p *ExternalPlugin) GetMetadata() PluginMetadata  { return p.info.Metadata }
+
+// execute runs a command in a new plugin process, serving its connection
+// callbacks with conn until it finishes
+func (p *ExternalPlugin) execute(ctx context.Context, conn Connection, command string, args []string, flags map[string]interface{}) error {
+	if p.checksum != "" {
+		if sum, err := FileChecksum(p.path); err != nil || sum != p.checksum {
+			return fmt.Errorf("plugin %s changed since it was loaded; refusing to run it", p.Name())
+		}
+	}
+	if p.confirm != nil && !p.confirm() {
+		return fmt.Errorf("plugin %s is untrusted; not running it", p.Name())
+	}
+
+	sudoPass, _ := flags["sudo-password"].(string)
+	handler := hostHandler(conn, sudoPass)
+
+	var proc *externalProcess
+	var info InitializeResult
+	var err error
+	if p.wasm {
+		perms := p.grantedPermissions()
+		handler = sandboxHandler(handler, perms)
+		proc, info, err = startWasm(ctx, p.path, p.hostVersion, perms)
+	} else {
+		proc, info, err = startExternal(ctx, p.path, p.hostVersion)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.Name(), err)
+	}
+	defer proc.close()
+	// Untrusted plugins are described by their manifest until now
+	if info.Metadata.Name != p.Name() {
+		return fmt.Errorf("plugin %s reports name '%s', which does not match its manifest", p.Name(), info.Metadata.Name)
+	}
+
+	pluginFlags := make(map[string]interface{}, len(flags))
+	for k, v := range flags {
+		pluginFlags[k] = v
+	}
+	if sudoPass != "" {
+		pluginFlags["sudo-password"] = HostSudoPassword
+	}
+
+	params := ExecuteParams{
+		Command: command,
+		Args:    args,
+		Flags:   pluginFlags,
+		Target:  TargetInfo{User: conn.User(), Host: conn.Host(), Port: conn.Port()},
+	}
+	err = proc.rpc.call(MethodExecute, params, nil, handler)
+	if rpcErr, ok := err.(*RPCError); ok {
+		// Handler errors come back verbatim
+		return errors.New(rpcErr.Message)
+	}
+	return err
+}
+
+// grantedPermissions returns the local access a WASM plugin gets for one command
+func (p *ExternalPlugin) grantedPermissions() Permissions {
+	if p.permissions.Empty() || p.approve == nil || p.approve(p.permissions) {
+		return p.permissions
+	}
+	fmt.Fprintf(os.Stderr, "⚠️  Running %s without the access it requested (%s)\n", p.Name(), p.permissions)
+	return Permissions{}
+}
+
+// externalProcess is a running plugin executable or WASM module
+type externalProcess struct {
+	rpc  *rpcConn
+	wait func() // returns once the plugin has exited
+	kill func()
+}
+
+// startExternal starts a plugin executable and performs the initialize handshake
+func startExternal(ctx context.Context, path, hostVersion string) (*externalProcess, InitializeResult, error) {
+	var info InitializeResult
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), fmt.Sprintf("VPS_INIT_PLUGIN_PROTOCOL=%d", ProtocolVersion))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, info, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, info, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, info, err
+	}
+
+	proc := &externalProcess{
+		rpc:  newRPCConn(stdout, stdin),
+		wait: func() { cmd.Wait() },
+		kill: func() {
+			if cmd.Process != nil {
+				cmd.Process.Kill()
+			}
+		},
+	}
+	info, err = proc.initialize(hostVersion)
+	if err != nil {
+		return nil, info, err
+	}
+	return proc, info, nil
+}
+
+// initialize performs the handshake, killing the plugin if it fails
+func (p *externalProcess) initialize(hostVersion string) (InitializeResult, error) {
+	var info InitializeResult
+	params := InitializeParams{ProtocolVersion: ProtocolVersion, HostVersion: hostVersion}
+	if err := p.rpc.call(MethodInitialize, params, &info, nil); err != nil {
+		p.kill()
+		return info, err
+	}
+	if info.ProtocolVersion != ProtocolVersion {
+		p.kill()
+		return info, fmt.Errorf("plugin speaks protocol v%d, vps-init supports v%d", info.ProtocolVersion, ProtocolVersion)
+	}
+	return info, nil
+}
+
+// close asks the plugin to exit and waits briefly before killing it
+func (p *externalProcess) close() {
+	p.rpc.notify(MethodShutdown, struct{}{})
+
+	done := make(chan struct{})
+	go func() {
+		p.wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		p.kill()
+	}
+}
+
+// hostHandler serves a plugin's connection callbacks using the host connection.
+// Sudo commands run with the host's sudo password, whatever the plugin passed.
+func hostHandler(conn Connection, sudoPass string) rpcHandler {
+	return func(method string, raw json.RawMessage) (interface{}, error) {
+		switch method {
+		case "conn.run_command", "conn.run_sudo":
+			var params connCommandParams
+			if err := decodeParams(raw, &params); err != nil {
+				return nil, err
+			}
+			if method == "conn.run_sudo" {
+				return conn.RunSudo(params.Cmd, sudoPass), nil
+			}
+			return conn.RunCommand(params.Cmd, params.Sudo), nil
+
+		case "conn.systemctl", "conn.install_package":
+			var params connServiceParams
+			if err := decodeParams(raw, &params); err != nil {
+				return nil, err
+			}
+			if method == "conn.systemctl" {
+				return conn.Systemctl(params.Action, params.Service), nil
+			}
+			return conn.InstallPackage(params.Package), nil
+
+		case MethodPrompt, MethodLog:
+			var params hostMessageParams
+			if err := decodeParams(raw, &params); err != nil {
+				return nil, err
+			}
+			if method == MethodLog {
+				fmt.Println(params.Message)
+				return nil, nil
+			}
+			return promptUser(params.Message)
+
+		case MethodFetch:
+			var req FetchRequest
+			if err := decodeParams(raw, &req); err != nil {
+				return nil, err
+			}
+			return fetch(req)
+
+		case "conn.platform":
+			return platformInfo{
+				Distro: conn.GetDistroInfo(),
+				Ubuntu: conn.IsUbuntu(),
+				Debian: conn.IsDebian(),
+				CentOS: conn.IsCentOS(),
+				RedHat: conn.IsRedHat(),
+			}, nil
+		}
+
+		var params connFileParams
+		if err := decodeParams(raw, &params); err != nil {
+			return nil, err
+		}
+		switch method {
+		case "conn.write_file":
+			return nil, conn.WriteFile(params.Content, params.Path)
+		case "conn.append_file":
+			return nil, conn.AppendFile(params.Content, params.Path)
+		case "conn.upload_file":
+			return nil, conn.UploadFile(params.Src, params.Dst)
+		case "conn.download_file":
+			return nil, conn.DownloadFile(params.Src, params.Dst)
+		case "conn.copy_file":
+			return nil, conn.CopyFile(params.Src, params.Dst)
+		case "conn.move_file":
+			return nil, conn.MoveFile(params.Src, params.Dst)
+		case "conn.delete_file":
+			return nil, conn.DeleteFile(params.Path)
+		case "conn.create_directory":
+			return nil, conn.CreateDirectory(params.Path)
+		case "conn.remove_directory":
+			return nil, conn.RemoveDirectory(params.Path, params.Recursive)
+		case "conn.change_permissions":
+			return nil, conn.ChangePermissions(params.Path, params.Mode)
+		case "conn.change_owner":
+			return nil, conn.ChangeOwner(params.Path, params.User, params.Group)
+		case "conn.list_directory":
+			return conn.ListDirectory(params.Path), nil
+		case "conn.file_info":
+			return conn.GetFileInfo(params.Path), nil
+		case "conn.file_exists":
+			return conn.FileExists(params.Path), nil
+		case "conn.directory_exists":
+			return conn.DirectoryExists(params.Path), nil
+		}
+		return nil, &RPCError{Code: ErrCodeMethodNotFound, Message: "unknown method: " + method}
+	}
+}
+
+// platformInfo is the result of conn.platform
+type platformInfo struct {
+	Distro interface{} `json:"distro"`
+	Ubuntu bool        `json:"ubuntu"`
+	Debian bool        `json:"debian"`
+	CentOS bool        `json:"centos"`
+	RedHat bool        `json:"redhat"`
+}
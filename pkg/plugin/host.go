@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// fetchTimeout bounds an HTTP request made with Fetch
+	fetchTimeout = 30 * time.Second
+	// maxFetchBody is the largest response body Fetch returns
+	maxFetchBody = 10 << 20
+)
+
+// FetchRequest is an HTTP request made with Fetch
+type FetchRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// FetchResponse is the response to a FetchRequest
+type FetchResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+// stdinReader is shared so answers typed ahead are not lost between prompts
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// Prompt asks the user a question and returns the answer. External plugins do
+// not own the terminal, so the host asks on their behalf.
+func Prompt(conn Connection, message string) (string, error) {
+	if rc, ok := conn.(*remoteConnection); ok {
+		var answer string
+		err := rc.call(MethodPrompt, hostMessageParams{Message: message}, &answer)
+		return answer, err
+	}
+	return promptUser(message)
+}
+
+// Log shows a message to the user
+func Log(conn Connection, message string) {
+	if rc, ok := conn.(*remoteConnection); ok {
+		if err := rc.call(MethodLog, hostMessageParams{Message: message}, nil); err == nil {
+			return
+		}
+	}
+	fmt.Println(message)
+}
+
+// Fetch makes an HTTP request from the machine running vps-init. WASM plugins
+// need the network permission for it.
+func Fetch(conn Connection, req FetchRequest) (*FetchResponse, error) {
+	if rc, ok := conn.(*remoteConnection); ok {
+		var resp FetchResponse
+		if err := rc.call(MethodFetch, req, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+	return fetch(req)
+}
+
+func promptUser(message string) (string, error) {
+	fmt.Print(message)
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", err
+	}
+	return strings.TrimRight(answer, "\r\n"), nil
+}
+
+func fetch(req FetchRequest) (*FetchResponse, error) {
+	if !strings.HasPrefix(req.URL, "https://") && !strings.HasPrefix(req.URL, "http://") {
+		return nil, fmt.Errorf("unsupported URL '%s'; only http and https are allowed", req.URL)
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	httpReq, err := http.NewRequest(method, req.URL, strings.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: fetchTimeout}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxFetchBody))
+	if err != nil {
+		return nil, err
+	}
+	resp := &FetchResponse{Status: httpResp.StatusCode, Headers: map[string]string{}, Body: string(body)}
+	for k := range httpResp.Header {
+		resp.Headers[k] = httpResp.Header.Get(k)
+	}
+	return resp, nil
+}
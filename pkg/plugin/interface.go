@@ -45,7 +45,9 @@ type Dependency struct {
 	Tags     []string `json:"tags,omitempty"`
 }
 
-// Compatibility defines plugin compatibility requirements
+// Compatibility defines plugin compatibility requirements. Platforms are the
+// local platforms vps-init runs on, not the target; plugins that only act on
+// the target over SSH leave it empty.
 type Compatibility struct {
 	MinVPSInitVersion string   `json:"min_vps_init_version"`
 	MaxVPSInitVersion string   `json:"max_vps_init_version,omitempty"`
@@ -124,12 +126,12 @@ type PluginMetadata struct {
 
 // BuildInfo contains build-related plugin information
 type BuildInfo struct {
-	GoVersion    string   `json:"go_version,omitempty"`
-	BuildTime    string   `json:"build_time,omitempty"`
-	GitCommit    string   `json:"git_commit,omitempty"`
-	GitTag       string   `json:"git_tag,omitempty"`
-	BuildFlags   []string `json:"build_flags,omitempty"`
-	Dependencies []string `json:"dependencies,omitempty"`
+	GoVersion    string   `json:"go_version,omitempty" yaml:"go_version,omitempty"`
+	BuildTime    string   `json:"build_time,omitempty" yaml:"build_time,omitempty"`
+	GitCommit    string   `json:"git_commit,omitempty" yaml:"git_commit,omitempty"`
+	GitTag       string   `json:"git_tag,omitempty" yaml:"git_tag,omitempty"`
+	BuildFlags   []string `json:"build_flags,omitempty" yaml:"build_flags,omitempty"`
+	Dependencies []string `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
 }
 
 // Connection interface for SSH connections (enhanced with all methods from vps-init-ssh)
@@ -203,7 +205,7 @@ type Result struct {
 // GetError returns the error from the result
 func (r *Result) GetError() error {
 	if !r.Success && r.Error != "" {
-		return fmt.Errorf(r.Error)
+		return fmt.Errorf("%s", r.Error)
 	}
 	return nil
 }
@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+)
+
+type connectionKey struct{}
+
+// WithConnection returns a context carrying the target connection, so a
+// plugin's Start and Stop can reach the target the command runs against
+func WithConnection(ctx context.Context, conn Connection) context.Context {
+	return context.WithValue(ctx, connectionKey{}, conn)
+}
+
+// ConnectionFromContext returns the target connection set by WithConnection
+func ConnectionFromContext(ctx context.Context) (Connection, bool) {
+	conn, ok := ctx.Value(connectionKey{}).(Connection)
+	return conn, ok
+}
+
+// WithLifecycle wraps a command handler in the plugin lifecycle:
+//
+//	Initialize(config) -> Validate() -> Start(ctx) -> handler -> Stop(ctx)
+//
+// Start and Stop receive a context carrying the target connection (see
+// ConnectionFromContext). Once Start has been called, Stop always runs, even
+// if Start or the handler fails, panics or the context is cancelled, so
+// plugins can release sessions and remove temporary files there.
+func WithLifecycle(p Plugin, config map[string]interface{}, handler CommandHandler) CommandHandler {
+	return func(ctx context.Context, conn Connection, args []string, flags map[string]interface{}) (err error) {
+		if err := p.Initialize(config); err != nil {
+			return fmt.Errorf("failed to initialize plugin %s: %w", p.Name(), err)
+		}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("plugin %s is not valid: %w", p.Name(), err)
+		}
+
+		ctx = WithConnection(ctx, conn)
+		defer func() {
+			// Stop must be able to clean up after a timeout, so it does not
+			// inherit the command's cancellation
+			if stopErr := p.Stop(context.WithoutCancel(ctx)); stopErr != nil && err == nil {
+				err = fmt.Errorf("failed to stop plugin %s: %w", p.Name(), stopErr)
+			}
+		}()
+
+		if err := p.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start plugin %s: %w", p.Name(), err)
+		}
+		return handler(ctx, conn, args, flags)
+	}
+}
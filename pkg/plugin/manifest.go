@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile is the name of the manifest in a plugin's source tree and install directory
+const ManifestFile = "vps-init-plugin.yaml"
+
+// Plugin runtimes
+const (
+	RuntimeExec = "exec" // a native executable
+	RuntimeWasm = "wasm" // a WebAssembly module run in the sandbox
+)
+
+// pluginNamePattern restricts plugin names to safe directory names
+var pluginNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ValidPluginName reports whether name is safe to use as a plugin directory
+func ValidPluginName(name string) bool {
+	return pluginNamePattern.MatchString(name)
+}
+
+// Manifest describes an external plugin. Authors provide the descriptive fields
+// and how to build the executable; the install fields are filled in by
+// "vps-init plugin install".
+type Manifest struct {
+	Name        string   `yaml:"name"`
+	Version     string   `yaml:"version"`
+	Description string   `yaml:"description,omitempty"`
+	Author      string   `yaml:"author,omitempty"`
+	License     string   `yaml:"license,omitempty"`
+	Homepage    string   `yaml:"homepage,omitempty"`
+	Repository  string   `yaml:"repository,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+
+	// Executable is the plugin binary or WASM module, relative to the manifest
+	Executable string `yaml:"executable"`
+	// Build is an optional shell command run in the source tree before install
+	Build string `yaml:"build,omitempty"`
+	// Runtime is "exec" or "wasm"; executables ending in .wasm default to "wasm"
+	Runtime string `yaml:"runtime,omitempty"`
+	// Permissions is the local access a WASM plugin asks for
+	Permissions Permissions `yaml:"permissions,omitempty"`
+	// Commands is recorded at install time from the plugin itself, so an
+	// untrusted plugin can be listed without starting it
+	Commands []CommandSpec `yaml:"commands,omitempty"`
+
+	// Installation information
+	InstallPath string    `yaml:"install_path,omitempty"`
+	InstalledAt string    `yaml:"installed_at,omitempty"`
+	LastUpdated string    `yaml:"last_updated,omitempty"`
+	Checksum    string    `yaml:"checksum,omitempty"`
+	Source      string    `yaml:"source,omitempty"`
+	BuildInfo   BuildInfo `yaml:"build_info,omitempty"`
+	// Granted is the local access the user approved at install time
+	Granted *Permissions `yaml:"granted_permissions,omitempty"`
+
+	// Signature is the publisher's ed25519 signature over the name, version
+	// and checksum, created with "vps-init plugin sign"
+	Signature string `yaml:"signature,omitempty"`
+}
+
+// LoadManifest reads and validates a manifest file
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if !pluginNamePattern.MatchString(m.Name) {
+		return nil, fmt.Errorf("%s: invalid plugin name '%s' (use lowercase letters, digits and dashes)", path, m.Name)
+	}
+	if m.Executable == "" {
+		return nil, fmt.Errorf("%s: executable is required", path)
+	}
+	if filepath.IsAbs(m.Executable) || strings.HasPrefix(filepath.Clean(m.Executable), "..") {
+		return nil, fmt.Errorf("%s: executable must be a path inside the plugin directory", path)
+	}
+	if m.Runtime != "" && m.Runtime != RuntimeExec && m.Runtime != RuntimeWasm {
+		return nil, fmt.Errorf("%s: unknown runtime '%s' (use '%s' or '%s')", path, m.Runtime, RuntimeExec, RuntimeWasm)
+	}
+	if !m.IsWasm() && !m.Permissions.Empty() {
+		return nil, fmt.Errorf("%s: permissions only apply to the '%s' runtime", path, RuntimeWasm)
+	}
+	return &m, nil
+}
+
+// IsWasm reports whether the plugin runs in the WASM sandbox
+func (m *Manifest) IsWasm() bool {
+	if m.Runtime != "" {
+		return m.Runtime == RuntimeWasm
+	}
+	return strings.HasSuffix(m.Executable, ".wasm")
+}
+
+// Save writes the manifest to path
+func (m *Manifest) Save(path string) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ExecutablePath returns the absolute path of the installed executable
+func (m *Manifest) ExecutablePath() string {
+	return filepath.Join(m.InstallPath, filepath.Base(m.Executable))
+}
+
+// Verify checks the installed executable against the checksum recorded at install time
+func (m *Manifest) Verify() error {
+	if m.Checksum == "" {
+		return fmt.Errorf("plugin %s has no recorded checksum; reinstall it with 'vps-init plugin install'", m.Name)
+	}
+	sum, err := FileChecksum(m.ExecutablePath())
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", m.Name, err)
+	}
+	if sum != m.Checksum {
+		return fmt.Errorf("plugin %s: checksum mismatch, the executable changed since it was installed (expected %s, got %s); reinstall it with 'vps-init plugin update %s'", m.Name, m.Checksum, sum, m.Name)
+	}
+	return nil
+}
+
+// Metadata merges the manifest into plugin metadata
+func (m *Manifest) Metadata(base PluginMetadata) PluginMetadata {
+	base.InstallPath = m.InstallPath
+	base.InstalledAt = m.InstalledAt
+	base.LastUpdated = m.LastUpdated
+	base.Checksum = m.Checksum
+	base.Source = m.Source
+	base.BuildInfo = m.BuildInfo
+	base.Signature = m.Signature
+	if base.License == "" {
+		base.License = m.License
+	}
+	if base.Homepage == "" {
+		base.Homepage = m.Homepage
+	}
+	if base.Repository == "" {
+		base.Repository = m.Repository
+	}
+	if len(base.Tags) == 0 {
+		base.Tags = m.Tags
+	}
+	return base
+}
+
+// FileChecksum returns the sha256 checksum of a file as "sha256:<hex>"
+func FileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
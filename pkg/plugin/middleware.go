@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"sync"
+)
+
+// Invocation describes a plugin command about to run
+type Invocation struct {
+	Plugin  Plugin
+	Command string
+	Target  string // the target as given on the command line (alias or user@host)
+	Args    []string
+}
+
+// Middleware wraps command handlers. A middleware can run code before and
+// after the command, inspect the error it returned, or refuse to run it by
+// returning an error without calling next.
+type Middleware interface {
+	Wrap(inv Invocation, next CommandHandler) CommandHandler
+}
+
+// MiddlewareFunc adapts a function to the Middleware interface
+type MiddlewareFunc func(inv Invocation, next CommandHandler) CommandHandler
+
+// Wrap calls f(inv, next)
+func (f MiddlewareFunc) Wrap(inv Invocation, next CommandHandler) CommandHandler {
+	return f(inv, next)
+}
+
+// middlewares holds the registered middleware chain
+var middlewares = &struct {
+	sync.RWMutex
+	chain []Middleware
+}{}
+
+// Use registers a middleware around every plugin command. Middlewares
+// registered first run outermost.
+func Use(m Middleware) {
+	middlewares.Lock()
+	defer middlewares.Unlock()
+	middlewares.chain = append(middlewares.chain, m)
+}
+
+// Chain wraps handler in every registered middleware
+func Chain(inv Invocation, handler CommandHandler) CommandHandler {
+	middlewares.RLock()
+	defer middlewares.RUnlock()
+
+	for i := len(middlewares.chain) - 1; i >= 0; i-- {
+		handler = middlewares.chain[i].Wrap(inv, handler)
+	}
+	return handler
+}
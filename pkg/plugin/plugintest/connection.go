@@ -0,0 +1,307 @@
+// Package plugintest provides a fake plugin.Connection for testing command
+// handlers without a server.
+package plugintest
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// Connection is an in-memory plugin.Connection. It records every command,
+// keeps written files in memory and answers commands with canned results.
+// Commands without a canned result succeed with no output.
+type Connection struct {
+	mu        sync.Mutex
+	commands  []string
+	responses []response
+	files     map[string]string
+	dirs      map[string]bool
+
+	// Distro is returned by GetDistroInfo; it defaults to Ubuntu 22.04
+	Distro *distro.DistroInfo
+	// Packages records the packages installed with InstallPackage
+	Packages []string
+	// Services records systemctl calls as "action service"
+	Services []string
+}
+
+type response struct {
+	match  string
+	result plugin.Result
+}
+
+// NewConnection returns an empty fake connection to an Ubuntu server
+func NewConnection() *Connection {
+	return &Connection{
+		files: make(map[string]string),
+		dirs:  make(map[string]bool),
+		Distro: &distro.DistroInfo{
+			ID:         "ubuntu",
+			Name:       "Ubuntu",
+			VersionID:  "22.04",
+			Family:     distro.DistroFamilyDebian,
+			PackageMgr: distro.PackageManagerAPT,
+			ServiceMgr: distro.ServiceManagerSystemd,
+		},
+	}
+}
+
+// Respond makes commands containing match return result. Later responses
+// take precedence over earlier ones.
+func (c *Connection) Respond(match string, result plugin.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses = append(c.responses, response{match: match, result: result})
+}
+
+// RespondOutput makes commands containing match succeed with stdout
+func (c *Connection) RespondOutput(match, stdout string) {
+	c.Respond(match, plugin.Result{Success: true, Output: stdout, Stdout: stdout})
+}
+
+// Fail makes commands containing match fail with stderr
+func (c *Connection) Fail(match, stderr string) {
+	c.Respond(match, plugin.Result{Success: false, Error: stderr, Stderr: stderr, ExitCode: 1})
+}
+
+// Commands returns the commands run so far, in order
+func (c *Connection) Commands() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.commands...)
+}
+
+// Ran reports whether any command run so far contains substr
+func (c *Connection) Ran(substr string) bool {
+	for _, cmd := range c.Commands() {
+		if strings.Contains(cmd, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// File returns the content of a file written on the fake server
+func (c *Connection) File(path string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.files[path]
+	return content, ok
+}
+
+// SetFile puts a file on the fake server
+func (c *Connection) SetFile(path, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] = content
+}
+
+// Files returns the paths of all files on the fake server, sorted
+func (c *Connection) Files() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	paths := make([]string, 0, len(c.files))
+	for path := range c.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (c *Connection) run(cmd string) plugin.Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands = append(c.commands, cmd)
+	for i := len(c.responses) - 1; i >= 0; i-- {
+		if strings.Contains(cmd, c.responses[i].match) {
+			return c.responses[i].result
+		}
+	}
+	return plugin.Result{Success: true}
+}
+
+func (c *Connection) RunCommand(cmd string, sudo bool) plugin.Result {
+	return c.run(cmd)
+}
+
+func (c *Connection) RunCommandWithOutput(cmd string, sudo bool) (string, error) {
+	result := c.run(cmd)
+	if !result.Success {
+		return result.Stdout, fmt.Errorf("%s", result.Stderr)
+	}
+	return result.Stdout, nil
+}
+
+func (c *Connection) RunSudo(cmd, password string) plugin.Result {
+	return c.run(cmd)
+}
+
+func (c *Connection) RunInteractive(cmd string) error {
+	result := c.run(cmd)
+	return result.GetError()
+}
+
+func (c *Connection) Shell() error {
+	return nil
+}
+
+func (c *Connection) UploadFile(localPath, remotePath string) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	c.SetFile(remotePath, string(data))
+	return nil
+}
+
+func (c *Connection) DownloadFile(remotePath, localPath string) error {
+	content, ok := c.File(remotePath)
+	if !ok {
+		return fmt.Errorf("%s: no such file", remotePath)
+	}
+	return os.WriteFile(localPath, []byte(content), 0644)
+}
+
+func (c *Connection) WriteFile(content, path string) error {
+	c.SetFile(path, content)
+	return nil
+}
+
+func (c *Connection) WriteFileFromLocal(localPath, remotePath string) error {
+	return c.UploadFile(localPath, remotePath)
+}
+
+func (c *Connection) AppendFile(content, path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] += content
+	return nil
+}
+
+func (c *Connection) CopyFile(src, dst string) error {
+	content, ok := c.File(src)
+	if !ok {
+		return fmt.Errorf("%s: no such file", src)
+	}
+	c.SetFile(dst, content)
+	return nil
+}
+
+func (c *Connection) MoveFile(src, dst string) error {
+	if err := c.CopyFile(src, dst); err != nil {
+		return err
+	}
+	return c.DeleteFile(src)
+}
+
+func (c *Connection) DeleteFile(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.files, path)
+	return nil
+}
+
+func (c *Connection) CreateDirectory(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirs[path] = true
+	return nil
+}
+
+func (c *Connection) RemoveDirectory(path string, recursive bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.dirs, path)
+	if recursive {
+		prefix := strings.TrimSuffix(path, "/") + "/"
+		for file := range c.files {
+			if strings.HasPrefix(file, prefix) {
+				delete(c.files, file)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Connection) ListDirectory(path string) plugin.Result {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	var names []string
+	for _, file := range c.Files() {
+		if rest, ok := strings.CutPrefix(file, prefix); ok && !strings.Contains(rest, "/") {
+			names = append(names, rest)
+		}
+	}
+	out := strings.Join(names, "\n")
+	return plugin.Result{Success: true, Output: out, Stdout: out}
+}
+
+func (c *Connection) GetFileInfo(path string) plugin.FileInfo {
+	content, _ := c.File(path)
+	return plugin.FileInfo{Name: path, Size: int64(len(content)), Permissions: "644"}
+}
+
+func (c *Connection) ChangePermissions(path, permissions string) error {
+	c.run(fmt.Sprintf("chmod %s %s", permissions, path))
+	return nil
+}
+
+func (c *Connection) ChangeOwner(path, user, group string) error {
+	c.run(fmt.Sprintf("chown %s:%s %s", user, group, path))
+	return nil
+}
+
+func (c *Connection) FileExists(path string) bool {
+	_, ok := c.File(path)
+	return ok
+}
+
+func (c *Connection) DirectoryExists(path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dirs[path]
+}
+
+func (c *Connection) Systemctl(action, service string) bool {
+	c.mu.Lock()
+	c.Services = append(c.Services, action+" "+service)
+	c.mu.Unlock()
+	return c.run(fmt.Sprintf("systemctl %s %s", action, service)).Success
+}
+
+func (c *Connection) InstallPackage(packageName string) bool {
+	c.mu.Lock()
+	c.Packages = append(c.Packages, packageName)
+	c.mu.Unlock()
+	return c.run("install " + packageName).Success
+}
+
+func (c *Connection) GetDistroInfo() interface{} {
+	return c.Distro
+}
+
+func (c *Connection) IsUbuntu() bool { return c.Distro.ID == "ubuntu" }
+func (c *Connection) IsDebian() bool { return c.Distro.ID == "debian" }
+func (c *Connection) IsCentOS() bool { return c.Distro.ID == "centos" }
+func (c *Connection) IsRedHat() bool { return c.Distro.ID == "rhel" }
+
+func (c *Connection) Connect() bool    { return true }
+func (c *Connection) Disconnect()      {}
+func (c *Connection) Reconnect() error { return nil }
+func (c *Connection) IsHealthy() bool  { return true }
+func (c *Connection) Close() error     { return nil }
+
+func (c *Connection) GetConnectionStats() *plugin.ConnectionStats {
+	return &plugin.ConnectionStats{}
+}
+
+func (c *Connection) User() string { return "root" }
+func (c *Connection) Host() string { return "test.example.com" }
+func (c *Connection) Port() int    { return 22 }
+
+var _ plugin.Connection = (*Connection)(nil)
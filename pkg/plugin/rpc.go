@@ -0,0 +1,261 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ProtocolVersion is the version of the external plugin protocol spoken by this
+// build. A plugin reporting a different version is refused at load time.
+//
+// The protocol is JSON-RPC 2.0 with one message per line on the plugin's
+// stdin/stdout. The host calls "initialize", "execute" and "shutdown"; while
+// handling "execute" the plugin calls back into the host with "conn.*"
+// methods, which are served by the host's Connection to the target.
+const ProtocolVersion = 1
+
+// HostSudoPassword is passed to external plugins as the sudo-password flag when
+// the host has a sudo password. The real password never leaves the host:
+// conn.run_sudo calls are executed with the host's password instead.
+const HostSudoPassword = "@host"
+
+// RPC method names
+const (
+	MethodInitialize = "initialize"
+	MethodExecute    = "execute"
+	MethodShutdown   = "shutdown"
+)
+
+// Host functions a plugin may call while handling "execute", besides the
+// "conn.*" methods
+const (
+	MethodPrompt = "host.prompt"
+	MethodLog    = "host.log"
+	MethodFetch  = "host.fetch"
+)
+
+// RPCError is a JSON-RPC error object
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// JSON-RPC error codes
+const (
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+
+	// ErrCodePermissionDenied is returned for host functions the WASM sandbox does not grant
+	ErrCodePermissionDenied = -32001
+)
+
+// rpcMessage is a JSON-RPC request, notification or response
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// InitializeParams is sent by the host when a plugin is started
+type InitializeParams struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	HostVersion     string `json:"host_version"`
+}
+
+// InitializeResult describes the plugin to the host
+type InitializeResult struct {
+	ProtocolVersion int            `json:"protocol_version"`
+	Metadata        PluginMetadata `json:"metadata"`
+	Commands        []CommandSpec  `json:"commands"`
+	Dependencies    []Dependency   `json:"dependencies,omitempty"`
+	Compatibility   Compatibility  `json:"compatibility"`
+}
+
+// CommandSpec is the wire form of a Command
+type CommandSpec struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description" yaml:"description"`
+	Aliases     []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+}
+
+// ExecuteParams asks the plugin to run one of its commands
+type ExecuteParams struct {
+	Command string                 `json:"command"`
+	Args    []string               `json:"args"`
+	Flags   map[string]interface{} `json:"flags"`
+	Target  TargetInfo             `json:"target"`
+}
+
+// TargetInfo identifies the server a command runs against
+type TargetInfo struct {
+	User string `json:"user"`
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// Connection callback parameters, sent by the plugin during "execute"
+type (
+	connCommandParams struct {
+		Cmd  string `json:"cmd"`
+		Sudo bool   `json:"sudo,omitempty"`
+	}
+	connFileParams struct {
+		Path      string `json:"path,omitempty"`
+		Content   string `json:"content,omitempty"`
+		Src       string `json:"src,omitempty"`
+		Dst       string `json:"dst,omitempty"`
+		Mode      string `json:"mode,omitempty"`
+		User      string `json:"user,omitempty"`
+		Group     string `json:"group,omitempty"`
+		Recursive bool   `json:"recursive,omitempty"`
+	}
+	connServiceParams struct {
+		Action  string `json:"action,omitempty"`
+		Service string `json:"service,omitempty"`
+		Package string `json:"package,omitempty"`
+	}
+	hostMessageParams struct {
+		Message string `json:"message"`
+	}
+)
+
+// rpcConn reads and writes newline-delimited JSON-RPC messages
+type rpcConn struct {
+	in     *bufio.Reader
+	out    io.Writer
+	mu     sync.Mutex
+	nextID int64
+}
+
+func newRPCConn(in io.Reader, out io.Writer) *rpcConn {
+	return &rpcConn{in: bufio.NewReader(in), out: out}
+}
+
+func (c *rpcConn) write(msg *rpcMessage) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.out.Write(append(data, '\n'))
+	return err
+}
+
+func (c *rpcConn) read() (*rpcMessage, error) {
+	line, err := c.in.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return nil, fmt.Errorf("malformed message from peer: %w", err)
+	}
+	return &msg, nil
+}
+
+// rpcHandler serves requests from the peer while a call is outstanding
+type rpcHandler func(method string, params json.RawMessage) (interface{}, error)
+
+// call sends a request and waits for its response. Requests arriving from the
+// peer in the meantime are passed to handler and answered.
+func (c *rpcConn) call(method string, params, result interface{}, handler rpcHandler) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	c.nextID++
+	id := c.nextID
+	if err := c.write(&rpcMessage{ID: &id, Method: method, Params: raw}); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+
+		if msg.Method != "" {
+			if err := c.serve(msg, handler); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.ID == nil || *msg.ID != id {
+			continue
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil && len(msg.Result) > 0 {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	}
+}
+
+// notify sends a request that expects no response
+func (c *rpcConn) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&rpcMessage{Method: method, Params: raw})
+}
+
+// serve answers a single request from the peer
+func (c *rpcConn) serve(msg *rpcMessage, handler rpcHandler) error {
+	var result interface{}
+	var rpcErr *RPCError
+
+	if handler == nil {
+		rpcErr = &RPCError{Code: ErrCodeMethodNotFound, Message: "unexpected request: " + msg.Method}
+	} else if res, err := handler(msg.Method, msg.Params); err != nil {
+		if e, ok := err.(*RPCError); ok {
+			rpcErr = e
+		} else {
+			rpcErr = &RPCError{Code: ErrCodeInternal, Message: err.Error()}
+		}
+	} else {
+		result = res
+	}
+
+	// Notifications get no response
+	if msg.ID == nil {
+		return nil
+	}
+
+	resp := &rpcMessage{ID: msg.ID, Error: rpcErr}
+	if rpcErr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resp.Result = raw
+	}
+	return c.write(resp)
+}
+
+// decodeParams unmarshals request params, reporting failures as invalid params
+func decodeParams(raw json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &RPCError{Code: ErrCodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,529 @@
+// Package sdk holds the helpers plugin command handlers share: flag access,
+// running commands with sudo, installing packages, writing privileged files
+// and managing services under systemd, OpenRC or SysV init, with consistent
+// output for each step.
+//
+// A handler creates a Context and uses it in place of the raw connection:
+//
+//	func (p *Plugin) install(ctx context.Context, conn plugin.Connection, args []string, flags map[string]interface{}) error {
+//		c := sdk.New(ctx, conn, flags)
+//		if err := c.Install("redis-server"); err != nil {
+//			return err
+//		}
+//		return c.EnableService("redis-server")
+//	}
+package sdk
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wasilwamark/vps-init/internal/distro"
+	"github.com/wasilwamark/vps-init/internal/pkgmgr"
+	"github.com/wasilwamark/vps-init/internal/svcmgr"
+	"github.com/wasilwamark/vps-init/pkg/plugin"
+)
+
+// BackupSuffix is appended to a file's path for the copy WriteFile keeps of
+// the previous version
+const BackupSuffix = ".vps-init.bak"
+
+// Context wraps the connection and flags a command handler receives
+type Context struct {
+	context.Context
+	Conn  plugin.Connection
+	Flags map[string]interface{}
+
+	distro  *distro.DistroInfo
+	pkgMgr  pkgmgr.PackageManager
+	svcMgr  svcmgr.ServiceManager
+	updated bool
+}
+
+// New returns a Context for one command invocation
+func New(ctx context.Context, conn plugin.Connection, flags map[string]interface{}) *Context {
+	if flags == nil {
+		flags = map[string]interface{}{}
+	}
+	return &Context{Context: ctx, Conn: conn, Flags: flags}
+}
+
+// String returns a string flag, or "" if it is not set
+func (c *Context) String(name string) string {
+	switch v := c.Flags[name].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Bool returns a boolean flag, or def if it is not set. "true"/"false"
+// strings are accepted too.
+func (c *Context) Bool(name string, def bool) bool {
+	switch v := c.Flags[name].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// Int returns an integer flag, or def if it is not set or not a number.
+// Flags that went through JSON arrive as float64 and are accepted too.
+func (c *Context) Int(name string, def int) int {
+	switch v := c.Flags[name].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// ParseOptions splits "--name value" options and the given "--name" switches
+// from a command's arguments, which is how plugin flags reach handlers.
+// Switches are set to "true".
+func ParseOptions(args []string, switches ...string) ([]string, map[string]string) {
+	var positional []string
+	options := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		name, ok := strings.CutPrefix(args[i], "--")
+		switch {
+		case ok && slices.Contains(switches, name):
+			options[name] = "true"
+		case ok && i+1 < len(args):
+			options[name] = args[i+1]
+			i++
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+	return positional, options
+}
+
+// SudoPassword returns the sudo password from the "sudo-password" flag
+func (c *Context) SudoPassword() string {
+	return c.String("sudo-password")
+}
+
+// Run runs a command as the connected user
+func (c *Context) Run(cmd string) plugin.Result {
+	return c.Conn.RunCommand(cmd, false)
+}
+
+// Sudo runs a command as root. Root logins without a sudo password run the
+// command directly.
+func (c *Context) Sudo(cmd string) plugin.Result {
+	pass := c.SudoPassword()
+	if pass == "" && c.Conn.User() == "root" {
+		return c.Conn.RunCommand(cmd, false)
+	}
+	return c.Conn.RunSudo(cmd, pass)
+}
+
+// Exec logs and runs a command as root, returning its stderr as the error
+// if it fails
+func (c *Context) Exec(cmd string) error {
+	fmt.Printf("⚡ Executing: %s\n", cmd)
+	return resultError(c.Sudo(cmd))
+}
+
+// Shell runs a shell script as root with sh -c, so pipes, redirects and
+// && apply to the whole script rather than its first command
+func (c *Context) Shell(script string) plugin.Result {
+	return c.Sudo("sh -c " + Quote(script))
+}
+
+// Distro returns the target's distribution. Over the external plugin
+// protocol the distro arrives as a map and is decoded here. A distribution
+// that can't be identified has no package manager, so package operations
+// fail with distro.ErrUnsupported.
+func (c *Context) Distro() *distro.DistroInfo {
+	if c.distro != nil {
+		return c.distro
+	}
+
+	switch info := c.Conn.GetDistroInfo().(type) {
+	case *distro.DistroInfo:
+		c.distro = info
+	case map[string]interface{}:
+		var decoded distro.DistroInfo
+		if data, err := json.Marshal(info); err == nil && json.Unmarshal(data, &decoded) == nil {
+			c.distro = &decoded
+		}
+	}
+	if c.distro == nil {
+		c.distro = &distro.DistroInfo{
+			ID:         "unknown",
+			Name:       "Unknown",
+			ServiceMgr: distro.ServiceManagerSystemd,
+		}
+	}
+	return c.distro
+}
+
+// PackageManager returns the package manager for the target's distribution
+func (c *Context) PackageManager() pkgmgr.PackageManager {
+	if c.pkgMgr == nil {
+		info := c.Distro()
+		c.pkgMgr = pkgmgr.GetPackageManager(info)
+		c.Info("Detected Distribution: %s %s", info.Name, info.Version)
+		fmt.Printf("📦 Using Package Manager: %s\n", info.PackageMgr)
+	}
+	return c.pkgMgr
+}
+
+// UpdatePackages refreshes the package lists. Install calls it once per
+// Context before the first install.
+func (c *Context) UpdatePackages() error {
+	updateCmd, err := c.PackageManager().Update()
+	if err != nil {
+		return err
+	}
+	if err := c.Exec(updateCmd); err != nil {
+		return fmt.Errorf("failed to update package lists: %w", err)
+	}
+	c.updated = true
+	return nil
+}
+
+// Query runs a read-only command as the connected user and returns its
+// stdout. It is the pkgmgr.Runner for package queries.
+func (c *Context) Query(cmd string) (string, error) {
+	result := c.Run(cmd)
+	if !result.Success {
+		return result.Stdout, resultError(result)
+	}
+	return result.Stdout, nil
+}
+
+// IsInstalled reports whether a package is installed. A logical name such as
+// "certbot-nginx" is installed when all of its packages are.
+func (c *Context) IsInstalled(pkg string) bool {
+	for _, name := range pkgmgr.Resolve(c.Distro(), pkg).Packages {
+		if !c.packageInstalled(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Context) packageInstalled(name string) bool {
+	installed, err := c.PackageManager().IsInstalled(c.Query, name)
+	return err == nil && installed
+}
+
+// InstalledVersion returns the installed version of a package, or "" if it
+// is not installed
+func (c *Context) InstalledVersion(pkg string) string {
+	version, _ := c.PackageManager().InstalledVersion(c.Query, pkg)
+	return version
+}
+
+// Upgradable lists the installed packages that have a newer version
+func (c *Context) Upgradable() ([]pkgmgr.Upgrade, error) {
+	return c.PackageManager().ListUpgradable(c.Query)
+}
+
+// Install installs packages with the target's package manager. Logical names
+// such as "certbot-nginx" are mapped to the distribution's packages with
+// pkgmgr.Resolve, and the repositories they need, such as EPEL, are enabled
+// first. Packages that are already installed are skipped, and nothing runs if
+// all of them are.
+func (c *Context) Install(packages ...string) error {
+	res := pkgmgr.Resolve(c.Distro(), packages...)
+	var missing []string
+	for _, pkg := range res.Packages {
+		if !c.packageInstalled(pkg) {
+			missing = append(missing, pkg)
+		}
+	}
+	if len(missing) == 0 {
+		c.Info("%s already installed", strings.Join(packages, ", "))
+		return nil
+	}
+	packages = missing
+
+	for _, repo := range res.Repos {
+		if err := c.EnableRepository(repo); err != nil {
+			return err
+		}
+	}
+	if !c.updated {
+		if err := c.UpdatePackages(); err != nil {
+			return err
+		}
+	}
+
+	installCmd, err := c.PackageManager().Install(packages...)
+	if err != nil {
+		return err
+	}
+	c.Step("Installing %s", strings.Join(packages, ", "))
+	if err := c.Exec(installCmd); err != nil {
+		return fmt.Errorf("failed to install %s: %w", strings.Join(packages, ", "), err)
+	}
+	return nil
+}
+
+// Remove uninstalls packages with the target's package manager, mapping
+// logical names like Install
+func (c *Context) Remove(packages ...string) error {
+	packages = pkgmgr.Resolve(c.Distro(), packages...).Packages
+	removeCmd, err := c.PackageManager().Remove(packages...)
+	if err != nil {
+		return err
+	}
+	c.Step("Removing %s", strings.Join(packages, ", "))
+	if err := c.Exec(removeCmd); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", strings.Join(packages, ", "), err)
+	}
+	return nil
+}
+
+// AddRepository adds a third-party package repository after verifying its
+// signing key against the pinned fingerprint. The package lists are
+// refreshed before the next Install.
+func (c *Context) AddRepository(repo pkgmgr.Repository) error {
+	script, err := c.PackageManager().AddRepository(repo)
+	if err != nil {
+		return err
+	}
+
+	var tools []string
+	for cmd, pkg := range c.PackageManager().RepositoryTools() {
+		if !c.Run("command -v " + cmd).Success {
+			tools = append(tools, pkg)
+		}
+	}
+	if len(tools) > 0 {
+		sort.Strings(tools)
+		if err := c.Install(tools...); err != nil {
+			return err
+		}
+	}
+
+	c.Step("Adding repository %s", repo.Name)
+	if err := resultError(c.Shell(script)); err != nil {
+		return fmt.Errorf("failed to add repository %s: %w", repo.Name, err)
+	}
+	c.updated = false
+	return nil
+}
+
+// EnableRepository enables a distribution repository that packages depend
+// on, such as pkgmgr.RepoEPEL. Nothing runs if it is already enabled.
+func (c *Context) EnableRepository(name string) error {
+	repo, err := pkgmgr.Prerequisite(c.Distro(), name)
+	if err != nil {
+		return err
+	}
+	if c.Run(repo.Check).Success {
+		return nil
+	}
+
+	c.Step("Enabling %s repository", strings.ToUpper(name))
+	for _, cmd := range repo.Enable {
+		if err := c.Exec(cmd); err != nil {
+			return fmt.Errorf("failed to enable %s: %w", name, err)
+		}
+	}
+	c.updated = false
+	return nil
+}
+
+// RemoveRepository removes a repository added with AddRepository
+func (c *Context) RemoveRepository(name string) error {
+	script, err := c.PackageManager().RemoveRepository(name)
+	if err != nil {
+		return err
+	}
+	c.Step("Removing repository %s", name)
+	if err := resultError(c.Shell(script)); err != nil {
+		return fmt.Errorf("failed to remove repository %s: %w", name, err)
+	}
+	c.updated = false
+	return nil
+}
+
+// Repositories lists the package repositories configured on the target
+func (c *Context) Repositories() ([]pkgmgr.Repository, error) {
+	return c.PackageManager().ListRepositories(c.Query)
+}
+
+// WriteFile writes a root-owned file atomically. The content is sent base64
+// encoded, decoded into a temporary file, installed next to the destination
+// and renamed over it, so readers never see a partial file. The previous
+// version, if any, is kept at path+BackupSuffix for RestoreBackup.
+func (c *Context) WriteFile(filePath, content string, mode os.FileMode) error {
+	dst := Quote(filePath)
+	backup := Quote(filePath + BackupSuffix)
+	next := Quote(filePath + ".vps-init.new")
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+	script := fmt.Sprintf(`set -e; tmp=$(mktemp); trap 'rm -f "$tmp"' EXIT; echo %s | base64 -d > "$tmp"; mkdir -p %s; if [ -f %s ]; then cp -p %s %s; else rm -f %s; fi; install -m %04o "$tmp" %s; mv -f %s %s`,
+		encoded, Quote(path.Dir(filePath)), dst, dst, backup, backup, uint32(mode.Perm()), next, next, dst)
+
+	fmt.Printf("📝 Writing %s\n", filePath)
+	if err := resultError(c.Shell(script)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// RestoreBackup undoes the last WriteFile of a path: the previous version is
+// put back, or the file is removed if WriteFile created it
+func (c *Context) RestoreBackup(filePath string) error {
+	dst := Quote(filePath)
+	backup := Quote(filePath + BackupSuffix)
+	script := fmt.Sprintf("if [ -f %s ]; then mv -f %s %s; else rm -f %s; fi", backup, backup, dst, dst)
+
+	c.Step("Restoring previous %s", filePath)
+	if err := resultError(c.Shell(script)); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// ServiceManager returns the service manager for the target's init system
+func (c *Context) ServiceManager() svcmgr.ServiceManager {
+	if c.svcMgr == nil {
+		c.svcMgr = svcmgr.GetServiceManager(c.Distro())
+	}
+	return c.svcMgr
+}
+
+// Service runs a service action such as start, stop, restart or reload
+func (c *Context) Service(action, name string) error {
+	cmd, err := svcmgr.Action(c.ServiceManager(), action, name)
+	if err != nil {
+		return err
+	}
+	if err := c.Exec(cmd); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", action, name, err)
+	}
+	return nil
+}
+
+// EnableService enables a service at boot and starts it now
+func (c *Context) EnableService(name string) error {
+	if err := c.Service("enable", name); err != nil {
+		return err
+	}
+	return c.Service("start", name)
+}
+
+// DisableService stops a service and keeps it from starting at boot
+func (c *Context) DisableService(name string) error {
+	if err := c.Service("stop", name); err != nil {
+		return err
+	}
+	return c.Service("disable", name)
+}
+
+// ServiceActive reports whether a service is running
+func (c *Context) ServiceActive(name string) bool {
+	cmd, err := c.ServiceManager().IsActive(name)
+	return err == nil && c.Run(cmd).Success
+}
+
+// ServiceEnabled reports whether a service starts at boot
+func (c *Context) ServiceEnabled(name string) bool {
+	cmd, err := c.ServiceManager().IsEnabled(name)
+	return err == nil && c.Run(cmd).Success
+}
+
+// ServiceStatus prints the init system's status report for a service. A
+// stopped service is reported, not treated as an error.
+func (c *Context) ServiceStatus(name string) error {
+	cmd, err := c.ServiceManager().Status(name)
+	if err != nil {
+		return err
+	}
+	result := c.Sudo(cmd)
+	if result.Stdout == "" {
+		return resultError(result)
+	}
+	fmt.Print(result.Stdout)
+	return nil
+}
+
+// ServiceLogs prints the last lines a service logged
+func (c *Context) ServiceLogs(name string, lines int) error {
+	cmd, err := c.ServiceManager().Logs(name, lines)
+	if err != nil {
+		return err
+	}
+	result := c.Sudo(cmd)
+	if !result.Success && result.Stdout == "" {
+		return fmt.Errorf("failed to read logs for %s: %w", name, resultError(result))
+	}
+	fmt.Print(result.Stdout)
+	return nil
+}
+
+// Publish publishes an event to subscribed plugins. Subscriber failures are
+// reported but do not fail the command that published the event.
+func (c *Context) Publish(event plugin.Event) {
+	if err := plugin.Publish(c, c.Conn, c.Flags, event); err != nil {
+		c.Warn("%v", err)
+	}
+}
+
+// Step reports the start of a step
+func (c *Context) Step(format string, args ...interface{}) {
+	fmt.Printf("⚙️  "+format+"...\n", args...)
+}
+
+// Success reports a step that completed
+func (c *Context) Success(format string, args ...interface{}) {
+	fmt.Printf("✅ "+format+"\n", args...)
+}
+
+// Warn reports a problem that does not stop the command
+func (c *Context) Warn(format string, args ...interface{}) {
+	fmt.Printf("⚠️  "+format+"\n", args...)
+}
+
+// Info reports something the user should know
+func (c *Context) Info(format string, args ...interface{}) {
+	fmt.Printf("ℹ️  "+format+"\n", args...)
+}
+
+// Quote single-quotes s for a POSIX shell
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// resultError turns a failed result into an error carrying its stderr
+func resultError(result plugin.Result) error {
+	if result.Success {
+		return nil
+	}
+	if msg := strings.TrimSpace(result.Stderr); msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+	if msg := strings.TrimSpace(result.Error); msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+	return fmt.Errorf("exit code %d", result.ExitCode)
+}
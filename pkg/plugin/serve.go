@@ -0,0 +1,280 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Serve runs p as an external plugin, speaking the plugin protocol on
+// stdin/stdout until the host sends "shutdown". Anything the plugin prints is
+// redirected to stderr, which vps-init shows to the user.
+//
+// An external plugin's main function is typically just:
+//
+//	func main() {
+//		if err := plugin.Serve(&MyPlugin{}); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//			os.Exit(1)
+//		}
+//	}
+func Serve(p Plugin) error {
+	protocolOut := os.Stdout
+	os.Stdout = os.Stderr
+	return ServeIO(p, os.Stdin, protocolOut)
+}
+
+// ServeIO is Serve over arbitrary streams
+func ServeIO(p Plugin, in io.Reader, out io.Writer) error {
+	rpc := newRPCConn(in, out)
+	config := map[string]interface{}{}
+
+	for {
+		msg, err := rpc.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Stop already ran at the end of each command's lifecycle
+		if msg.Method == MethodShutdown {
+			return nil
+		}
+
+		handler := func(method string, raw json.RawMessage) (interface{}, error) {
+			switch method {
+			case MethodInitialize:
+				return describe(p, raw, config)
+			case MethodExecute:
+				return nil, executeServed(p, rpc, raw, config)
+			}
+			return nil, &RPCError{Code: ErrCodeMethodNotFound, Message: "unknown method: " + method}
+		}
+		if err := rpc.serve(msg, handler); err != nil {
+			return err
+		}
+	}
+}
+
+// describe answers the initialize handshake, recording the host's details in config
+func describe(p Plugin, raw json.RawMessage, config map[string]interface{}) (interface{}, error) {
+	var params InitializeParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	config["host_version"] = params.HostVersion
+	if err := p.Initialize(config); err != nil {
+		return nil, err
+	}
+
+	result := InitializeResult{
+		ProtocolVersion: ProtocolVersion,
+		Metadata:        p.GetMetadata(),
+		Dependencies:    p.Dependencies(),
+		Compatibility:   p.Compatibility(),
+	}
+	for _, cmd := range p.GetCommands() {
+		result.Commands = append(result.Commands, CommandSpec{
+			Name:        cmd.Name,
+			Description: cmd.Description,
+			Aliases:     cmd.Aliases,
+		})
+	}
+	return result, nil
+}
+
+// executeServed runs a command handler through the plugin lifecycle, with a
+// connection proxied to the host
+func executeServed(p Plugin, rpc *rpcConn, raw json.RawMessage, config map[string]interface{}) error {
+	var params ExecuteParams
+	if err := decodeParams(raw, &params); err != nil {
+		return err
+	}
+
+	for _, cmd := range p.GetCommands() {
+		if cmd.Name != params.Command && !contains(cmd.Aliases, params.Command) {
+			continue
+		}
+		if params.Flags == nil {
+			params.Flags = map[string]interface{}{}
+		}
+		config["target"] = params.Target.Host
+		conn := &remoteConnection{rpc: rpc, target: params.Target}
+		handler := WithLifecycle(p, config, cmd.Handler)
+		return handler(context.Background(), conn, params.Args, params.Flags)
+	}
+	return fmt.Errorf("unknown command '%s'", params.Command)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteConnection is the Connection handed to external plugin handlers. Every
+// call is forwarded to the host, which runs it on the real target connection.
+type remoteConnection struct {
+	rpc      *rpcConn
+	target   TargetInfo
+	platform *platformInfo
+}
+
+func (c *remoteConnection) call(method string, params, result interface{}) error {
+	return c.rpc.call(method, params, result, nil)
+}
+
+func (c *remoteConnection) RunCommand(cmd string, sudo bool) Result {
+	var result Result
+	if err := c.call("conn.run_command", connCommandParams{Cmd: cmd, Sudo: sudo}, &result); err != nil {
+		return Result{Success: false, Error: err.Error(), ExitCode: -1}
+	}
+	return result
+}
+
+func (c *remoteConnection) RunCommandWithOutput(cmd string, sudo bool) (string, error) {
+	result := c.RunCommand(cmd, sudo)
+	if !result.Success {
+		return result.Stdout, fmt.Errorf("command failed: %s", result.Stderr)
+	}
+	return result.Stdout, nil
+}
+
+// RunSudo runs cmd with the host's sudo password; the password argument is ignored
+func (c *remoteConnection) RunSudo(cmd, password string) Result {
+	var result Result
+	if err := c.call("conn.run_sudo", connCommandParams{Cmd: cmd}, &result); err != nil {
+		return Result{Success: false, Error: err.Error(), ExitCode: -1}
+	}
+	return result
+}
+
+func (c *remoteConnection) RunInteractive(cmd string) error {
+	return fmt.Errorf("interactive commands are not supported in external plugins")
+}
+
+func (c *remoteConnection) Shell() error {
+	return fmt.Errorf("interactive shells are not supported in external plugins")
+}
+
+func (c *remoteConnection) UploadFile(localPath, remotePath string) error {
+	return c.call("conn.upload_file", connFileParams{Src: localPath, Dst: remotePath}, nil)
+}
+
+func (c *remoteConnection) DownloadFile(remotePath, localPath string) error {
+	return c.call("conn.download_file", connFileParams{Src: remotePath, Dst: localPath}, nil)
+}
+
+func (c *remoteConnection) WriteFile(content, path string) error {
+	return c.call("conn.write_file", connFileParams{Content: content, Path: path}, nil)
+}
+
+func (c *remoteConnection) WriteFileFromLocal(localPath, remotePath string) error {
+	return c.UploadFile(localPath, remotePath)
+}
+
+func (c *remoteConnection) AppendFile(content, path string) error {
+	return c.call("conn.append_file", connFileParams{Content: content, Path: path}, nil)
+}
+
+func (c *remoteConnection) CopyFile(src, dst string) error {
+	return c.call("conn.copy_file", connFileParams{Src: src, Dst: dst}, nil)
+}
+
+func (c *remoteConnection) MoveFile(src, dst string) error {
+	return c.call("conn.move_file", connFileParams{Src: src, Dst: dst}, nil)
+}
+
+func (c *remoteConnection) DeleteFile(path string) error {
+	return c.call("conn.delete_file", connFileParams{Path: path}, nil)
+}
+
+func (c *remoteConnection) CreateDirectory(path string) error {
+	return c.call("conn.create_directory", connFileParams{Path: path}, nil)
+}
+
+func (c *remoteConnection) RemoveDirectory(path string, recursive bool) error {
+	return c.call("conn.remove_directory", connFileParams{Path: path, Recursive: recursive}, nil)
+}
+
+func (c *remoteConnection) ListDirectory(path string) Result {
+	var result Result
+	if err := c.call("conn.list_directory", connFileParams{Path: path}, &result); err != nil {
+		return Result{Success: false, Error: err.Error(), ExitCode: -1}
+	}
+	return result
+}
+
+func (c *remoteConnection) GetFileInfo(path string) FileInfo {
+	var info FileInfo
+	c.call("conn.file_info", connFileParams{Path: path}, &info)
+	return info
+}
+
+func (c *remoteConnection) ChangePermissions(path, permissions string) error {
+	return c.call("conn.change_permissions", connFileParams{Path: path, Mode: permissions}, nil)
+}
+
+func (c *remoteConnection) ChangeOwner(path, user, group string) error {
+	return c.call("conn.change_owner", connFileParams{Path: path, User: user, Group: group}, nil)
+}
+
+func (c *remoteConnection) FileExists(path string) bool {
+	var exists bool
+	c.call("conn.file_exists", connFileParams{Path: path}, &exists)
+	return exists
+}
+
+func (c *remoteConnection) DirectoryExists(path string) bool {
+	var exists bool
+	c.call("conn.directory_exists", connFileParams{Path: path}, &exists)
+	return exists
+}
+
+func (c *remoteConnection) Systemctl(action, service string) bool {
+	var ok bool
+	c.call("conn.systemctl", connServiceParams{Action: action, Service: service}, &ok)
+	return ok
+}
+
+func (c *remoteConnection) InstallPackage(packageName string) bool {
+	var ok bool
+	c.call("conn.install_package", connServiceParams{Package: packageName}, &ok)
+	return ok
+}
+
+func (c *remoteConnection) loadPlatform() *platformInfo {
+	if c.platform == nil {
+		c.platform = &platformInfo{}
+		c.call("conn.platform", struct{}{}, c.platform)
+	}
+	return c.platform
+}
+
+// GetDistroInfo returns the host's distro information decoded as a map
+// (ID, Family, PackageMgr, ServiceMgr, ...)
+func (c *remoteConnection) GetDistroInfo() interface{} { return c.loadPlatform().Distro }
+func (c *remoteConnection) IsUbuntu() bool             { return c.loadPlatform().Ubuntu }
+func (c *remoteConnection) IsDebian() bool             { return c.loadPlatform().Debian }
+func (c *remoteConnection) IsCentOS() bool             { return c.loadPlatform().CentOS }
+func (c *remoteConnection) IsRedHat() bool             { return c.loadPlatform().RedHat }
+
+// The host owns the SSH session, so connection management is a no-op here
+func (c *remoteConnection) Close() error                         { return nil }
+func (c *remoteConnection) Connect() bool                        { return true }
+func (c *remoteConnection) Disconnect()                          {}
+func (c *remoteConnection) Reconnect() error                     { return nil }
+func (c *remoteConnection) IsHealthy() bool                      { return true }
+func (c *remoteConnection) GetConnectionStats() *ConnectionStats { return nil }
+
+func (c *remoteConnection) User() string { return c.target.User }
+func (c *remoteConnection) Host() string { return c.target.Host }
+func (c *remoteConnection) Port() int    { return c.target.Port }
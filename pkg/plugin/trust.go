@@ -0,0 +1,235 @@
+package plugin
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Trust levels
+const (
+	TrustOfficial  = "official"
+	TrustCommunity = "community"
+	TrustVerified  = "verified"
+	TrustUntrusted = "untrusted"
+)
+
+// TrustLevels lists the valid trust levels, most trusted first
+var TrustLevels = []string{TrustOfficial, TrustVerified, TrustCommunity, TrustUntrusted}
+
+// Trust policies decide what happens with untrusted external plugins
+const (
+	PolicyRefuse  = "refuse"  // untrusted plugins are not loaded
+	PolicyConfirm = "confirm" // the user is asked before each run
+	PolicyAllow   = "allow"   // untrusted plugins run without asking
+)
+
+// TrustedKey is a publisher's ed25519 public key in the local trust store
+type TrustedKey struct {
+	Name    string `json:"name"`
+	Key     string `json:"key"` // ed25519:<base64 public key>
+	Level   string `json:"level"`
+	AddedAt string `json:"added_at"`
+}
+
+// ID returns the short key ID used in signatures
+func (k TrustedKey) ID() string {
+	pub, err := ParsePublicKey(k.Key)
+	if err != nil {
+		return ""
+	}
+	return KeyID(pub)
+}
+
+// TrustStore holds the trusted publisher keys and the policy for everything
+// else. Verification only uses this local file, never the network.
+type TrustStore struct {
+	path   string
+	Policy string       `json:"policy"`
+	Keys   []TrustedKey `json:"keys"`
+}
+
+// LoadTrustStore reads the trust store, returning an empty store with the
+// confirm policy if the file does not exist
+func LoadTrustStore(path string) (*TrustStore, error) {
+	ts := &TrustStore{path: path, Policy: PolicyConfirm}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, ts); err != nil {
+		return nil, fmt.Errorf("failed to parse trust store %s: %w", path, err)
+	}
+	if ts.Policy == "" {
+		ts.Policy = PolicyConfirm
+	}
+	return ts, nil
+}
+
+// Save writes the trust store
+func (ts *TrustStore) Save() error {
+	data, err := json.MarshalIndent(ts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ts.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(ts.path, data, 0600)
+}
+
+// Add trusts a publisher key at the given level, replacing a key with the same name
+func (ts *TrustStore) Add(name, key, level string) error {
+	if _, err := ParsePublicKey(key); err != nil {
+		return err
+	}
+	if level == "" {
+		level = TrustVerified
+	}
+	if level == TrustUntrusted || !contains(TrustLevels, level) {
+		return fmt.Errorf("invalid trust level '%s' (use official, verified or community)", level)
+	}
+
+	ts.Remove(name)
+	ts.Keys = append(ts.Keys, TrustedKey{
+		Name:    name,
+		Key:     key,
+		Level:   level,
+		AddedAt: time.Now().Format(time.RFC3339),
+	})
+	return nil
+}
+
+// Remove drops a key by name or key ID, reporting whether one was removed
+func (ts *TrustStore) Remove(nameOrID string) bool {
+	kept := ts.Keys[:0]
+	removed := false
+	for _, k := range ts.Keys {
+		if k.Name == nameOrID || k.ID() == nameOrID {
+			removed = true
+			continue
+		}
+		kept = append(kept, k)
+	}
+	ts.Keys = kept
+	return removed
+}
+
+// SetPolicy changes how untrusted plugins are handled
+func (ts *TrustStore) SetPolicy(policy string) error {
+	switch policy {
+	case PolicyRefuse, PolicyConfirm, PolicyAllow:
+		ts.Policy = policy
+		return nil
+	}
+	return fmt.Errorf("invalid policy '%s' (use refuse, confirm or allow)", policy)
+}
+
+// Verify checks a manifest's signature and returns the trust level it earns.
+// Unsigned plugins and plugins signed by unknown keys are untrusted; a
+// signature from a trusted key that does not match is an error, because it
+// means the plugin was tampered with.
+func (ts *TrustStore) Verify(m *Manifest) (string, error) {
+	if m.Signature == "" {
+		return TrustUntrusted, nil
+	}
+
+	keyID, sig, err := parseSignature(m.Signature)
+	if err != nil {
+		return "", fmt.Errorf("plugin %s: %w", m.Name, err)
+	}
+	for _, k := range ts.Keys {
+		if k.ID() != keyID {
+			continue
+		}
+		pub, err := ParsePublicKey(k.Key)
+		if err != nil {
+			return "", err
+		}
+		if !ed25519.Verify(pub, signedPayload(m), sig) {
+			return "", fmt.Errorf("plugin %s: signature by '%s' does not match the installed executable", m.Name, k.Name)
+		}
+		return k.Level, nil
+	}
+	return TrustUntrusted, nil
+}
+
+// signedPayload is what a publisher signs: the plugin identity and the
+// checksum of its executable
+func signedPayload(m *Manifest) []byte {
+	return []byte(fmt.Sprintf("vps-init-plugin:v1\n%s\n%s\n%s\n", m.Name, m.Version, m.Checksum))
+}
+
+// SignManifest signs a manifest whose Checksum is set with a private key
+func SignManifest(m *Manifest, priv ed25519.PrivateKey) {
+	pub := priv.Public().(ed25519.PublicKey)
+	sig := ed25519.Sign(priv, signedPayload(m))
+	m.Signature = fmt.Sprintf("ed25519:%s:%s", KeyID(pub), base64.StdEncoding.EncodeToString(sig))
+}
+
+// GenerateKey creates a signing key pair, encoded as "ed25519:<base64>"
+func GenerateKey() (publicKey, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return "ed25519:" + base64.StdEncoding.EncodeToString(pub),
+		"ed25519:" + base64.StdEncoding.EncodeToString(priv), nil
+}
+
+// ParsePublicKey decodes an "ed25519:<base64>" public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := decodeKey(s)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key, expected ed25519:<base64 of %d bytes>", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// ParsePrivateKey decodes an "ed25519:<base64>" private key
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	raw, err := decodeKey(strings.TrimSpace(s))
+	if err != nil || len(raw) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key, expected ed25519:<base64 of %d bytes>", ed25519.PrivateKeySize)
+	}
+	return ed25519.PrivateKey(raw), nil
+}
+
+// KeyID returns the first 16 hex characters of the sha256 of a public key
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func decodeKey(s string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(s, "ed25519:")
+	if !ok {
+		return nil, fmt.Errorf("missing ed25519: prefix")
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// parseSignature splits "ed25519:<key id>:<base64 signature>"
+func parseSignature(s string) (string, []byte, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || parts[0] != "ed25519" {
+		return "", nil, fmt.Errorf("invalid signature format, expected ed25519:<key id>:<base64>")
+	}
+	sig, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return "", nil, fmt.Errorf("invalid signature encoding")
+	}
+	return parts[1], sig, nil
+}
@@ -3,8 +3,8 @@ package plugin
 import (
 	"fmt"
 	"regexp"
-	"strings"
 	"runtime"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 )
@@ -66,6 +66,9 @@ func (v *Validator) ValidatePlugin(plugin Plugin) ValidationErrors {
 	// Validate plugin structure
 	errors = append(errors, v.validateStructure(plugin)...)
 
+	// Validate metadata fields (checksum, signature, trust level)
+	errors = append(errors, v.ValidatePluginMetadata(plugin.GetMetadata())...)
+
 	// Run plugin-specific validation
 	if err := plugin.Validate(); err != nil {
 		errors = append(errors, ValidationError{
@@ -214,7 +217,7 @@ func (v *Validator) validateCompatibility(plugin Plugin) ValidationErrors {
 			return errors
 		}
 
-		minVersion, err := semver.NewConstraint(compat.MinVPSInitVersion)
+		minVersion, err := minVersionConstraint(compat.MinVPSInitVersion)
 		if err != nil {
 			errors = append(errors, ValidationError{
 				Field:   "compatibility.min_vps_init_version",
@@ -223,10 +226,10 @@ func (v *Validator) validateCompatibility(plugin Plugin) ValidationErrors {
 			})
 		} else if !minVersion.Check(currentVersion) {
 			errors = append(errors, ValidationError{
-				Field:   "compatibility",
+				Field: "compatibility",
 				Message: fmt.Sprintf("plugin requires VPS-Init version %s, but current version is %s",
 					compat.MinVPSInitVersion, v.vpsInitVersion),
-				Code:    "INCOMPATIBLE_VPS_INIT_VERSION",
+				Code: "INCOMPATIBLE_VPS_INIT_VERSION",
 			})
 		}
 	}
@@ -247,10 +250,10 @@ func (v *Validator) validateCompatibility(plugin Plugin) ValidationErrors {
 			})
 		} else if !maxVersion.Check(currentVersion) {
 			errors = append(errors, ValidationError{
-				Field:   "compatibility",
+				Field: "compatibility",
 				Message: fmt.Sprintf("plugin requires VPS-Init version <= %s, but current version is %s",
 					compat.MaxVPSInitVersion, v.vpsInitVersion),
-				Code:    "INCOMPATIBLE_VPS_INIT_VERSION",
+				Code: "INCOMPATIBLE_VPS_INIT_VERSION",
 			})
 		}
 	}
@@ -347,6 +350,9 @@ func (v *Validator) validateStructure(plugin Plugin) ValidationErrors {
 	return errors
 }
 
+// checksumPattern matches a sha256 checksum, optionally prefixed as written by FileChecksum
+var checksumPattern = regexp.MustCompile(`^(sha256:)?[a-fA-F0-9]{64}$`)
+
 // ValidatePluginMetadata validates plugin metadata struct
 func (v *Validator) ValidatePluginMetadata(metadata PluginMetadata) ValidationErrors {
 	var errors ValidationErrors
@@ -383,10 +389,8 @@ func (v *Validator) ValidatePluginMetadata(metadata PluginMetadata) ValidationEr
 	}
 
 	// Validate checksum format if present
-	// Note: Checksum field not available in core.PluginMetadata
-	/*
 	if metadata.Checksum != "" {
-		if !regexp.MustCompile(`^[a-fA-F0-9]{64}$`).MatchString(metadata.Checksum) {
+		if !checksumPattern.MatchString(metadata.Checksum) {
 			errors = append(errors, ValidationError{
 				Field:   "metadata.checksum",
 				Message: "checksum must be a valid SHA256 hash",
@@ -394,53 +398,39 @@ func (v *Validator) ValidatePluginMetadata(metadata PluginMetadata) ValidationEr
 			})
 		}
 	}
-	*/
 
-	// Validate trust level
-	// Note: TrustLevel field not available in core.PluginMetadata
-	/*
-	if metadata.TrustLevel != "" {
-		validTrustLevels := []string{"official", "community", "verified", "untrusted"}
-		valid := false
-		for _, level := range validTrustLevels {
-			if metadata.TrustLevel == level {
-				valid = true
-				break
-			}
-		}
-		if !valid {
+	// Validate signature format if present
+	if metadata.Signature != "" {
+		if _, _, err := parseSignature(metadata.Signature); err != nil {
 			errors = append(errors, ValidationError{
-				Field:   "metadata.trust_level",
-				Message: fmt.Sprintf("trust level must be one of: %s", strings.Join(validTrustLevels, ", ")),
-				Code:    "INVALID_TRUST_LEVEL",
+				Field:   "metadata.signature",
+				Message: err.Error(),
+				Code:    "INVALID_SIGNATURE",
 			})
 		}
 	}
-	*/
+
+	// Validate trust level
+	if metadata.TrustLevel != "" && !contains(TrustLevels, metadata.TrustLevel) {
+		errors = append(errors, ValidationError{
+			Field:   "metadata.trust_level",
+			Message: fmt.Sprintf("trust level must be one of: %s", strings.Join(TrustLevels, ", ")),
+			Code:    "INVALID_TRUST_LEVEL",
+		})
+	}
 
 	return errors
 }
 
-// GetTrustLevel determines the trust level for a plugin
-// Note: TrustLevel field not available in core.PluginMetadata, using fallback logic
+// GetTrustLevel determines the trust level for a plugin. External plugins get
+// theirs from signature verification against the trust store when they are
+// loaded; plugins compiled into vps-init are official.
 func GetTrustLevel(metadata PluginMetadata) string {
-	// Since TrustLevel field is not available in core.PluginMetadata,
-	// we'll determine it based on repository pattern
-	/*
 	if metadata.TrustLevel != "" {
 		return metadata.TrustLevel
 	}
-	*/
-
-	// Auto-determine trust level based on repository
-	if strings.Contains(metadata.Repository, "github.com/vps-init") ||
-		strings.Contains(metadata.Repository, "github.com/wasilwamark/vps-init") {
-		return "official"
+	if metadata.InstallPath == "" {
+		return TrustOfficial
 	}
-
-	if metadata.Repository != "" {
-		return "community"
-	}
-
-	return "untrusted"
-}
\ No newline at end of file
+	return TrustUntrusted
+}
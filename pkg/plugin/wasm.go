@@ -0,0 +1,219 @@
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Permissions is the local access a WASM plugin may have beyond the host
+// functions every sandboxed plugin gets
+type Permissions struct {
+	// Filesystem lists local directories mounted into the sandbox at the same
+	// path. They are read-only unless the entry ends in ":rw".
+	Filesystem []string `yaml:"filesystem,omitempty" json:"filesystem,omitempty"`
+	// Network allows HTTP requests through the host with Fetch
+	Network bool `yaml:"network,omitempty" json:"network,omitempty"`
+}
+
+// Empty reports whether no access is requested
+func (p Permissions) Empty() bool {
+	return len(p.Filesystem) == 0 && !p.Network
+}
+
+// Covers reports whether p grants everything other asks for
+func (p Permissions) Covers(other Permissions) bool {
+	if other.Network && !p.Network {
+		return false
+	}
+	for _, dir := range other.Filesystem {
+		if !contains(p.Filesystem, dir) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Permissions) String() string {
+	var parts []string
+	for _, entry := range p.Filesystem {
+		dir, writable := strings.CutSuffix(entry, ":rw")
+		if writable {
+			parts = append(parts, "read-write "+dir)
+		} else {
+			parts = append(parts, "read-only "+dir)
+		}
+	}
+	if p.Network {
+		parts = append(parts, "network")
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fsConfig mounts the permitted directories
+func (p Permissions) fsConfig() (wazero.FSConfig, error) {
+	config := wazero.NewFSConfig()
+	for _, entry := range p.Filesystem {
+		dir, writable := strings.CutSuffix(entry, ":rw")
+		if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			dir = filepath.Join(home, rest)
+		}
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("filesystem permission '%s' must be an absolute path", entry)
+		}
+		if writable {
+			config = config.WithDirMount(dir, dir)
+		} else {
+			config = config.WithReadOnlyDirMount(dir, dir)
+		}
+	}
+	return config, nil
+}
+
+// sandboxMethods are the host functions every WASM plugin may call: running
+// commands on the target, writing files there, prompting and logging. The
+// target's platform is included because it only describes the target.
+var sandboxMethods = map[string]bool{
+	"conn.run_command": true,
+	"conn.run_sudo":    true,
+	"conn.write_file":  true,
+	"conn.platform":    true,
+	MethodPrompt:       true,
+	MethodLog:          true,
+}
+
+// sandboxHandler only passes on the host functions granted to a WASM plugin
+func sandboxHandler(next rpcHandler, perms Permissions) rpcHandler {
+	return func(method string, raw json.RawMessage) (interface{}, error) {
+		if sandboxMethods[method] || (method == MethodFetch && perms.Network) {
+			return next(method, raw)
+		}
+		return nil, &RPCError{Code: ErrCodePermissionDenied, Message: method + " is not permitted in the WASM sandbox"}
+	}
+}
+
+// wasmCache keeps compiled modules, so a plugin is compiled once per run
+// rather than for discovery and again for the command
+var wasmCache = wazero.NewCompilationCache()
+
+// SetWasmCacheDir keeps compiled modules in dir across runs, so a WASM plugin
+// is only compiled again after it changes
+func SetWasmCacheDir(dir string) error {
+	cache, err := wazero.NewCompilationCacheWithDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open WASM cache: %w", err)
+	}
+	wasmCache = cache
+	return nil
+}
+
+// LoadWasmPlugin compiles the WASM module at path and asks it to describe itself
+func LoadWasmPlugin(path, hostVersion string) (*ExternalPlugin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), externalLoadTimeout)
+	defer cancel()
+
+	proc, info, err := startWasm(ctx, path, hostVersion, Permissions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin %s: %w", filepath.Base(path), err)
+	}
+	proc.close()
+
+	if info.Metadata.Name == "" {
+		return nil, fmt.Errorf("failed to load plugin %s: plugin did not report a name", filepath.Base(path))
+	}
+
+	info.Metadata.InstallPath = path
+	return &ExternalPlugin{path: path, hostVersion: hostVersion, info: info, wasm: true}, nil
+}
+
+// startWasm instantiates a WASM plugin with WASI and performs the initialize
+// handshake. The module gets the protocol on stdin/stdout, stderr for output,
+// clocks and randomness, and only the directories perms mounts: WASI has no
+// sockets, so it has no network access of its own.
+func startWasm(ctx context.Context, path, hostVersion string, perms Permissions) (*externalProcess, InitializeResult, error) {
+	var info InitializeResult
+
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, info, err
+	}
+	fsConfig, err := perms.fsConfig()
+	if err != nil {
+		return nil, info, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCompilationCache(wasmCache).
+		WithCloseOnContextDone(true))
+	fail := func(err error) (*externalProcess, InitializeResult, error) {
+		runtime.Close(context.Background())
+		cancel()
+		return nil, info, err
+	}
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		return fail(err)
+	}
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		return fail(fmt.Errorf("invalid WASM module: %w", err))
+	}
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	config := wazero.NewModuleConfig().
+		WithName(filepath.Base(path)).
+		WithArgs(filepath.Base(path)).
+		WithEnv("VPS_INIT_PLUGIN_PROTOCOL", fmt.Sprint(ProtocolVersion)).
+		WithStdin(stdinR).
+		WithStdout(stdoutW).
+		WithStderr(os.Stderr).
+		WithFSConfig(fsConfig).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := runtime.InstantiateModule(ctx, compiled, config)
+		var exitErr *sys.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 0) && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "⚠️  plugin %s: %v\n", filepath.Base(path), err)
+		}
+		// Unblock the host if the module exits while it waits for a reply
+		stdoutW.CloseWithError(io.EOF)
+		stdinR.Close()
+		runtime.Close(context.Background())
+		cancel()
+	}()
+
+	proc := &externalProcess{
+		rpc:  newRPCConn(stdoutR, stdinW),
+		wait: func() { <-done },
+		kill: cancel,
+	}
+	info, err = proc.initialize(hostVersion)
+	if err != nil {
+		return nil, info, err
+	}
+	return proc, info, nil
+}